@@ -14,6 +14,8 @@ import (
 const (
 	migrationUp   = "up"
 	migrationDown = "down"
+	// defaultMigrationsTable is the table of the sqlite migrations, postgres keeps its own default
+	defaultMigrationsTable = "migrations"
 )
 
 func mustMigrateUp(m *migrate.Migrate) {
@@ -49,7 +51,7 @@ func main() {
 	flag.StringVar(&db, "db", "sqlite3", "database")
 	flag.StringVar(&storagePath, "storage-path", "", "path to the storage")
 	flag.StringVar(&migrationsPath, "migrations-path", "", "path to migrations")
-	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrations table")
+	flag.StringVar(&migrationsTable, "migrations-table", defaultMigrationsTable, "name of migrations table")
 	flag.Parse()
 
 	if storagePath == "" && db == "sqlite3" {
@@ -83,7 +85,12 @@ func dbUrl(db, storagePath, migrationsTable string) string {
 	case "sqlite3":
 		return fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", storagePath, migrationsTable)
 	case "postgres":
-		return fmt.Sprintf("postgres://postgres:password@%s/sso?sslmode=disable", storagePath)
+		url := fmt.Sprintf("postgres://postgres:password@%s/sso?sslmode=disable", storagePath)
+		if migrationsTable != defaultMigrationsTable {
+			url += "&x-migrations-table=" + migrationsTable
+		}
+
+		return url
 	}
 
 	return ""
@@ -19,7 +19,7 @@ func main() {
 	logger := logger.New(cfg.Env)
 	logger.Log.Info("starting application", slog.String("env", cfg.Env))
 
-	application := app.New(logger.Log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL, cfg.Addr)
+	application := app.New(logger.Log, cfg)
 
 	go application.MustRun()
 
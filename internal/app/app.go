@@ -7,71 +7,291 @@ import (
 	"time"
 
 	grpcapp "github.com/BariVakhidov/sso/internal/app/grpc"
+	httpapp "github.com/BariVakhidov/sso/internal/app/http"
 	prometheusapp "github.com/BariVakhidov/sso/internal/app/prometheus"
 	storageapp "github.com/BariVakhidov/sso/internal/app/storage"
 	redisapp "github.com/BariVakhidov/sso/internal/app/storage/redis"
 	"github.com/BariVakhidov/sso/internal/config"
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/http/account"
+	"github.com/BariVakhidov/sso/internal/http/oauth"
+	"github.com/BariVakhidov/sso/internal/http/wellknown"
 	"github.com/BariVakhidov/sso/internal/kafka"
+	"github.com/BariVakhidov/sso/internal/lib/encryption"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/passhash"
+	"github.com/BariVakhidov/sso/internal/lib/passwordpolicy"
+	"github.com/BariVakhidov/sso/internal/mailer"
 	authservice "github.com/BariVakhidov/sso/internal/services/auth"
 	eventsender "github.com/BariVakhidov/sso/internal/services/event_sender"
+	"github.com/BariVakhidov/sso/internal/services/keys"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 const (
-	eventsLimit       = 100
-	producingInterval = time.Millisecond * 1000
+	eventsLimit         = 100
+	producingInterval   = time.Millisecond * 1000
+	keyRotationInterval = time.Minute
 )
 
 type App struct {
 	grpcServer   *grpcapp.App
+	httpServer   *httpapp.App
 	metrics      *prometheusapp.App
 	storage      *storageapp.App
 	redisStorage *redisapp.App
 	eventSender  *eventsender.Sender
+	keyManager   *keys.Manager
 }
 
-func New(log *slog.Logger, grpcPort int, storagePath string, ttl time.Duration, addr config.Addr) *App {
+func New(log *slog.Logger, cfg *config.Config) *App {
 	metrics := prometheusapp.New(log, 9090)
 	brokers := []string{"host.docker.internal:29092"}
 	topic := "user_created"
 	kafkaPublisher := kafka.NewKafkaProducer(brokers, topic)
 
 	//TODO: configs
-	storage := storageapp.MustCreateApp(fmt.Sprintf("postgres://postgres:password@%s/sso", addr.Db), log)
+	storage := storageapp.MustCreateApp(fmt.Sprintf("postgres://postgres:password@%s/sso", cfg.Addr.Db), log)
 
-	redisApp := redisapp.New(log, addr.Redis, time.Minute*10)
+	redisApp := redisapp.New(log, cfg.Addr.Redis, time.Minute*10)
 
 	eventSender := eventsender.NewSender(log, kafkaPublisher, storage.Storage)
 
-	authService := authservice.New(
+	keyManager := keys.New(
 		log,
 		storage.Storage,
-		storage.Storage,
-		storage.Storage,
-		redisApp.Storage,
-		ttl,
-		metrics.FailedLoginsCounter,
+		cfg.JWT.Algorithm,
+		cfg.JWT.KeyRotationInterval,
+		cfg.JWT.KeyOverlap,
+		mustEncryptionKey(cfg.JWT),
+		mustSigningSecret(cfg.JWT),
 	)
+	keyManager.MustInit(context.Background())
+
+	authService := authservice.New(log, authservice.Deps{
+		UserSaver:            storage.Storage,
+		UserProvider:         storage.Storage,
+		FailedLoginsProvider: redisApp.Storage,
+		PasswordHasher:       mustPasswordHasher(cfg.PasswordHash),
+		PasswordPolicy:       mustPasswordPolicy(cfg.PasswordPolicy),
+		FailedLogins:         metrics.FailedLoginsCounter,
+
+		AppProvider:          storage.Storage,
+		RoleProvider:         storage.Storage,
+		OrganizationProvider: storage.Storage,
+		GroupProvider:        storage.Storage,
+		RelationshipProvider: storage.Storage,
+		APIKeyProvider:       storage.Storage,
+
+		KeyProvider:          keyManager,
+		RefreshTokenProvider: storage.Storage,
+		SessionProvider:      storage.Storage,
+		RevokedTokenProvider: redisApp.Storage,
+		SSOSessions:          redisApp.Storage,
+		SSOSessionLimits:     authservice.SSOSessionLimits{MaxAge: cfg.SSOSession.MaxAge, IdleTimeout: cfg.SSOSession.IdleTimeout},
+		Issuer:               cfg.JWT.Issuer,
+		TokenTTL:             cfg.TokenTTL,
+		RefreshTokenTTL:      cfg.RefreshTokenTTL,
+
+		AuthCodeProvider: redisApp.Storage,
+		DeviceProvider:   redisApp.Storage,
+		RateLimiter:      redisApp.Storage,
+		ClientTokenTTL:   cfg.ClientCredentials.TokenTTL,
+		ClientRateLimit:  models.RateLimit{Limit: cfg.ClientCredentials.RateLimit, Window: cfg.ClientCredentials.RateWindow},
+
+		MFAProvider:          storage.Storage,
+		MFAChallengeProvider: redisApp.Storage,
+		MFAIssuer:            cfg.MFA.Issuer,
+		MFAEncryptionKey:     mustMFAEncryptionKey(cfg.MFA),
+		PasskeyProvider:      storage.Storage,
+		PasskeySessions:      redisApp.Storage,
+		WebAuthn:             mustWebAuthn(cfg.WebAuthn),
+
+		PasswordResets: redisApp.Storage,
+		MagicLinks:     redisApp.Storage,
+		Mailer:         mustMailer(log, cfg.Mailer),
+		EmailLinks:     mustEmailLinks(cfg.Email),
+	})
 
 	grpcappOpts := grpcapp.AppOpts{
 		Log:         log,
-		Port:        grpcPort,
-		StoragePath: storagePath,
-		TTL:         ttl,
+		Port:        cfg.GRPC.Port,
+		StoragePath: cfg.StoragePath,
+		TTL:         cfg.TokenTTL,
 	}
-	grpcApp := grpcapp.New(grpcappOpts, authService, metrics, metrics.RecoveryOpt, metrics.MetricsInterceptor)
+	grpcApp := grpcapp.New(grpcappOpts, authService, authService, authService, metrics, metrics.RecoveryOpt, metrics.MetricsInterceptor)
+
+	httpApp := httpapp.New(
+		log,
+		cfg.HTTP.Port,
+		wellknown.New(log, cfg.JWT.Issuer, keyManager),
+		oauth.New(log, cfg.JWT.Issuer, authService),
+		account.New(log, authService),
+	)
 
-	return &App{grpcServer: grpcApp, storage: storage, metrics: metrics, redisStorage: redisApp, eventSender: eventSender}
+	return &App{
+		grpcServer:   grpcApp,
+		httpServer:   httpApp,
+		storage:      storage,
+		metrics:      metrics,
+		redisStorage: redisApp,
+		eventSender:  eventSender,
+		keyManager:   keyManager,
+	}
 }
 
 func (a *App) MustRun() {
 	go a.grpcServer.MustRun()
+	go a.httpServer.MustRun()
 	go a.metrics.MustRun()
 	go a.eventSender.StartProducing(context.Background(), eventsLimit, producingInterval)
+	a.keyManager.StartRotation(context.Background(), keyRotationInterval)
 }
 
 func (a *App) Stop() error {
 	a.grpcServer.Stop()
+	a.httpServer.Stop()
+	a.keyManager.StopRotation()
 	a.storage.Stop()
 	a.eventSender.StopSending()
 	return a.redisStorage.Stop()
 }
+
+// mustEncryptionKey parses the key private signing keys are encrypted with, HS256 doesn't need one
+func mustEncryptionKey(cfg config.JWTConfig) []byte {
+	if cfg.Algorithm == jwt.AlgorithmHS256 {
+		return nil
+	}
+
+	key, err := encryption.ParseKey(cfg.KeyEncryptionKey)
+	if err != nil {
+		panic("invalid jwt key_encryption_key: " + err.Error())
+	}
+
+	return key
+}
+
+// mustSigningSecret parses the HMAC key of HS256 tokens, asymmetric algorithms don't need one
+func mustSigningSecret(cfg config.JWTConfig) []byte {
+	if cfg.Algorithm != jwt.AlgorithmHS256 {
+		return nil
+	}
+
+	key, err := encryption.ParseKey(cfg.Secret)
+	if err != nil {
+		panic("invalid jwt secret: " + err.Error())
+	}
+
+	return key
+}
+
+// mustMFAEncryptionKey parses the key TOTP secrets are encrypted with
+func mustMFAEncryptionKey(cfg config.MFAConfig) []byte {
+	key, err := encryption.ParseKey(cfg.SecretEncryptionKey)
+	if err != nil {
+		panic("invalid mfa secret_encryption_key: " + err.Error())
+	}
+
+	return key
+}
+
+// mustMailer creates the mailer of the configured type
+func mustMailer(log *slog.Logger, cfg config.MailerConfig) authservice.Mailer {
+	switch cfg.Type {
+	case "smtp":
+		smtpMailer, err := mailer.NewSMTP(log, cfg.SMTP.Addr, cfg.From, cfg.SMTP.Username, cfg.SMTP.Password)
+		if err != nil {
+			panic("invalid smtp mailer config: " + err.Error())
+		}
+
+		return smtpMailer
+	case "outbox":
+		outbox, err := mailer.NewOutbox(log, cfg.OutboxDir, cfg.From)
+		if err != nil {
+			panic("failed to create mailer outbox: " + err.Error())
+		}
+
+		return outbox
+	default:
+		panic("unsupported mailer type: " + cfg.Type)
+	}
+}
+
+// mustEmailLinks parses the settings of the links sent to users by email
+func mustEmailLinks(cfg config.EmailConfig) authservice.EmailLinks {
+	key, err := encryption.ParseKey(cfg.TokenKey)
+	if err != nil {
+		panic("invalid email token_key: " + err.Error())
+	}
+
+	return authservice.EmailLinks{
+		TokenKey:         key,
+		VerificationURL:  cfg.VerificationURL,
+		VerificationTTL:  cfg.VerificationTTL,
+		PasswordResetURL: cfg.PasswordResetURL,
+		PasswordResetTTL: cfg.PasswordResetTTL,
+		MagicLinkTTL:     cfg.MagicLinkTTL,
+	}
+}
+
+// mustPasswordHasher creates the hasher of the configured algorithm
+func mustPasswordHasher(cfg config.PasswordHashConfig) *passhash.Hasher {
+	var pepper []byte
+	if cfg.Pepper != "" {
+		key, err := encryption.ParseKey(cfg.Pepper)
+		if err != nil {
+			panic("invalid password_hash pepper: " + err.Error())
+		}
+
+		pepper = key
+	}
+
+	hasher, err := passhash.New(passhash.Params{
+		Algorithm:  cfg.Algorithm,
+		BcryptCost: cfg.BcryptCost,
+		Argon2: passhash.Argon2Params{
+			Memory:      cfg.Argon2Memory,
+			Iterations:  cfg.Argon2Iterations,
+			Parallelism: cfg.Argon2Parallelism,
+		},
+	}, pepper)
+	if err != nil {
+		panic("invalid password_hash config: " + err.Error())
+	}
+
+	return hasher
+}
+
+// mustPasswordPolicy checks the global password policy can be satisfied
+func mustPasswordPolicy(cfg config.PasswordPolicyConfig) models.PasswordPolicy {
+	policy := models.PasswordPolicy{
+		MinLength:     cfg.MinLength,
+		MaxLength:     cfg.MaxLength,
+		RequireUpper:  cfg.RequireUpper,
+		RequireLower:  cfg.RequireLower,
+		RequireDigit:  cfg.RequireDigit,
+		RequireSymbol: cfg.RequireSymbol,
+		RejectCommon:  cfg.RejectCommon,
+		RejectEmail:   cfg.RejectEmail,
+	}
+
+	if err := passwordpolicy.Validate(policy); err != nil {
+		panic("invalid password_policy: " + err.Error())
+	}
+
+	return policy
+}
+
+// mustWebAuthn creates the WebAuthn relying party passkeys are registered for
+func mustWebAuthn(cfg config.WebAuthnConfig) *webauthn.WebAuthn {
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+	})
+	if err != nil {
+		panic("invalid webauthn config: " + err.Error())
+	}
+
+	return webAuthn
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/BariVakhidov/sso/internal/grpc/auth"
 	authgrpc "github.com/BariVakhidov/sso/internal/grpc/auth"
+	authorizationgrpc "github.com/BariVakhidov/sso/internal/grpc/authorization"
+	introspectiongrpc "github.com/BariVakhidov/sso/internal/grpc/introspection"
 	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
@@ -32,7 +34,15 @@ type App struct {
 	gRPCServer *grpc.Server
 }
 
-func New(opts AppOpts, auth auth.AuthService, metrics Metrics, recoveryOpt recovery.Option, metricsInterceptor grpc.UnaryServerInterceptor) *App {
+func New(
+	opts AppOpts,
+	auth auth.AuthService,
+	introspection introspectiongrpc.IntrospectionService,
+	authorization authorizationgrpc.AuthorizationService,
+	metrics Metrics,
+	recoveryOpt recovery.Option,
+	metricsInterceptor grpc.UnaryServerInterceptor,
+) *App {
 	logOpts := []logging.Option{
 		logging.WithLogOnEvents(logging.PayloadSent, logging.PayloadReceived),
 	}
@@ -48,6 +58,8 @@ func New(opts AppOpts, auth auth.AuthService, metrics Metrics, recoveryOpt recov
 	server := authgrpc.InitializeServerAPI(auth)
 
 	ssov1.RegisterAuthServer(gRPCServer, server)
+	ssov1.RegisterIntrospectionServer(gRPCServer, introspectiongrpc.InitializeServerAPI(introspection))
+	ssov1.RegisterAuthorizationServer(gRPCServer, authorizationgrpc.InitializeServerAPI(authorization))
 
 	return &App{gRPCServer: gRPCServer, AppOpts: opts}
 }
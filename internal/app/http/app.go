@@ -0,0 +1,76 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 10 * time.Second
+)
+
+// Routes registers handlers of a feature on the mux
+type Routes interface {
+	Register(mux *http.ServeMux)
+}
+
+type App struct {
+	log    *slog.Logger
+	port   int
+	server *http.Server
+}
+
+func New(log *slog.Logger, port int, routes ...Routes) *App {
+	mux := http.NewServeMux()
+	for _, r := range routes {
+		r.Register(mux)
+	}
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	return &App{log: log, port: port, server: server}
+}
+
+// MustRun runs HTTP server and panic if any error occurs
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+	log := a.log.With(slog.String("op", op), slog.Int("port", a.port))
+
+	log.Info("HTTP server is running", slog.String("addr", a.server.Addr))
+
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+	log := a.log.With(slog.String("op", op), slog.Int("port", a.port))
+	log.Info("stopping HTTP server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.Error("failed to stop HTTP server", sl.Err(err))
+	}
+}
@@ -9,11 +9,22 @@ import (
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
-	Addr        Addr          `yaml:"addr"`
+	Env               string                  `yaml:"env" env-default:"local"`
+	StoragePath       string                  `yaml:"storage_path" env-required:"true"`
+	TokenTTL          time.Duration           `yaml:"token_ttl" env-required:"true"`
+	RefreshTokenTTL   time.Duration           `yaml:"refresh_token_ttl" env-default:"720h"`
+	ClientCredentials ClientCredentialsConfig `yaml:"client_credentials"`
+	GRPC              GRPCConfig              `yaml:"grpc"`
+	HTTP              HTTPConfig              `yaml:"http"`
+	JWT               JWTConfig               `yaml:"jwt"`
+	MFA               MFAConfig               `yaml:"mfa"`
+	WebAuthn          WebAuthnConfig          `yaml:"webauthn"`
+	Mailer            MailerConfig            `yaml:"mailer"`
+	Email             EmailConfig             `yaml:"email"`
+	PasswordPolicy    PasswordPolicyConfig    `yaml:"password_policy"`
+	PasswordHash      PasswordHashConfig      `yaml:"password_hash"`
+	SSOSession        SSOSessionConfig        `yaml:"sso_session"`
+	Addr              Addr                    `yaml:"addr"`
 }
 
 type Addr struct {
@@ -26,6 +37,113 @@ type GRPCConfig struct {
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+type HTTPConfig struct {
+	Port int `yaml:"port" env-default:"8081"`
+}
+
+// ClientCredentialsConfig configures service-to-service tokens, issued apart from user tokens
+type ClientCredentialsConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"15m"`
+	// RateLimit is how many tokens an app can get per RateWindow
+	RateLimit  int           `yaml:"rate_limit" env-default:"60"`
+	RateWindow time.Duration `yaml:"rate_window" env-default:"1m"`
+}
+
+type JWTConfig struct {
+	// Issuer is put into the iss claim and required from validated tokens
+	Issuer string `yaml:"issuer" env-default:"sso"`
+	// Algorithm is one of RS256, ES256, EdDSA or HS256. Asymmetric keys are rotated and published,
+	// HS256 signs tokens with Secret and is left for deployments that can't verify asymmetric tokens
+	Algorithm string `yaml:"algorithm" env-default:"RS256"`
+	// Secret is base64 encoded 32 bytes HMAC key of HS256 tokens, it is held by the server only
+	Secret              string        `yaml:"secret" env:"JWT_SECRET"`
+	KeyRotationInterval time.Duration `yaml:"key_rotation_interval" env-default:"720h"`
+	// KeyOverlap is how long a retired key is still published, must exceed the token TTL
+	KeyOverlap time.Duration `yaml:"key_overlap" env-default:"24h"`
+	// KeyEncryptionKey is base64 encoded AES-256 key the private keys are encrypted with in the database
+	KeyEncryptionKey string `yaml:"key_encryption_key" env:"KEY_ENCRYPTION_KEY"`
+}
+
+type MFAConfig struct {
+	// Issuer is the account issuer shown in authenticator apps
+	Issuer string `yaml:"issuer" env-default:"SSO"`
+	// SecretEncryptionKey is base64 encoded AES-256 key the TOTP secrets are encrypted with in the database
+	SecretEncryptionKey string `yaml:"secret_encryption_key" env:"MFA_ENCRYPTION_KEY"`
+}
+
+// WebAuthnConfig configures the relying party passkeys are registered for
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are bound to, the origins must be on it or its subdomains
+	RPID          string   `yaml:"rp_id" env-default:"localhost"`
+	RPDisplayName string   `yaml:"rp_display_name" env-default:"SSO"`
+	RPOrigins     []string `yaml:"rp_origins" env-default:"http://localhost:8081"`
+}
+
+// MailerConfig configures how emails are sent to users
+type MailerConfig struct {
+	// Type is smtp or outbox, outbox writes emails to files in OutboxDir instead of sending them
+	Type      string     `yaml:"type" env-default:"outbox"`
+	From      string     `yaml:"from" env-default:"SSO <sso@localhost>"`
+	OutboxDir string     `yaml:"outbox_dir" env-default:"./storage/outbox"`
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Addr     string `yaml:"addr" env:"SMTP_ADDR" env-default:"localhost:25"`
+	Username string `yaml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD"`
+}
+
+// EmailConfig configures the links sent to users by email
+type EmailConfig struct {
+	// TokenKey is base64 encoded 32 bytes key the tokens of the links are signed with
+	TokenKey string `yaml:"token_key" env:"EMAIL_TOKEN_KEY"`
+	// VerificationURL is the page verifying the email, the token is added as the token query parameter
+	VerificationURL string        `yaml:"verification_url" env-default:"http://localhost:8081/verify_email"`
+	VerificationTTL time.Duration `yaml:"verification_ttl" env-default:"24h"`
+	// PasswordResetURL is the page setting the new password, the token is added as the token query parameter
+	PasswordResetURL string        `yaml:"password_reset_url" env-default:"http://localhost:8081/reset_password"`
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env-default:"30m"`
+	// MagicLinkTTL is how long sign in links are valid, they point to the redirect URIs of the apps
+	MagicLinkTTL time.Duration `yaml:"magic_link_ttl" env-default:"15m"`
+}
+
+// PasswordPolicyConfig is the global policy of the passwords users choose, apps may override it
+type PasswordPolicyConfig struct {
+	MinLength int `yaml:"min_length" env-default:"8"`
+	// MaxLength is 0 when the length isn't limited, passwords longer than 72 bytes are always rejected
+	MaxLength     int  `yaml:"max_length" env-default:"64"`
+	RequireUpper  bool `yaml:"require_upper"`
+	RequireLower  bool `yaml:"require_lower"`
+	RequireDigit  bool `yaml:"require_digit"`
+	RequireSymbol bool `yaml:"require_symbol"`
+	// RejectCommon rejects passwords from the bundled list of common passwords
+	RejectCommon bool `yaml:"reject_common" env-default:"true"`
+	// RejectEmail rejects passwords containing words of the user's email
+	RejectEmail bool `yaml:"reject_email" env-default:"true"`
+}
+
+// PasswordHashConfig configures how passwords are hashed, hashes made otherwise are upgraded on login
+type PasswordHashConfig struct {
+	// Algorithm is argon2id or bcrypt
+	Algorithm  string `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost int    `yaml:"bcrypt_cost" env-default:"10"`
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32 `yaml:"argon2_memory" env-default:"19456"`
+	Argon2Iterations  uint32 `yaml:"argon2_iterations" env-default:"2"`
+	Argon2Parallelism uint8  `yaml:"argon2_parallelism" env-default:"1"`
+	// Pepper is optional base64 encoded 32 bytes secret mixed into passwords, it must not change once set
+	Pepper string `yaml:"pepper" env:"PASSWORD_PEPPER"`
+}
+
+// SSOSessionConfig configures the SSO sessions letting users obtain tokens of other apps without the password
+type SSOSessionConfig struct {
+	// MaxAge is how long the session lasts after the login regardless of its use
+	MaxAge time.Duration `yaml:"max_age" env-default:"24h"`
+	// IdleTimeout ends the session when it isn't used for that long
+	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"2h"`
+}
+
 func MustLoad() *Config {
 	path := fetchConfigPath()
 	if path == "" {
@@ -0,0 +1,44 @@
+package converter
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+	"github.com/google/uuid"
+)
+
+func ToAPIKeyFromStorage(storageKey storageModel.APIKey) models.APIKey {
+	return models.APIKey{
+		ID:         storageKey.ID,
+		UserID:     storageKey.UserID,
+		AppID:      storageKey.AppID.UUID,
+		Name:       storageKey.Name,
+		Prefix:     storageKey.Prefix,
+		KeyHash:    storageKey.KeyHash,
+		Scopes:     storageKey.Scopes,
+		CreatedAt:  storageKey.CreatedAt,
+		ExpiresAt:  storageKey.ExpiresAt.Time,
+		LastUsedAt: storageKey.LastUsedAt.Time,
+	}
+}
+
+func ToAPIKeysFromStorage(storageKeys []storageModel.APIKey) []models.APIKey {
+	keys := make([]models.APIKey, len(storageKeys))
+	for i, key := range storageKeys {
+		keys[i] = ToAPIKeyFromStorage(key)
+	}
+
+	return keys
+}
+
+// ToStorageAPIKeyAppID stores keys of no app with NULL app_id
+func ToStorageAPIKeyAppID(appID uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: appID, Valid: appID != uuid.Nil}
+}
+
+// ToStorageAPIKeyExpiresAt stores keys which never expire with NULL expires_at
+func ToStorageAPIKeyExpiresAt(expiresAt time.Time) sql.NullTime {
+	return sql.NullTime{Time: expiresAt, Valid: !expiresAt.IsZero()}
+}
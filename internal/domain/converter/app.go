@@ -0,0 +1,67 @@
+package converter
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToAppFromStorage(storageApp storageModel.App) models.App {
+	return models.App{
+		ID:                     storageApp.ID,
+		OrgID:                  storageApp.OrgID,
+		Name:                   storageApp.Name,
+		Secret:                 storageApp.Secret,
+		TokenTTL:               time.Duration(storageApp.TokenTTLSeconds.Int64) * time.Second,
+		Claims:                 storageApp.Claims,
+		RedirectURIs:           storageApp.RedirectURIs,
+		ClientType:             storageApp.ClientType,
+		PostLogoutRedirectURIs: storageApp.PostLogoutRedirectURIs,
+		Scopes:                 storageApp.Scopes,
+		RequireVerifiedEmail:   storageApp.RequireVerifiedEmail,
+		PasswordPolicy:         toPasswordPolicyFromStorage(storageApp.PasswordPolicy),
+		MagicLinkEnabled:       storageApp.MagicLinkEnabled,
+	}
+}
+
+func toPasswordPolicyFromStorage(policy *storageModel.PasswordPolicy) *models.PasswordPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &models.PasswordPolicy{
+		MinLength:     policy.MinLength,
+		MaxLength:     policy.MaxLength,
+		RequireUpper:  policy.RequireUpper,
+		RequireLower:  policy.RequireLower,
+		RequireDigit:  policy.RequireDigit,
+		RequireSymbol: policy.RequireSymbol,
+		RejectCommon:  policy.RejectCommon,
+		RejectEmail:   policy.RejectEmail,
+	}
+}
+
+// ToStoragePasswordPolicy converts the password policy of the app, nil policy is stored as NULL
+func ToStoragePasswordPolicy(policy *models.PasswordPolicy) *storageModel.PasswordPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &storageModel.PasswordPolicy{
+		MinLength:     policy.MinLength,
+		MaxLength:     policy.MaxLength,
+		RequireUpper:  policy.RequireUpper,
+		RequireLower:  policy.RequireLower,
+		RequireDigit:  policy.RequireDigit,
+		RequireSymbol: policy.RequireSymbol,
+		RejectCommon:  policy.RejectCommon,
+		RejectEmail:   policy.RejectEmail,
+	}
+}
+
+// ToStorageTokenTTL converts app token TTL to seconds, zero TTL is stored as NULL
+func ToStorageTokenTTL(ttl time.Duration) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(ttl / time.Second), Valid: ttl > 0}
+}
@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToGroupFromStorage(storageGroup storageModel.Group) models.Group {
+	return models.Group{
+		ID:        storageGroup.ID,
+		OrgID:     storageGroup.OrgID,
+		Name:      storageGroup.Name,
+		CreatedAt: storageGroup.CreatedAt,
+	}
+}
+
+func ToGroupsFromStorage(storageGroups []storageModel.Group) []models.Group {
+	groups := make([]models.Group, len(storageGroups))
+	for i, group := range storageGroups {
+		groups[i] = ToGroupFromStorage(group)
+	}
+
+	return groups
+}
+
+func ToGroupUsersFromStorage(storageUsers []storageModel.GroupUser) []models.GroupUser {
+	users := make([]models.GroupUser, len(storageUsers))
+	for i, user := range storageUsers {
+		users[i] = models.GroupUser{
+			UserID: user.UserID,
+			Email:  user.Email,
+		}
+	}
+
+	return users
+}
@@ -0,0 +1,27 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToTOTPSecretFromStorage(storageSecret storageModel.TOTPSecret) models.TOTPSecret {
+	return models.TOTPSecret{
+		UserID:          storageSecret.UserID,
+		EncryptedSecret: storageSecret.EncryptedSecret,
+		EnabledAt:       storageSecret.EnabledAt.Time,
+		LastUsedStep:    storageSecret.LastUsedStep,
+	}
+}
+
+func ToRecoveryCodesFromStorage(storageCodes []storageModel.RecoveryCode) []models.RecoveryCode {
+	codes := make([]models.RecoveryCode, 0, len(storageCodes))
+	for _, code := range storageCodes {
+		codes = append(codes, models.RecoveryCode{
+			ID:       code.ID,
+			CodeHash: code.CodeHash,
+		})
+	}
+
+	return codes
+}
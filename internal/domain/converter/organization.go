@@ -0,0 +1,33 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToOrganizationFromStorage(storageOrg storageModel.Organization) models.Organization {
+	return models.Organization{
+		ID:        storageOrg.ID,
+		Name:      storageOrg.Name,
+		CreatedAt: storageOrg.CreatedAt,
+	}
+}
+
+func ToOrganizationMemberFromStorage(storageMember storageModel.OrganizationMember) models.OrganizationMember {
+	return models.OrganizationMember{
+		OrgID:     storageMember.OrgID,
+		UserID:    storageMember.UserID,
+		Email:     storageMember.Email,
+		Role:      storageMember.Role,
+		CreatedAt: storageMember.CreatedAt,
+	}
+}
+
+func ToOrganizationMembersFromStorage(storageMembers []storageModel.OrganizationMember) []models.OrganizationMember {
+	members := make([]models.OrganizationMember, len(storageMembers))
+	for i, member := range storageMembers {
+		members[i] = ToOrganizationMemberFromStorage(member)
+	}
+
+	return members
+}
@@ -0,0 +1,22 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToRelationshipsFromStorage(storageRelationships []storageModel.Relationship) []models.Relationship {
+	relationships := make([]models.Relationship, len(storageRelationships))
+	for i, relationship := range storageRelationships {
+		relationships[i] = models.Relationship{
+			Object:   models.ObjectRef{Type: relationship.ObjectType, ID: relationship.ObjectID},
+			Relation: relationship.Relation,
+			Subject: models.SubjectRef{
+				Object:   models.ObjectRef{Type: relationship.SubjectType, ID: relationship.SubjectID},
+				Relation: relationship.SubjectRelation,
+			},
+		}
+	}
+
+	return relationships
+}
@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+	"github.com/google/uuid"
+)
+
+func ToRoleFromStorage(storageRole storageModel.Role) models.Role {
+	return models.Role{
+		ID:          storageRole.ID,
+		Name:        storageRole.Name,
+		AppID:       storageRole.AppID.UUID,
+		Permissions: storageRole.Permissions,
+		BuiltIn:     storageRole.BuiltIn,
+		CreatedAt:   storageRole.CreatedAt,
+	}
+}
+
+func ToRolesFromStorage(storageRoles []storageModel.Role) []models.Role {
+	roles := make([]models.Role, len(storageRoles))
+	for i, role := range storageRoles {
+		roles[i] = ToRoleFromStorage(role)
+	}
+
+	return roles
+}
+
+// ToStorageRoleAppID stores global roles with NULL app_id
+func ToStorageRoleAppID(appID uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: appID, Valid: appID != uuid.Nil}
+}
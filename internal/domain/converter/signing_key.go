@@ -0,0 +1,26 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToSigningKeyFromStorage(storageKey storageModel.SigningKey) models.StoredSigningKey {
+	return models.StoredSigningKey{
+		ID:                  storageKey.ID,
+		Algorithm:           storageKey.Algorithm,
+		EncryptedPrivateKey: storageKey.PrivateKey,
+		PublicKey:           storageKey.PublicKey,
+		CreatedAt:           storageKey.CreatedAt,
+		ExpiresAt:           storageKey.ExpiresAt,
+	}
+}
+
+func ToSigningKeysFromStorage(storageKeys []storageModel.SigningKey) []models.StoredSigningKey {
+	keys := make([]models.StoredSigningKey, len(storageKeys))
+	for i, key := range storageKeys {
+		keys[i] = ToSigningKeyFromStorage(key)
+	}
+
+	return keys
+}
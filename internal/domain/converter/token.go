@@ -0,0 +1,41 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToRefreshTokenFromStorage(storageToken storageModel.RefreshToken) models.RefreshToken {
+	return models.RefreshToken{
+		ID:        storageToken.ID,
+		FamilyID:  storageToken.FamilyID,
+		UserID:    storageToken.UserID,
+		AppID:     storageToken.AppID,
+		TokenHash: storageToken.TokenHash,
+		Scope:     storageToken.Scope,
+		ExpiresAt: storageToken.ExpiresAt,
+		RotatedAt: storageToken.RotatedAt.Time,
+		RevokedAt: storageToken.RevokedAt.Time,
+	}
+}
+
+func ToSessionFromStorage(storageSession storageModel.Session) models.Session {
+	return models.Session{
+		ID:         storageSession.ID,
+		UserID:     storageSession.UserID,
+		AppID:      storageSession.AppID,
+		UserAgent:  storageSession.UserAgent,
+		IPAddress:  storageSession.IPAddress,
+		CreatedAt:  storageSession.CreatedAt,
+		LastSeenAt: storageSession.LastSeenAt,
+	}
+}
+
+func ToSessionsFromStorage(storageSessions []storageModel.Session) []models.Session {
+	sessions := make([]models.Session, len(storageSessions))
+	for i, session := range storageSessions {
+		sessions[i] = ToSessionFromStorage(session)
+	}
+
+	return sessions
+}
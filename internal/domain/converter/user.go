@@ -7,9 +7,11 @@ import (
 
 func ToUserFromStorage(storageUser storageModel.User) models.User {
 	return models.User{
-		ID:       storageUser.ID,
-		Email:    storageUser.Email,
-		PassHash: storageUser.PassHash,
+		ID:            storageUser.ID,
+		OrgID:         storageUser.OrgID,
+		Email:         storageUser.Email,
+		PassHash:      storageUser.PassHash,
+		EmailVerified: storageUser.EmailVerified,
 	}
 }
 
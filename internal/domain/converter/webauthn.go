@@ -0,0 +1,28 @@
+package converter
+
+import (
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	storageModel "github.com/BariVakhidov/sso/internal/storage/model"
+)
+
+func ToWebAuthnCredentialsFromStorage(storageCredentials []storageModel.WebAuthnCredential) []models.WebAuthnCredential {
+	credentials := make([]models.WebAuthnCredential, 0, len(storageCredentials))
+	for _, credential := range storageCredentials {
+		credentials = append(credentials, models.WebAuthnCredential{
+			ID:              credential.ID,
+			UserID:          credential.UserID,
+			Name:            credential.Name,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			AAGUID:          credential.AAGUID,
+			SignCount:       uint32(credential.SignCount),
+			Transports:      credential.Transports,
+			BackupEligible:  credential.BackupEligible,
+			BackupState:     credential.BackupState,
+			CreatedAt:       credential.CreatedAt,
+			LastUsedAt:      credential.LastUsedAt.Time,
+		})
+	}
+
+	return credentials
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a long-lived credential of the user, only the hash of the key is stored
+type APIKey struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// AppID is the app the key is bound to, uuid.Nil for keys of no app
+	AppID uuid.UUID
+	Name  string
+	// Prefix is the visible start of the key telling keys apart
+	Prefix    string
+	KeyHash   string
+	Scopes    []string
+	CreatedAt time.Time
+	// ExpiresAt is zero for keys which never expire
+	ExpiresAt time.Time
+	// LastUsedAt is zero for keys which were never used, it's updated at most once a minute
+	LastUsedAt time.Time
+}
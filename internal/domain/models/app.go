@@ -1,9 +1,39 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ClientTypeConfidential apps can keep the secret, e.g. server side web apps
+	ClientTypeConfidential = "confidential"
+	// ClientTypePublic apps can't keep the secret, e.g. SPAs and mobile apps, and must use PKCE
+	ClientTypePublic = "public"
+)
 
 type App struct {
-	ID     uuid.UUID
+	ID uuid.UUID
+	// OrgID is the organization owning the app, only its users can sign in to the app
+	OrgID  uuid.UUID
 	Name   string
 	Secret string
+	// TokenTTL overrides the global token TTL when set
+	TokenTTL time.Duration
+	// Claims are extra claims the app requests in its tokens
+	Claims []string
+	// RedirectURIs are the only URIs OAuth authorization responses are sent to
+	RedirectURIs []string
+	ClientType   string
+	// PostLogoutRedirectURIs are the only URIs the user is sent to after OpenID Connect logout
+	PostLogoutRedirectURIs []string
+	// Scopes are granted to the app's own tokens issued with client credentials
+	Scopes []string
+	// RequireVerifiedEmail denies tokens to users who haven't verified their email
+	RequireVerifiedEmail bool
+	// PasswordPolicy overrides the global password policy when set
+	PasswordPolicy *PasswordPolicy
+	// MagicLinkEnabled lets users sign in to the app with links sent by email instead of the password
+	MagicLinkEnabled bool
 }
@@ -0,0 +1,8 @@
+package models
+
+// Email is a plain text message sent to the user
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
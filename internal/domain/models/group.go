@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Group is a team of users and other groups of the organization, roles granted to it apply to all its members
+type Group struct {
+	ID        uuid.UUID
+	OrgID     uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+// GroupUser is the user belonging to the group, directly or through its subgroups
+type GroupUser struct {
+	UserID uuid.UUID
+	Email  string
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AMROTP is the authentication method reference of the one-time code (RFC 8176)
+	AMROTP = "otp"
+	// ACRMultiFactor is the authentication context class of the login with a second factor
+	ACRMultiFactor = "2"
+)
+
+// Flows an MFA challenge is started by, a challenge can be passed only in the flow that started it
+const (
+	MFAPurposeLogin     = "login"
+	MFAPurposeAuthorize = "authorize"
+	MFAPurposeDevice    = "device"
+)
+
+// TOTPSecret is the TOTP secret of the user, MFA is enabled once the enrollment is confirmed
+type TOTPSecret struct {
+	UserID          uuid.UUID
+	EncryptedSecret []byte
+	EnabledAt       time.Time
+	// LastUsedStep is the time step of the last accepted code, codes can't be used twice
+	LastUsedStep int64
+}
+
+// MFAChallenge is the pending login waiting for the second factor
+type MFAChallenge struct {
+	UserID uuid.UUID `json:"user_id"`
+	// AppID is the client the challenge was started for, the tokens or the code are issued for it once the challenge is passed
+	AppID uuid.UUID `json:"app_id"`
+	// Purpose is the flow that started the challenge, one of MFAPurpose*
+	Purpose string `json:"purpose"`
+}
+
+// RecoveryCode is the unused single-use recovery code of the user, hashed like the password
+type RecoveryCode struct {
+	ID       uuid.UUID
+	CodeHash []byte
+}
+
+// RecoveryCodeEvent is published when the user passes the second factor with a recovery code
+type RecoveryCodeEvent struct {
+	UserID    uuid.UUID
+	Remaining int
+}
+
+// MFAEnrollment is the secret shown to the user to add to an authenticator app
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CodeChallengeMethodS256 = "S256"
+
+	ScopeOpenID  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+
+	// AMRPassword is the authentication method reference of the password login (RFC 8176)
+	AMRPassword = "pwd"
+	// ACRSingleFactor is the authentication context class of the single factor login
+	ACRSingleFactor = "1"
+)
+
+// AuthorizationRequest is the client's request to /authorize (RFC 6749 section 4.1.1, RFC 7636)
+type AuthorizationRequest struct {
+	ClientID            uuid.UUID
+	RedirectURI         string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Scopes              []string
+	Nonce               string
+}
+
+// AuthorizationCode is granted to the user on /authorize and exchanged for tokens on /token
+type AuthorizationCode struct {
+	UserID              uuid.UUID `json:"user_id"`
+	AppID               uuid.UUID `json:"app_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	Scopes              []string  `json:"scopes"`
+	Nonce               string    `json:"nonce"`
+	AuthTime            time.Time `json:"auth_time"`
+	AMR                 []string  `json:"amr"`
+}
+
+// RedeemedAuthorizationCode is kept after the code is exchanged to revoke the tokens issued from it
+// when the code is presented again (RFC 6749 section 4.1.2)
+type RedeemedAuthorizationCode struct {
+	AppID     uuid.UUID `json:"app_id"`
+	SessionID uuid.UUID `json:"session_id"`
+}
+
+// CodeExchange is the authorization_code grant request to /token
+type CodeExchange struct {
+	Code         string
+	ClientID     uuid.UUID
+	ClientSecret string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// UserInfo are the claims about the user released for the scopes of the access token
+type UserInfo struct {
+	Subject           uuid.UUID
+	Email             string
+	PreferredUsername string
+}
+
+// EndSession is the OpenID Connect RP-initiated logout request
+type EndSession struct {
+	IDTokenHint           string
+	ClientID              uuid.UUID
+	PostLogoutRedirectURI string
+}
+
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+)
+
+// DeviceAuthorization is the pending device authorization request (RFC 8628)
+type DeviceAuthorization struct {
+	AppID    uuid.UUID `json:"app_id"`
+	UserCode string    `json:"user_code"`
+	Scopes   []string  `json:"scopes"`
+	Status   string    `json:"status"`
+	// UserID, AuthTime and AMR are set once the user approved the request
+	UserID   uuid.UUID `json:"user_id"`
+	AuthTime time.Time `json:"auth_time"`
+	AMR      []string  `json:"amr"`
+}
+
+// DeviceCode is returned to the device to show the user code and poll for tokens with the device code
+type DeviceCode struct {
+	DeviceCode string
+	UserCode   string
+	ExpiresIn  time.Duration
+	Interval   time.Duration
+}
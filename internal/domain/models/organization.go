@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultOrgID is the ID of the organization owning the users and apps created without one
+var DefaultOrgID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+const (
+	// OrganizationRoleMember is the role users get in the organization they register in
+	OrganizationRoleMember = "member"
+	// OrganizationRoleAdmin members manage the users and apps of their organization
+	OrganizationRoleAdmin = "admin"
+)
+
+// Organization is a tenant owning users and apps, emails and app names are unique within it
+type Organization struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedAt time.Time
+}
+
+// OrganizationMember is the membership of the user in the organization
+type OrganizationMember struct {
+	OrgID     uuid.UUID
+	UserID    uuid.UUID
+	Email     string
+	Role      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package models
+
+import "github.com/google/uuid"
+
+// PasswordReset is the pending reset of the forgotten password
+type PasswordReset struct {
+	UserID uuid.UUID `json:"user_id"`
+	// AppID is the app the reset was requested for, its password policy applies to the new password
+	AppID uuid.UUID `json:"app_id"`
+	// PasswordState is the hash of the password hash at the time of the request,
+	// the reset is void once the password changes
+	PasswordState string `json:"password_state"`
+}
+
+// PasswordPolicy are the rules passwords chosen by users must follow
+type PasswordPolicy struct {
+	MinLength int `json:"min_length"`
+	// MaxLength is 0 when the length isn't limited by the policy
+	MaxLength     int  `json:"max_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	// RejectCommon rejects passwords from the bundled list of common passwords
+	RejectCommon bool `json:"reject_common"`
+	// RejectEmail rejects passwords containing words of the user's email
+	RejectEmail bool `json:"reject_email"`
+}
+
+// PasswordViolation is the rule of the password policy the password breaks
+type PasswordViolation struct {
+	Rule        string
+	Description string
+}
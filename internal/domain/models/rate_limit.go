@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// RateLimit allows Limit attempts per Window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
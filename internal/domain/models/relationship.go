@@ -0,0 +1,29 @@
+package models
+
+// ObjectRef identifies an object of the app, e.g. document:readme or user:42
+type ObjectRef struct {
+	Type string
+	ID   string
+}
+
+// SubjectRef is either an object itself or, with Relation set,
+// every subject having the relation to the object, e.g. group:eng#member
+type SubjectRef struct {
+	Object   ObjectRef
+	Relation string
+}
+
+// Relationship says the subject has the relation to the object, e.g. document:readme#owner@user:42
+type Relationship struct {
+	Object   ObjectRef
+	Relation string
+	Subject  SubjectRef
+}
+
+// RelationRewrite makes the relation of the objects of the type granted by other relations
+// of the same object too, e.g. editors of a document include its owners
+type RelationRewrite struct {
+	ObjectType string
+	Relation   string
+	ImpliedBy  []string
+}
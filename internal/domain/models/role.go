@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminRoleID is the ID of the built-in global admin role, it grants every permission
+var AdminRoleID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Role is a named set of permissions granted to the users it is assigned to
+type Role struct {
+	ID   uuid.UUID
+	Name string
+	// AppID is the app the role is scoped to, the permissions of global roles with uuid.Nil apply to every app
+	AppID       uuid.UUID
+	Permissions []string
+	// BuiltIn roles are created by migrations and can't be deleted
+	BuiltIn   bool
+	CreatedAt time.Time
+}
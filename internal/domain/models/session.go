@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login of the user to the app, its ID is the ID of the refresh token family issued at the login
+type Session struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	AppID     uuid.UUID
+	UserAgent string
+	IPAddress string
+	CreatedAt time.Time
+	// LastSeenAt is the time the tokens of the session were last refreshed
+	LastSeenAt time.Time
+	// Current is set for the session of the token the sessions were listed with
+	Current bool
+}
+
+// SSOSession lets the user obtain tokens of any app without the password until it expires or the user logs out everywhere
+type SSOSession struct {
+	UserID uuid.UUID `json:"user_id"`
+	// TokenVersion is the user's token version at the login, bumping it ends the session
+	TokenVersion int64     `json:"token_version"`
+	CreatedAt    time.Time `json:"created_at"`
+}
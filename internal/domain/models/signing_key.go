@@ -0,0 +1,29 @@
+package models
+
+import (
+	"crypto"
+	"time"
+)
+
+// SigningKey is a decrypted key used to sign tokens.
+// For HS256 only Secret is set, it is held by the server and never published.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+	Secret     []byte
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// StoredSigningKey is a signing key as it is kept in the database,
+// the private key is encrypted and both keys are DER encoded
+type StoredSigningKey struct {
+	ID                  string
+	Algorithm           string
+	EncryptedPrivateKey []byte
+	PublicKey           []byte
+	CreatedAt           time.Time
+	ExpiresAt           time.Time
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// IDToken is issued only for the openid scope
+	IDToken string
+	// Scope is the space separated list of granted OAuth scopes
+	Scope string
+	// ExpiresIn is the lifetime of the access token
+	ExpiresIn time.Duration
+	// SessionID identifies the refresh token family the tokens belong to
+	SessionID uuid.UUID
+	// MFAToken is set instead of the tokens when the login waits for the second factor
+	MFAToken string
+	// SSOSessionToken is issued at the login and lets the user obtain tokens of other apps without the password
+	SSOSessionToken string
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID
+	FamilyID  uuid.UUID
+	UserID    uuid.UUID
+	AppID     uuid.UUID
+	TokenHash string
+	Scope     string
+	ExpiresAt time.Time
+	RotatedAt time.Time
+	RevokedAt time.Time
+}
+
+const (
+	CredentialTypeAccessToken = "access_token"
+	CredentialTypeAPIKey      = "api_key"
+)
+
+// Principal is the user authenticated either by an access token or by an API key,
+// or the app authenticated by its own client credentials token
+type Principal struct {
+	// UserID is uuid.Nil for tokens of the app itself
+	UserID uuid.UUID
+	OrgID  uuid.UUID
+	// AppID is the app the token was issued for or the key is bound to, uuid.Nil for keys of no app
+	AppID uuid.UUID
+	// ClientID is set only for tokens of the app itself, it equals AppID
+	ClientID uuid.UUID
+	// CredentialID is the ID of the access token or of the API key
+	CredentialID   string
+	CredentialType string
+	Scopes         []string
+	// ExpiresAt is zero for API keys which never expire
+	ExpiresAt time.Time
+}
+
+// Introspection is the state of an access token or an API key, only Active is set for inactive ones
+type Introspection struct {
+	Active    bool
+	TokenID   string
+	TokenType string
+	UserID    uuid.UUID
+	Email     string
+	AppID     uuid.UUID
+	ClientID  uuid.UUID
+	OrgID     uuid.UUID
+	Roles     []string
+	Scope     string
+	ExpiresAt time.Time
+}
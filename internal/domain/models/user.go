@@ -3,9 +3,13 @@ package models
 import "github.com/google/uuid"
 
 type User struct {
-	ID       uuid.UUID
+	ID uuid.UUID
+	// OrgID is the organization owning the user, the email is unique within it
+	OrgID    uuid.UUID
 	Email    string
 	PassHash []byte
+	// EmailVerified is set once the user opens the link sent to the email
+	EmailVerified bool
 }
 
 type UserEvent struct {
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is the passkey registered by the user
+type WebAuthnCredential struct {
+	ID              []byte
+	UserID          uuid.UUID
+	Name            string
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	// SignCount is the signature counter of the last assertion, it must grow unless the authenticator doesn't count
+	SignCount      uint32
+	Transports     []string
+	BackupEligible bool
+	BackupState    bool
+	CreatedAt      time.Time
+	LastUsedAt     time.Time
+}
+
+// WebAuthnSession is the pending registration or login ceremony
+type WebAuthnSession struct {
+	// UserID is the user registering a passkey, it's not known for the passkey login
+	UserID uuid.UUID `json:"user_id"`
+	// AppID is the app the tokens are issued for after the passkey login
+	AppID uuid.UUID `json:"app_id"`
+	// Data is the ceremony state checked against the authenticator response
+	Data json.RawMessage `json:"data"`
+}
+
+// PasskeyCeremony is the started registration or login, the options are passed to navigator.credentials
+type PasskeyCeremony struct {
+	SessionID string
+	Options   json.RawMessage
+}
@@ -1,18 +1,88 @@
 package auth
 
 const (
-	ErrInvalidEmail           = "invalid email format"
-	ErrPasswordRequired       = "password is required"
-	ErrEmailRequired          = "email is required"
-	ErrUserIDRequired         = "userID is required"
-	ErrUserNotFound           = "user not found"
-	ErrUserExists             = "user already exists"
-	ErrAppExists              = "app already exists"
-	ErrAppNotFound            = "app not found"
-	ErrAppNameRequired        = "app name required"
-	ErrAppSecretRequired      = "app secret required"
-	ErrAppIDRequired          = "app_id is required"
-	ErrInternal               = "internal error"
-	ErrInvalidCredentials     = "invalid credentials"
-	ErrAccountTemporaryLocked = "account is temporary locked"
+	ErrInvalidEmail            = "invalid email format"
+	ErrPasswordRequired        = "password is required"
+	ErrCurrentPasswordRequired = "current_password is required"
+	ErrNewPasswordRequired     = "new_password is required"
+	ErrEmailRequired           = "email is required"
+	ErrUserIDRequired          = "userID is required"
+	ErrUserNotFound            = "user not found"
+	ErrUserExists              = "user already exists"
+	ErrAppExists               = "app already exists"
+	ErrAppNotFound             = "app not found"
+	ErrAppNameRequired         = "app name required"
+	ErrAppSecretRequired       = "app secret required"
+	ErrAppIDRequired           = "app_id is required"
+	ErrInvalidTokenTTL         = "token_ttl_seconds must not be negative"
+	ErrUnsupportedClaim        = "unsupported claim"
+	ErrInvalidClientType       = "client_type must be confidential or public"
+	ErrInvalidRedirectURI      = "redirect uris must be absolute URIs without fragment"
+	ErrInvalidClient           = "invalid client credentials"
+	ErrUnauthorizedClient      = "public apps can't use client credentials"
+	ErrInvalidScope            = "scope is not assigned to the app"
+	ErrRateLimited             = "too many requests"
+	ErrUserCodeRequired        = "user_code is required"
+	ErrInvalidUserCode         = "user code is invalid or expired"
+	ErrInternal                = "internal error"
+	ErrInvalidCredentials      = "invalid credentials"
+	ErrAccountTemporaryLocked  = "account is temporary locked"
+	ErrRefreshTokenRequired    = "refresh_token is required"
+	ErrInvalidRefreshToken     = "invalid refresh token"
+	ErrTokenRequired           = "token is required"
+	ErrInvalidToken            = "invalid token"
+	ErrMFATokenRequired        = "mfa_token is required"
+	ErrMFACodeRequired         = "code is required"
+	ErrInvalidMFACode          = "invalid mfa code"
+	ErrInvalidMFAToken         = "mfa token is invalid or expired"
+	ErrMFAAlreadyEnabled       = "mfa is already enabled"
+	ErrMFANotEnrolled          = "mfa is not enrolled"
+	ErrSessionIDRequired       = "session_id is required"
+	ErrCredentialRequired      = "credential is required"
+	ErrInvalidPasskey          = "invalid passkey"
+	ErrPasskeyExists           = "passkey already registered"
+	ErrEmailNotVerified        = "email is not verified"
+	ErrInvalidEmailToken       = "link is invalid or expired"
+	ErrWeakPassword            = "password violates the password policy"
+	ErrInvalidPasswordPolicy   = "invalid password policy"
+	ErrRedirectURIRequired     = "redirect_uri is required"
+	ErrUnregisteredRedirectURI = "redirect_uri is not registered for the app"
+	ErrMagicLinkDisabled       = "magic link login is disabled for the app"
+	ErrInvalidUserID           = "invalid user_id"
+	ErrSessionNotFound         = "session not found"
+	ErrSessionsPermission      = "only admins can manage sessions of other users"
+	ErrSSOSessionTokenRequired = "sso_session_token is required"
+	ErrInvalidSSOSession       = "sso session is invalid or expired"
+	ErrRoleNameRequired        = "name is required"
+	ErrRoleIDRequired          = "role_id is required"
+	ErrPermissionRequired      = "permission is required"
+	ErrInvalidPermission       = "permissions must not be empty or contain whitespace"
+	ErrRoleNotFound            = "role not found"
+	ErrRoleExists              = "role already exists"
+	ErrBuiltInRole             = "built-in roles can't be deleted"
+	ErrRolesPermission         = "roles.manage permission is required"
+	ErrCallerRequired          = "token or client credentials are required"
+	ErrOrgNameRequired         = "name is required"
+	ErrOrgIDRequired           = "org_id is required"
+	ErrInvalidOrgID            = "invalid org_id"
+	ErrInvalidOrgRole          = "role must be member or admin"
+	ErrOrganizationNotFound    = "organization not found"
+	ErrOrganizationExists      = "organization already exists"
+	ErrMemberNotFound          = "organization member not found"
+	ErrOrganizationsPermission = "only admins of the organization can manage it"
+	ErrGroupNameRequired       = "name is required"
+	ErrGroupIDRequired         = "group_id is required"
+	ErrGroupMemberRequired     = "exactly one of user_id and member_group_id is required"
+	ErrGroupNotFound           = "group not found"
+	ErrGroupExists             = "group already exists"
+	ErrGroupCycle              = "group can't be nested in itself"
+	ErrGroupsPermission        = "only admins of the organization can manage its groups"
+	ErrGroupRolesPermission    = "managing both the role and the group is required"
+	ErrAPIKeyNameRequired      = "name is required"
+	ErrAPIKeyIDRequired        = "key_id is required"
+	ErrInvalidAPIKeyScope      = "scopes must not be empty or contain whitespace"
+	ErrAPIKeyScopeNotAllowed   = "scope must be granted to the token and to the app of the key"
+	ErrInvalidAPIKeyTTL        = "ttl_seconds must not be negative"
+	ErrAPIKeyNotFound          = "api key not found"
+	ErrAPIKeysPermission       = "only admins can manage api keys of other users"
 )
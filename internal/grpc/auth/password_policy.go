@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// weakPasswordError returns InvalidArgument status with the broken rules of the password policy
+// as field violations of the password field
+func weakPasswordError(err error, field string) error {
+	var policyErr *auth.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return status.Error(codes.InvalidArgument, ErrWeakPassword)
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range policyErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: violation.Description,
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, ErrWeakPassword).WithDetails(badRequest)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, ErrWeakPassword)
+	}
+
+	return st.Err()
+}
+
+// optionalAppID parses the app ID, uuid.Nil is returned when it isn't given
+func optionalAppID(appID string) (uuid.UUID, error) {
+	if appID == emptyValue {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(appID)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, ErrAppNotFound)
+	}
+
+	return id, nil
+}
+
+func toPasswordPolicy(policy *ssov1.PasswordPolicy) *models.PasswordPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &models.PasswordPolicy{
+		MinLength:     int(policy.GetMinLength()),
+		MaxLength:     int(policy.GetMaxLength()),
+		RequireUpper:  policy.GetRequireUpper(),
+		RequireLower:  policy.GetRequireLower(),
+		RequireDigit:  policy.GetRequireDigit(),
+		RequireSymbol: policy.GetRequireSymbol(),
+		RejectCommon:  policy.GetRejectCommon(),
+		RejectEmail:   policy.GetRejectEmail(),
+	}
+}
+
+func fromPasswordPolicy(policy *models.PasswordPolicy) *ssov1.PasswordPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	return &ssov1.PasswordPolicy{
+		MinLength:     int32(policy.MinLength),
+		MaxLength:     int32(policy.MaxLength),
+		RequireUpper:  policy.RequireUpper,
+		RequireLower:  policy.RequireLower,
+		RequireDigit:  policy.RequireDigit,
+		RequireSymbol: policy.RequireSymbol,
+		RejectCommon:  policy.RejectCommon,
+		RejectEmail:   policy.RejectEmail,
+	}
+}
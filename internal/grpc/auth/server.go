@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"time"
 
 	"github.com/BariVakhidov/sso/internal/domain/models"
 	"github.com/BariVakhidov/sso/internal/services/auth"
@@ -18,11 +20,66 @@ const (
 )
 
 type AuthService interface {
-	Login(ctx context.Context, email string, password string, appID uuid.UUID) (token string, err error)
-	RegisterNewUser(ctx context.Context, email string, password string) (userID uuid.UUID, err error)
+	Login(ctx context.Context, email string, password string, appID uuid.UUID) (tokens models.TokenPair, err error)
+	Refresh(ctx context.Context, refreshToken string) (tokens models.TokenPair, err error)
+	Logout(ctx context.Context, token string, refreshToken string) error
+	LogoutAll(ctx context.Context, token string) error
+	ClientToken(ctx context.Context, appID uuid.UUID, secret string, scopes []string) (tokens models.TokenPair, err error)
+	ApproveDevice(ctx context.Context, token string, userCode string, approve bool) error
+	EnrollMFA(ctx context.Context, token string) (models.MFAEnrollment, error)
+	ConfirmMFA(ctx context.Context, token string, code string) (recoveryCodes []string, err error)
+	VerifyMFA(ctx context.Context, mfaToken string, code string) (tokens models.TokenPair, err error)
+	RegenerateRecoveryCodes(ctx context.Context, token string) (recoveryCodes []string, err error)
+	BeginPasskeyRegistration(ctx context.Context, token string) (models.PasskeyCeremony, error)
+	FinishPasskeyRegistration(ctx context.Context, token string, sessionID string, name string, response []byte) (credentialID []byte, err error)
+	BeginPasskeyLogin(ctx context.Context, appID uuid.UUID) (models.PasskeyCeremony, error)
+	FinishPasskeyLogin(ctx context.Context, sessionID string, response []byte) (tokens models.TokenPair, err error)
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string, appID uuid.UUID) error
+	RequestPasswordReset(ctx context.Context, email string, appID uuid.UUID) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	ChangePassword(ctx context.Context, token string, refreshToken string, currentPassword string, newPassword string) (tokens models.TokenPair, err error)
+	RequestMagicLink(ctx context.Context, email string, appID uuid.UUID, redirectURI string) error
+	ConsumeMagicLink(ctx context.Context, token string, appID uuid.UUID) (tokens models.TokenPair, err error)
+	ListSessions(ctx context.Context, token string, userID uuid.UUID) ([]models.Session, error)
+	RevokeSession(ctx context.Context, token string, sessionID uuid.UUID) error
+	ObtainAppToken(ctx context.Context, ssoSessionToken string, appID uuid.UUID) (tokens models.TokenPair, err error)
+	CreateRole(ctx context.Context, token string, role models.Role) (roleID uuid.UUID, err error)
+	DeleteRole(ctx context.Context, token string, roleID uuid.UUID) error
+	ListRoles(ctx context.Context, token string, appID uuid.UUID) ([]models.Role, error)
+	AssignRole(ctx context.Context, token string, userID uuid.UUID, roleID uuid.UUID) error
+	UnassignRole(ctx context.Context, token string, userID uuid.UUID, roleID uuid.UUID) error
+	ListUserRoles(ctx context.Context, token string, userID uuid.UUID) ([]models.Role, error)
+	HasPermission(
+		ctx context.Context,
+		token string,
+		clientID uuid.UUID,
+		clientSecret string,
+		userID uuid.UUID,
+		appID uuid.UUID,
+		permission string,
+	) (bool, error)
+	CreateOrganization(ctx context.Context, token string, name string) (orgID uuid.UUID, err error)
+	ListOrganizationMembers(ctx context.Context, token string, orgID uuid.UUID) ([]models.OrganizationMember, error)
+	SetOrganizationMemberRole(ctx context.Context, token string, orgID uuid.UUID, userID uuid.UUID, role string) error
+	CreateGroup(ctx context.Context, token string, name string) (groupID uuid.UUID, err error)
+	DeleteGroup(ctx context.Context, token string, groupID uuid.UUID) error
+	ListGroups(ctx context.Context, token string) ([]models.Group, error)
+	AddGroupUser(ctx context.Context, token string, groupID uuid.UUID, userID uuid.UUID) error
+	RemoveGroupUser(ctx context.Context, token string, groupID uuid.UUID, userID uuid.UUID) error
+	AddSubgroup(ctx context.Context, token string, groupID uuid.UUID, subgroupID uuid.UUID) error
+	RemoveSubgroup(ctx context.Context, token string, groupID uuid.UUID, subgroupID uuid.UUID) error
+	ListGroupMembers(ctx context.Context, token string, groupID uuid.UUID, transitive bool) ([]models.GroupUser, []models.Group, error)
+	ListUserGroups(ctx context.Context, token string, userID uuid.UUID) ([]models.Group, error)
+	GrantGroupRole(ctx context.Context, token string, groupID uuid.UUID, roleID uuid.UUID) error
+	RevokeGroupRole(ctx context.Context, token string, groupID uuid.UUID, roleID uuid.UUID) error
+	CreateAPIKey(ctx context.Context, token string, name string, appID uuid.UUID, scopes []string, ttl time.Duration) (models.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, token string, userID uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, token string, keyID uuid.UUID) error
+	RegisterNewUser(ctx context.Context, email string, password string, appID uuid.UUID) (userID uuid.UUID, err error)
 	IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error)
-	CreateApp(ctx context.Context, name, secret string) (uuid.UUID, error)
-	App(ctx context.Context, name string) (models.App, error)
+	CreateApp(ctx context.Context, token string, app models.App) (models.App, error)
+	App(ctx context.Context, orgID uuid.UUID, name string) (models.App, error)
 }
 
 type ServerAPI struct {
@@ -43,42 +100,1165 @@ func (s *ServerAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 		return nil, err
 	}
 
-	userID, err := s.authService.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword())
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := s.authService.RegisterNewUser(ctx, req.GetEmail(), req.GetPassword(), appID)
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, ErrUserExists)
 		}
 
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "password")
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RegisterResponse{UserId: userID.String()}, nil
+}
+
+func (s *ServerAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
+	if err := s.validateLoginReq(req); err != nil {
+		return nil, err
+	}
+
+	appId, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidCredentials)
+	}
+
+	tokens, err := s.authService.Login(ctx, req.GetEmail(), req.GetPassword(), appId)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidCredentials)
+		}
+
+		if errors.Is(err, auth.ErrAccountIsLocked) {
+			return nil, status.Error(codes.InvalidArgument, ErrAccountTemporaryLocked)
+		}
+
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, ErrEmailNotVerified)
+		}
+
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.LoginResponse{
+		Token:           tokens.AccessToken,
+		RefreshToken:    tokens.RefreshToken,
+		MfaToken:        tokens.MFAToken,
+		SsoSessionToken: tokens.SSOSessionToken,
+	}, nil
+}
+
+func (s *ServerAPI) Refresh(ctx context.Context, req *ssov1.RefreshRequest) (*ssov1.RefreshResponse, error) {
+	if err := s.validateRefreshReq(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.authService.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidRefreshToken)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RefreshResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *ServerAPI) ClientToken(ctx context.Context, req *ssov1.ClientTokenRequest) (*ssov1.ClientTokenResponse, error) {
+	if err := s.validateClientTokenReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+	}
+
+	tokens, err := s.authService.ClientToken(ctx, appID, req.GetSecret(), req.GetScopes())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+		case errors.Is(err, auth.ErrUnauthorizedClient):
+			return nil, status.Error(codes.PermissionDenied, ErrUnauthorizedClient)
+		case errors.Is(err, auth.ErrInvalidScope):
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidScope)
+		case errors.Is(err, auth.ErrRateLimited):
+			return nil, status.Error(codes.ResourceExhausted, ErrRateLimited)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ClientTokenResponse{
+		Token:     tokens.AccessToken,
+		ExpiresIn: int64(tokens.ExpiresIn / time.Second),
+		Scope:     tokens.Scope,
+	}, nil
+}
+
+func (s *ServerAPI) ApproveDevice(ctx context.Context, req *ssov1.ApproveDeviceRequest) (*ssov1.ApproveDeviceResponse, error) {
+	if err := s.validateApproveDeviceReq(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.ApproveDevice(ctx, req.GetToken(), req.GetUserCode(), !req.GetDeny()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrInvalidUserCode) {
+			return nil, status.Error(codes.NotFound, ErrInvalidUserCode)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ApproveDeviceResponse{}, nil
+}
+
+func (s *ServerAPI) EnrollMFA(ctx context.Context, req *ssov1.EnrollMFARequest) (*ssov1.EnrollMFAResponse, error) {
+	if err := s.validateEnrollMFAReq(req); err != nil {
+		return nil, err
+	}
+
+	enrollment, err := s.authService.EnrollMFA(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, ErrMFAAlreadyEnabled)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.EnrollMFAResponse{OtpauthUri: enrollment.URI, Secret: enrollment.Secret}, nil
+}
+
+func (s *ServerAPI) ConfirmMFA(ctx context.Context, req *ssov1.ConfirmMFARequest) (*ssov1.ConfirmMFAResponse, error) {
+	if err := s.validateConfirmMFAReq(req); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.authService.ConfirmMFA(ctx, req.GetToken(), req.GetCode())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidMFACode)
+		}
+
+		if errors.Is(err, auth.ErrMFAAlreadyEnabled) {
+			return nil, status.Error(codes.FailedPrecondition, ErrMFAAlreadyEnabled)
+		}
+
+		if errors.Is(err, auth.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, ErrMFANotEnrolled)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ConfirmMFAResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *ServerAPI) VerifyMFA(ctx context.Context, req *ssov1.VerifyMFARequest) (*ssov1.VerifyMFAResponse, error) {
+	if err := s.validateVerifyMFAReq(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.authService.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMFACode) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidMFACode)
+		}
+
+		if errors.Is(err, auth.ErrInvalidMFAToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidMFAToken)
+		}
+
+		if errors.Is(err, auth.ErrAccountIsLocked) {
+			return nil, status.Error(codes.InvalidArgument, ErrAccountTemporaryLocked)
+		}
+
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, ErrEmailNotVerified)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.VerifyMFAResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, SsoSessionToken: tokens.SSOSessionToken}, nil
+}
+
+func (s *ServerAPI) RegenerateRecoveryCodes(ctx context.Context, req *ssov1.RegenerateRecoveryCodesRequest) (*ssov1.RegenerateRecoveryCodesResponse, error) {
+	if err := s.validateRegenerateRecoveryCodesReq(req); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.authService.RegenerateRecoveryCodes(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.FailedPrecondition, ErrMFANotEnrolled)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RegenerateRecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *ServerAPI) BeginPasskeyRegistration(ctx context.Context, req *ssov1.BeginPasskeyRegistrationRequest) (*ssov1.BeginPasskeyRegistrationResponse, error) {
+	if err := s.validateBeginPasskeyRegistrationReq(req); err != nil {
+		return nil, err
+	}
+
+	ceremony, err := s.authService.BeginPasskeyRegistration(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.BeginPasskeyRegistrationResponse{SessionId: ceremony.SessionID, Options: string(ceremony.Options)}, nil
+}
+
+func (s *ServerAPI) FinishPasskeyRegistration(ctx context.Context, req *ssov1.FinishPasskeyRegistrationRequest) (*ssov1.FinishPasskeyRegistrationResponse, error) {
+	if err := s.validateFinishPasskeyRegistrationReq(req); err != nil {
+		return nil, err
+	}
+
+	credentialID, err := s.authService.FinishPasskeyRegistration(ctx, req.GetToken(), req.GetSessionId(), req.GetName(), []byte(req.GetCredential()))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrInvalidPasskey) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidPasskey)
+		}
+
+		if errors.Is(err, auth.ErrPasskeyExists) {
+			return nil, status.Error(codes.AlreadyExists, ErrPasskeyExists)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.FinishPasskeyRegistrationResponse{CredentialId: base64.RawURLEncoding.EncodeToString(credentialID)}, nil
+}
+
+func (s *ServerAPI) BeginPasskeyLogin(ctx context.Context, req *ssov1.BeginPasskeyLoginRequest) (*ssov1.BeginPasskeyLoginResponse, error) {
+	if err := s.validateBeginPasskeyLoginReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrAppNotFound)
+	}
+
+	ceremony, err := s.authService.BeginPasskeyLogin(ctx, appID)
+	if err != nil {
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.BeginPasskeyLoginResponse{SessionId: ceremony.SessionID, Options: string(ceremony.Options)}, nil
+}
+
+func (s *ServerAPI) FinishPasskeyLogin(ctx context.Context, req *ssov1.FinishPasskeyLoginRequest) (*ssov1.FinishPasskeyLoginResponse, error) {
+	if err := s.validateFinishPasskeyLoginReq(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.authService.FinishPasskeyLogin(ctx, req.GetSessionId(), []byte(req.GetCredential()))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidPasskey) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidPasskey)
+		}
+
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, ErrEmailNotVerified)
+		}
+
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.FinishPasskeyLoginResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken, SsoSessionToken: tokens.SSOSessionToken}, nil
+}
+
+func (s *ServerAPI) VerifyEmail(ctx context.Context, req *ssov1.VerifyEmailRequest) (*ssov1.VerifyEmailResponse, error) {
+	if err := s.validateVerifyEmailReq(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.VerifyEmail(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidEmailToken)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.VerifyEmailResponse{}, nil
+}
+
+func (s *ServerAPI) ResendVerification(ctx context.Context, req *ssov1.ResendVerificationRequest) (*ssov1.ResendVerificationResponse, error) {
+	if err := s.validateResendVerificationReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.ResendVerification(ctx, req.GetEmail(), appID); err != nil {
+		if errors.Is(err, auth.ErrRateLimited) {
+			return nil, status.Error(codes.ResourceExhausted, ErrRateLimited)
+		}
+
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ResendVerificationResponse{}, nil
+}
+
+func (s *ServerAPI) RequestPasswordReset(ctx context.Context, req *ssov1.RequestPasswordResetRequest) (*ssov1.RequestPasswordResetResponse, error) {
+	if err := s.validateRequestPasswordResetReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.RequestPasswordReset(ctx, req.GetEmail(), appID); err != nil {
+		if errors.Is(err, auth.ErrRateLimited) {
+			return nil, status.Error(codes.ResourceExhausted, ErrRateLimited)
+		}
+
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RequestPasswordResetResponse{}, nil
+}
+
+func (s *ServerAPI) ResetPassword(ctx context.Context, req *ssov1.ResetPasswordRequest) (*ssov1.ResetPasswordResponse, error) {
+	if err := s.validateResetPasswordReq(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.ResetPassword(ctx, req.GetToken(), req.GetPassword()); err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidEmailToken)
+		}
+
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "password")
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ResetPasswordResponse{}, nil
+}
+
+func (s *ServerAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswordRequest) (*ssov1.ChangePasswordResponse, error) {
+	if err := s.validateChangePasswordReq(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.authService.ChangePassword(ctx, req.GetToken(), req.GetRefreshToken(), req.GetCurrentPassword(), req.GetNewPassword())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidCredentials)
+		}
+
+		if errors.Is(err, auth.ErrAccountIsLocked) {
+			return nil, status.Error(codes.InvalidArgument, ErrAccountTemporaryLocked)
+		}
+
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidRefreshToken)
+		}
+
+		if errors.Is(err, auth.ErrWeakPassword) {
+			return nil, weakPasswordError(err, "new_password")
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ChangePasswordResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *ServerAPI) RequestMagicLink(ctx context.Context, req *ssov1.RequestMagicLinkRequest) (*ssov1.RequestMagicLinkResponse, error) {
+	if err := s.validateRequestMagicLinkReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ErrAppNotFound)
+	}
+
+	if err := s.authService.RequestMagicLink(ctx, req.GetEmail(), appID, req.GetRedirectUri()); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		case errors.Is(err, auth.ErrMagicLinkDisabled):
+			return nil, status.Error(codes.FailedPrecondition, ErrMagicLinkDisabled)
+		case errors.Is(err, auth.ErrInvalidRedirectURI):
+			return nil, status.Error(codes.InvalidArgument, ErrUnregisteredRedirectURI)
+		case errors.Is(err, auth.ErrRateLimited):
+			return nil, status.Error(codes.ResourceExhausted, ErrRateLimited)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RequestMagicLinkResponse{}, nil
+}
+
+func (s *ServerAPI) ConsumeMagicLink(ctx context.Context, req *ssov1.ConsumeMagicLinkRequest) (*ssov1.ConsumeMagicLinkResponse, error) {
+	if err := s.validateConsumeMagicLinkReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidEmailToken)
+	}
+
+	tokens, err := s.authService.ConsumeMagicLink(ctx, req.GetToken(), appID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidEmailToken):
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidEmailToken)
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		case errors.Is(err, auth.ErrMagicLinkDisabled):
+			return nil, status.Error(codes.FailedPrecondition, ErrMagicLinkDisabled)
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			return nil, status.Error(codes.FailedPrecondition, ErrEmailNotVerified)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ConsumeMagicLinkResponse{
+		Token:           tokens.AccessToken,
+		RefreshToken:    tokens.RefreshToken,
+		MfaToken:        tokens.MFAToken,
+		SsoSessionToken: tokens.SSOSessionToken,
+	}, nil
+}
+
+func (s *ServerAPI) ListSessions(ctx context.Context, req *ssov1.ListSessionsRequest) (*ssov1.ListSessionsResponse, error) {
+	if err := s.validateListSessionsReq(req); err != nil {
+		return nil, err
+	}
+
+	userID := uuid.Nil
+	if req.GetUserId() != emptyValue {
+		var err error
+		if userID, err = uuid.Parse(req.GetUserId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+		}
+	}
+
+	sessions, err := s.authService.ListSessions(ctx, req.GetToken(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, ErrSessionsPermission)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	resp := &ssov1.ListSessionsResponse{Sessions: make([]*ssov1.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &ssov1.Session{
+			SessionId:  session.ID.String(),
+			AppId:      session.AppID.String(),
+			UserAgent:  session.UserAgent,
+			IpAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastSeenAt: session.LastSeenAt.Unix(),
+			Current:    session.Current,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *ServerAPI) RevokeSession(ctx context.Context, req *ssov1.RevokeSessionRequest) (*ssov1.RevokeSessionResponse, error) {
+	if err := s.validateRevokeSessionReq(req); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := uuid.Parse(req.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ErrSessionNotFound)
+	}
+
+	if err := s.authService.RevokeSession(ctx, req.GetToken(), sessionID); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, ErrSessionNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.RevokeSessionResponse{}, nil
+}
+
+func (s *ServerAPI) ObtainAppToken(ctx context.Context, req *ssov1.ObtainAppTokenRequest) (*ssov1.ObtainAppTokenResponse, error) {
+	if err := s.validateObtainAppTokenReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ErrAppNotFound)
+	}
+
+	tokens, err := s.authService.ObtainAppToken(ctx, req.GetSsoSessionToken(), appID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidSSOSession):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidSSOSession)
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			return nil, status.Error(codes.FailedPrecondition, ErrEmailNotVerified)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ObtainAppTokenResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *ServerAPI) CreateRole(ctx context.Context, req *ssov1.CreateRoleRequest) (*ssov1.CreateRoleResponse, error) {
+	if err := s.validateCreateRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	roleID, err := s.authService.CreateRole(ctx, req.GetToken(), models.Role{
+		Name:        req.GetName(),
+		AppID:       appID,
+		Permissions: req.GetPermissions(),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		case errors.Is(err, auth.ErrRoleExists):
+			return nil, status.Error(codes.AlreadyExists, ErrRoleExists)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.CreateRoleResponse{RoleId: roleID.String()}, nil
+}
+
+func (s *ServerAPI) DeleteRole(ctx context.Context, req *ssov1.DeleteRoleRequest) (*ssov1.DeleteRoleResponse, error) {
+	if err := s.validateDeleteRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	roleID, err := uuid.Parse(req.GetRoleId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ErrRoleNotFound)
+	}
+
+	if err := s.authService.DeleteRole(ctx, req.GetToken(), roleID); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		case errors.Is(err, auth.ErrRoleNotFound):
+			return nil, status.Error(codes.NotFound, ErrRoleNotFound)
+		case errors.Is(err, auth.ErrBuiltInRole):
+			return nil, status.Error(codes.FailedPrecondition, ErrBuiltInRole)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.DeleteRoleResponse{}, nil
+}
+
+func (s *ServerAPI) ListRoles(ctx context.Context, req *ssov1.ListRolesRequest) (*ssov1.ListRolesResponse, error) {
+	if err := s.validateListRolesReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.authService.ListRoles(ctx, req.GetToken(), appID)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ListRolesResponse{Roles: toRoles(roles)}, nil
+}
+
+func (s *ServerAPI) AssignRole(ctx context.Context, req *ssov1.AssignRoleRequest) (*ssov1.AssignRoleResponse, error) {
+	if err := s.validateAssignRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	userID, roleID, err := parseRoleAssignment(req.GetUserId(), req.GetRoleId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.AssignRole(ctx, req.GetToken(), userID, roleID); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		case errors.Is(err, auth.ErrRoleNotFound):
+			return nil, status.Error(codes.NotFound, ErrRoleNotFound)
+		case errors.Is(err, auth.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, ErrUserNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.AssignRoleResponse{}, nil
+}
+
+func (s *ServerAPI) UnassignRole(ctx context.Context, req *ssov1.UnassignRoleRequest) (*ssov1.UnassignRoleResponse, error) {
+	if err := s.validateUnassignRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	userID, roleID, err := parseRoleAssignment(req.GetUserId(), req.GetRoleId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.UnassignRole(ctx, req.GetToken(), userID, roleID); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		case errors.Is(err, auth.ErrRoleNotFound):
+			return nil, status.Error(codes.NotFound, ErrRoleNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.UnassignRoleResponse{}, nil
+}
+
+func (s *ServerAPI) ListUserRoles(ctx context.Context, req *ssov1.ListUserRolesRequest) (*ssov1.ListUserRolesResponse, error) {
+	if err := s.validateListUserRolesReq(req); err != nil {
+		return nil, err
+	}
+
+	userID := uuid.Nil
+	if req.GetUserId() != emptyValue {
+		var err error
+		if userID, err = uuid.Parse(req.GetUserId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+		}
+	}
+
+	roles, err := s.authService.ListUserRoles(ctx, req.GetToken(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, ErrRolesPermission)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ListUserRolesResponse{Roles: toRoles(roles)}, nil
+}
+
+func (s *ServerAPI) HasPermission(ctx context.Context, req *ssov1.HasPermissionRequest) (*ssov1.HasPermissionResponse, error) {
+	if err := s.validateHasPermissionReq(req); err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	// the client credentials are checked only without the token
+	clientID := uuid.Nil
+	if req.GetToken() == emptyValue {
+		if clientID, err = uuid.Parse(req.GetClientId()); err != nil {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+		}
+	}
+
+	hasPermission, err := s.authService.HasPermission(ctx, req.GetToken(), clientID, req.GetClientSecret(), userID, appID, req.GetPermission())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrInvalidClient):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+		case errors.Is(err, auth.ErrUnauthorizedClient):
+			return nil, status.Error(codes.PermissionDenied, ErrUnauthorizedClient)
+		case errors.Is(err, auth.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, ErrUserNotFound)
+		case errors.Is(err, auth.ErrAppNotFound):
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.HasPermissionResponse{HasPermission: hasPermission}, nil
+}
+
+func (s *ServerAPI) CreateOrganization(ctx context.Context, req *ssov1.CreateOrganizationRequest) (*ssov1.CreateOrganizationResponse, error) {
+	if err := s.validateCreateOrganizationReq(req); err != nil {
+		return nil, err
+	}
+
+	orgID, err := s.authService.CreateOrganization(ctx, req.GetToken(), req.GetName())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrOrganizationsPermission)
+		case errors.Is(err, auth.ErrOrganizationExists):
+			return nil, status.Error(codes.AlreadyExists, ErrOrganizationExists)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.CreateOrganizationResponse{OrgId: orgID.String()}, nil
+}
+
+func (s *ServerAPI) ListOrganizationMembers(ctx context.Context, req *ssov1.ListOrganizationMembersRequest) (*ssov1.ListOrganizationMembersResponse, error) {
+	if err := s.validateListOrganizationMembersReq(req); err != nil {
+		return nil, err
+	}
+
+	orgID, err := optionalOrgID(req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.authService.ListOrganizationMembers(ctx, req.GetToken(), orgID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, ErrOrganizationsPermission)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ListOrganizationMembersResponse{Members: toOrganizationMembers(members)}, nil
+}
+
+func (s *ServerAPI) SetOrganizationMemberRole(ctx context.Context, req *ssov1.SetOrganizationMemberRoleRequest) (*ssov1.SetOrganizationMemberRoleResponse, error) {
+	if err := s.validateSetOrganizationMemberRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	orgID, err := optionalOrgID(req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := uuid.Parse(req.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+	}
+
+	if err := s.authService.SetOrganizationMemberRole(ctx, req.GetToken(), orgID, userID, req.GetRole()); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrOrganizationsPermission)
+		case errors.Is(err, auth.ErrMemberNotFound):
+			return nil, status.Error(codes.NotFound, ErrMemberNotFound)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.SetOrganizationMemberRoleResponse{}, nil
+}
+
+func (s *ServerAPI) CreateGroup(ctx context.Context, req *ssov1.CreateGroupRequest) (*ssov1.CreateGroupResponse, error) {
+	if err := s.validateCreateGroupReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, err := s.authService.CreateGroup(ctx, req.GetToken(), req.GetName())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		case errors.Is(err, auth.ErrPermissionDenied):
+			return nil, status.Error(codes.PermissionDenied, ErrGroupsPermission)
+		case errors.Is(err, auth.ErrGroupExists):
+			return nil, status.Error(codes.AlreadyExists, ErrGroupExists)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.CreateGroupResponse{GroupId: groupID.String()}, nil
+}
+
+func (s *ServerAPI) DeleteGroup(ctx context.Context, req *ssov1.DeleteGroupRequest) (*ssov1.DeleteGroupResponse, error) {
+	if err := s.validateDeleteGroupReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, err := parseGroupID(req.GetGroupId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.DeleteGroup(ctx, req.GetToken(), groupID); err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.DeleteGroupResponse{}, nil
+}
+
+func (s *ServerAPI) ListGroups(ctx context.Context, req *ssov1.ListGroupsRequest) (*ssov1.ListGroupsResponse, error) {
+	if err := s.validateListGroupsReq(req); err != nil {
+		return nil, err
+	}
+
+	groups, err := s.authService.ListGroups(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
 		return nil, status.Error(codes.Internal, ErrInternal)
 	}
 
-	return &ssov1.RegisterResponse{UserId: userID.String()}, nil
+	return &ssov1.ListGroupsResponse{Groups: toGroups(groups)}, nil
 }
 
-func (s *ServerAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
-	if err := s.validateLoginReq(req); err != nil {
+func (s *ServerAPI) AddGroupMember(ctx context.Context, req *ssov1.AddGroupMemberRequest) (*ssov1.AddGroupMemberResponse, error) {
+	if err := s.validateAddGroupMemberReq(req); err != nil {
 		return nil, err
 	}
 
-	appId, err := uuid.Parse(req.GetAppId())
+	groupID, userID, subgroupID, err := parseGroupMember(req.GetGroupId(), req.GetUserId(), req.GetMemberGroupId())
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, ErrInvalidCredentials)
+		return nil, err
+	}
+
+	if userID != uuid.Nil {
+		err = s.authService.AddGroupUser(ctx, req.GetToken(), groupID, userID)
+	} else {
+		err = s.authService.AddSubgroup(ctx, req.GetToken(), groupID, subgroupID)
 	}
 
-	token, err := s.authService.Login(ctx, req.GetEmail(), req.GetPassword(), appId)
 	if err != nil {
-		if errors.Is(err, auth.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, ErrInvalidCredentials)
+		return nil, groupError(err)
+	}
+
+	return &ssov1.AddGroupMemberResponse{}, nil
+}
+
+func (s *ServerAPI) RemoveGroupMember(ctx context.Context, req *ssov1.RemoveGroupMemberRequest) (*ssov1.RemoveGroupMemberResponse, error) {
+	if err := s.validateRemoveGroupMemberReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, userID, subgroupID, err := parseGroupMember(req.GetGroupId(), req.GetUserId(), req.GetMemberGroupId())
+	if err != nil {
+		return nil, err
+	}
+
+	if userID != uuid.Nil {
+		err = s.authService.RemoveGroupUser(ctx, req.GetToken(), groupID, userID)
+	} else {
+		err = s.authService.RemoveSubgroup(ctx, req.GetToken(), groupID, subgroupID)
+	}
+
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.RemoveGroupMemberResponse{}, nil
+}
+
+func (s *ServerAPI) ListGroupMembers(ctx context.Context, req *ssov1.ListGroupMembersRequest) (*ssov1.ListGroupMembersResponse, error) {
+	if err := s.validateListGroupMembersReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, err := parseGroupID(req.GetGroupId())
+	if err != nil {
+		return nil, err
+	}
+
+	users, subgroups, err := s.authService.ListGroupMembers(ctx, req.GetToken(), groupID, req.GetTransitive())
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.ListGroupMembersResponse{Users: toGroupUsers(users), Subgroups: toGroups(subgroups)}, nil
+}
+
+func (s *ServerAPI) ListUserGroups(ctx context.Context, req *ssov1.ListUserGroupsRequest) (*ssov1.ListUserGroupsResponse, error) {
+	if err := s.validateListUserGroupsReq(req); err != nil {
+		return nil, err
+	}
+
+	userID := uuid.Nil
+	if req.GetUserId() != emptyValue {
+		parsed, err := uuid.Parse(req.GetUserId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
 		}
 
-		if errors.Is(err, auth.ErrAccountIsLocked) {
-			return nil, status.Error(codes.InvalidArgument, ErrAccountTemporaryLocked)
+		userID = parsed
+	}
+
+	groups, err := s.authService.ListUserGroups(ctx, req.GetToken(), userID)
+	if err != nil {
+		return nil, groupError(err)
+	}
+
+	return &ssov1.ListUserGroupsResponse{Groups: toGroups(groups)}, nil
+}
+
+func (s *ServerAPI) GrantGroupRole(ctx context.Context, req *ssov1.GrantGroupRoleRequest) (*ssov1.GrantGroupRoleResponse, error) {
+	if err := s.validateGrantGroupRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, roleID, err := parseGroupRole(req.GetGroupId(), req.GetRoleId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.GrantGroupRole(ctx, req.GetToken(), groupID, roleID); err != nil {
+		return nil, groupRoleError(err)
+	}
+
+	return &ssov1.GrantGroupRoleResponse{}, nil
+}
+
+func (s *ServerAPI) RevokeGroupRole(ctx context.Context, req *ssov1.RevokeGroupRoleRequest) (*ssov1.RevokeGroupRoleResponse, error) {
+	if err := s.validateRevokeGroupRoleReq(req); err != nil {
+		return nil, err
+	}
+
+	groupID, roleID, err := parseGroupRole(req.GetGroupId(), req.GetRoleId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.RevokeGroupRole(ctx, req.GetToken(), groupID, roleID); err != nil {
+		return nil, groupRoleError(err)
+	}
+
+	return &ssov1.RevokeGroupRoleResponse{}, nil
+}
+
+func (s *ServerAPI) CreateAPIKey(ctx context.Context, req *ssov1.CreateAPIKeyRequest) (*ssov1.CreateAPIKeyResponse, error) {
+	if err := s.validateCreateAPIKeyReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := optionalAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(req.GetTtlSeconds()) * time.Second
+
+	key, rawKey, err := s.authService.CreateAPIKey(ctx, req.GetToken(), req.GetName(), appID, req.GetScopes(), ttl)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAppNotFound)
+		}
+
+		if errors.Is(err, auth.ErrInvalidScope) {
+			return nil, status.Error(codes.InvalidArgument, ErrAPIKeyScopeNotAllowed)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.CreateAPIKeyResponse{
+		KeyId:     key.ID.String(),
+		ApiKey:    rawKey,
+		Prefix:    key.Prefix,
+		ExpiresAt: unixOrZero(key.ExpiresAt),
+	}, nil
+}
+
+func (s *ServerAPI) ListAPIKeys(ctx context.Context, req *ssov1.ListAPIKeysRequest) (*ssov1.ListAPIKeysResponse, error) {
+	if err := s.validateListAPIKeysReq(req); err != nil {
+		return nil, err
+	}
+
+	userID := uuid.Nil
+	if req.GetUserId() != emptyValue {
+		var err error
+		if userID, err = uuid.Parse(req.GetUserId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+		}
+	}
+
+	keys, err := s.authService.ListAPIKeys(ctx, req.GetToken(), userID)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, ErrAPIKeysPermission)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.ListAPIKeysResponse{ApiKeys: toAPIKeys(keys)}, nil
+}
+
+func (s *ServerAPI) RevokeAPIKey(ctx context.Context, req *ssov1.RevokeAPIKeyRequest) (*ssov1.RevokeAPIKeyResponse, error) {
+	if err := s.validateRevokeAPIKeyReq(req); err != nil {
+		return nil, err
+	}
+
+	// malformed IDs look like unknown keys
+	keyID, err := uuid.Parse(req.GetKeyId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ErrAPIKeyNotFound)
+	}
+
+	if err := s.authService.RevokeAPIKey(ctx, req.GetToken(), keyID); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			return nil, status.Error(codes.NotFound, ErrAPIKeyNotFound)
 		}
 
 		return nil, status.Error(codes.Internal, ErrInternal)
 	}
 
-	return &ssov1.LoginResponse{Token: token}, nil
+	return &ssov1.RevokeAPIKeyResponse{}, nil
 }
 
 func (s *ServerAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ssov1.IsAdminResponse, error) {
@@ -108,16 +1288,257 @@ func (s *ServerAPI) CreateApp(ctx context.Context, req *ssov1.CreateAppRequest)
 		return nil, err
 	}
 
-	appID, err := s.authService.CreateApp(ctx, req.GetName(), req.GetSecret())
+	orgID, err := optionalOrgID(req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := s.authService.CreateApp(ctx, req.GetToken(), models.App{
+		OrgID:                  orgID,
+		Name:                   req.GetName(),
+		TokenTTL:               time.Duration(req.GetTokenTtlSeconds()) * time.Second,
+		Claims:                 req.GetClaims(),
+		RedirectURIs:           req.GetRedirectUris(),
+		ClientType:             req.GetClientType(),
+		PostLogoutRedirectURIs: req.GetPostLogoutRedirectUris(),
+		Scopes:                 req.GetScopes(),
+		RequireVerifiedEmail:   req.GetRequireVerifiedEmail(),
+		PasswordPolicy:         toPasswordPolicy(req.GetPasswordPolicy()),
+		MagicLinkEnabled:       req.GetMagicLinkEnabled(),
+	})
 	if err != nil {
 		if errors.Is(err, auth.ErrAppExists) {
 			return nil, status.Error(codes.AlreadyExists, ErrAppExists)
 		}
 
+		if errors.Is(err, auth.ErrUnsupportedClaim) {
+			return nil, status.Error(codes.InvalidArgument, ErrUnsupportedClaim)
+		}
+
+		if errors.Is(err, auth.ErrInvalidPasswordPolicy) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidPasswordPolicy)
+		}
+
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, ErrOrganizationsPermission)
+		}
+
+		if errors.Is(err, auth.ErrOrganizationNotFound) {
+			return nil, status.Error(codes.NotFound, ErrOrganizationNotFound)
+		}
+
 		return nil, status.Error(codes.Internal, ErrInternal)
 	}
 
-	return &ssov1.CreateAppResponse{AppId: appID.String()}, nil
+	return &ssov1.CreateAppResponse{AppId: app.ID.String(), Secret: app.Secret}, nil
+}
+
+// parseRoleAssignment parses the IDs of the user and the role of AssignRole and UnassignRole requests
+func parseRoleAssignment(userID string, roleID string) (uuid.UUID, uuid.UUID, error) {
+	parsedUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+	}
+
+	parsedRoleID, err := uuid.Parse(roleID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.NotFound, ErrRoleNotFound)
+	}
+
+	return parsedUserID, parsedRoleID, nil
+}
+
+// parseGroupID parses the ID of the group, malformed IDs look like unknown groups
+func parseGroupID(groupID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(groupID)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.NotFound, ErrGroupNotFound)
+	}
+
+	return id, nil
+}
+
+// parseGroupMember parses the IDs of AddGroupMember and RemoveGroupMember requests, only one of the user and the subgroup is set
+func parseGroupMember(groupID string, userID string, subgroupID string) (uuid.UUID, uuid.UUID, uuid.UUID, error) {
+	parsedGroupID, err := parseGroupID(groupID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
+	if userID != emptyValue {
+		parsedUserID, err := uuid.Parse(userID)
+		if err != nil {
+			return uuid.Nil, uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, ErrInvalidUserID)
+		}
+
+		return parsedGroupID, parsedUserID, uuid.Nil, nil
+	}
+
+	parsedSubgroupID, err := parseGroupID(subgroupID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, uuid.Nil, err
+	}
+
+	return parsedGroupID, uuid.Nil, parsedSubgroupID, nil
+}
+
+// parseGroupRole parses the IDs of the group and the role of GrantGroupRole and RevokeGroupRole requests
+func parseGroupRole(groupID string, roleID string) (uuid.UUID, uuid.UUID, error) {
+	parsedGroupID, err := parseGroupID(groupID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	parsedRoleID, err := uuid.Parse(roleID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.NotFound, ErrRoleNotFound)
+	}
+
+	return parsedGroupID, parsedRoleID, nil
+}
+
+// groupError converts errors of the group management to statuses
+func groupError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, ErrInvalidToken)
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, ErrGroupsPermission)
+	case errors.Is(err, auth.ErrGroupNotFound):
+		return status.Error(codes.NotFound, ErrGroupNotFound)
+	case errors.Is(err, auth.ErrUserNotFound):
+		return status.Error(codes.NotFound, ErrUserNotFound)
+	case errors.Is(err, auth.ErrGroupCycle):
+		return status.Error(codes.FailedPrecondition, ErrGroupCycle)
+	}
+
+	return status.Error(codes.Internal, ErrInternal)
+}
+
+// groupRoleError converts errors of GrantGroupRole and RevokeGroupRole to statuses
+func groupRoleError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, ErrInvalidToken)
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, ErrGroupRolesPermission)
+	case errors.Is(err, auth.ErrGroupNotFound):
+		return status.Error(codes.NotFound, ErrGroupNotFound)
+	case errors.Is(err, auth.ErrRoleNotFound):
+		return status.Error(codes.NotFound, ErrRoleNotFound)
+	}
+
+	return status.Error(codes.Internal, ErrInternal)
+}
+
+// optionalOrgID parses the ID of the organization, uuid.Nil when not set
+func optionalOrgID(orgID string) (uuid.UUID, error) {
+	if orgID == emptyValue {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, ErrInvalidOrgID)
+	}
+
+	return id, nil
+}
+
+func toOrganizationMembers(members []models.OrganizationMember) []*ssov1.OrganizationMember {
+	resp := make([]*ssov1.OrganizationMember, 0, len(members))
+	for _, member := range members {
+		resp = append(resp, &ssov1.OrganizationMember{
+			UserId:   member.UserID.String(),
+			Email:    member.Email,
+			Role:     member.Role,
+			JoinedAt: member.CreatedAt.Unix(),
+		})
+	}
+
+	return resp
+}
+
+func toGroups(groups []models.Group) []*ssov1.Group {
+	resp := make([]*ssov1.Group, 0, len(groups))
+	for _, group := range groups {
+		resp = append(resp, &ssov1.Group{
+			GroupId:   group.ID.String(),
+			OrgId:     group.OrgID.String(),
+			Name:      group.Name,
+			CreatedAt: group.CreatedAt.Unix(),
+		})
+	}
+
+	return resp
+}
+
+func toGroupUsers(users []models.GroupUser) []*ssov1.GroupUser {
+	resp := make([]*ssov1.GroupUser, 0, len(users))
+	for _, user := range users {
+		resp = append(resp, &ssov1.GroupUser{
+			UserId: user.UserID.String(),
+			Email:  user.Email,
+		})
+	}
+
+	return resp
+}
+
+func toAPIKeys(keys []models.APIKey) []*ssov1.APIKey {
+	resp := make([]*ssov1.APIKey, 0, len(keys))
+	for _, key := range keys {
+		appID := emptyValue
+		if key.AppID != uuid.Nil {
+			appID = key.AppID.String()
+		}
+
+		resp = append(resp, &ssov1.APIKey{
+			KeyId:      key.ID.String(),
+			Name:       key.Name,
+			Prefix:     key.Prefix,
+			AppId:      appID,
+			Scopes:     key.Scopes,
+			CreatedAt:  key.CreatedAt.Unix(),
+			ExpiresAt:  unixOrZero(key.ExpiresAt),
+			LastUsedAt: unixOrZero(key.LastUsedAt),
+		})
+	}
+
+	return resp
+}
+
+// unixOrZero returns seconds since the epoch, 0 for the zero time meaning the time isn't set
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+func toRoles(roles []models.Role) []*ssov1.Role {
+	resp := make([]*ssov1.Role, 0, len(roles))
+	for _, role := range roles {
+		appID := emptyValue
+		if role.AppID != uuid.Nil {
+			appID = role.AppID.String()
+		}
+
+		resp = append(resp, &ssov1.Role{
+			RoleId:      role.ID.String(),
+			Name:        role.Name,
+			AppId:       appID,
+			Permissions: role.Permissions,
+			BuiltIn:     role.BuiltIn,
+		})
+	}
+
+	return resp
 }
 
 func (s *ServerAPI) App(ctx context.Context, req *ssov1.AppRequest) (*ssov1.AppResponse, error) {
@@ -125,7 +1546,12 @@ func (s *ServerAPI) App(ctx context.Context, req *ssov1.AppRequest) (*ssov1.AppR
 		return nil, err
 	}
 
-	app, err := s.authService.App(ctx, req.GetName())
+	orgID, err := optionalOrgID(req.GetOrgId())
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := s.authService.App(ctx, orgID, req.GetName())
 	if err != nil {
 		if errors.Is(err, auth.ErrAppNotFound) {
 			return nil, status.Error(codes.NotFound, ErrAppNotFound)
@@ -134,5 +1560,54 @@ func (s *ServerAPI) App(ctx context.Context, req *ssov1.AppRequest) (*ssov1.AppR
 		return nil, status.Error(codes.Internal, ErrInternal)
 	}
 
-	return &ssov1.AppResponse{AppId: app.ID.String(), Name: app.Name}, nil
+	return &ssov1.AppResponse{
+		AppId:                  app.ID.String(),
+		Name:                   app.Name,
+		TokenTtlSeconds:        int64(app.TokenTTL / time.Second),
+		Claims:                 app.Claims,
+		RedirectUris:           app.RedirectURIs,
+		ClientType:             app.ClientType,
+		PostLogoutRedirectUris: app.PostLogoutRedirectURIs,
+		Scopes:                 app.Scopes,
+		RequireVerifiedEmail:   app.RequireVerifiedEmail,
+		PasswordPolicy:         fromPasswordPolicy(app.PasswordPolicy),
+		MagicLinkEnabled:       app.MagicLinkEnabled,
+		OrgId:                  app.OrgID.String(),
+	}, nil
+}
+
+func (s *ServerAPI) Logout(ctx context.Context, req *ssov1.LogoutRequest) (*ssov1.LogoutResponse, error) {
+	if err := s.validateLogoutReq(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.Logout(ctx, req.GetToken(), req.GetRefreshToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		if errors.Is(err, auth.ErrInvalidRefreshToken) {
+			return nil, status.Error(codes.InvalidArgument, ErrInvalidRefreshToken)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.LogoutResponse{}, nil
+}
+
+func (s *ServerAPI) LogoutAll(ctx context.Context, req *ssov1.LogoutAllRequest) (*ssov1.LogoutAllResponse, error) {
+	if err := s.validateLogoutAllReq(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.LogoutAll(ctx, req.GetToken()); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidToken)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	return &ssov1.LogoutAllResponse{}, nil
 }
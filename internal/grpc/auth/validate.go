@@ -1,6 +1,12 @@
 package auth
 
 import (
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
 	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -55,8 +61,25 @@ func (s *ServerAPI) validateCreateAppReq(req *ssov1.CreateAppRequest) error {
 		return status.Error(codes.InvalidArgument, ErrAppNameRequired)
 	}
 
-	if req.GetSecret() == emptyValue {
-		return status.Error(codes.InvalidArgument, ErrAppSecretRequired)
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetTokenTtlSeconds() < 0 {
+		return status.Error(codes.InvalidArgument, ErrInvalidTokenTTL)
+	}
+
+	switch req.GetClientType() {
+	case emptyValue, models.ClientTypeConfidential, models.ClientTypePublic:
+	default:
+		return status.Error(codes.InvalidArgument, ErrInvalidClientType)
+	}
+
+	for _, redirectURI := range slices.Concat(req.GetRedirectUris(), req.GetPostLogoutRedirectUris()) {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != emptyValue {
+			return status.Error(codes.InvalidArgument, ErrInvalidRedirectURI)
+		}
 	}
 
 	return nil
@@ -69,3 +92,520 @@ func (s *ServerAPI) validateAppReq(req *ssov1.AppRequest) error {
 
 	return nil
 }
+
+func (s *ServerAPI) validateRefreshReq(req *ssov1.RefreshRequest) error {
+	if req.GetRefreshToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRefreshTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateLogoutReq(req *ssov1.LogoutRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateClientTokenReq(req *ssov1.ClientTokenRequest) error {
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	if req.GetSecret() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppSecretRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateApproveDeviceReq(req *ssov1.ApproveDeviceRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetUserCode() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrUserCodeRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateEnrollMFAReq(req *ssov1.EnrollMFARequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateConfirmMFAReq(req *ssov1.ConfirmMFARequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetCode() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrMFACodeRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateVerifyMFAReq(req *ssov1.VerifyMFARequest) error {
+	if req.GetMfaToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrMFATokenRequired)
+	}
+
+	if req.GetCode() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrMFACodeRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateRegenerateRecoveryCodesReq(req *ssov1.RegenerateRecoveryCodesRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateBeginPasskeyRegistrationReq(req *ssov1.BeginPasskeyRegistrationRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateFinishPasskeyRegistrationReq(req *ssov1.FinishPasskeyRegistrationRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetSessionId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrSessionIDRequired)
+	}
+
+	if req.GetCredential() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrCredentialRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateBeginPasskeyLoginReq(req *ssov1.BeginPasskeyLoginRequest) error {
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateFinishPasskeyLoginReq(req *ssov1.FinishPasskeyLoginRequest) error {
+	if req.GetSessionId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrSessionIDRequired)
+	}
+
+	if req.GetCredential() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrCredentialRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateVerifyEmailReq(req *ssov1.VerifyEmailRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateResendVerificationReq(req *ssov1.ResendVerificationRequest) error {
+	if req.GetEmail() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrEmailRequired)
+	}
+
+	if err := s.validator.Var(req.GetEmail(), "email"); err != nil {
+		return status.Error(codes.InvalidArgument, ErrInvalidEmail)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateRequestPasswordResetReq(req *ssov1.RequestPasswordResetRequest) error {
+	if req.GetEmail() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrEmailRequired)
+	}
+
+	if err := s.validator.Var(req.GetEmail(), "email"); err != nil {
+		return status.Error(codes.InvalidArgument, ErrInvalidEmail)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateResetPasswordReq(req *ssov1.ResetPasswordRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetPassword() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrPasswordRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateChangePasswordReq(req *ssov1.ChangePasswordRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetCurrentPassword() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrCurrentPasswordRequired)
+	}
+
+	if req.GetNewPassword() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrNewPasswordRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateRequestMagicLinkReq(req *ssov1.RequestMagicLinkRequest) error {
+	if req.GetEmail() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrEmailRequired)
+	}
+
+	if err := s.validator.Var(req.GetEmail(), "email"); err != nil {
+		return status.Error(codes.InvalidArgument, ErrInvalidEmail)
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	if req.GetRedirectUri() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRedirectURIRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateConsumeMagicLinkReq(req *ssov1.ConsumeMagicLinkRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateListSessionsReq(req *ssov1.ListSessionsRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateRevokeSessionReq(req *ssov1.RevokeSessionRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetSessionId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrSessionIDRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateObtainAppTokenReq(req *ssov1.ObtainAppTokenRequest) error {
+	if req.GetSsoSessionToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrSSOSessionTokenRequired)
+	}
+
+	if req.GetAppId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateLogoutAllReq(req *ssov1.LogoutAllRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateCreateRoleReq(req *ssov1.CreateRoleRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetName() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRoleNameRequired)
+	}
+
+	for _, permission := range req.GetPermissions() {
+		if !validPermission(permission) {
+			return status.Error(codes.InvalidArgument, ErrInvalidPermission)
+		}
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateDeleteRoleReq(req *ssov1.DeleteRoleRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetRoleId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRoleIDRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateListRolesReq(req *ssov1.ListRolesRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateAssignRoleReq(req *ssov1.AssignRoleRequest) error {
+	return validateRoleAssignment(req.GetToken(), req.GetUserId(), req.GetRoleId())
+}
+
+func (s *ServerAPI) validateUnassignRoleReq(req *ssov1.UnassignRoleRequest) error {
+	return validateRoleAssignment(req.GetToken(), req.GetUserId(), req.GetRoleId())
+}
+
+func (s *ServerAPI) validateListUserRolesReq(req *ssov1.ListUserRolesRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateHasPermissionReq(req *ssov1.HasPermissionRequest) error {
+	if req.GetUserId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrUserIDRequired)
+	}
+
+	if req.GetPermission() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrPermissionRequired)
+	}
+
+	if req.GetToken() == emptyValue && (req.GetClientId() == emptyValue || req.GetClientSecret() == emptyValue) {
+		return status.Error(codes.InvalidArgument, ErrCallerRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateCreateOrganizationReq(req *ssov1.CreateOrganizationRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetName() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrOrgNameRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateListOrganizationMembersReq(req *ssov1.ListOrganizationMembersRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateSetOrganizationMemberRoleReq(req *ssov1.SetOrganizationMemberRoleRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetOrgId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrOrgIDRequired)
+	}
+
+	if req.GetUserId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrUserIDRequired)
+	}
+
+	if req.GetRole() != models.OrganizationRoleMember && req.GetRole() != models.OrganizationRoleAdmin {
+		return status.Error(codes.InvalidArgument, ErrInvalidOrgRole)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateCreateGroupReq(req *ssov1.CreateGroupRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetName() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrGroupNameRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateDeleteGroupReq(req *ssov1.DeleteGroupRequest) error {
+	return validateGroupReq(req.GetToken(), req.GetGroupId())
+}
+
+func (s *ServerAPI) validateListGroupsReq(req *ssov1.ListGroupsRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateAddGroupMemberReq(req *ssov1.AddGroupMemberRequest) error {
+	return validateGroupMember(req.GetToken(), req.GetGroupId(), req.GetUserId(), req.GetMemberGroupId())
+}
+
+func (s *ServerAPI) validateRemoveGroupMemberReq(req *ssov1.RemoveGroupMemberRequest) error {
+	return validateGroupMember(req.GetToken(), req.GetGroupId(), req.GetUserId(), req.GetMemberGroupId())
+}
+
+func (s *ServerAPI) validateListGroupMembersReq(req *ssov1.ListGroupMembersRequest) error {
+	return validateGroupReq(req.GetToken(), req.GetGroupId())
+}
+
+func (s *ServerAPI) validateListUserGroupsReq(req *ssov1.ListUserGroupsRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateGrantGroupRoleReq(req *ssov1.GrantGroupRoleRequest) error {
+	return validateGroupRole(req.GetToken(), req.GetGroupId(), req.GetRoleId())
+}
+
+func (s *ServerAPI) validateRevokeGroupRoleReq(req *ssov1.RevokeGroupRoleRequest) error {
+	return validateGroupRole(req.GetToken(), req.GetGroupId(), req.GetRoleId())
+}
+
+func (s *ServerAPI) validateCreateAPIKeyReq(req *ssov1.CreateAPIKeyRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetName() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAPIKeyNameRequired)
+	}
+
+	for _, scope := range req.GetScopes() {
+		if !validPermission(scope) {
+			return status.Error(codes.InvalidArgument, ErrInvalidAPIKeyScope)
+		}
+	}
+
+	if req.GetTtlSeconds() < 0 {
+		return status.Error(codes.InvalidArgument, ErrInvalidAPIKeyTTL)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateListAPIKeysReq(req *ssov1.ListAPIKeysRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	return nil
+}
+
+func (s *ServerAPI) validateRevokeAPIKeyReq(req *ssov1.RevokeAPIKeyRequest) error {
+	if req.GetToken() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetKeyId() == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAPIKeyIDRequired)
+	}
+
+	return nil
+}
+
+func validateGroupReq(token string, groupID string) error {
+	if token == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if groupID == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrGroupIDRequired)
+	}
+
+	return nil
+}
+
+func validateGroupMember(token string, groupID string, userID string, subgroupID string) error {
+	if err := validateGroupReq(token, groupID); err != nil {
+		return err
+	}
+
+	if (userID == emptyValue) == (subgroupID == emptyValue) {
+		return status.Error(codes.InvalidArgument, ErrGroupMemberRequired)
+	}
+
+	return nil
+}
+
+func validateGroupRole(token string, groupID string, roleID string) error {
+	if err := validateGroupReq(token, groupID); err != nil {
+		return err
+	}
+
+	if roleID == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRoleIDRequired)
+	}
+
+	return nil
+}
+
+func validateRoleAssignment(token string, userID string, roleID string) error {
+	if token == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if userID == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrUserIDRequired)
+	}
+
+	if roleID == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrRoleIDRequired)
+	}
+
+	return nil
+}
+
+// validPermission rejects empty permissions and permissions with whitespace, as they can't be told apart in claims
+func validPermission(permission string) bool {
+	return permission != emptyValue && !strings.ContainsFunc(permission, unicode.IsSpace)
+}
@@ -0,0 +1,17 @@
+package authorization
+
+const (
+	ErrAppIDRequired         = "app_id is required"
+	ErrAppSecretRequired     = "app secret required"
+	ErrInvalidClient         = "invalid client credentials"
+	ErrUnauthorizedClient    = "public apps can't manage relationships"
+	ErrRelationshipsRequired = "writes or deletes are required"
+	ErrObjectRequired        = "object is required"
+	ErrSubjectRequired       = "subject is required"
+	ErrInvalidType           = "types must be lowercase identifiers"
+	ErrInvalidRelation       = "relations must be lowercase identifiers"
+	ErrInvalidObjectID       = "object ids must not be empty or contain whitespace"
+	ErrSelfImpliedRelation   = "relation can't be implied by itself"
+	ErrRelationTooDeep       = "relationship graph is too deep"
+	ErrInternal              = "internal error"
+)
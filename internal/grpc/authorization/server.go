@@ -0,0 +1,150 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AuthorizationService interface {
+	WriteRelationships(ctx context.Context, appID uuid.UUID, secret string, writes []models.Relationship, deletes []models.Relationship) error
+	DefineRelation(ctx context.Context, appID uuid.UUID, secret string, rewrite models.RelationRewrite) error
+	Check(ctx context.Context, appID uuid.UUID, secret string, object models.ObjectRef, relation string, subject models.SubjectRef) (bool, error)
+	ListObjects(ctx context.Context, appID uuid.UUID, secret string, objectType string, relation string, subject models.SubjectRef) ([]string, error)
+}
+
+type ServerAPI struct {
+	authorizationService AuthorizationService
+	ssov1.UnimplementedAuthorizationServer
+}
+
+func InitializeServerAPI(authorizationService AuthorizationService) *ServerAPI {
+	return &ServerAPI{authorizationService: authorizationService}
+}
+
+func (s *ServerAPI) WriteRelationships(ctx context.Context, req *ssov1.WriteRelationshipsRequest) (*ssov1.WriteRelationshipsResponse, error) {
+	if err := validateWriteRelationshipsReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := parseAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizationService.WriteRelationships(ctx, appID, req.GetSecret(), toRelationships(req.GetWrites()), toRelationships(req.GetDeletes()))
+	if err != nil {
+		return nil, authorizationError(err)
+	}
+
+	return &ssov1.WriteRelationshipsResponse{}, nil
+}
+
+func (s *ServerAPI) DefineRelation(ctx context.Context, req *ssov1.DefineRelationRequest) (*ssov1.DefineRelationResponse, error) {
+	if err := validateDefineRelationReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := parseAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.authorizationService.DefineRelation(ctx, appID, req.GetSecret(), models.RelationRewrite{
+		ObjectType: req.GetObjectType(),
+		Relation:   req.GetRelation(),
+		ImpliedBy:  req.GetImpliedBy(),
+	})
+	if err != nil {
+		return nil, authorizationError(err)
+	}
+
+	return &ssov1.DefineRelationResponse{}, nil
+}
+
+func (s *ServerAPI) Check(ctx context.Context, req *ssov1.CheckRequest) (*ssov1.CheckResponse, error) {
+	if err := validateCheckReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := parseAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.authorizationService.Check(ctx, appID, req.GetSecret(), toObjectRef(req.GetObject()), req.GetRelation(), toSubjectRef(req.GetSubject()))
+	if err != nil {
+		return nil, authorizationError(err)
+	}
+
+	return &ssov1.CheckResponse{Allowed: allowed}, nil
+}
+
+func (s *ServerAPI) ListObjects(ctx context.Context, req *ssov1.ListObjectsRequest) (*ssov1.ListObjectsResponse, error) {
+	if err := validateListObjectsReq(req); err != nil {
+		return nil, err
+	}
+
+	appID, err := parseAppID(req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	objectIDs, err := s.authorizationService.ListObjects(ctx, appID, req.GetSecret(), req.GetObjectType(), req.GetRelation(), toSubjectRef(req.GetSubject()))
+	if err != nil {
+		return nil, authorizationError(err)
+	}
+
+	return &ssov1.ListObjectsResponse{ObjectIds: objectIDs}, nil
+}
+
+// parseAppID parses the ID of the app authenticating itself, malformed IDs are invalid credentials
+func parseAppID(appID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(appID)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+	}
+
+	return id, nil
+}
+
+// authorizationError converts errors of the authorization service to statuses
+func authorizationError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		return status.Error(codes.Unauthenticated, ErrInvalidClient)
+	case errors.Is(err, auth.ErrUnauthorizedClient):
+		return status.Error(codes.PermissionDenied, ErrUnauthorizedClient)
+	case errors.Is(err, auth.ErrRelationTooDeep):
+		return status.Error(codes.FailedPrecondition, ErrRelationTooDeep)
+	}
+
+	return status.Error(codes.Internal, ErrInternal)
+}
+
+func toObjectRef(object *ssov1.ObjectReference) models.ObjectRef {
+	return models.ObjectRef{Type: object.GetType(), ID: object.GetId()}
+}
+
+func toSubjectRef(subject *ssov1.SubjectReference) models.SubjectRef {
+	return models.SubjectRef{Object: toObjectRef(subject.GetObject()), Relation: subject.GetRelation()}
+}
+
+func toRelationships(relationships []*ssov1.Relationship) []models.Relationship {
+	resp := make([]models.Relationship, 0, len(relationships))
+	for _, relationship := range relationships {
+		resp = append(resp, models.Relationship{
+			Object:   toObjectRef(relationship.GetObject()),
+			Relation: relationship.GetRelation(),
+			Subject:  toSubjectRef(relationship.GetSubject()),
+		})
+	}
+
+	return resp
+}
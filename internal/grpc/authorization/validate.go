@@ -0,0 +1,164 @@
+package authorization
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	emptyValue = ""
+	// maxNameLength limits types and relations
+	maxNameLength = 64
+	// maxObjectIDLength limits IDs of objects
+	maxObjectIDLength = 256
+)
+
+func validateWriteRelationshipsReq(req *ssov1.WriteRelationshipsRequest) error {
+	if err := validateClient(req.GetAppId(), req.GetSecret()); err != nil {
+		return err
+	}
+
+	if len(req.GetWrites()) == 0 && len(req.GetDeletes()) == 0 {
+		return status.Error(codes.InvalidArgument, ErrRelationshipsRequired)
+	}
+
+	for _, relationship := range slices.Concat(req.GetWrites(), req.GetDeletes()) {
+		if err := validateObject(relationship.GetObject()); err != nil {
+			return err
+		}
+
+		if !isName(relationship.GetRelation()) {
+			return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+		}
+
+		if err := validateSubject(relationship.GetSubject()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateDefineRelationReq(req *ssov1.DefineRelationRequest) error {
+	if err := validateClient(req.GetAppId(), req.GetSecret()); err != nil {
+		return err
+	}
+
+	if !isName(req.GetObjectType()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidType)
+	}
+
+	if !isName(req.GetRelation()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+	}
+
+	for _, impliedBy := range req.GetImpliedBy() {
+		if !isName(impliedBy) {
+			return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+		}
+
+		if impliedBy == req.GetRelation() {
+			return status.Error(codes.InvalidArgument, ErrSelfImpliedRelation)
+		}
+	}
+
+	return nil
+}
+
+func validateCheckReq(req *ssov1.CheckRequest) error {
+	if err := validateClient(req.GetAppId(), req.GetSecret()); err != nil {
+		return err
+	}
+
+	if err := validateObject(req.GetObject()); err != nil {
+		return err
+	}
+
+	if !isName(req.GetRelation()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+	}
+
+	return validateSubject(req.GetSubject())
+}
+
+func validateListObjectsReq(req *ssov1.ListObjectsRequest) error {
+	if err := validateClient(req.GetAppId(), req.GetSecret()); err != nil {
+		return err
+	}
+
+	if !isName(req.GetObjectType()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidType)
+	}
+
+	if !isName(req.GetRelation()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+	}
+
+	return validateSubject(req.GetSubject())
+}
+
+func validateClient(appID string, secret string) error {
+	if appID == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	if secret == emptyValue {
+		return status.Error(codes.InvalidArgument, ErrAppSecretRequired)
+	}
+
+	return nil
+}
+
+func validateObject(object *ssov1.ObjectReference) error {
+	if object == nil {
+		return status.Error(codes.InvalidArgument, ErrObjectRequired)
+	}
+
+	if !isName(object.GetType()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidType)
+	}
+
+	id := object.GetId()
+	if id == emptyValue || len(id) > maxObjectIDLength || strings.IndexFunc(id, unicode.IsSpace) >= 0 {
+		return status.Error(codes.InvalidArgument, ErrInvalidObjectID)
+	}
+
+	return nil
+}
+
+// validateSubject checks the subject object and the relation of the userset if it's set
+func validateSubject(subject *ssov1.SubjectReference) error {
+	if subject == nil || subject.GetObject() == nil {
+		return status.Error(codes.InvalidArgument, ErrSubjectRequired)
+	}
+
+	if err := validateObject(subject.GetObject()); err != nil {
+		return err
+	}
+
+	if subject.GetRelation() != emptyValue && !isName(subject.GetRelation()) {
+		return status.Error(codes.InvalidArgument, ErrInvalidRelation)
+	}
+
+	return nil
+}
+
+// isName reports whether the type or the relation is a lowercase identifier, e.g. document or can_edit
+func isName(name string) bool {
+	if name == emptyValue || len(name) > maxNameLength || name[0] < 'a' || name[0] > 'z' {
+		return false
+	}
+
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,10 @@
+package introspection
+
+const (
+	ErrTokenRequired      = "token is required"
+	ErrAppIDRequired      = "app_id is required"
+	ErrAppSecretRequired  = "app secret required"
+	ErrInvalidClient      = "invalid client credentials"
+	ErrUnauthorizedClient = "public apps can't introspect tokens"
+	ErrInternal           = "internal error"
+)
@@ -0,0 +1,102 @@
+package introspection
+
+import (
+	"context"
+	"errors"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type IntrospectionService interface {
+	Introspect(ctx context.Context, clientID uuid.UUID, clientSecret string, token string) (models.Introspection, error)
+}
+
+type ServerAPI struct {
+	introspectionService IntrospectionService
+	ssov1.UnimplementedIntrospectionServer
+}
+
+func InitializeServerAPI(introspectionService IntrospectionService) *ServerAPI {
+	return &ServerAPI{introspectionService: introspectionService}
+}
+
+// Introspect never fails for invalid tokens, they are reported as inactive.
+// The caller authenticates with the credentials of a confidential app (RFC 7662 section 2.1).
+func (s *ServerAPI) Introspect(ctx context.Context, req *ssov1.IntrospectRequest) (*ssov1.IntrospectResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, ErrTokenRequired)
+	}
+
+	if req.GetAppId() == "" {
+		return nil, status.Error(codes.InvalidArgument, ErrAppIDRequired)
+	}
+
+	if req.GetSecret() == "" {
+		return nil, status.Error(codes.InvalidArgument, ErrAppSecretRequired)
+	}
+
+	// malformed IDs are invalid credentials
+	clientID, err := uuid.Parse(req.GetAppId())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+	}
+
+	introspection, err := s.introspectionService.Introspect(ctx, clientID, req.GetSecret(), req.GetToken())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			return nil, status.Error(codes.Unauthenticated, ErrInvalidClient)
+		case errors.Is(err, auth.ErrUnauthorizedClient):
+			return nil, status.Error(codes.PermissionDenied, ErrUnauthorizedClient)
+		}
+
+		return nil, status.Error(codes.Internal, ErrInternal)
+	}
+
+	if !introspection.Active {
+		return &ssov1.IntrospectResponse{Active: false}, nil
+	}
+
+	// API keys of no app and keys which never expire have no app_id and exp
+	appID := ""
+	if introspection.AppID != uuid.Nil {
+		appID = introspection.AppID.String()
+	}
+
+	var exp int64
+	if !introspection.ExpiresAt.IsZero() {
+		exp = introspection.ExpiresAt.Unix()
+	}
+
+	// client credentials tokens have no user, they are issued to the app itself
+	if introspection.ClientID != uuid.Nil {
+		return &ssov1.IntrospectResponse{
+			Active:    true,
+			AppId:     appID,
+			ClientId:  introspection.ClientID.String(),
+			Exp:       exp,
+			Jti:       introspection.TokenID,
+			OrgId:     introspection.OrgID.String(),
+			Scope:     introspection.Scope,
+			TokenType: introspection.TokenType,
+		}, nil
+	}
+
+	return &ssov1.IntrospectResponse{
+		Active:    true,
+		UserId:    introspection.UserID.String(),
+		Email:     introspection.Email,
+		AppId:     appID,
+		Roles:     introspection.Roles,
+		Exp:       exp,
+		Jti:       introspection.TokenID,
+		OrgId:     introspection.OrgID.String(),
+		Scope:     introspection.Scope,
+		TokenType: introspection.TokenType,
+	}, nil
+}
@@ -0,0 +1,119 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+)
+
+type AccountService interface {
+	VerifyEmail(ctx context.Context, token string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+}
+
+// Handler serves the pages the links sent to users by email point to
+type Handler struct {
+	log            *slog.Logger
+	accountService AccountService
+}
+
+func New(log *slog.Logger, accountService AccountService) *Handler {
+	return &Handler{log: log, accountService: accountService}
+}
+
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /verify_email", h.VerifyEmail)
+	mux.HandleFunc("GET /reset_password", h.ResetPasswordForm)
+	mux.HandleFunc("POST /reset_password", h.ResetPassword)
+}
+
+// VerifyEmail verifies the email with the token of the verification link
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	const op = "http.account.VerifyEmail"
+	log := h.log.With(slog.String("op", op))
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Email verification", Text: "The link is malformed"})
+		return
+	}
+
+	if err := h.accountService.VerifyEmail(r.Context(), token); err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Email verification", Text: "The link is invalid or expired, request a new one"})
+			return
+		}
+
+		log.Error("failed to verify email", sl.Err(err))
+		render(w, log, http.StatusInternalServerError, messageTemplate, message{Title: "Email verification", Text: "Something went wrong, try again later"})
+		return
+	}
+
+	render(w, log, http.StatusOK, messageTemplate, message{Title: "Email verification", Text: "Your email is verified"})
+}
+
+// ResetPasswordForm shows the form setting the new password with the token of the reset link
+func (h *Handler) ResetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	const op = "http.account.ResetPasswordForm"
+	log := h.log.With(slog.String("op", op))
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Password reset", Text: "The link is malformed"})
+		return
+	}
+
+	render(w, log, http.StatusOK, resetPasswordTemplate, resetPasswordForm{Token: token})
+}
+
+// ResetPassword sets the new password submitted with the reset form
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	const op = "http.account.ResetPassword"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Password reset", Text: "The request is malformed"})
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	password := r.PostForm.Get("password")
+
+	if token == "" {
+		render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Password reset", Text: "The link is malformed"})
+		return
+	}
+
+	if password == "" {
+		render(w, log, http.StatusBadRequest, resetPasswordTemplate, resetPasswordForm{Token: token, Error: "Enter the new password"})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(r.Context(), token, password); err != nil {
+		if errors.Is(err, auth.ErrInvalidEmailToken) {
+			render(w, log, http.StatusBadRequest, messageTemplate, message{Title: "Password reset", Text: "The link is invalid or expired, request a new one"})
+			return
+		}
+
+		var policyErr *auth.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			form := resetPasswordForm{Token: token, Error: "Choose another password"}
+			for _, violation := range policyErr.Violations {
+				form.Violations = append(form.Violations, violation.Description)
+			}
+
+			render(w, log, http.StatusBadRequest, resetPasswordTemplate, form)
+			return
+		}
+
+		log.Error("failed to reset password", sl.Err(err))
+		render(w, log, http.StatusInternalServerError, messageTemplate, message{Title: "Password reset", Text: "Something went wrong, try again later"})
+		return
+	}
+
+	render(w, log, http.StatusOK, messageTemplate, message{Title: "Password reset", Text: "Your password is changed, sign in with the new one"})
+}
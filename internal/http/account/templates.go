@@ -0,0 +1,60 @@
+package account
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+)
+
+type message struct {
+	Title string
+	Text  string
+}
+
+var messageTemplate = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Text}}</p>
+</body>
+</html>
+`))
+
+type resetPasswordForm struct {
+	Token string
+	Error string
+	// Violations are the rules of the password policy the submitted password breaks
+	Violations []string
+}
+
+var resetPasswordTemplate = template.Must(template.New("resetPassword").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Password reset</title></head>
+<body>
+<h1>Set a new password</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Violations}}<ul role="alert">{{range .Violations}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<form method="post" action="/reset_password">
+<input type="hidden" name="token" value="{{.Token}}">
+<label>New password <input type="password" name="password" autocomplete="new-password" required></label>
+<button type="submit">Set password</button>
+</form>
+</body>
+</html>
+`))
+
+func render(w http.ResponseWriter, log *slog.Logger, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// the token stays in the page URL, it must not leak to other sites
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Error("failed to render page", sl.Err(err))
+	}
+}
@@ -0,0 +1,144 @@
+package oauth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	"github.com/google/uuid"
+)
+
+// deviceActionApprove is the value of the approve button, any other action denies the device
+const deviceActionApprove = "approve"
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorization issues the device and user codes (RFC 8628 section 3.1)
+func (h *Handler) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.DeviceAuthorization"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	clientID, clientSecret := clientCredentials(r)
+	appID, err := uuid.Parse(clientID)
+	if err != nil {
+		writeJSON(w, log, http.StatusUnauthorized, errorResponse{Error: errInvalidClient})
+		return
+	}
+
+	deviceCode, err := h.oauthService.DeviceAuthorization(r.Context(), appID, clientSecret, strings.Fields(r.PostForm.Get("scope")))
+	if err != nil {
+		h.tokenError(w, log, err)
+		return
+	}
+
+	verificationURI := h.issuer + "/device"
+
+	writeJSON(w, log, http.StatusOK, deviceAuthorizationResponse{
+		DeviceCode:              deviceCode.DeviceCode,
+		UserCode:                deviceCode.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + deviceCode.UserCode,
+		ExpiresIn:               int64(deviceCode.ExpiresIn.Seconds()),
+		Interval:                int64(deviceCode.Interval.Seconds()),
+	})
+}
+
+// DeviceForm shows the page the user enters the user code on
+func (h *Handler) DeviceForm(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.DeviceForm"
+	log := h.log.With(slog.String("op", op))
+
+	h.renderDevice(w, log, http.StatusOK, r.URL.Query().Get("user_code"), "", "")
+}
+
+// VerifyDevice authenticates the user and records the decision on the device request.
+// Users with MFA are asked for the code of the second factor first.
+func (h *Handler) VerifyDevice(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.VerifyDevice"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, log, http.StatusBadRequest, "malformed request")
+		return
+	}
+
+	userCode := r.PostForm.Get("user_code")
+	approve := r.PostForm.Get("action") == deviceActionApprove
+
+	if mfaToken := r.PostForm.Get("mfa_token"); mfaToken != "" {
+		h.verifyDeviceMFA(w, r, log, userCode, mfaToken, approve)
+		return
+	}
+
+	mfaToken, err := h.oauthService.VerifyDevice(withPeer(r), userCode, r.PostForm.Get("email"), r.PostForm.Get("password"), approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			h.renderDevice(w, log, http.StatusUnauthorized, userCode, "Invalid email or password", "")
+		case errors.Is(err, auth.ErrAccountIsLocked):
+			h.renderDevice(w, log, http.StatusUnauthorized, userCode, "Account is temporary locked, try again later", "")
+		case errors.Is(err, auth.ErrInvalidUserCode):
+			h.renderDevice(w, log, http.StatusBadRequest, userCode, "The code is invalid or expired", "")
+		default:
+			log.Error("failed to verify device", sl.Err(err))
+			h.renderError(w, log, http.StatusInternalServerError, "internal error")
+		}
+
+		return
+	}
+
+	if mfaToken != "" {
+		h.renderDeviceMFA(w, log, http.StatusOK, userCode, mfaToken, "", "")
+		return
+	}
+
+	h.renderDeviceDecision(w, log, approve)
+}
+
+// verifyDeviceMFA checks the code of the second factor and records the decision on the device request
+func (h *Handler) verifyDeviceMFA(w http.ResponseWriter, r *http.Request, log *slog.Logger, userCode, mfaToken string, approve bool) {
+	err := h.oauthService.VerifyDeviceMFA(withPeer(r), userCode, mfaToken, r.PostForm.Get("otp"), approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			h.renderDeviceMFA(w, log, http.StatusUnauthorized, userCode, mfaToken, "Invalid code", "")
+		case errors.Is(err, auth.ErrInvalidMFAToken):
+			h.renderDevice(w, log, http.StatusUnauthorized, userCode, "The sign in has expired, try again", "")
+		case errors.Is(err, auth.ErrAccountIsLocked):
+			h.renderDevice(w, log, http.StatusUnauthorized, userCode, "Account is temporary locked, try again later", "")
+		case errors.Is(err, auth.ErrInvalidUserCode):
+			h.renderDevice(w, log, http.StatusBadRequest, userCode, "The code is invalid or expired", "")
+		default:
+			log.Error("failed to verify device", sl.Err(err))
+			h.renderError(w, log, http.StatusInternalServerError, "internal error")
+		}
+
+		return
+	}
+
+	h.renderDeviceDecision(w, log, approve)
+}
+
+func (h *Handler) renderDeviceDecision(w http.ResponseWriter, log *slog.Logger, approve bool) {
+	if approve {
+		h.renderDevice(w, log, http.StatusOK, "", "", "The device is connected, you can return to it")
+		return
+	}
+
+	h.renderDevice(w, log, http.StatusOK, "", "", "The device was denied access")
+}
@@ -0,0 +1,254 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// OAuth 2.0 error codes (RFC 6749 section 4.1.2.1 and 5.2)
+const (
+	errInvalidRequest       = "invalid_request"
+	errInvalidClient        = "invalid_client"
+	errInvalidGrant         = "invalid_grant"
+	errInvalidScope         = "invalid_scope"
+	errUnauthorizedClient   = "unauthorized_client"
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+	errAccessDenied         = "access_denied"
+	errExpiredToken         = "expired_token"
+	errUnsupportedGrantType = "unsupported_grant_type"
+	errUnsupportedRespType  = "unsupported_response_type"
+	errServerError          = "server_error"
+)
+
+const (
+	responseTypeCode           = "code"
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+type OAuthService interface {
+	ValidateAuthorizationRequest(ctx context.Context, req models.AuthorizationRequest) (models.App, error)
+	Authorize(ctx context.Context, req models.AuthorizationRequest, email string, password string) (code string, mfaToken string, err error)
+	AuthorizeMFA(ctx context.Context, req models.AuthorizationRequest, mfaToken string, otp string) (code string, err error)
+	ExchangeCode(ctx context.Context, exchange models.CodeExchange) (tokens models.TokenPair, err error)
+	ClientToken(ctx context.Context, appID uuid.UUID, secret string, scopes []string) (tokens models.TokenPair, err error)
+	DeviceAuthorization(ctx context.Context, clientID uuid.UUID, clientSecret string, scopes []string) (models.DeviceCode, error)
+	VerifyDevice(ctx context.Context, userCode string, email string, password string, approve bool) (mfaToken string, err error)
+	VerifyDeviceMFA(ctx context.Context, userCode string, mfaToken string, otp string, approve bool) error
+	DeviceToken(ctx context.Context, clientID uuid.UUID, clientSecret string, deviceCode string) (tokens models.TokenPair, err error)
+	UserInfo(ctx context.Context, accessToken string) (models.UserInfo, error)
+	EndSession(ctx context.Context, req models.EndSession) (redirectURI string, err error)
+}
+
+type Handler struct {
+	log          *slog.Logger
+	issuer       string
+	oauthService OAuthService
+}
+
+func New(log *slog.Logger, issuer string, oauthService OAuthService) *Handler {
+	return &Handler{log: log, issuer: issuer, oauthService: oauthService}
+}
+
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /authorize", h.AuthorizeForm)
+	mux.HandleFunc("POST /authorize", h.Authorize)
+	mux.HandleFunc("POST /token", h.Token)
+	mux.HandleFunc("POST /device_authorization", h.DeviceAuthorization)
+	mux.HandleFunc("GET /device", h.DeviceForm)
+	mux.HandleFunc("POST /device", h.VerifyDevice)
+	mux.HandleFunc("GET /userinfo", h.UserInfo)
+	mux.HandleFunc("POST /userinfo", h.UserInfo)
+	mux.HandleFunc("GET /end_session", h.EndSession)
+	mux.HandleFunc("POST /end_session", h.EndSession)
+}
+
+// AuthorizeForm validates the authorization request and shows the login form
+func (h *Handler) AuthorizeForm(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.AuthorizeForm"
+	log := h.log.With(slog.String("op", op))
+
+	req, ok := h.authorizationRequest(w, r, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	if _, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), req); err != nil {
+		h.authorizationError(w, r, log, req, err)
+		return
+	}
+
+	h.renderLogin(w, log, http.StatusOK, req, "")
+}
+
+// Authorize authenticates the user and redirects back to the client with the authorization code.
+// Users with MFA are asked for the code of the second factor first.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.Authorize"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, log, http.StatusBadRequest, "malformed request")
+		return
+	}
+
+	req, ok := h.authorizationRequest(w, r, r.PostForm)
+	if !ok {
+		return
+	}
+
+	if mfaToken := r.PostForm.Get("mfa_token"); mfaToken != "" {
+		h.authorizeMFA(w, r, log, req, mfaToken)
+		return
+	}
+
+	code, mfaToken, err := h.oauthService.Authorize(withPeer(r), req, r.PostForm.Get("email"), r.PostForm.Get("password"))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			h.renderLogin(w, log, http.StatusUnauthorized, req, "Invalid email or password")
+		case errors.Is(err, auth.ErrAccountIsLocked):
+			h.renderLogin(w, log, http.StatusUnauthorized, req, "Account is temporary locked, try again later")
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			h.renderLogin(w, log, http.StatusForbidden, req, "Verify your email before signing in")
+		default:
+			h.authorizationError(w, r, log, req, err)
+		}
+
+		return
+	}
+
+	if mfaToken != "" {
+		h.renderMFA(w, log, http.StatusOK, req, mfaToken, "")
+		return
+	}
+
+	redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// authorizeMFA checks the code of the second factor and redirects back to the client with the authorization code
+func (h *Handler) authorizeMFA(w http.ResponseWriter, r *http.Request, log *slog.Logger, req models.AuthorizationRequest, mfaToken string) {
+	code, err := h.oauthService.AuthorizeMFA(withPeer(r), req, mfaToken, r.PostForm.Get("otp"))
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidMFACode):
+			h.renderMFA(w, log, http.StatusUnauthorized, req, mfaToken, "Invalid code")
+		case errors.Is(err, auth.ErrInvalidMFAToken):
+			h.renderLogin(w, log, http.StatusUnauthorized, req, "The sign in has expired, try again")
+		case errors.Is(err, auth.ErrAccountIsLocked):
+			h.renderLogin(w, log, http.StatusUnauthorized, req, "Account is temporary locked, try again later")
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			h.renderLogin(w, log, http.StatusForbidden, req, "Verify your email before signing in")
+		default:
+			h.authorizationError(w, r, log, req, err)
+		}
+
+		return
+	}
+
+	redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// authorizationRequest parses the request parameters, rendering an error page if they are malformed
+func (h *Handler) authorizationRequest(w http.ResponseWriter, r *http.Request, params url.Values) (models.AuthorizationRequest, bool) {
+	clientID, err := uuid.Parse(params.Get("client_id"))
+	if err != nil {
+		h.renderError(w, h.log, http.StatusBadRequest, "invalid client_id")
+		return models.AuthorizationRequest{}, false
+	}
+
+	req := models.AuthorizationRequest{
+		ClientID:            clientID,
+		RedirectURI:         params.Get("redirect_uri"),
+		State:               params.Get("state"),
+		CodeChallenge:       params.Get("code_challenge"),
+		CodeChallengeMethod: params.Get("code_challenge_method"),
+		Scopes:              strings.Fields(params.Get("scope")),
+		Nonce:               params.Get("nonce"),
+	}
+
+	if params.Get("response_type") != responseTypeCode {
+		if _, err := h.oauthService.ValidateAuthorizationRequest(r.Context(), req); err != nil {
+			h.authorizationError(w, r, h.log, req, err)
+			return models.AuthorizationRequest{}, false
+		}
+
+		redirect(w, r, req, url.Values{"error": {errUnsupportedRespType}})
+		return models.AuthorizationRequest{}, false
+	}
+
+	return req, true
+}
+
+// authorizationError shows errors of the client and the redirect URI to the user,
+// other errors are sent to the redirect URI which is known to be valid by then
+func (h *Handler) authorizationError(w http.ResponseWriter, r *http.Request, log *slog.Logger, req models.AuthorizationRequest, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		h.renderError(w, log, http.StatusBadRequest, "unknown client")
+	case errors.Is(err, auth.ErrInvalidRedirectURI):
+		h.renderError(w, log, http.StatusBadRequest, "redirect_uri is not registered for the client")
+	case errors.Is(err, auth.ErrPKCERequired):
+		redirect(w, r, req, url.Values{
+			"error":             {errInvalidRequest},
+			"error_description": {"code_challenge with S256 method is required"},
+		})
+	default:
+		log.Error("failed to authorize", sl.Err(err))
+		h.renderError(w, log, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// redirect sends the authorization response to the client redirect URI
+func redirect(w http.ResponseWriter, r *http.Request, req models.AuthorizationRequest, params url.Values) {
+	redirectURI, _ := url.Parse(req.RedirectURI)
+
+	query := redirectURI.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// withPeer puts the remote address to the context as the gRPC peer the service reads the client address from,
+// and the user agent as the gRPC metadata the service reads it from for sessions
+func withPeer(r *http.Request) context.Context {
+	ctx := metadata.NewIncomingContext(r.Context(), metadata.Pairs("user-agent", r.UserAgent()))
+
+	addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	if err != nil {
+		return ctx
+	}
+
+	return peer.NewContext(ctx, &peer.Peer{Addr: addr})
+}
+
+func writeJSON(w http.ResponseWriter, log *slog.Logger, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("failed to write response", sl.Err(err))
+	}
+}
@@ -0,0 +1,134 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	"github.com/google/uuid"
+)
+
+// Bearer token error codes (RFC 6750 section 3.1)
+const (
+	errInvalidToken      = "invalid_token"
+	errInsufficientScope = "insufficient_scope"
+)
+
+type userInfoResponse struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// UserInfo returns claims about the owner of the access token (OpenID Connect Core section 5.3)
+func (h *Handler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.UserInfo"
+	log := h.log.With(slog.String("op", op))
+
+	accessToken, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sso"`)
+		writeJSON(w, log, http.StatusUnauthorized, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	userInfo, err := h.oauthService.UserInfo(r.Context(), accessToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q`, errInvalidToken))
+			writeJSON(w, log, http.StatusUnauthorized, errorResponse{Error: errInvalidToken})
+		case errors.Is(err, auth.ErrInsufficientScope):
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, scope=%q`, errInsufficientScope, models.ScopeOpenID))
+			writeJSON(w, log, http.StatusForbidden, errorResponse{Error: errInsufficientScope})
+		default:
+			log.Error("failed to get user info", sl.Err(err))
+			writeJSON(w, log, http.StatusInternalServerError, errorResponse{Error: errServerError})
+		}
+
+		return
+	}
+
+	writeJSON(w, log, http.StatusOK, userInfoResponse{
+		Subject:           userInfo.Subject.String(),
+		Email:             userInfo.Email,
+		PreferredUsername: userInfo.PreferredUsername,
+	})
+}
+
+// EndSession logs the user out on behalf of the client (OpenID Connect RP-Initiated Logout)
+func (h *Handler) EndSession(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.EndSession"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, log, http.StatusBadRequest, "malformed request")
+		return
+	}
+
+	req := models.EndSession{
+		IDTokenHint:           r.Form.Get("id_token_hint"),
+		PostLogoutRedirectURI: r.Form.Get("post_logout_redirect_uri"),
+	}
+
+	if clientID := r.Form.Get("client_id"); clientID != "" {
+		id, err := uuid.Parse(clientID)
+		if err != nil {
+			h.renderError(w, log, http.StatusBadRequest, "invalid client_id")
+			return
+		}
+		req.ClientID = id
+	}
+
+	redirectURI, err := h.oauthService.EndSession(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			h.renderError(w, log, http.StatusBadRequest, "invalid id_token_hint")
+		case errors.Is(err, auth.ErrInvalidClient):
+			h.renderError(w, log, http.StatusBadRequest, "unknown client")
+		case errors.Is(err, auth.ErrInvalidRedirectURI):
+			h.renderError(w, log, http.StatusBadRequest, "post_logout_redirect_uri is not registered for the client")
+		default:
+			log.Error("failed to end session", sl.Err(err))
+			h.renderError(w, log, http.StatusInternalServerError, "internal error")
+		}
+
+		return
+	}
+
+	if redirectURI == "" {
+		h.renderSignedOut(w, log)
+		return
+	}
+
+	location, _ := url.Parse(redirectURI)
+	if state := r.Form.Get("state"); state != "" {
+		query := location.Query()
+		query.Set("state", state)
+		location.RawQuery = query.Encode()
+	}
+
+	http.Redirect(w, r, location.String(), http.StatusFound)
+}
+
+// bearerToken reads the access token from the Authorization header or, for POST, from the form
+func bearerToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, tokenTypeBearer+" ")
+		return token, ok && token != ""
+	}
+
+	if r.Method == http.MethodPost {
+		token := r.PostFormValue("access_token")
+		return token, token != ""
+	}
+
+	return "", false
+}
@@ -0,0 +1,125 @@
+package oauth
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+)
+
+var loginTemplate = template.Must(template.New("login").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="/authorize">
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="scope" value="{{join .Request.Scopes " "}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+{{if .MFAToken}}<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label>Code from the authenticator app or a recovery code <input type="text" name="otp" autocomplete="one-time-code" required></label>
+<button type="submit">Verify</button>
+{{else}}<label>Email <input type="email" name="email" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit">Sign in</button>{{end}}
+</form>
+</body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization error</title></head>
+<body>
+<h1>Authorization error</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+func (h *Handler) renderLogin(w http.ResponseWriter, log *slog.Logger, status int, req models.AuthorizationRequest, errorMessage string) {
+	h.renderMFA(w, log, status, req, "", errorMessage)
+}
+
+// renderMFA shows the login form asking for the code of the second factor
+func (h *Handler) renderMFA(w http.ResponseWriter, log *slog.Logger, status int, req models.AuthorizationRequest, mfaToken, errorMessage string) {
+	render(w, log, status, loginTemplate, struct {
+		Request  models.AuthorizationRequest
+		MFAToken string
+		Error    string
+	}{Request: req, MFAToken: mfaToken, Error: errorMessage})
+}
+
+var deviceTemplate = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<h1>Connect a device</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Done}}<p>{{.Done}}</p>{{else}}
+<form method="post" action="/device">
+{{if .MFAToken}}<input type="hidden" name="user_code" value="{{.UserCode}}">
+<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label>Code from the authenticator app or a recovery code <input type="text" name="otp" autocomplete="one-time-code" required></label>
+{{else}}<label>Code shown on the device <input type="text" name="user_code" value="{{.UserCode}}" required></label>
+<label>Email <input type="email" name="email" required></label>
+<label>Password <input type="password" name="password" required></label>{{end}}
+<button type="submit" name="action" value="approve">Approve</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+var signedOutTemplate = template.Must(template.New("signedOut").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Signed out</title></head>
+<body>
+<h1>You have been signed out</h1>
+</body>
+</html>
+`))
+
+func (h *Handler) renderSignedOut(w http.ResponseWriter, log *slog.Logger) {
+	render(w, log, http.StatusOK, signedOutTemplate, nil)
+}
+
+func (h *Handler) renderDevice(w http.ResponseWriter, log *slog.Logger, status int, userCode, errorMessage, done string) {
+	h.renderDeviceMFA(w, log, status, userCode, "", errorMessage, done)
+}
+
+// renderDeviceMFA shows the device page asking for the code of the second factor
+func (h *Handler) renderDeviceMFA(w http.ResponseWriter, log *slog.Logger, status int, userCode, mfaToken, errorMessage, done string) {
+	render(w, log, status, deviceTemplate, struct {
+		UserCode string
+		MFAToken string
+		Error    string
+		Done     string
+	}{UserCode: userCode, MFAToken: mfaToken, Error: errorMessage, Done: done})
+}
+
+func (h *Handler) renderError(w http.ResponseWriter, log *slog.Logger, status int, message string) {
+	render(w, log, status, errorTemplate, message)
+}
+
+func render(w http.ResponseWriter, log *slog.Logger, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// the login form must not be framed by other sites
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Error("failed to render page", sl.Err(err))
+	}
+}
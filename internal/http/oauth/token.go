@@ -0,0 +1,136 @@
+package oauth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/services/auth"
+	"github.com/google/uuid"
+)
+
+const tokenTypeBearer = "Bearer"
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Token issues tokens for the authorization code (RFC 6749 section 4.1.3),
+// for the client itself (RFC 6749 section 4.4) and for the device code (RFC 8628 section 3.4)
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	const op = "http.oauth.Token"
+	log := h.log.With(slog.String("op", op))
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidRequest})
+		return
+	}
+
+	clientID, clientSecret := clientCredentials(r)
+	appID, err := uuid.Parse(clientID)
+	if err != nil {
+		writeJSON(w, log, http.StatusUnauthorized, errorResponse{Error: errInvalidClient})
+		return
+	}
+
+	var tokens models.TokenPair
+
+	switch r.PostForm.Get("grant_type") {
+	case grantTypeAuthorizationCode:
+		code := r.PostForm.Get("code")
+		if code == "" {
+			writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidRequest, ErrorDescription: "code is required"})
+			return
+		}
+
+		tokens, err = h.oauthService.ExchangeCode(withPeer(r), models.CodeExchange{
+			Code:         code,
+			ClientID:     appID,
+			ClientSecret: clientSecret,
+			RedirectURI:  r.PostForm.Get("redirect_uri"),
+			CodeVerifier: r.PostForm.Get("code_verifier"),
+		})
+	case grantTypeClientCredentials:
+		tokens, err = h.oauthService.ClientToken(r.Context(), appID, clientSecret, strings.Fields(r.PostForm.Get("scope")))
+	case grantTypeDeviceCode:
+		deviceCode := r.PostForm.Get("device_code")
+		if deviceCode == "" {
+			writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidRequest, ErrorDescription: "device_code is required"})
+			return
+		}
+
+		tokens, err = h.oauthService.DeviceToken(withPeer(r), appID, clientSecret, deviceCode)
+	default:
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errUnsupportedGrantType})
+		return
+	}
+
+	if err != nil {
+		h.tokenError(w, log, err)
+		return
+	}
+
+	writeJSON(w, log, http.StatusOK, newTokenResponse(tokens))
+}
+
+func (h *Handler) tokenError(w http.ResponseWriter, log *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		w.Header().Set("WWW-Authenticate", `Basic realm="sso"`)
+		writeJSON(w, log, http.StatusUnauthorized, errorResponse{Error: errInvalidClient})
+	case errors.Is(err, auth.ErrInvalidGrant):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidGrant})
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidGrant, ErrorDescription: "email is not verified"})
+	case errors.Is(err, auth.ErrInvalidScope):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errInvalidScope})
+	case errors.Is(err, auth.ErrUnauthorizedClient):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errUnauthorizedClient})
+	case errors.Is(err, auth.ErrAuthPending):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errAuthorizationPending})
+	case errors.Is(err, auth.ErrSlowDown):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errSlowDown})
+	case errors.Is(err, auth.ErrAccessDenied):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errAccessDenied})
+	case errors.Is(err, auth.ErrExpiredToken):
+		writeJSON(w, log, http.StatusBadRequest, errorResponse{Error: errExpiredToken})
+	case errors.Is(err, auth.ErrRateLimited):
+		writeJSON(w, log, http.StatusTooManyRequests, errorResponse{Error: errInvalidRequest, ErrorDescription: "too many requests"})
+	default:
+		log.Error("failed to issue tokens", sl.Err(err))
+		writeJSON(w, log, http.StatusInternalServerError, errorResponse{Error: errServerError})
+	}
+}
+
+func newTokenResponse(tokens models.TokenPair) tokenResponse {
+	return tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    tokenTypeBearer,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		Scope:        tokens.Scope,
+	}
+}
+
+// clientCredentials reads the client credentials from HTTP Basic auth or from the form
+func clientCredentials(r *http.Request) (string, string) {
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		return clientID, clientSecret
+	}
+
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+}
@@ -0,0 +1,60 @@
+package wellknown
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwk"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+)
+
+const jwksMaxAge = "max-age=300"
+
+type KeyProvider interface {
+	PublicKeys(ctx context.Context) []models.SigningKey
+	Algorithm() string
+}
+
+type Handler struct {
+	log         *slog.Logger
+	issuer      string
+	keyProvider KeyProvider
+}
+
+func New(log *slog.Logger, issuer string, keyProvider KeyProvider) *Handler {
+	return &Handler{log: log, issuer: issuer, keyProvider: keyProvider}
+}
+
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /.well-known/jwks.json", h.JWKS)
+	mux.HandleFunc("GET /.well-known/openid-configuration", h.OpenIDConfiguration)
+}
+
+// JWKS publishes public keys resource servers verify tokens with
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	const op = "http.wellknown.JWKS"
+	log := h.log.With(slog.String("op", op))
+
+	publicKeys := h.keyProvider.PublicKeys(r.Context())
+
+	set := jwk.Set{Keys: make([]jwk.Key, 0, len(publicKeys))}
+	for _, publicKey := range publicKeys {
+		key, err := jwk.FromPublicKey(publicKey.ID, publicKey.Algorithm, publicKey.PublicKey)
+		if err != nil {
+			log.Error("failed to convert public key", slog.String("kid", publicKey.ID), sl.Err(err))
+			continue
+		}
+
+		set.Keys = append(set.Keys, key)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", jwksMaxAge)
+
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		log.Error("failed to write jwks", sl.Err(err))
+	}
+}
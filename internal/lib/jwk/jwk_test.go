@@ -0,0 +1,136 @@
+package jwk_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BariVakhidov/sso/internal/lib/jwk"
+)
+
+func TestFromPublicKey_RSA(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	key, err := jwk.FromPublicKey("kid", "RS256", &privateKey.PublicKey)
+	require.NoError(t, err)
+
+	assert.Equal(t, jwk.Key{Kty: "RSA", Kid: "kid", Use: "sig", Alg: "RS256", N: key.N, E: "AQAB"}, key)
+
+	n := new(big.Int).SetBytes(decode(t, key.N))
+	assert.Equal(t, 0, privateKey.N.Cmp(n))
+}
+
+func TestFromPublicKey_EC(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key, err := jwk.FromPublicKey("kid", "ES256", &privateKey.PublicKey)
+	require.NoError(t, err)
+
+	assert.Equal(t, "EC", key.Kty)
+	assert.Equal(t, "P-256", key.Crv)
+	assert.Equal(t, "ES256", key.Alg)
+
+	// coordinates are padded to the curve size
+	x, y := decode(t, key.X), decode(t, key.Y)
+	require.Len(t, x, 32)
+	require.Len(t, y, 32)
+
+	publicKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	assert.True(t, publicKey.Equal(&privateKey.PublicKey))
+}
+
+func TestFromPublicKey_Ed25519(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key, err := jwk.FromPublicKey("kid", "EdDSA", publicKey)
+	require.NoError(t, err)
+
+	assert.Equal(t, "OKP", key.Kty)
+	assert.Equal(t, "Ed25519", key.Crv)
+	assert.Empty(t, key.Y)
+	assert.True(t, bytes.Equal(publicKey, decode(t, key.X)))
+}
+
+func TestKey_PublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		publicKey interface{ Equal(crypto.PublicKey) bool }
+	}{
+		{name: "RSA", algorithm: "RS256", publicKey: &rsaKey.PublicKey},
+		{name: "EC", algorithm: "ES256", publicKey: &ecKey.PublicKey},
+		{name: "Ed25519", algorithm: "EdDSA", publicKey: edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := jwk.FromPublicKey("kid", tt.algorithm, tt.publicKey)
+			require.NoError(t, err)
+
+			publicKey, err := key.PublicKey()
+			require.NoError(t, err)
+			assert.True(t, tt.publicKey.Equal(publicKey))
+		})
+	}
+}
+
+func TestKey_PublicKey_Invalid(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	key, err := jwk.FromPublicKey("kid", "ES256", &ecKey.PublicKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  jwk.Key
+	}{
+		{name: "Point not on the curve", key: jwk.Key{Kty: "EC", Crv: "P-256", X: key.X, Y: key.X}},
+		{name: "Short Ed25519 key", key: jwk.Key{Kty: "OKP", Crv: "Ed25519", X: key.X[:10]}},
+		{name: "Unsupported curve", key: jwk.Key{Kty: "EC", Crv: "P-384", X: key.X, Y: key.Y}},
+		{name: "Unsupported type", key: jwk.Key{Kty: "oct"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.key.PublicKey()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestFromPublicKey_Unsupported(t *testing.T) {
+	_, err := jwk.FromPublicKey("kid", "HS256", []byte("secret"))
+	require.Error(t, err)
+}
+
+func decode(t *testing.T, value string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	require.NoError(t, err)
+
+	return b
+}
@@ -1,27 +1,462 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	"github.com/BariVakhidov/sso/internal/domain/models"
 )
 
-// NewToken generates new JWT token and returns tokenString and err
-func NewToken(user *models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+// Claims are the claims of a token issued by NewToken or NewClientToken
+type Claims struct {
+	ID     string
+	Issuer string
+	// UserID is uuid.Nil for tokens of the app itself
+	UserID uuid.UUID
+	Email  string
+	AppID  uuid.UUID
+	// ClientID is set only for tokens of the app itself issued by NewClientToken, it equals AppID
+	ClientID uuid.UUID
+	// OrgID is the organization of the user, tokens issued before organizations belong to the default one
+	OrgID   uuid.UUID
+	Version int64
+	// Scope is the space separated list of OAuth scopes granted to the token
+	Scope string
+	// SessionID is the session the token was issued in, empty for tokens issued before sessions were tracked
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// IDTokenOptions are the settings of an OpenID Connect ID token issued by NewIDToken
+type IDTokenOptions struct {
+	Issuer string
+	TTL    time.Duration
+	Key    models.SigningKey
+	// SessionID is put into the sid claim and identifies the session for logout
+	SessionID string
+	Nonce     string
+	AuthTime  time.Time
+	AMR       []string
+	ACR       string
+	// ExtraClaims are the claims of the granted scopes, registered claims can't be overridden
+	ExtraClaims map[string]any
+}
+
+// ClientTokenOptions are the settings of an app's own token issued by NewClientToken
+type ClientTokenOptions struct {
+	Issuer string
+	TTL    time.Duration
+	Key    models.SigningKey
+	// Scope is the space separated list of the app scopes granted to the token
+	Scope string
+}
+
+// IDTokenClaims are the claims of an ID token issued by NewIDToken
+type IDTokenClaims struct {
+	Issuer    string
+	Subject   uuid.UUID
+	Audience  uuid.UUID
+	SessionID string
+	ExpiresAt time.Time
+}
+
+// TokenOptions are the settings of a token issued by NewToken
+type TokenOptions struct {
+	Issuer string
+	TTL    time.Duration
+	// Version is the user's token version at the time of issuing, bumping it invalidates the token
+	Version int64
+	// SessionID is put into the sid claim, revoking the session invalidates the token
+	SessionID string
+	// Key signs the token, HS256 tokens are signed with its Secret
+	Key models.SigningKey
+	// ExtraClaims are added to the token as is, registered claims can't be overridden
+	ExtraClaims map[string]any
+}
+
+// ActionTokenOptions are the settings of a single-purpose token sent to the user by email, e.g. in a verification link
+type ActionTokenOptions struct {
+	Issuer string
+	// Purpose is put into the aud claim, the token is accepted only for the purpose it was issued for
+	Purpose string
+	TTL     time.Duration
+	// Key is the HMAC key the token is signed with, it isn't shared with apps
+	Key []byte
+	// State is the hash of the user state the token is bound to, the token is void once the state changes
+	State string
+}
+
+// ActionTokenClaims are the claims of a token issued by NewActionToken
+type ActionTokenClaims struct {
+	UserID    uuid.UUID
+	State     string
+	ExpiresAt time.Time
+}
+
+// VerificationKeys resolves keys to verify token signatures with
+type VerificationKeys struct {
+	// Secret returns the server-held HMAC key of HS256 tokens, nil rejects HS256 tokens
+	Secret func() ([]byte, error)
+	// PublicKey returns the asymmetric key with the kid from the token header, nil rejects asymmetric tokens
+	PublicKey func(keyID string) (models.SigningKey, error)
+}
+
+// NewToken generates new JWT token with RFC 7519 registered claims and returns tokenString and err.
+// HS256 tokens are signed with the secret of opts.Key, others with its private part.
+func NewToken(user *models.User, app models.App, opts TokenOptions) (string, error) {
+	method := jwt.GetSigningMethod(opts.Key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, opts.Key.Algorithm)
+	}
+
+	token := jwt.New(method)
+	now := time.Now()
 
 	claims := token.Claims.(jwt.MapClaims)
+	for name, value := range opts.ExtraClaims {
+		claims[name] = value
+	}
+
+	claims["iss"] = opts.Issuer
+	claims["sub"] = user.ID
+	claims["aud"] = app.ID
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(opts.TTL).Unix()
+	claims["jti"] = uuid.NewString()
 	claims["email"] = user.Email
 	claims["uid"] = user.ID
-	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.ID
+	claims["org_id"] = user.OrgID
+	claims["ver"] = opts.Version
+	if opts.SessionID != "" {
+		claims["sid"] = opts.SessionID
+	}
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	return sign(token, opts.Key)
+}
+
+// NewIDToken generates new OpenID Connect ID token for the app the user signed in to
+func NewIDToken(user *models.User, app models.App, opts IDTokenOptions) (string, error) {
+	method := jwt.GetSigningMethod(opts.Key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, opts.Key.Algorithm)
+	}
+
+	token := jwt.New(method)
+	now := time.Now()
+
+	claims := token.Claims.(jwt.MapClaims)
+	for name, value := range opts.ExtraClaims {
+		claims[name] = value
+	}
+
+	claims["iss"] = opts.Issuer
+	claims["sub"] = user.ID
+	claims["aud"] = app.ID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(opts.TTL).Unix()
+	claims["auth_time"] = opts.AuthTime.Unix()
+	claims["sid"] = opts.SessionID
+	claims["amr"] = opts.AMR
+	claims["acr"] = opts.ACR
+	if opts.Nonce != "" {
+		claims["nonce"] = opts.Nonce
+	}
+
+	return sign(token, opts.Key)
+}
+
+// NewClientToken generates new JWT token for the app itself, sub and client_id are the app ID
+func NewClientToken(app models.App, opts ClientTokenOptions) (string, error) {
+	method := jwt.GetSigningMethod(opts.Key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, opts.Key.Algorithm)
+	}
+
+	token := jwt.New(method)
+	now := time.Now()
+
+	claims := token.Claims.(jwt.MapClaims)
+	claims["iss"] = opts.Issuer
+	claims["sub"] = app.ID
+	claims["aud"] = app.ID
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(opts.TTL).Unix()
+	claims["jti"] = uuid.NewString()
+	claims["client_id"] = app.ID
+	claims["org_id"] = app.OrgID
+	if opts.Scope != "" {
+		claims["scope"] = opts.Scope
+	}
+
+	return sign(token, opts.Key)
+}
+
+// NewActionToken generates new HS256 JWT token letting the user perform the action of opts.Purpose
+func NewActionToken(userID uuid.UUID, opts ActionTokenOptions) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	now := time.Now()
+
+	claims := token.Claims.(jwt.MapClaims)
+	claims["iss"] = opts.Issuer
+	claims["sub"] = userID
+	claims["aud"] = opts.Purpose
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(opts.TTL).Unix()
+	claims["jti"] = uuid.NewString()
+	claims["state"] = opts.State
+
+	return token.SignedString(opts.Key)
+}
+
+// sign signs HS256 tokens with the secret and others with the private part of the key.
+// App secrets are never used, apps choose them and could forge tokens of each other.
+func sign(token *jwt.Token, key models.SigningKey) (string, error) {
+	var signingKey interface{} = key.PrivateKey
+	if key.Algorithm == AlgorithmHS256 {
+		if len(key.Secret) == 0 {
+			return "", fmt.Errorf("%w: %s key has no secret", ErrUnsupportedAlgorithm, AlgorithmHS256)
+		}
+
+		signingKey = key.Secret
+	} else {
+		token.Header["kid"] = key.ID
+	}
+
+	tokenString, err := token.SignedString(signingKey)
 	if err != nil {
 		return "", err
 	}
 
 	return tokenString, nil
 }
+
+// ParseToken verifies the token signature, issuer and expiration and returns its claims.
+// Errors caused by the token itself wrap ErrInvalidToken, errors of keys are returned as is.
+func ParseToken(tokenString string, issuer string, keys VerificationKeys) (Claims, error) {
+	const op = "jwt.ParseToken"
+
+	token, err := jwt.Parse(
+		tokenString,
+		keys.keyFunc,
+		jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(issuer),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenUnverifiable) {
+			return Claims{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return Claims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	claims, err := toClaims(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return claims, nil
+}
+
+// ParseIDToken verifies the signature and the issuer of the ID token issued by NewIDToken.
+// Expired tokens are accepted as ID tokens are passed back only as hints, e.g. on logout.
+func ParseIDToken(tokenString string, issuer string, keys VerificationKeys) (IDTokenClaims, error) {
+	const op = "jwt.ParseIDToken"
+
+	token, err := jwt.Parse(
+		tokenString,
+		keys.keyFunc,
+		jwt.WithValidMethods([]string{AlgorithmHS256, AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA}),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenUnverifiable) {
+			return IDTokenClaims{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return IDTokenClaims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	mapClaims := token.Claims.(jwt.MapClaims)
+
+	var claims IDTokenClaims
+	if claims.Issuer, _ = mapClaims["iss"].(string); claims.Issuer != issuer {
+		return IDTokenClaims{}, fmt.Errorf("%s: %w: issuer mismatch", op, ErrInvalidToken)
+	}
+
+	if claims.SessionID, _ = mapClaims["sid"].(string); claims.SessionID == "" {
+		return IDTokenClaims{}, fmt.Errorf("%s: %w: sid claim is missing", op, ErrInvalidToken)
+	}
+
+	if claims.Subject, err = uuidClaim(mapClaims, "sub"); err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.Audience, err = uuidClaim(mapClaims, "aud"); err != nil {
+		return IDTokenClaims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if exp, err := mapClaims.GetExpirationTime(); err == nil && exp != nil {
+		claims.ExpiresAt = exp.Time
+	}
+
+	return claims, nil
+}
+
+// ParseActionToken verifies the signature, issuer, purpose and expiration of the token issued by NewActionToken
+func ParseActionToken(tokenString string, issuer string, purpose string, key []byte) (ActionTokenClaims, error) {
+	const op = "jwt.ParseActionToken"
+
+	token, err := jwt.Parse(
+		tokenString,
+		func(*jwt.Token) (interface{}, error) { return key, nil },
+		jwt.WithValidMethods([]string{AlgorithmHS256}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(purpose),
+	)
+	if err != nil {
+		return ActionTokenClaims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+
+	mapClaims := token.Claims.(jwt.MapClaims)
+
+	var claims ActionTokenClaims
+	if claims.UserID, err = uuidClaim(mapClaims, "sub"); err != nil {
+		return ActionTokenClaims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims.State, _ = mapClaims["state"].(string)
+
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil {
+		return ActionTokenClaims{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidToken, err)
+	}
+	claims.ExpiresAt = exp.Time
+
+	return claims, nil
+}
+
+func (k VerificationKeys) keyFunc(t *jwt.Token) (interface{}, error) {
+	if t.Method.Alg() == AlgorithmHS256 {
+		if k.Secret == nil {
+			return nil, fmt.Errorf("%w: %s tokens are not accepted", ErrInvalidToken, AlgorithmHS256)
+		}
+
+		return k.Secret()
+	}
+
+	if k.PublicKey == nil {
+		return nil, fmt.Errorf("%w: %s tokens are not accepted", ErrInvalidToken, t.Method.Alg())
+	}
+
+	keyID, ok := t.Header["kid"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: kid header is missing", ErrInvalidToken)
+	}
+
+	key, err := k.PublicKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the key decides the algorithm, not the token header
+	if key.Algorithm != t.Method.Alg() {
+		return nil, fmt.Errorf("%w: algorithm does not match the key", ErrInvalidToken)
+	}
+
+	return key.PublicKey, nil
+}
+
+func toClaims(mapClaims jwt.MapClaims) (Claims, error) {
+	var (
+		claims Claims
+		ok     bool
+		err    error
+	)
+
+	if claims.ID, ok = mapClaims["jti"].(string); !ok {
+		return Claims{}, fmt.Errorf("%w: jti claim is missing", ErrInvalidToken)
+	}
+
+	// tokens of the app itself have client_id instead of uid and app_id
+	if _, ok := mapClaims["client_id"]; ok {
+		if claims.ClientID, err = uuidClaim(mapClaims, "client_id"); err != nil {
+			return Claims{}, err
+		}
+
+		claims.AppID = claims.ClientID
+	} else {
+		if claims.UserID, err = uuidClaim(mapClaims, "uid"); err != nil {
+			return Claims{}, err
+		}
+
+		if claims.AppID, err = uuidClaim(mapClaims, "app_id"); err != nil {
+			return Claims{}, err
+		}
+	}
+
+	if claims.OrgID, err = uuidClaim(mapClaims, "org_id"); err != nil {
+		claims.OrgID = models.DefaultOrgID
+	}
+
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.Issuer, _ = mapClaims["iss"].(string)
+	claims.Scope, _ = mapClaims["scope"].(string)
+	claims.SessionID, _ = mapClaims["sid"].(string)
+
+	// numbers are decoded as float64
+	version, _ := mapClaims["ver"].(float64)
+	claims.Version = int64(version)
+
+	exp, err := mapClaims.GetExpirationTime()
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	claims.ExpiresAt = exp.Time
+
+	iat, err := mapClaims.GetIssuedAt()
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if iat != nil {
+		claims.IssuedAt = iat.Time
+	}
+
+	return claims, nil
+}
+
+func uuidClaim(mapClaims jwt.MapClaims, name string) (uuid.UUID, error) {
+	value, ok := mapClaims[name].(string)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%w: %s claim is missing", ErrInvalidToken, name)
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %s claim is malformed", ErrInvalidToken, name)
+	}
+
+	return id, nil
+}
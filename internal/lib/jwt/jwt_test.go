@@ -0,0 +1,254 @@
+package jwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+)
+
+const issuer = "sso-unit-tests"
+
+var errUnknownKey = errors.New("unknown key")
+
+func TestNewToken_SignVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		key  models.SigningKey
+	}{
+		{name: "HS256", key: models.SigningKey{Algorithm: jwt.AlgorithmHS256, Secret: newSecret(t)}},
+		{name: "RS256", key: newKey(t, jwt.AlgorithmRS256)},
+		{name: "ES256", key: newKey(t, jwt.AlgorithmES256)},
+		{name: "EdDSA", key: newKey(t, jwt.AlgorithmEdDSA)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{ID: uuid.New(), OrgID: uuid.New(), Email: "user@sso.tests"}
+			app := models.App{ID: uuid.New(), OrgID: user.OrgID}
+
+			token, err := jwt.NewToken(user, app, jwt.TokenOptions{
+				Issuer:    issuer,
+				TTL:       time.Hour,
+				Version:   3,
+				SessionID: "session",
+				Key:       tt.key,
+			})
+			require.NoError(t, err)
+
+			claims, err := jwt.ParseToken(token, issuer, verificationKeys(tt.key))
+			require.NoError(t, err)
+
+			assert.Equal(t, user.ID, claims.UserID)
+			assert.Equal(t, user.Email, claims.Email)
+			assert.Equal(t, user.OrgID, claims.OrgID)
+			assert.Equal(t, app.ID, claims.AppID)
+			assert.Equal(t, uuid.Nil, claims.ClientID)
+			assert.Equal(t, int64(3), claims.Version)
+			assert.Equal(t, "session", claims.SessionID)
+			assert.InDelta(t, time.Now().Add(time.Hour).Unix(), claims.ExpiresAt.Unix(), 1)
+		})
+	}
+}
+
+func TestNewClientToken_SignVerify(t *testing.T) {
+	key := newKey(t, jwt.AlgorithmES256)
+	app := models.App{ID: uuid.New(), OrgID: uuid.New()}
+
+	token, err := jwt.NewClientToken(app, jwt.ClientTokenOptions{
+		Issuer: issuer,
+		TTL:    time.Hour,
+		Key:    key,
+		Scope:  "documents.read",
+	})
+	require.NoError(t, err)
+
+	claims, err := jwt.ParseToken(token, issuer, verificationKeys(key))
+	require.NoError(t, err)
+
+	assert.Equal(t, app.ID, claims.ClientID)
+	assert.Equal(t, app.ID, claims.AppID)
+	assert.Equal(t, uuid.Nil, claims.UserID)
+	assert.Equal(t, app.OrgID, claims.OrgID)
+	assert.Equal(t, "documents.read", claims.Scope)
+}
+
+func TestNewToken_NoSecret(t *testing.T) {
+	_, err := jwt.NewToken(&models.User{ID: uuid.New()}, models.App{ID: uuid.New()}, jwt.TokenOptions{
+		Issuer: issuer,
+		TTL:    time.Hour,
+		Key:    models.SigningKey{Algorithm: jwt.AlgorithmHS256},
+	})
+	require.ErrorIs(t, err, jwt.ErrUnsupportedAlgorithm)
+}
+
+func TestParseToken_Rejected(t *testing.T) {
+	secret := models.SigningKey{Algorithm: jwt.AlgorithmHS256, Secret: newSecret(t)}
+	key := newKey(t, jwt.AlgorithmES256)
+
+	tests := []struct {
+		name    string
+		signKey models.SigningKey
+		ttl     time.Duration
+		issuer  string
+		keys    jwt.VerificationKeys
+	}{
+		{
+			name:    "Signed with another secret",
+			signKey: models.SigningKey{Algorithm: jwt.AlgorithmHS256, Secret: newSecret(t)},
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(secret),
+		},
+		{
+			name:    "Signed with another key with the same kid",
+			signKey: withID(newKey(t, jwt.AlgorithmES256), key.ID),
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(key),
+		},
+		{
+			name:    "Unknown kid",
+			signKey: newKey(t, jwt.AlgorithmES256),
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(key),
+		},
+		{
+			name:    "Algorithm does not match the key",
+			signKey: withID(newKey(t, jwt.AlgorithmEdDSA), key.ID),
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(key),
+		},
+		{
+			name:    "HS256 is not accepted",
+			signKey: secret,
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(key),
+		},
+		{
+			name:    "Asymmetric is not accepted",
+			signKey: key,
+			ttl:     time.Hour,
+			issuer:  issuer,
+			keys:    verificationKeys(secret),
+		},
+		{
+			name:    "Expired",
+			signKey: key,
+			ttl:     -time.Minute,
+			issuer:  issuer,
+			keys:    verificationKeys(key),
+		},
+		{
+			name:    "Another issuer",
+			signKey: key,
+			ttl:     time.Hour,
+			issuer:  "another-issuer",
+			keys:    verificationKeys(key),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewToken(&models.User{ID: uuid.New()}, models.App{ID: uuid.New()}, jwt.TokenOptions{
+				Issuer: tt.issuer,
+				TTL:    tt.ttl,
+				Key:    tt.signKey,
+			})
+			require.NoError(t, err)
+
+			_, err = jwt.ParseToken(token, issuer, tt.keys)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestParseToken_KeyError(t *testing.T) {
+	key := newKey(t, jwt.AlgorithmES256)
+
+	token, err := jwt.NewToken(&models.User{ID: uuid.New()}, models.App{ID: uuid.New()}, jwt.TokenOptions{
+		Issuer: issuer,
+		TTL:    time.Hour,
+		Key:    key,
+	})
+	require.NoError(t, err)
+
+	// errors of the keys are not the token's fault
+	_, err = jwt.ParseToken(token, issuer, jwt.VerificationKeys{
+		PublicKey: func(string) (models.SigningKey, error) { return models.SigningKey{}, errUnknownKey },
+	})
+	require.ErrorIs(t, err, errUnknownKey)
+	assert.NotErrorIs(t, err, jwt.ErrInvalidToken)
+}
+
+func verificationKeys(key models.SigningKey) jwt.VerificationKeys {
+	if key.Algorithm == jwt.AlgorithmHS256 {
+		return jwt.VerificationKeys{Secret: func() ([]byte, error) { return key.Secret, nil }}
+	}
+
+	return jwt.VerificationKeys{
+		PublicKey: func(keyID string) (models.SigningKey, error) {
+			if keyID != key.ID {
+				return models.SigningKey{}, jwt.ErrInvalidToken
+			}
+
+			return key, nil
+		},
+	}
+}
+
+func newSecret(t *testing.T) []byte {
+	t.Helper()
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	require.NoError(t, err)
+
+	return secret
+}
+
+func newKey(t *testing.T, algorithm string) models.SigningKey {
+	t.Helper()
+
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch algorithm {
+	case jwt.AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	}
+	require.NoError(t, err)
+
+	return models.SigningKey{
+		ID:         uuid.NewString(),
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		PublicKey:  privateKey.Public(),
+	}
+}
+
+func withID(key models.SigningKey, keyID string) models.SigningKey {
+	key.ID = keyID
+	return key
+}
@@ -0,0 +1,301 @@
+package passhash
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltSize = 16
+	argon2KeySize  = 32
+	// pepperIDSize is the length of the pepper fingerprint kept in the hashes
+	pepperIDSize = 8
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrInvalidParams        = errors.New("invalid password hash parameters")
+	ErrMalformedHash        = errors.New("malformed password hash")
+	ErrUnknownPepper        = errors.New("password hash uses another pepper")
+)
+
+// Argon2Params are the argon2id cost parameters, Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// Params are the algorithm and its cost parameters new hashes are made with
+type Params struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Hasher hashes passwords into PHC strings:
+//
+//	$argon2id$v=19$m=19456,t=2,p=1[,keyid=<pepper id>]$<salt>$<hash>
+//	$bcrypt$r=10[,keyid=<pepper id>]$<bcrypt salt and hash>
+//
+// With the pepper the password is mixed with the secret kept out of the database before hashing,
+// keyid in the hash tells the pepper apart. Plain bcrypt hashes, like $2a$10$..., are verified too.
+type Hasher struct {
+	params   Params
+	pepper   []byte
+	pepperID string
+}
+
+// hash is the parsed password hash
+type hash struct {
+	algorithm string
+	argon2    Argon2Params
+	cost      int
+	pepperID  string
+	salt      []byte
+	key       []byte
+	// bcrypt is the hash in the format bcrypt verifies
+	bcrypt []byte
+	// plain is set for bcrypt hashes not in the PHC format
+	plain bool
+}
+
+// New creates the hasher, pepper is optional and must not change once hashes are made with it
+func New(params Params, pepper []byte) (*Hasher, error) {
+	const op = "passhash.New"
+
+	switch params.Algorithm {
+	case AlgorithmArgon2id:
+		if params.Argon2.Memory < 8*uint32(params.Argon2.Parallelism) || params.Argon2.Iterations < 1 || params.Argon2.Parallelism < 1 {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidParams)
+		}
+	case AlgorithmBcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidParams)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", op, ErrUnsupportedAlgorithm)
+	}
+
+	hasher := &Hasher{params: params}
+	if len(pepper) > 0 {
+		sum := sha256.Sum256(pepper)
+		hasher.pepper = pepper
+		hasher.pepperID = base64.RawStdEncoding.EncodeToString(sum[:])[:pepperIDSize]
+	}
+
+	return hasher, nil
+}
+
+// Hash hashes the password with the configured algorithm, parameters and pepper
+func (h *Hasher) Hash(password string) ([]byte, error) {
+	const op = "passhash.Hash"
+
+	secret := h.peppered(password, h.pepperID != "")
+
+	var keyID string
+	if h.pepperID != "" {
+		keyID = ",keyid=" + h.pepperID
+	}
+
+	switch h.params.Algorithm {
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		p := h.params.Argon2
+		key := argon2.IDKey(secret, salt, p.Iterations, p.Memory, p.Parallelism, argon2KeySize)
+
+		return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d%s$%s$%s",
+			argon2.Version, p.Memory, p.Iterations, p.Parallelism, keyID,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		)), nil
+	default:
+		bcryptHash, err := bcrypt.GenerateFromPassword(secret, h.params.BcryptCost)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// $2a$10$<salt and hash>
+		body := bcryptHash[bytes.LastIndexByte(bcryptHash, '$')+1:]
+
+		return []byte(fmt.Sprintf("$bcrypt$r=%d%s$%s", h.params.BcryptCost, keyID, body)), nil
+	}
+}
+
+// Verify reports whether the password matches the hash and whether the hash should be replaced with a new one,
+// as it's made with another algorithm, parameters or without the pepper
+func (h *Hasher) Verify(encodedHash []byte, password string) (match bool, needsRehash bool, err error) {
+	const op = "passhash.Verify"
+
+	parsed, err := parse(encodedHash)
+	if err != nil {
+		return false, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if parsed.pepperID != "" && parsed.pepperID != h.pepperID {
+		return false, false, fmt.Errorf("%s: %w", op, ErrUnknownPepper)
+	}
+
+	secret := h.peppered(password, parsed.pepperID != "")
+
+	switch parsed.algorithm {
+	case AlgorithmArgon2id:
+		p := parsed.argon2
+		key := argon2.IDKey(secret, parsed.salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(parsed.key)))
+		match = subtle.ConstantTimeCompare(key, parsed.key) == 1
+	default:
+		err := bcrypt.CompareHashAndPassword(parsed.bcrypt, secret)
+		if err != nil && !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, fmt.Errorf("%s: %w", op, err)
+		}
+		match = err == nil
+	}
+
+	return match, h.outdated(parsed), nil
+}
+
+// outdated reports whether the hash differs from the ones Hash makes
+func (h *Hasher) outdated(parsed hash) bool {
+	if parsed.algorithm != h.params.Algorithm || parsed.pepperID != h.pepperID {
+		return true
+	}
+
+	if parsed.algorithm == AlgorithmArgon2id {
+		return parsed.argon2 != h.params.Argon2 || len(parsed.key) != argon2KeySize
+	}
+
+	// plain bcrypt hashes are moved to the PHC format
+	return parsed.cost != h.params.BcryptCost || parsed.plain
+}
+
+// peppered returns the password mixed with the pepper, hashes without keyid are made without it
+func (h *Hasher) peppered(password string, withPepper bool) []byte {
+	if !withPepper {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(password))
+
+	// bcrypt stops at the first zero byte, so the MAC is encoded
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+func parse(encodedHash []byte) (hash, error) {
+	parts := strings.Split(string(encodedHash), "$")
+	if len(parts) < 2 || parts[0] != "" {
+		return hash{}, ErrMalformedHash
+	}
+
+	switch parts[1] {
+	case AlgorithmArgon2id:
+		return parseArgon2id(parts)
+	case AlgorithmBcrypt:
+		return parseBcrypt(parts)
+	case "2a", "2b", "2y":
+		cost, err := bcrypt.Cost(encodedHash)
+		if err != nil {
+			return hash{}, ErrMalformedHash
+		}
+
+		return hash{algorithm: AlgorithmBcrypt, cost: cost, bcrypt: encodedHash, plain: true}, nil
+	default:
+		return hash{}, ErrUnsupportedAlgorithm
+	}
+}
+
+// parseArgon2id parses $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>[,keyid=<pepper id>]$<salt>$<hash>
+func parseArgon2id(parts []string) (hash, error) {
+	if len(parts) != 6 || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return hash{}, ErrMalformedHash
+	}
+
+	params, err := parseParams(parts[3])
+	if err != nil {
+		return hash{}, err
+	}
+
+	memory, errMemory := strconv.ParseUint(params["m"], 10, 32)
+	iterations, errIterations := strconv.ParseUint(params["t"], 10, 32)
+	parallelism, errParallelism := strconv.ParseUint(params["p"], 10, 8)
+	if err := errors.Join(errMemory, errIterations, errParallelism); err != nil {
+		return hash{}, ErrMalformedHash
+	}
+
+	salt, errSalt := base64.RawStdEncoding.DecodeString(parts[4])
+	key, errKey := base64.RawStdEncoding.DecodeString(parts[5])
+	if errSalt != nil || errKey != nil || len(key) == 0 {
+		return hash{}, ErrMalformedHash
+	}
+
+	return hash{
+		algorithm: AlgorithmArgon2id,
+		argon2: Argon2Params{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+		},
+		pepperID: params["keyid"],
+		salt:     salt,
+		key:      key,
+	}, nil
+}
+
+// parseBcrypt parses $bcrypt$r=<cost>[,keyid=<pepper id>]$<bcrypt salt and hash>
+func parseBcrypt(parts []string) (hash, error) {
+	if len(parts) != 4 {
+		return hash{}, ErrMalformedHash
+	}
+
+	params, err := parseParams(parts[2])
+	if err != nil {
+		return hash{}, err
+	}
+
+	cost, err := strconv.Atoi(params["r"])
+	if err != nil {
+		return hash{}, ErrMalformedHash
+	}
+
+	return hash{
+		algorithm: AlgorithmBcrypt,
+		cost:      cost,
+		pepperID:  params["keyid"],
+		bcrypt:    []byte(fmt.Sprintf("$2a$%02d$%s", cost, parts[3])),
+	}, nil
+}
+
+// parseParams parses comma separated name=value pairs
+func parseParams(encodedParams string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, param := range strings.Split(encodedParams, ",") {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, ErrMalformedHash
+		}
+
+		params[name] = value
+	}
+
+	return params, nil
+}
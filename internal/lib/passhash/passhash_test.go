@@ -0,0 +1,272 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const password = "correct horse battery staple"
+
+var argon2Params = Argon2Params{Memory: 19456, Iterations: 2, Parallelism: 1}
+
+func TestHasher_HashVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		pepper []byte
+		prefix string
+	}{
+		{
+			name:   "Argon2id",
+			params: Params{Algorithm: AlgorithmArgon2id, Argon2: argon2Params},
+			prefix: "$argon2id$v=19$m=19456,t=2,p=1$",
+		},
+		{
+			name:   "Argon2id with pepper",
+			params: Params{Algorithm: AlgorithmArgon2id, Argon2: argon2Params},
+			pepper: []byte("pepper"),
+			prefix: "$argon2id$v=19$m=19456,t=2,p=1,keyid=",
+		},
+		{
+			name:   "Bcrypt",
+			params: Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost},
+			prefix: "$bcrypt$r=4$",
+		},
+		{
+			name:   "Bcrypt with pepper",
+			params: Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost},
+			pepper: []byte("pepper"),
+			prefix: "$bcrypt$r=4,keyid=",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher := newHasher(t, tt.params, tt.pepper)
+
+			hash, err := hasher.Hash(password)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(hash), tt.prefix), string(hash))
+
+			match, needsRehash, err := hasher.Verify(hash, password)
+			require.NoError(t, err)
+			assert.True(t, match)
+			assert.False(t, needsRehash)
+
+			match, _, err = hasher.Verify(hash, "wrong password")
+			require.NoError(t, err)
+			assert.False(t, match)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want hash
+	}{
+		{
+			name: "Argon2id",
+			hash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+			want: hash{
+				algorithm: AlgorithmArgon2id,
+				argon2:    argon2Params,
+				salt:      []byte("saltsaltsaltsalt"),
+				key:       []byte("key"),
+			},
+		},
+		{
+			name: "Argon2id with keyid",
+			hash: "$argon2id$v=19$m=8192,t=1,p=4,keyid=abcdefgh$c2FsdA$a2V5",
+			want: hash{
+				algorithm: AlgorithmArgon2id,
+				argon2:    Argon2Params{Memory: 8192, Iterations: 1, Parallelism: 4},
+				pepperID:  "abcdefgh",
+				salt:      []byte("salt"),
+				key:       []byte("key"),
+			},
+		},
+		{
+			name: "Bcrypt with keyid",
+			hash: "$bcrypt$r=12,keyid=abcdefgh$saltandhash",
+			want: hash{
+				algorithm: AlgorithmBcrypt,
+				cost:      12,
+				pepperID:  "abcdefgh",
+				bcrypt:    []byte("$2a$12$saltandhash"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parse([]byte(tt.hash))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, parsed)
+		})
+	}
+}
+
+func TestParse_PlainBcrypt(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	parsed, err := parse(bcryptHash)
+	require.NoError(t, err)
+	assert.Equal(t, hash{algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost, bcrypt: bcryptHash, plain: true}, parsed)
+}
+
+func TestParse_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		err  error
+	}{
+		{name: "Empty", hash: "", err: ErrMalformedHash},
+		{name: "No leading separator", hash: "argon2id$v=19$m=1,t=1,p=1$c2FsdA$a2V5", err: ErrMalformedHash},
+		{name: "Unknown algorithm", hash: "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5", err: ErrUnsupportedAlgorithm},
+		{name: "Argon2id another version", hash: "$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$a2V5", err: ErrMalformedHash},
+		{name: "Argon2id missing part", hash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA", err: ErrMalformedHash},
+		{name: "Argon2id missing parameter", hash: "$argon2id$v=19$m=19456,t=2$c2FsdA$a2V5", err: ErrMalformedHash},
+		{name: "Argon2id parameter without value", hash: "$argon2id$v=19$m=19456,t=2,p$c2FsdA$a2V5", err: ErrMalformedHash},
+		{name: "Argon2id parallelism overflow", hash: "$argon2id$v=19$m=19456,t=2,p=256$c2FsdA$a2V5", err: ErrMalformedHash},
+		{name: "Argon2id invalid salt", hash: "$argon2id$v=19$m=19456,t=2,p=1$!!!$a2V5", err: ErrMalformedHash},
+		{name: "Argon2id empty key", hash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$", err: ErrMalformedHash},
+		{name: "Bcrypt invalid cost", hash: "$bcrypt$r=x$saltandhash", err: ErrMalformedHash},
+		{name: "Plain bcrypt truncated", hash: "$2a$1", err: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse([]byte(tt.hash))
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestHasher_Verify_Pepper(t *testing.T) {
+	params := Params{Algorithm: AlgorithmArgon2id, Argon2: argon2Params}
+
+	plainHash, err := newHasher(t, params, nil).Hash(password)
+	require.NoError(t, err)
+
+	pepperedHash, err := newHasher(t, params, []byte("pepper")).Hash(password)
+	require.NoError(t, err)
+
+	// the hash is made with the pepper, not the password alone
+	unpeppered := strings.Replace(string(pepperedHash), ",keyid="+newHasher(t, params, []byte("pepper")).pepperID, "", 1)
+	match, _, err := newHasher(t, params, nil).Verify([]byte(unpeppered), password)
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	t.Run("Hash without pepper is upgraded once the pepper is set", func(t *testing.T) {
+		match, needsRehash, err := newHasher(t, params, []byte("pepper")).Verify(plainHash, password)
+		require.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("Hash with another pepper", func(t *testing.T) {
+		_, _, err := newHasher(t, params, []byte("another pepper")).Verify(pepperedHash, password)
+		require.ErrorIs(t, err, ErrUnknownPepper)
+	})
+
+	t.Run("Hash with pepper once the pepper is unset", func(t *testing.T) {
+		_, _, err := newHasher(t, params, nil).Verify(pepperedHash, password)
+		require.ErrorIs(t, err, ErrUnknownPepper)
+	})
+}
+
+func TestHasher_Verify_Outdated(t *testing.T) {
+	current := newHasher(t, Params{Algorithm: AlgorithmArgon2id, Argon2: argon2Params}, nil)
+
+	plainBcrypt, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   []byte
+	}{
+		{
+			name: "Plain bcrypt",
+			hash: plainBcrypt,
+		},
+		{
+			name:   "Bcrypt in the PHC format",
+			hasher: newHasher(t, Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, nil),
+		},
+		{
+			name:   "Argon2id with less memory",
+			hasher: newHasher(t, Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 8192, Iterations: 2, Parallelism: 1}}, nil),
+		},
+		{
+			name:   "Argon2id with fewer iterations",
+			hasher: newHasher(t, Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 19456, Iterations: 1, Parallelism: 1}}, nil),
+		},
+		{
+			name:   "Argon2id with another parallelism",
+			hasher: newHasher(t, Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 19456, Iterations: 2, Parallelism: 2}}, nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := tt.hash
+			if hash == nil {
+				hash, err = tt.hasher.Hash(password)
+				require.NoError(t, err)
+			}
+
+			match, needsRehash, err := current.Verify(hash, password)
+			require.NoError(t, err)
+			assert.True(t, match)
+			assert.True(t, needsRehash)
+		})
+	}
+
+	t.Run("Plain bcrypt with the current cost", func(t *testing.T) {
+		hasher := newHasher(t, Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, nil)
+
+		match, needsRehash, err := hasher.Verify(plainBcrypt, password)
+		require.NoError(t, err)
+		assert.True(t, match)
+		assert.True(t, needsRehash)
+	})
+}
+
+func TestNew_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		err    error
+	}{
+		{name: "Unknown algorithm", params: Params{Algorithm: "scrypt"}, err: ErrUnsupportedAlgorithm},
+		{name: "Bcrypt cost too low", params: Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost - 1}, err: ErrInvalidParams},
+		{name: "Bcrypt cost too high", params: Params{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1}, err: ErrInvalidParams},
+		{name: "Argon2id no iterations", params: Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 19456, Parallelism: 1}}, err: ErrInvalidParams},
+		{name: "Argon2id no parallelism", params: Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 19456, Iterations: 2}}, err: ErrInvalidParams},
+		{name: "Argon2id too little memory", params: Params{Algorithm: AlgorithmArgon2id, Argon2: Argon2Params{Memory: 7, Iterations: 2, Parallelism: 1}}, err: ErrInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.params, nil)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func newHasher(t *testing.T, params Params, pepper []byte) *Hasher {
+	t.Helper()
+
+	hasher, err := New(params, pepper)
+	require.NoError(t, err)
+
+	return hasher
+}
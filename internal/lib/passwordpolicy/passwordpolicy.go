@@ -0,0 +1,198 @@
+package passwordpolicy
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+)
+
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleRequireUpper  = "require_upper"
+	RuleRequireLower  = "require_lower"
+	RuleRequireDigit  = "require_digit"
+	RuleRequireSymbol = "require_symbol"
+	RuleCommon        = "common"
+	RuleEmail         = "email"
+)
+
+// MaxBytes is the longest password bcrypt can hash, longer ones are rejected by every policy
+const MaxBytes = 72
+
+// minEmailWordLength keeps short parts of the email, like "a" or "io", from rejecting most passwords
+const minEmailWordLength = 3
+
+var ErrInvalidPolicy = errors.New("invalid password policy")
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords are lower case passwords of the bundled list
+var commonPasswords = parseCommonPasswords(commonPasswordsList)
+
+// Validate checks the policy can be satisfied
+func Validate(policy models.PasswordPolicy) error {
+	if policy.MinLength < 1 {
+		return fmt.Errorf("%w: min_length must be positive", ErrInvalidPolicy)
+	}
+
+	if policy.MaxLength < 0 {
+		return fmt.Errorf("%w: max_length must not be negative", ErrInvalidPolicy)
+	}
+
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return fmt.Errorf("%w: max_length is less than min_length", ErrInvalidPolicy)
+	}
+
+	if policy.MinLength > MaxBytes {
+		return fmt.Errorf("%w: min_length exceeds %d", ErrInvalidPolicy, MaxBytes)
+	}
+
+	return nil
+}
+
+// Check returns the rules of the policy the password breaks, none when the password follows the policy.
+// The email is the email of the user choosing the password.
+func Check(policy models.PasswordPolicy, password string, email string) []models.PasswordViolation {
+	var violations []models.PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleMinLength,
+			Description: fmt.Sprintf("password must be at least %d characters long", policy.MinLength),
+		})
+	}
+
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleMaxLength,
+			Description: fmt.Sprintf("password must be at most %d characters long", policy.MaxLength),
+		})
+	} else if len(password) > MaxBytes {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleMaxLength,
+			Description: fmt.Sprintf("password must be at most %d bytes long", MaxBytes),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleRequireUpper,
+			Description: "password must contain an upper case letter",
+		})
+	}
+
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleRequireLower,
+			Description: "password must contain a lower case letter",
+		})
+	}
+
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleRequireDigit,
+			Description: "password must contain a digit",
+		})
+	}
+
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleRequireSymbol,
+			Description: "password must contain a symbol",
+		})
+	}
+
+	if policy.RejectCommon && isCommon(password) {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleCommon,
+			Description: "password is too common",
+		})
+	}
+
+	if policy.RejectEmail && containsEmailWord(password, email) {
+		violations = append(violations, models.PasswordViolation{
+			Rule:        RuleEmail,
+			Description: "password must not contain parts of the email",
+		})
+	}
+
+	return violations
+}
+
+// isCommon reports whether the password is a common one, optionally followed by digits and symbols, e.g. "Password123!"
+func isCommon(password string) bool {
+	password = strings.ToLower(password)
+	if _, ok := commonPasswords[password]; ok {
+		return true
+	}
+
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	_, ok := commonPasswords[base]
+
+	return ok
+}
+
+// containsEmailWord reports whether the password contains the local part of the email or a word of the email
+func containsEmailWord(password string, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+
+	localPart, domain, _ := strings.Cut(email, "@")
+	if len(localPart) >= minEmailWordLength && strings.Contains(password, localPart) {
+		return true
+	}
+
+	// the top level domain, like "com", is shared by too many users to be derived from the email
+	if i := strings.LastIndex(domain, "."); i >= 0 {
+		domain = domain[:i]
+	}
+
+	words := strings.FieldsFunc(localPart+" "+domain, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if utf8.RuneCountInString(word) >= minEmailWordLength && strings.Contains(password, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+
+	return passwords
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+)
+
+var ErrInvalidHeader = errors.New("header must not contain line breaks")
+
+// message formats the email as an RFC 5322 message with a UTF-8 plain text body
+func message(from string, email models.Email) ([]byte, error) {
+	for _, value := range []string{from, email.To, email.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(email.Body, "\n", "\r\n"))
+
+	return buf.Bytes(), nil
+}
+
+// address returns the bare address of the mailbox, e.g. sso@example.com of SSO <sso@example.com>
+func address(mailbox string) (string, error) {
+	parsed, err := mail.ParseAddress(mailbox)
+	if err != nil {
+		return "", err
+	}
+
+	return parsed.Address, nil
+}
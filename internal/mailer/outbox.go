@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+)
+
+// Outbox writes emails to .eml files in a directory instead of sending them,
+// so the flows sending emails work locally without a mail server
+type Outbox struct {
+	log  *slog.Logger
+	dir  string
+	from string
+}
+
+// NewOutbox returns the mailer writing emails to dir, the directory is created if it doesn't exist
+func NewOutbox(log *slog.Logger, dir string, from string) (*Outbox, error) {
+	const op = "mailer.NewOutbox"
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Outbox{log: log, dir: dir, from: from}, nil
+}
+
+// Send writes the email to <unix nano>_<recipient>.eml, the file appears once it is fully written
+func (m *Outbox) Send(_ context.Context, email models.Email) error {
+	const op = "mailer.Outbox.Send"
+	log := m.log.With(slog.String("op", op))
+
+	msg, err := message(m.from, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), fileName(email.To))
+
+	tmp, err := os.CreateTemp(m.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(msg); err != nil {
+		tmp.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	path := filepath.Join(m.dir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email written to outbox", slog.String("path", path))
+
+	return nil
+}
+
+// fileName keeps the recipient from escaping the outbox directory
+func fileName(recipient string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator || r < ' ' {
+			return '_'
+		}
+
+		return r
+	}, recipient)
+}
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+)
+
+// SMTP sends emails through the SMTP server, STARTTLS is used when the server supports it
+type SMTP struct {
+	log  *slog.Logger
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTP returns the mailer sending emails through the server at addr, username may be empty for servers without auth
+func NewSMTP(log *slog.Logger, addr string, from string, username string, password string) (*SMTP, error) {
+	const op = "mailer.NewSMTP"
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := address(from); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTP{log: log, addr: addr, from: from, auth: auth}, nil
+}
+
+func (m *SMTP) Send(ctx context.Context, email models.Email) error {
+	const op = "mailer.SMTP.Send"
+	log := m.log.With(slog.String("op", op))
+
+	msg, err := message(m.from, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	from, err := address(m.from)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := smtp.SendMail(m.addr, m.auth, from, []string{email.To}, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email sent", slog.String("subject", email.Subject))
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so keys are told apart from JWTs and found by secret scanners
+	APIKeyPrefix = "sso_"
+	// apiKeyPrefixSize is the size of the random part of the visible prefix
+	apiKeyPrefixSize = 6
+	apiKeySecretSize = 32
+	// apiKeyLastUsedPrecision limits how often using the key is recorded
+	apiKeyLastUsedPrecision = time.Minute
+)
+
+// CreateAPIKey creates the API key of the owner of the token and returns it with the key itself, which is never shown again.
+// The key is bound to the app of the organization of the user unless appID is uuid.Nil, zero ttl keys never expire.
+// Scopes are limited to the scopes of the token and, for keys bound to an app, to the scopes both the token and the app have.
+// API keys can't be created with other API keys. Logging out of all devices and changing
+// or resetting the password revoke the keys of the user.
+func (a *Auth) CreateAPIKey(ctx context.Context, token string, name string, appID uuid.UUID, scopes []string, ttl time.Duration) (models.APIKey, string, error) {
+	const op = "auth.CreateAPIKey"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", name),
+	)
+	log.Info("creating api key")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	// a key never grants more than the token it was created with
+	allowedScopes := strings.Fields(claims.Scope)
+
+	if appID != uuid.Nil {
+		app, err := a.appProvider.App(ctx, appID)
+		if err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				log.Warn("app not found", sl.Err(err))
+				return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+			}
+
+			log.Error("failed to get app", sl.Err(err))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		// apps of other organizations look like unknown ones
+		if app.OrgID != claims.OrgID {
+			log.Warn("app belongs to another organization", slog.String("orgID", app.OrgID.String()))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		allowedScopes = slices.DeleteFunc(allowedScopes, func(scope string) bool {
+			return !slices.Contains(app.Scopes, scope)
+		})
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(allowedScopes, scope) {
+			log.Warn("scope is not allowed", slog.String("scope", scope))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidScope)
+		}
+	}
+
+	prefix, err := opaque.NewToken(apiKeyPrefixSize)
+	if err != nil {
+		log.Error("failed to generate api key prefix", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := opaque.NewToken(apiKeySecretSize)
+	if err != nil {
+		log.Error("failed to generate api key", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	prefix = APIKeyPrefix + prefix
+	rawKey := prefix + "_" + secret
+
+	key := models.APIKey{
+		ID:        uuid.New(),
+		UserID:    claims.UserID,
+		AppID:     appID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   opaque.Hash(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	if ttl > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(ttl)
+	}
+
+	if err := a.apiKeyProvider.SaveAPIKey(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to save api key", sl.Err(err))
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key created", slog.String("keyID", key.ID.String()))
+
+	return key, rawKey, nil
+}
+
+// ListAPIKeys returns the API keys of the user which were not revoked, the owner of the token when userID is uuid.Nil.
+// Only admins can list keys of other users.
+func (a *Auth) ListAPIKeys(ctx context.Context, token string, userID uuid.UUID) ([]models.APIKey, error) {
+	const op = "auth.ListAPIKeys"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing api keys")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == uuid.Nil {
+		userID = claims.UserID
+	}
+
+	log = log.With(slog.String("userID", userID.String()))
+
+	if err := a.checkUserAccess(ctx, log, claims, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := a.apiKeyProvider.APIKeys(ctx, userID)
+	if err != nil {
+		log.Error("failed to get api keys", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the API key, it stops passing validation at once.
+// Users can revoke their own keys, admins can revoke keys of any user.
+func (a *Auth) RevokeAPIKey(ctx context.Context, token string, keyID uuid.UUID) error {
+	const op = "auth.RevokeAPIKey"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("keyID", keyID.String()),
+	)
+	log.Info("revoking api key")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := a.apiKeyProvider.APIKey(ctx, keyID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to get api key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkUserAccess(ctx, log, claims, key.UserID); err != nil {
+		// keys of other users are not revealed to users who can't manage them
+		if errors.Is(err, ErrPermissionDenied) {
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.apiKeyProvider.RevokeAPIKey(ctx, keyID); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key already revoked", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+		}
+
+		log.Error("failed to revoke api key", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key revoked", slog.String("userID", key.UserID.String()))
+
+	return nil
+}
+
+// ValidateCredential verifies either an access token or an API key and returns the user it authenticates.
+// Client credentials tokens are accepted as well, they authenticate the app with sub = client_id.
+// Invalid, expired or revoked credentials of all kinds fail with ErrInvalidToken.
+func (a *Auth) ValidateCredential(ctx context.Context, credential string) (models.Principal, error) {
+	const op = "auth.ValidateCredential"
+
+	if !strings.HasPrefix(credential, APIKeyPrefix) {
+		claims, err := a.validateAccessToken(ctx, credential)
+		if err != nil {
+			return models.Principal{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return models.Principal{
+			UserID:         claims.UserID,
+			OrgID:          claims.OrgID,
+			AppID:          claims.AppID,
+			ClientID:       claims.ClientID,
+			CredentialID:   claims.ID,
+			CredentialType: models.CredentialTypeAccessToken,
+			Scopes:         strings.Fields(claims.Scope),
+			ExpiresAt:      claims.ExpiresAt,
+		}, nil
+	}
+
+	principal, err := a.validateAPIKey(ctx, credential)
+	if err != nil {
+		return models.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return principal, nil
+}
+
+func (a *Auth) validateAPIKey(ctx context.Context, rawKey string) (models.Principal, error) {
+	const op = "auth.validateAPIKey"
+	log := a.log.With(slog.String("op", op))
+
+	key, err := a.apiKeyProvider.APIKeyByHash(ctx, opaque.Hash(rawKey))
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Warn("api key not found", sl.Err(err))
+			return models.Principal{}, ErrInvalidToken
+		}
+
+		log.Error("failed to get api key", sl.Err(err))
+		return models.Principal{}, err
+	}
+
+	log = log.With(slog.String("keyID", key.ID.String()))
+
+	now := time.Now()
+	if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+		log.Warn("api key expired")
+		return models.Principal{}, ErrInvalidToken
+	}
+
+	user, err := a.userProvider.UserByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user of the api key not found", sl.Err(err))
+			return models.Principal{}, ErrInvalidToken
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.Principal{}, err
+	}
+
+	// failing to record the use doesn't fail the validation
+	if now.Sub(key.LastUsedAt) >= apiKeyLastUsedPrecision {
+		if err := a.apiKeyProvider.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Error("failed to record api key use", sl.Err(err))
+		}
+	}
+
+	return models.Principal{
+		UserID:         user.ID,
+		OrgID:          user.OrgID,
+		AppID:          key.AppID,
+		CredentialID:   key.ID.String(),
+		CredentialType: models.CredentialTypeAPIKey,
+		Scopes:         key.Scopes,
+		ExpiresAt:      key.ExpiresAt,
+	}, nil
+}
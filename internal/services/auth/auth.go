@@ -6,14 +6,16 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"slices"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/BariVakhidov/sso/internal/domain/models"
 	"github.com/BariVakhidov/sso/internal/lib/jwt"
 	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/lib/passwordpolicy"
 	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/peer"
@@ -24,7 +26,38 @@ type Auth struct {
 	userSaver            UserSaver
 	userProvider         UserProvider
 	appProvider          AppProvider
+	refreshTokenProvider RefreshTokenProvider
+	sessionProvider      SessionProvider
+	roleProvider         RoleProvider
+	organizationProvider OrganizationProvider
+	groupProvider        GroupProvider
+	relationshipProvider RelationshipProvider
+	apiKeyProvider       APIKeyProvider
+	revokedTokenProvider RevokedTokenProvider
+	authCodeProvider     AuthCodeProvider
+	deviceProvider       DeviceAuthorizationProvider
+	rateLimiter          RateLimiter
+	keyProvider          KeyProvider
+	mfaProvider          MFAProvider
+	mfaChallengeProvider MFAChallengeProvider
+	passkeyProvider      PasskeyProvider
+	passkeySessions      WebAuthnSessionProvider
+	passwordResets       PasswordResetProvider
+	magicLinks           MagicLinkProvider
+	ssoSessions          SSOSessionProvider
+	mailer               Mailer
+	passwordHasher       PasswordHasher
+	webAuthn             *webauthn.WebAuthn
+	emailLinks           EmailLinks
+	passwordPolicy       models.PasswordPolicy
+	ssoSessionLimits     SSOSessionLimits
+	issuer               string
+	mfaIssuer            string
+	mfaEncryptionKey     []byte
 	tokenTTL             time.Duration
+	refreshTokenTTL      time.Duration
+	clientTokenTTL       time.Duration
+	clientRateLimit      models.RateLimit
 	failedLogins         *prometheus.CounterVec
 	failedLoginsProvider FailedLoginProvider
 }
@@ -36,48 +69,348 @@ type FailedLoginProvider interface {
 }
 
 type UserSaver interface {
-	SaveUser(ctx context.Context, userID string, email string, passwordHash []byte) (user models.User, err error)
+	// SaveUser saves the user as a member of the organization
+	SaveUser(ctx context.Context, orgID uuid.UUID, userID string, email string, passwordHash []byte) (user models.User, err error)
+	SetEmailVerified(ctx context.Context, userID uuid.UUID, email string) (bool, error)
+	ResetPassword(ctx context.Context, userID uuid.UUID, passwordHash []byte) error
+	ChangePassword(ctx context.Context, userID uuid.UUID, passwordHash []byte, keptFamilyID uuid.UUID) error
+	UpgradePasswordHash(ctx context.Context, userID uuid.UUID, oldPasswordHash []byte, newPasswordHash []byte) error
 }
 
 type UserProvider interface {
-	User(ctx context.Context, email string) (models.User, error)
+	User(ctx context.Context, orgID uuid.UUID, email string) (models.User, error)
+	UserByID(ctx context.Context, userID uuid.UUID) (models.User, error)
 	IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error)
 }
 
 type AppProvider interface {
 	App(ctx context.Context, appID uuid.UUID) (models.App, error)
-	FindApp(ctx context.Context, name string) (models.App, error)
-	CreateApp(ctx context.Context, appID, name, secret string) (models.App, error)
+	FindApp(ctx context.Context, orgID uuid.UUID, name string) (models.App, error)
+	CreateApp(ctx context.Context, app models.App) (models.App, error)
+}
+
+type RefreshTokenProvider interface {
+	RefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenID uuid.UUID, newToken models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+}
+
+type SessionProvider interface {
+	// StartSession saves the session with the first refresh token of its token family
+	StartSession(ctx context.Context, session models.Session, token models.RefreshToken) error
+	Session(ctx context.Context, sessionID uuid.UUID) (models.Session, error)
+	Sessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+}
+
+type RoleProvider interface {
+	SaveRole(ctx context.Context, role models.Role) error
+	Role(ctx context.Context, roleID uuid.UUID) (models.Role, error)
+	Roles(ctx context.Context, appID uuid.UUID) ([]models.Role, error)
+	DeleteRole(ctx context.Context, roleID uuid.UUID) error
+	AssignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error
+	UnassignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error
+	UserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error)
+}
+
+type OrganizationProvider interface {
+	SaveOrganization(ctx context.Context, org models.Organization) error
+	Organization(ctx context.Context, orgID uuid.UUID) (models.Organization, error)
+	OrganizationMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (models.OrganizationMember, error)
+	OrganizationMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error)
+	SetOrganizationMemberRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) error
+}
+
+type GroupProvider interface {
+	SaveGroup(ctx context.Context, group models.Group) error
+	Group(ctx context.Context, groupID uuid.UUID) (models.Group, error)
+	Groups(ctx context.Context, orgID uuid.UUID) ([]models.Group, error)
+	DeleteGroup(ctx context.Context, groupID uuid.UUID) error
+	AddGroupUser(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error
+	RemoveGroupUser(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error
+	AddSubgroup(ctx context.Context, groupID uuid.UUID, subgroupID uuid.UUID) error
+	RemoveSubgroup(ctx context.Context, groupID uuid.UUID, subgroupID uuid.UUID) error
+	GroupUsers(ctx context.Context, groupID uuid.UUID, transitive bool) ([]models.GroupUser, error)
+	Subgroups(ctx context.Context, groupID uuid.UUID) ([]models.Group, error)
+	// UserGroups returns the groups the user belongs to directly or through subgroups
+	UserGroups(ctx context.Context, userID uuid.UUID) ([]models.Group, error)
+	GrantGroupRole(ctx context.Context, groupID uuid.UUID, roleID uuid.UUID) error
+	RevokeGroupRole(ctx context.Context, groupID uuid.UUID, roleID uuid.UUID) error
+}
+
+type RelationshipProvider interface {
+	WriteRelationships(ctx context.Context, appID uuid.UUID, writes []models.Relationship, deletes []models.Relationship) error
+	// Relationships returns the relationships to the object with any of the relations
+	Relationships(ctx context.Context, appID uuid.UUID, object models.ObjectRef, relations []string) ([]models.Relationship, error)
+	// SubjectRelationships returns the relationships the subject is in
+	SubjectRelationships(ctx context.Context, appID uuid.UUID, subject models.SubjectRef) ([]models.Relationship, error)
+	SaveRelationRewrite(ctx context.Context, appID uuid.UUID, rewrite models.RelationRewrite) error
+	RelationRewrites(ctx context.Context, appID uuid.UUID, objectType string) ([]models.RelationRewrite, error)
+}
+
+type APIKeyProvider interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey) error
+	APIKey(ctx context.Context, keyID uuid.UUID) (models.APIKey, error)
+	APIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+	APIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error
+	RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID) error
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) error
+}
+
+type RevokedTokenProvider interface {
+	RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	TokenVersion(ctx context.Context, userID string) (int64, error)
+	IncrementTokenVersion(ctx context.Context, userID string) (int64, error)
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+type AuthCodeProvider interface {
+	SaveAuthorizationCode(ctx context.Context, codeHash string, code models.AuthorizationCode, ttl time.Duration) error
+	AuthorizationCode(ctx context.Context, codeHash string) (models.AuthorizationCode, error)
+	// ConsumeAuthorizationCode deletes the code keeping the redemption, failing for codes already consumed
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, redeemed models.RedeemedAuthorizationCode, ttl time.Duration) error
+	RedeemedAuthorizationCode(ctx context.Context, codeHash string) (models.RedeemedAuthorizationCode, error)
+}
+
+type DeviceAuthorizationProvider interface {
+	SaveDeviceAuthorization(ctx context.Context, deviceCodeHash string, authorization models.DeviceAuthorization, ttl time.Duration) error
+	DeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error)
+	DeviceCodeHash(ctx context.Context, userCode string) (string, error)
+	UpdateDeviceAuthorization(ctx context.Context, deviceCodeHash string, authorization models.DeviceAuthorization) error
+	DeleteDeviceAuthorization(ctx context.Context, deviceCodeHash string, userCode string) (bool, error)
+	MarkDevicePoll(ctx context.Context, deviceCodeHash string, interval time.Duration) (bool, error)
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit models.RateLimit) (bool, error)
+}
+
+type MFAProvider interface {
+	SaveTOTPSecret(ctx context.Context, userID uuid.UUID, encryptedSecret []byte) error
+	TOTPSecret(ctx context.Context, userID uuid.UUID) (models.TOTPSecret, error)
+	EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) error
+	UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error)
+	SaveRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) error
+	RecoveryCodes(ctx context.Context, userID uuid.UUID) ([]models.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeID uuid.UUID) (bool, error)
+}
+
+type MFAChallengeProvider interface {
+	SaveMFAChallenge(ctx context.Context, tokenHash string, challenge models.MFAChallenge, ttl time.Duration) error
+	MFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error)
+	DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error)
+}
+
+type PasskeyProvider interface {
+	SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error
+	WebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	UseWebAuthnCredential(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) (bool, error)
+}
+
+type WebAuthnSessionProvider interface {
+	SaveWebAuthnSession(ctx context.Context, sessionIDHash string, session models.WebAuthnSession, ttl time.Duration) error
+	ConsumeWebAuthnSession(ctx context.Context, sessionIDHash string) (models.WebAuthnSession, error)
+}
+
+type PasswordResetProvider interface {
+	SavePasswordReset(ctx context.Context, tokenHash string, reset models.PasswordReset, ttl time.Duration) error
+	PasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error)
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error)
+}
+
+type MagicLinkProvider interface {
+	SaveMagicLink(ctx context.Context, tokenHash string, ttl time.Duration) error
+	ConsumeMagicLink(ctx context.Context, tokenHash string) error
+}
+
+type SSOSessionProvider interface {
+	SaveSSOSession(ctx context.Context, tokenHash string, session models.SSOSession, ttl time.Duration) error
+	SSOSession(ctx context.Context, tokenHash string) (models.SSOSession, error)
+	ExtendSSOSession(ctx context.Context, tokenHash string, ttl time.Duration) error
+	DeleteSSOSession(ctx context.Context, tokenHash string) error
+}
+
+type Mailer interface {
+	Send(ctx context.Context, email models.Email) error
+}
+
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	// Verify reports whether the password matches the hash and whether the hash is outdated
+	Verify(hash []byte, password string) (match bool, needsRehash bool, err error)
+}
+
+type KeyProvider interface {
+	SigningKey(ctx context.Context) (models.SigningKey, error)
+	PublicKey(ctx context.Context, keyID string) (models.SigningKey, error)
+	Symmetric() bool
 }
 
 const (
 	MaxFailedLoginAttempts = 10
 	attemptWindow          = 15 * time.Minute
 	BaseLockoutDuration    = 15 * time.Second
+	refreshTokenSize       = 32
+	appSecretSize          = 32
+	authCodeSize           = 32
+	authCodeTTL            = time.Minute
+	mfaChallengeSize       = 32
+	mfaChallengeTTL        = 5 * time.Minute
+	passkeySessionSize     = 32
+	passkeySessionTTL      = 5 * time.Minute
+	passwordResetTokenSize = 32
+	ssoSessionTokenSize    = 32
+
+	RoleAdmin = "admin"
+
+	// PermissionAll granted by a role grants every permission, the built-in admin role has it
+	PermissionAll = "*"
+	// PermissionManageRoles lets users create roles and assign them
+	PermissionManageRoles = "roles.manage"
+	// PermissionManageOrganizations lets users create organizations and manage all of them
+	PermissionManageOrganizations = "organizations.manage"
+	// PermissionManageGroups lets users manage the groups of every organization
+	PermissionManageGroups = "groups.manage"
+
+	ClaimRoles       = "roles"
+	ClaimPermissions = "permissions"
+	ClaimIsAdmin     = "is_admin"
+	ClaimGroups      = "groups"
 )
 
+// EmailLinks configures the links sent to users by email
+type EmailLinks struct {
+	// TokenKey is the HMAC key the tokens of the links are signed with
+	TokenKey []byte
+	// VerificationURL is the page the verification link points to
+	VerificationURL string
+	VerificationTTL time.Duration
+	// PasswordResetURL is the page the password reset link points to
+	PasswordResetURL string
+	PasswordResetTTL time.Duration
+	MagicLinkTTL     time.Duration
+}
+
+// SSOSessionLimits bound the lifetime of SSO sessions
+type SSOSessionLimits struct {
+	// MaxAge is how long the session lasts after the login regardless of its use
+	MaxAge time.Duration
+	// IdleTimeout ends the session when no app token is obtained with it for that long
+	IdleTimeout time.Duration
+}
+
+// supportedClaims are the extra claims an app can request in its tokens
+var supportedClaims = map[string]struct{}{
+	ClaimRoles:       {},
+	ClaimPermissions: {},
+	ClaimIsAdmin:     {},
+	ClaimGroups:      {},
+}
+
+// Deps are the dependencies and settings of the Auth service
+type Deps struct {
+	// users and their credentials
+	UserSaver            UserSaver
+	UserProvider         UserProvider
+	FailedLoginsProvider FailedLoginProvider
+	PasswordHasher       PasswordHasher
+	PasswordPolicy       models.PasswordPolicy
+	FailedLogins         *prometheus.CounterVec
+
+	// apps, organizations and access control
+	AppProvider          AppProvider
+	RoleProvider         RoleProvider
+	OrganizationProvider OrganizationProvider
+	GroupProvider        GroupProvider
+	RelationshipProvider RelationshipProvider
+	APIKeyProvider       APIKeyProvider
+
+	// tokens and sessions
+	KeyProvider          KeyProvider
+	RefreshTokenProvider RefreshTokenProvider
+	SessionProvider      SessionProvider
+	RevokedTokenProvider RevokedTokenProvider
+	SSOSessions          SSOSessionProvider
+	SSOSessionLimits     SSOSessionLimits
+	Issuer               string
+	TokenTTL             time.Duration
+	RefreshTokenTTL      time.Duration
+
+	// OAuth flows
+	AuthCodeProvider AuthCodeProvider
+	DeviceProvider   DeviceAuthorizationProvider
+	RateLimiter      RateLimiter
+	ClientTokenTTL   time.Duration
+	ClientRateLimit  models.RateLimit
+
+	// MFA and passkeys
+	MFAProvider          MFAProvider
+	MFAChallengeProvider MFAChallengeProvider
+	MFAIssuer            string
+	MFAEncryptionKey     []byte
+	PasskeyProvider      PasskeyProvider
+	PasskeySessions      WebAuthnSessionProvider
+	WebAuthn             *webauthn.WebAuthn
+
+	// emails
+	PasswordResets PasswordResetProvider
+	MagicLinks     MagicLinkProvider
+	Mailer         Mailer
+	EmailLinks     EmailLinks
+}
+
 // New returns a new instance of the Auth service
-func New(
-	log *slog.Logger,
-	userSaver UserSaver,
-	userProvider UserProvider,
-	appProvider AppProvider,
-	failedLoginsProvider FailedLoginProvider,
-	tokenTTL time.Duration,
-	failedLogins *prometheus.CounterVec,
-) *Auth {
+func New(log *slog.Logger, deps Deps) *Auth {
 	return &Auth{
 		log:                  log,
-		userSaver:            userSaver,
-		userProvider:         userProvider,
-		appProvider:          appProvider,
-		tokenTTL:             tokenTTL,
-		failedLogins:         failedLogins,
-		failedLoginsProvider: failedLoginsProvider,
+		userSaver:            deps.UserSaver,
+		userProvider:         deps.UserProvider,
+		appProvider:          deps.AppProvider,
+		refreshTokenProvider: deps.RefreshTokenProvider,
+		sessionProvider:      deps.SessionProvider,
+		roleProvider:         deps.RoleProvider,
+		organizationProvider: deps.OrganizationProvider,
+		groupProvider:        deps.GroupProvider,
+		relationshipProvider: deps.RelationshipProvider,
+		apiKeyProvider:       deps.APIKeyProvider,
+		revokedTokenProvider: deps.RevokedTokenProvider,
+		authCodeProvider:     deps.AuthCodeProvider,
+		deviceProvider:       deps.DeviceProvider,
+		rateLimiter:          deps.RateLimiter,
+		keyProvider:          deps.KeyProvider,
+		mfaProvider:          deps.MFAProvider,
+		mfaChallengeProvider: deps.MFAChallengeProvider,
+		passkeyProvider:      deps.PasskeyProvider,
+		passkeySessions:      deps.PasskeySessions,
+		passwordResets:       deps.PasswordResets,
+		magicLinks:           deps.MagicLinks,
+		ssoSessions:          deps.SSOSessions,
+		mailer:               deps.Mailer,
+		passwordHasher:       deps.PasswordHasher,
+		webAuthn:             deps.WebAuthn,
+		emailLinks:           deps.EmailLinks,
+		passwordPolicy:       deps.PasswordPolicy,
+		ssoSessionLimits:     deps.SSOSessionLimits,
+		issuer:               deps.Issuer,
+		mfaIssuer:            deps.MFAIssuer,
+		mfaEncryptionKey:     deps.MFAEncryptionKey,
+		tokenTTL:             deps.TokenTTL,
+		refreshTokenTTL:      deps.RefreshTokenTTL,
+		clientTokenTTL:       deps.ClientTokenTTL,
+		clientRateLimit:      deps.ClientRateLimit,
+		failedLogins:         deps.FailedLogins,
+		failedLoginsProvider: deps.FailedLoginsProvider,
 	}
 }
 
-func (a *Auth) Login(ctx context.Context, email string, password string, appID uuid.UUID) (string, error) {
+// Login checks user credentials and issues an access token with a refresh token starting a new token family,
+// and the SSO session token ObtainAppToken exchanges for tokens of other apps.
+// Users with MFA get models.TokenPair with only MFAToken set, VerifyMFA completes their login.
+// Apps requiring verified emails get ErrEmailNotVerified for users who haven't verified theirs.
+func (a *Auth) Login(ctx context.Context, email string, password string, appID uuid.UUID) (models.TokenPair, error) {
 	const op = "auth.Login"
 	log := a.log.With(
 		slog.String("op", op),
@@ -85,61 +418,264 @@ func (a *Auth) Login(ctx context.Context, email string, password string, appID u
 	)
 	log.Info("attempting to login user")
 
-	user, err := a.userProvider.User(ctx, email)
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, mfaRequired, err := a.authenticate(ctx, log, app.OrgID, email, password)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.loginTokens(ctx, log, &user, app, mfaRequired)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// loginTokens issues tokens with the SSO session to the authenticated user, or the MFA challenge when the second factor is required
+func (a *Auth) loginTokens(ctx context.Context, log *slog.Logger, user *models.User, app models.App, mfaRequired bool) (models.TokenPair, error) {
+	if mfaRequired {
+		// fail before the second factor is asked for, it wouldn't get the user tokens anyway
+		if err := checkEmailVerified(log, user, app); err != nil {
+			return models.TokenPair{}, err
+		}
+
+		mfaToken, err := a.newMFAChallenge(ctx, user.ID, app.ID, models.MFAPurposeLogin)
+		if err != nil {
+			log.Error("failed to create mfa challenge", sl.Err(err))
+			return models.TokenPair{}, err
+		}
+
+		log.Info("mfa required")
+
+		return models.TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := a.issueTokens(ctx, log, user, app, "")
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if tokens.SSOSessionToken, err = a.startSSOSession(ctx, log, user.ID); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return tokens, nil
+}
+
+// authenticate checks credentials of the user of the organization, counting failed attempts and locking the account out after too many of them.
+// For users with MFA the failed attempts are kept until the second factor is passed too.
+func (a *Auth) authenticate(ctx context.Context, log *slog.Logger, orgID uuid.UUID, email string, password string) (models.User, bool, error) {
+	user, err := a.userProvider.User(ctx, orgID, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found", sl.Err(err))
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			log.Warn("user not found", sl.Err(err))
+			return models.User{}, false, ErrInvalidCredentials
 		}
 
 		log.Error("failed to get user", sl.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.User{}, false, err
+	}
+
+	failedLoginAttempt, isFirstAttempt, err := a.checkLockout(ctx, log, user.ID)
+	if err != nil {
+		return models.User{}, false, err
+	}
+
+	match, needsRehash, err := a.passwordHasher.Verify(user.PassHash, password)
+	if err != nil {
+		log.Error("failed to verify password", sl.Err(err))
+		return models.User{}, false, err
 	}
 
-	// Check if user is currently locked out
-	failedLoginAttempt, err := a.failedLoginsProvider.FailedLoginAttempts(ctx, user.ID.String())
+	if !match {
+		log.Error("invalid credentials")
+
+		if err := a.registerFailedAttempt(ctx, user, failedLoginAttempt, isFirstAttempt); err != nil {
+			return models.User{}, false, err
+		}
+
+		return models.User{}, false, ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		a.upgradePasswordHash(ctx, log, user, password)
+	}
+
+	mfaRequired, err := a.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return models.User{}, false, err
+	}
+
+	if mfaRequired {
+		return user, true, nil
+	}
+
+	if err := a.failedLoginsProvider.RemoveFailedLoginAttempts(ctx, user.ID.String()); err != nil {
+		return models.User{}, false, err
+	}
+
+	return user, false, nil
+}
+
+// upgradePasswordHash rehashes the verified password with the current algorithm and parameters.
+// The login doesn't depend on it, so failures are only logged.
+func (a *Auth) upgradePasswordHash(ctx context.Context, log *slog.Logger, user models.User, password string) {
+	passwordHash, err := a.passwordHasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", sl.Err(err))
+		return
+	}
+
+	if err := a.userSaver.UpgradePasswordHash(ctx, user.ID, user.PassHash, passwordHash); err != nil {
+		log.Error("failed to upgrade password hash", sl.Err(err))
+		return
+	}
+
+	log.Info("password hash upgraded", slog.String("userID", user.ID.String()))
+}
+
+// checkLockout returns failed attempts of the user, failing with ErrAccountIsLocked while the account is locked out
+func (a *Auth) checkLockout(ctx context.Context, log *slog.Logger, userID uuid.UUID) (models.FailedLogin, bool, error) {
+	failedLoginAttempt, err := a.failedLoginsProvider.FailedLoginAttempts(ctx, userID.String())
 	isFirstAttempt := errors.Is(err, storage.ErrFailedLoginNotFound)
 	if err != nil && !isFirstAttempt {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.FailedLogin{}, false, err
 	}
 
 	if !isFirstAttempt && time.Now().Before(failedLoginAttempt.LockedUntil) {
-		log.Warn("account is locked", slog.String("email", email))
-		return "", fmt.Errorf("%s: %w", op, ErrAccountIsLocked)
+		log.Warn("account is locked", slog.String("userID", userID.String()))
+		return models.FailedLogin{}, false, ErrAccountIsLocked
+	}
+
+	return failedLoginAttempt, isFirstAttempt, nil
+}
+
+func (a *Auth) registerFailedAttempt(ctx context.Context, user models.User, failedLoginAttempt models.FailedLogin, isFirstAttempt bool) error {
+	a.failedLogins.WithLabelValues(user.Email, clientAddr(ctx)).Inc()
+
+	newAttempt := a.handleFailedLogin(user.ID, failedLoginAttempt, isFirstAttempt)
+
+	return a.failedLoginsProvider.SaveFailedLoginAttempts(ctx, user.ID.String(), newAttempt)
+}
+
+// issueTokens issues a token pair with the granted OAuth scope starting a new session.
+// Apps of other organizations look like unknown ones to the user.
+func (a *Auth) issueTokens(ctx context.Context, log *slog.Logger, user *models.User, app models.App, scope string) (models.TokenPair, error) {
+	return a.issueSessionTokens(ctx, log, user, app, uuid.New(), scope)
+}
+
+// issueSessionTokens is issueTokens for the session with the id chosen by the caller, the id of its token family
+func (a *Auth) issueSessionTokens(ctx context.Context, log *slog.Logger, user *models.User, app models.App, sessionID uuid.UUID, scope string) (models.TokenPair, error) {
+	if user.OrgID != app.OrgID {
+		log.Warn("app belongs to another organization", slog.String("orgID", app.OrgID.String()))
+		return models.TokenPair{}, ErrAppNotFound
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		log.Error("invalid credentials", sl.Err(err))
-		p, _ := peer.FromContext(ctx)
-		a.failedLogins.WithLabelValues(email, p.Addr.String()).Inc()
+	if err := checkEmailVerified(log, user, app); err != nil {
+		return models.TokenPair{}, err
+	}
 
-		newAttempt := a.handleFailedLogin(user.ID, failedLoginAttempt, isFirstAttempt)
-		if err := a.failedLoginsProvider.SaveFailedLoginAttempts(ctx, user.ID.String(), newAttempt); err != nil {
-			return "", fmt.Errorf("%s: %w", op, err)
+	tokens, refreshToken, err := a.newTokenPair(ctx, user, app, sessionID, scope)
+	if err != nil {
+		log.Error("failed to generate tokens", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	if err := a.sessionProvider.StartSession(ctx, newSession(ctx, refreshToken), refreshToken); err != nil {
+		log.Error("failed to start session", sl.Err(err))
+		return models.TokenPair{}, err
+	}
+
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the refresh token.
+// Presenting an already rotated token is treated as a theft and revokes the whole token family.
+func (a *Auth) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	const op = "auth.Refresh"
+	log := a.log.With(slog.String("op", op))
+	log.Info("refreshing tokens")
+
+	storedToken, err := a.refreshTokenProvider.RefreshToken(ctx, opaque.Hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			log.Warn("refresh token not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
 		}
 
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		log.Error("failed to get refresh token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := a.failedLoginsProvider.RemoveFailedLoginAttempts(ctx, user.ID.String()); err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+	log = log.With(slog.String("familyID", storedToken.FamilyID.String()))
+
+	if !storedToken.RevokedAt.IsZero() {
+		log.Warn("refresh token is revoked")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
 	}
 
-	app, err := a.appProvider.App(ctx, appID)
+	if !storedToken.RotatedAt.IsZero() {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, storedToken.FamilyID))
+	}
+
+	if time.Now().After(storedToken.ExpiresAt) {
+		log.Warn("refresh token is expired")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, storedToken.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, storedToken.AppID)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	token, err := jwt.NewToken(&user, app, a.tokenTTL)
+	tokens, newRefreshToken, err := a.newTokenPair(ctx, &user, app, storedToken.FamilyID, storedToken.Scope)
 	if err != nil {
-		a.log.Error("failed to generate token", sl.Err(err))
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to generate tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.refreshTokenProvider.RotateRefreshToken(ctx, storedToken.ID, newRefreshToken); err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenRotated) {
+			// Lost the race to a concurrent refresh with the same token
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.revokeReusedFamily(ctx, log, storedToken.FamilyID))
+		}
+
+		log.Error("failed to rotate refresh token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return token, nil
+	log.Info("tokens refreshed")
+
+	return tokens, nil
 }
 
-func (a *Auth) RegisterNewUser(ctx context.Context, email string, password string) (uuid.UUID, error) {
+// RegisterNewUser registers the user in the organization of the app with the password following the password policy of the app,
+// the user joins the default organization under the global policy when appID is uuid.Nil.
+func (a *Auth) RegisterNewUser(ctx context.Context, email string, password string, appID uuid.UUID) (uuid.UUID, error) {
 	const op = "auth.RegisterNewUser"
 	log := a.log.With(
 		slog.String("op", op),
@@ -147,13 +683,22 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, password strin
 	)
 	log.Info("registering new user")
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	app, err := a.optionalApp(ctx, log, appID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkPassword(log, a.passwordPolicyOf(app), password, email); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := a.passwordHasher.Hash(password)
 	if err != nil {
 		log.Error("failed to generate passwordHash", sl.Err(err))
 		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	user, err := a.userSaver.SaveUser(ctx, uuid.New().String(), email, passwordHash)
+	user, err := a.userSaver.SaveUser(ctx, app.OrgID, uuid.New().String(), email, passwordHash)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
 			log.Error("user exists", sl.Err(err))
@@ -166,6 +711,11 @@ func (a *Auth) RegisterNewUser(ctx context.Context, email string, password strin
 
 	log.Info("user registered")
 
+	// the user can ask for another email, so failing to send it doesn't fail the registration
+	if err := a.sendVerificationEmail(ctx, user); err != nil {
+		log.Error("failed to send verification email", sl.Err(err))
+	}
+
 	return user.ID, nil
 }
 
@@ -190,33 +740,85 @@ func (a *Auth) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 	return isAdmin, nil
 }
 
-func (a *Auth) CreateApp(ctx context.Context, name, secret string) (uuid.UUID, error) {
+// CreateApp creates a new app, apps are confidential OAuth clients unless newApp.ClientType says otherwise.
+// Apps join the default organization when newApp.OrgID is uuid.Nil. The owner of the token must be an admin
+// of the organization or have PermissionManageOrganizations, the default organization included.
+// The secret is generated here and returned with the app only once.
+func (a *Auth) CreateApp(ctx context.Context, token string, newApp models.App) (models.App, error) {
 	const op = "auth.CreateApp"
 	log := a.log.With("op", op)
 	log.Info("creating new app")
 
-	app, err := a.appProvider.CreateApp(ctx, uuid.New().String(), name, secret)
+	if newApp.OrgID == uuid.Nil {
+		newApp.OrgID = models.DefaultOrgID
+	}
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.authorizeOrganization(ctx, log, claims.UserID, newApp.OrgID, PermissionManageOrganizations); err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, claim := range newApp.Claims {
+		if _, ok := supportedClaims[claim]; !ok {
+			log.Warn("unsupported claim", slog.String("claim", claim))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrUnsupportedClaim)
+		}
+	}
+
+	if newApp.PasswordPolicy != nil {
+		if err := passwordpolicy.Validate(*newApp.PasswordPolicy); err != nil {
+			log.Warn("invalid password policy", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidPasswordPolicy)
+		}
+	}
+
+	newApp.ID = uuid.New()
+	if newApp.ClientType == "" {
+		newApp.ClientType = models.ClientTypeConfidential
+	}
+
+	newApp.Secret, err = opaque.NewToken(appSecretSize)
+	if err != nil {
+		log.Error("failed to generate app secret", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.CreateApp(ctx, newApp)
 	if err != nil {
 		if errors.Is(err, storage.ErrAppExists) {
 			log.Error("app exists", sl.Err(err))
-			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrAppExists)
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrAppExists)
+		}
+
+		if errors.Is(err, storage.ErrOrganizationNotFound) {
+			log.Warn("organization not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrOrganizationNotFound)
 		}
 
 		log.Error("failed to create app", sl.Err(err))
-		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("created new app", slog.String("appName", app.Name))
 
-	return app.ID, nil
+	return app, nil
 }
 
-func (a *Auth) App(ctx context.Context, name string) (models.App, error) {
+// App returns the app of the organization with the name, the default organization when orgID is uuid.Nil
+func (a *Auth) App(ctx context.Context, orgID uuid.UUID, name string) (models.App, error) {
 	const op = "auth.App"
 	log := a.log.With("op", op)
 	log.Info("getting app")
 
-	app, err := a.appProvider.FindApp(ctx, name)
+	if orgID == uuid.Nil {
+		orgID = models.DefaultOrgID
+	}
+
+	app, err := a.appProvider.FindApp(ctx, orgID, name)
 	if err != nil {
 		if errors.Is(err, storage.ErrAppNotFound) {
 			log.Error("app not found", sl.Err(err))
@@ -230,6 +832,135 @@ func (a *Auth) App(ctx context.Context, name string) (models.App, error) {
 	return app, nil
 }
 
+// newTokenPair generates access token and refresh token belonging to the familyID.
+// The returned models.RefreshToken holds only the hash and must be persisted by the caller.
+func (a *Auth) newTokenPair(ctx context.Context, user *models.User, app models.App, familyID uuid.UUID, scope string) (models.TokenPair, models.RefreshToken, error) {
+	version, err := a.revokedTokenProvider.TokenVersion(ctx, user.ID.String())
+	if err != nil {
+		return models.TokenPair{}, models.RefreshToken{}, err
+	}
+
+	signingKey, err := a.keyProvider.SigningKey(ctx)
+	if err != nil {
+		return models.TokenPair{}, models.RefreshToken{}, err
+	}
+
+	extraClaims, err := a.appClaims(ctx, user, app)
+	if err != nil {
+		return models.TokenPair{}, models.RefreshToken{}, err
+	}
+
+	if scope != "" {
+		extraClaims["scope"] = scope
+	}
+
+	tokenTTL := a.appTokenTTL(app)
+
+	accessToken, err := jwt.NewToken(user, app, jwt.TokenOptions{
+		Issuer:      a.issuer,
+		TTL:         tokenTTL,
+		Version:     version,
+		SessionID:   familyID.String(),
+		Key:         signingKey,
+		ExtraClaims: extraClaims,
+	})
+	if err != nil {
+		return models.TokenPair{}, models.RefreshToken{}, err
+	}
+
+	refreshToken, err := opaque.NewToken(refreshTokenSize)
+	if err != nil {
+		return models.TokenPair{}, models.RefreshToken{}, err
+	}
+
+	storedToken := models.RefreshToken{
+		ID:        uuid.New(),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		AppID:     app.ID,
+		TokenHash: opaque.Hash(refreshToken),
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(a.refreshTokenTTL),
+	}
+
+	return models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Scope:        scope,
+		ExpiresIn:    tokenTTL,
+		SessionID:    familyID,
+	}, storedToken, nil
+}
+
+// appTokenTTL returns the token TTL of the app, falling back to the global one
+func (a *Auth) appTokenTTL(app models.App) time.Duration {
+	if app.TokenTTL > 0 {
+		return app.TokenTTL
+	}
+
+	return a.tokenTTL
+}
+
+// appClaims resolves the extra claims the app requested for its tokens
+func (a *Auth) appClaims(ctx context.Context, user *models.User, app models.App) (map[string]any, error) {
+	claims := make(map[string]any, len(app.Claims))
+
+	var roles []models.Role
+	if slices.Contains(app.Claims, ClaimRoles) || slices.Contains(app.Claims, ClaimPermissions) {
+		var err error
+		if roles, err = a.userRoles(ctx, user.ID, app.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, claim := range app.Claims {
+		switch claim {
+		case ClaimRoles:
+			claims[ClaimRoles] = roleNames(roles)
+		case ClaimPermissions:
+			claims[ClaimPermissions] = rolePermissions(roles)
+		case ClaimIsAdmin:
+			isAdmin, err := a.userProvider.IsAdmin(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			claims[ClaimIsAdmin] = isAdmin
+		case ClaimGroups:
+			groups, err := a.groupProvider.UserGroups(ctx, user.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			claims[ClaimGroups] = groupNames(groups)
+		}
+	}
+
+	return claims, nil
+}
+
+// revokeReusedFamily revokes every token of the family after a rotated refresh token was presented again
+func (a *Auth) revokeReusedFamily(ctx context.Context, log *slog.Logger, familyID uuid.UUID) error {
+	log.Warn("refresh token reuse detected, revoking token family")
+
+	if err := a.refreshTokenProvider.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		log.Error("failed to revoke token family", sl.Err(err))
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// clientAddr returns the address of the peer, HTTP handlers set it from the request remote address
+func clientAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	return p.Addr.String()
+}
+
 func (a *Auth) handleFailedLogin(userID uuid.UUID, failedLoginAttempt models.FailedLogin, isFirstAttempt bool) models.FailedLogin {
 	now := time.Now()
 
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/google/uuid"
+)
+
+// ClientToken issues a token for the app itself (OAuth 2.0 client credentials grant).
+// Only confidential apps can get one, the token carries the requested app scopes or all of them if none requested.
+func (a *Auth) ClientToken(ctx context.Context, appID uuid.UUID, secret string, scopes []string) (models.TokenPair, error) {
+	const op = "auth.ClientToken"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", appID.String()),
+	)
+	log.Info("issuing client token")
+
+	// limited before authentication, so secrets can't be brute forced either
+	allowed, err := a.rateLimiter.Allow(ctx, fmt.Sprintf("clientToken:%s", appID), a.clientRateLimit)
+	if err != nil {
+		log.Error("failed to check rate limit", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !allowed {
+		log.Warn("client token rate limit exceeded")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrRateLimited)
+	}
+
+	app, err := a.authenticateClient(ctx, appID, secret)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			log.Warn("client authentication failed", sl.Err(err))
+		} else {
+			log.Error("failed to authenticate client", sl.Err(err))
+		}
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.ClientType == models.ClientTypePublic {
+		log.Warn("public client can't use client credentials")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrUnauthorizedClient)
+	}
+
+	if len(scopes) == 0 {
+		scopes = app.Scopes
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(app.Scopes, scope) {
+			log.Warn("scope is not assigned to the app", slog.String("scope", scope))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidScope)
+		}
+	}
+
+	signingKey, err := a.keyProvider.SigningKey(ctx)
+	if err != nil {
+		log.Error("failed to get signing key", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scope := strings.Join(scopes, " ")
+
+	token, err := jwt.NewClientToken(app, jwt.ClientTokenOptions{
+		Issuer: a.issuer,
+		TTL:    a.clientTokenTTL,
+		Key:    signingKey,
+		Scope:  scope,
+	})
+	if err != nil {
+		log.Error("failed to generate client token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("client token issued")
+
+	return models.TokenPair{AccessToken: token, Scope: scope, ExpiresIn: a.clientTokenTTL}, nil
+}
@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	deviceCodeSize     = 32
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 * time.Second
+	userCodeLength     = 8
+	// userCodeAlphabet has no vowels and look-alike characters (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+)
+
+// DeviceAuthorization starts the device authorization grant (RFC 8628) for the client
+func (a *Auth) DeviceAuthorization(ctx context.Context, clientID uuid.UUID, clientSecret string, scopes []string) (models.DeviceCode, error) {
+	const op = "auth.DeviceAuthorization"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", clientID.String()),
+	)
+	log.Info("starting device authorization")
+
+	if _, err := a.authenticateClient(ctx, clientID, clientSecret); err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			log.Warn("client authentication failed", sl.Err(err))
+		} else {
+			log.Error("failed to authenticate client", sl.Err(err))
+		}
+
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, err := opaque.NewToken(deviceCodeSize)
+	if err != nil {
+		log.Error("failed to generate device code", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userCode, err := newUserCode()
+	if err != nil {
+		log.Error("failed to generate user code", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	authorization := models.DeviceAuthorization{
+		AppID:    clientID,
+		UserCode: userCode,
+		Scopes:   supportedScopes(scopes),
+		Status:   models.DeviceStatusPending,
+	}
+
+	if err := a.deviceProvider.SaveDeviceAuthorization(ctx, opaque.Hash(deviceCode), authorization, deviceCodeTTL); err != nil {
+		log.Error("failed to save device authorization", sl.Err(err))
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.DeviceCode{
+		DeviceCode: deviceCode,
+		UserCode:   formatUserCode(userCode),
+		ExpiresIn:  deviceCodeTTL,
+		Interval:   devicePollInterval,
+	}, nil
+}
+
+// VerifyDevice authenticates the user of the organization of the client on the verification page and records the decision on the user code.
+// Users with MFA get an MFA token instead, VerifyDeviceMFA records the decision for it.
+func (a *Auth) VerifyDevice(ctx context.Context, userCode string, email string, password string, approve bool) (string, error) {
+	const op = "auth.VerifyDevice"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("username", email),
+	)
+	log.Info("verifying device")
+
+	_, authorization, err := a.pendingDeviceAuthorization(ctx, log, userCode)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, authorization.AppID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, mfaRequired, err := a.authenticate(ctx, log, app.OrgID, email, password)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if mfaRequired {
+		mfaToken, err := a.newMFAChallenge(ctx, user.ID, app.ID, models.MFAPurposeDevice)
+		if err != nil {
+			log.Error("failed to create mfa challenge", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("mfa required")
+
+		return mfaToken, nil
+	}
+
+	if err := a.decideDevice(ctx, log, userCode, user.ID, []string{models.AMRPassword}, approve); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return "", nil
+}
+
+// VerifyDeviceMFA records the decision on the user code once the user passes the second factor of the VerifyDevice challenge
+func (a *Auth) VerifyDeviceMFA(ctx context.Context, userCode string, mfaToken string, otp string, approve bool) error {
+	const op = "auth.VerifyDeviceMFA"
+	log := a.log.With(slog.String("op", op))
+	log.Info("verifying device with mfa")
+
+	_, authorization, err := a.pendingDeviceAuthorization(ctx, log, userCode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, user, err := a.passMFAChallenge(ctx, log, mfaToken, otp, models.MFAPurposeDevice, authorization.AppID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.decideDevice(ctx, log, userCode, user.ID, []string{models.AMRPassword, models.AMROTP}, approve); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ApproveDevice records the decision of the user signed in with the access token on the user code
+func (a *Auth) ApproveDevice(ctx context.Context, token string, userCode string, approve bool) error {
+	const op = "auth.ApproveDevice"
+	log := a.log.With(slog.String("op", op))
+	log.Info("approving device")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the user signed in with a password to get the access token
+	if err := a.decideDevice(ctx, log, userCode, claims.UserID, []string{models.AMRPassword}, approve); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeviceToken is polled by the device until the user decides on its request
+func (a *Auth) DeviceToken(ctx context.Context, clientID uuid.UUID, clientSecret string, deviceCode string) (models.TokenPair, error) {
+	const op = "auth.DeviceToken"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", clientID.String()),
+	)
+
+	app, err := a.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			log.Warn("client authentication failed", sl.Err(err))
+		} else {
+			log.Error("failed to authenticate client", sl.Err(err))
+		}
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCodeHash := opaque.Hash(deviceCode)
+
+	authorization, err := a.deviceProvider.DeviceAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			// the request may be unknown as well, but it can't be told from the expired one
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrExpiredToken)
+		}
+
+		log.Error("failed to get device authorization", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if authorization.AppID != app.ID {
+		log.Warn("device code was issued to another client")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+
+	polled, err := a.deviceProvider.MarkDevicePoll(ctx, deviceCodeHash, devicePollInterval)
+	if err != nil {
+		log.Error("failed to mark device poll", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !polled {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrSlowDown)
+	}
+
+	switch authorization.Status {
+	case models.DeviceStatusPending:
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAuthPending)
+	case models.DeviceStatusDenied:
+		if _, err := a.deviceProvider.DeleteDeviceAuthorization(ctx, deviceCodeHash, authorization.UserCode); err != nil {
+			log.Error("failed to delete device authorization", sl.Err(err))
+		}
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	}
+
+	deleted, err := a.deviceProvider.DeleteDeviceAuthorization(ctx, deviceCodeHash, authorization.UserCode)
+	if err != nil {
+		log.Error("failed to delete device authorization", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !deleted {
+		log.Warn("device code was already exchanged")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, authorization.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.issueTokens(ctx, log, &user, app, strings.Join(authorization.Scopes, " "))
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if slices.Contains(authorization.Scopes, models.ScopeOpenID) {
+		code := models.AuthorizationCode{
+			Scopes:   authorization.Scopes,
+			AuthTime: authorization.AuthTime,
+			AMR:      authorization.AMR,
+		}
+
+		if tokens.IDToken, err = a.newIDToken(ctx, &user, app, code, tokens.SessionID); err != nil {
+			log.Error("failed to generate id token", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("device authorized", slog.String("userID", user.ID.String()))
+
+	return tokens, nil
+}
+
+func (a *Auth) decideDevice(ctx context.Context, log *slog.Logger, userCode string, userID uuid.UUID, amr []string, approve bool) error {
+	deviceCodeHash, authorization, err := a.pendingDeviceAuthorization(ctx, log, userCode)
+	if err != nil {
+		return err
+	}
+
+	authorization.Status = models.DeviceStatusDenied
+	if approve {
+		authorization.Status = models.DeviceStatusApproved
+		authorization.UserID = userID
+		authorization.AuthTime = time.Now()
+		authorization.AMR = amr
+	}
+
+	if err := a.deviceProvider.UpdateDeviceAuthorization(ctx, deviceCodeHash, authorization); err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			return ErrInvalidUserCode
+		}
+
+		log.Error("failed to update device authorization", sl.Err(err))
+		return err
+	}
+
+	log.Info("device authorization decided", slog.String("status", authorization.Status))
+
+	return nil
+}
+
+// pendingDeviceAuthorization returns the undecided device authorization of the user code with the hash of its device code
+func (a *Auth) pendingDeviceAuthorization(ctx context.Context, log *slog.Logger, userCode string) (string, models.DeviceAuthorization, error) {
+	deviceCodeHash, err := a.deviceProvider.DeviceCodeHash(ctx, normalizeUserCode(userCode))
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			log.Warn("user code not found")
+			return "", models.DeviceAuthorization{}, ErrInvalidUserCode
+		}
+
+		log.Error("failed to get device code", sl.Err(err))
+		return "", models.DeviceAuthorization{}, err
+	}
+
+	authorization, err := a.deviceProvider.DeviceAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			return "", models.DeviceAuthorization{}, ErrInvalidUserCode
+		}
+
+		log.Error("failed to get device authorization", sl.Err(err))
+		return "", models.DeviceAuthorization{}, err
+	}
+
+	if authorization.Status != models.DeviceStatusPending {
+		log.Warn("device authorization was already decided")
+		return "", models.DeviceAuthorization{}, ErrInvalidUserCode
+	}
+
+	return deviceCodeHash, authorization, nil
+}
+
+func newUserCode() (string, error) {
+	return randomCode(userCodeAlphabet, userCodeLength)
+}
+
+// randomCode returns the code of uniformly chosen characters of the alphabet
+func randomCode(alphabet string, length int) (string, error) {
+	code := make([]byte, length)
+	alphabetSize := big.NewInt(int64(len(alphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+
+		code[i] = alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// formatUserCode splits the user code in halves for readability, e.g. WDJB-MJHT
+func formatUserCode(userCode string) string {
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+// normalizeUserCode drops separators and case the user could type the code with
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(userCode))
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// purposeEmailVerification is the audience of the tokens in verification links
+const purposeEmailVerification = "email_verification"
+
+// verificationRateLimit limits verification emails sent to an address
+var verificationRateLimit = models.RateLimit{Limit: 5, Window: time.Hour}
+
+const verificationEmailBody = `Hello,
+
+Open the link below to verify your email:
+
+%s
+
+The link expires in %s. If you didn't create an account, ignore this email.
+`
+
+// VerifyEmail marks the email of the user verified with the token from the verification link.
+// Verifying an already verified email succeeds.
+func (a *Auth) VerifyEmail(ctx context.Context, token string) error {
+	const op = "auth.VerifyEmail"
+	log := a.log.With(slog.String("op", op))
+	log.Info("verifying email")
+
+	claims, err := jwt.ParseActionToken(token, a.issuer, purposeEmailVerification, a.emailLinks.TokenKey)
+	if err != nil {
+		log.Warn("invalid verification token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the token verifies only the email it was sent to
+	if claims.State != emailState(user.Email) {
+		log.Warn("verification token was sent to another email")
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+	}
+
+	if user.EmailVerified {
+		log.Info("email already verified")
+		return nil
+	}
+
+	if _, err := a.userSaver.SetEmailVerified(ctx, user.ID, user.Email); err != nil {
+		log.Error("failed to set email verified", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified")
+
+	return nil
+}
+
+// ResendVerification sends the verification email to the user of the organization of the app again,
+// the user of the default organization when appID is uuid.Nil.
+// Unknown and verified emails are silently skipped, so the result doesn't reveal registered emails.
+func (a *Auth) ResendVerification(ctx context.Context, email string, appID uuid.UUID) error {
+	const op = "auth.ResendVerification"
+	log := a.log.With(slog.String("op", op))
+	log.Info("resending verification email")
+
+	allowed, err := a.rateLimiter.Allow(ctx, fmt.Sprintf("verificationEmail:%s", opaque.Hash(email)), verificationRateLimit)
+	if err != nil {
+		log.Error("failed to check rate limit", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !allowed {
+		log.Warn("verification email rate limited")
+		return fmt.Errorf("%s: %w", op, ErrRateLimited)
+	}
+
+	app, err := a.optionalApp(ctx, log, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, app.OrgID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.EmailVerified {
+		log.Info("email already verified")
+		return nil
+	}
+
+	if err := a.sendVerificationEmail(ctx, user); err != nil {
+		log.Error("failed to send verification email", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// sendVerificationEmail mails the user the link with a token bound to the current email
+func (a *Auth) sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := jwt.NewActionToken(user.ID, jwt.ActionTokenOptions{
+		Issuer:  a.issuer,
+		Purpose: purposeEmailVerification,
+		TTL:     a.emailLinks.VerificationTTL,
+		Key:     a.emailLinks.TokenKey,
+		State:   emailState(user.Email),
+	})
+	if err != nil {
+		return err
+	}
+
+	link, err := linkWithToken(a.emailLinks.VerificationURL, token)
+	if err != nil {
+		return err
+	}
+
+	return a.mailer.Send(ctx, models.Email{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf(verificationEmailBody, link, a.emailLinks.VerificationTTL),
+	})
+}
+
+// checkEmailVerified denies tokens of the app requiring verified emails to users who haven't verified theirs
+func checkEmailVerified(log *slog.Logger, user *models.User, app models.App) error {
+	if app.RequireVerifiedEmail && !user.EmailVerified {
+		log.Warn("email is not verified", slog.String("userID", user.ID.String()))
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
+
+// emailState binds tokens sent by email to the address they were sent to
+func emailState(email string) string {
+	return opaque.Hash(email)
+}
+
+// linkWithToken adds the token to the page URL as the token query parameter
+func linkWithToken(pageURL string, token string) (string, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), nil
+}
@@ -3,11 +3,53 @@ package auth
 import "errors"
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrInvalidUserID      = errors.New("invalid userID")
-	ErrUserExists         = errors.New("user exists")
-	ErrAppExists          = errors.New("app exists")
-	ErrAppNotFound        = errors.New("app not found")
-	ErrUserNotFound       = errors.New("user not found")
-	ErrAccountIsLocked    = errors.New("account is locked")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrInvalidUserID         = errors.New("invalid userID")
+	ErrUserExists            = errors.New("user exists")
+	ErrAppExists             = errors.New("app exists")
+	ErrAppNotFound           = errors.New("app not found")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrAccountIsLocked       = errors.New("account is locked")
+	ErrInvalidRefreshToken   = errors.New("invalid refresh token")
+	ErrRefreshTokenReused    = errors.New("refresh token reused")
+	ErrInvalidToken          = errors.New("invalid token")
+	ErrUnsupportedClaim      = errors.New("unsupported claim")
+	ErrInvalidClient         = errors.New("invalid client")
+	ErrInvalidRedirectURI    = errors.New("invalid redirect uri")
+	ErrPKCERequired          = errors.New("pkce is required")
+	ErrInvalidGrant          = errors.New("invalid grant")
+	ErrInsufficientScope     = errors.New("insufficient scope")
+	ErrInvalidScope          = errors.New("invalid scope")
+	ErrUnauthorizedClient    = errors.New("unauthorized client")
+	ErrRateLimited           = errors.New("rate limit exceeded")
+	ErrInvalidUserCode       = errors.New("invalid user code")
+	ErrAuthPending           = errors.New("authorization pending")
+	ErrSlowDown              = errors.New("slow down")
+	ErrAccessDenied          = errors.New("access denied")
+	ErrExpiredToken          = errors.New("expired token")
+	ErrInvalidMFACode        = errors.New("invalid mfa code")
+	ErrInvalidMFAToken       = errors.New("invalid mfa token")
+	ErrMFAAlreadyEnabled     = errors.New("mfa already enabled")
+	ErrMFANotEnrolled        = errors.New("mfa not enrolled")
+	ErrInvalidPasskey        = errors.New("invalid passkey")
+	ErrPasskeyExists         = errors.New("passkey already registered")
+	ErrEmailNotVerified      = errors.New("email not verified")
+	ErrInvalidEmailToken     = errors.New("invalid email token")
+	ErrWeakPassword          = errors.New("password violates the password policy")
+	ErrInvalidPasswordPolicy = errors.New("invalid password policy")
+	ErrMagicLinkDisabled     = errors.New("magic link disabled")
+	ErrSessionNotFound       = errors.New("session not found")
+	ErrPermissionDenied      = errors.New("permission denied")
+	ErrInvalidSSOSession     = errors.New("invalid sso session")
+	ErrRoleNotFound          = errors.New("role not found")
+	ErrRoleExists            = errors.New("role exists")
+	ErrBuiltInRole           = errors.New("built-in role")
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrOrganizationExists    = errors.New("organization exists")
+	ErrMemberNotFound        = errors.New("organization member not found")
+	ErrGroupNotFound         = errors.New("group not found")
+	ErrGroupExists           = errors.New("group exists")
+	ErrGroupCycle            = errors.New("group membership cycle")
+	ErrRelationTooDeep       = errors.New("relationship graph is too deep")
+	ErrAPIKeyNotFound        = errors.New("api key not found")
 )
@@ -0,0 +1,491 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// CreateGroup creates the group in the organization of the token owner,
+// who must be an admin of the organization or have the global PermissionManageGroups
+func (a *Auth) CreateGroup(ctx context.Context, token string, name string) (uuid.UUID, error) {
+	const op = "auth.CreateGroup"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", name),
+	)
+	log.Info("creating group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.authorizeOrganization(ctx, log, claims.UserID, claims.OrgID, PermissionManageGroups); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group := models.Group{
+		ID:    uuid.New(),
+		OrgID: claims.OrgID,
+		Name:  name,
+	}
+
+	if err := a.groupProvider.SaveGroup(ctx, group); err != nil {
+		if errors.Is(err, storage.ErrGroupExists) {
+			log.Warn("group already exists", sl.Err(err))
+			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrGroupExists)
+		}
+
+		log.Error("failed to save group", sl.Err(err))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("group created", slog.String("groupID", group.ID.String()))
+
+	return group.ID, nil
+}
+
+// DeleteGroup deletes the group, its members lose the roles granted to it
+func (a *Auth) DeleteGroup(ctx context.Context, token string, groupID uuid.UUID) error {
+	const op = "auth.DeleteGroup"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+	)
+	log.Info("deleting group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedGroup(ctx, log, claims.UserID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.groupProvider.DeleteGroup(ctx, groupID); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to delete group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("group deleted")
+
+	return nil
+}
+
+// ListGroups returns the groups of the organization of the token owner
+func (a *Auth) ListGroups(ctx context.Context, token string) ([]models.Group, error) {
+	const op = "auth.ListGroups"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing groups")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := a.groupProvider.Groups(ctx, claims.OrgID)
+	if err != nil {
+		log.Error("failed to get groups", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// AddGroupUser adds the user of the organization of the group to the group
+func (a *Auth) AddGroupUser(ctx context.Context, token string, groupID uuid.UUID, userID uuid.UUID) error {
+	const op = "auth.AddGroupUser"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("userID", userID.String()),
+	)
+	log.Info("adding user to group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := a.managedGroup(ctx, log, claims.UserID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.OrgID != group.OrgID {
+		log.Warn("user belongs to another organization", slog.String("orgID", user.OrgID.String()))
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	if err := a.groupProvider.AddGroupUser(ctx, groupID, userID); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to add user to group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user added to group")
+
+	return nil
+}
+
+// RemoveGroupUser removes the user from the group, memberships through subgroups stay
+func (a *Auth) RemoveGroupUser(ctx context.Context, token string, groupID uuid.UUID, userID uuid.UUID) error {
+	const op = "auth.RemoveGroupUser"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("userID", userID.String()),
+	)
+	log.Info("removing user from group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedGroup(ctx, log, claims.UserID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.groupProvider.RemoveGroupUser(ctx, groupID, userID); err != nil {
+		log.Error("failed to remove user from group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user removed from group")
+
+	return nil
+}
+
+// AddSubgroup makes the subgroup of the same organization a member of the group.
+// Nesting the group in itself, directly or through other groups, fails with ErrGroupCycle.
+func (a *Auth) AddSubgroup(ctx context.Context, token string, groupID uuid.UUID, subgroupID uuid.UUID) error {
+	const op = "auth.AddSubgroup"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("subgroupID", subgroupID.String()),
+	)
+	log.Info("adding subgroup")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := a.managedGroup(ctx, log, claims.UserID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.orgGroup(ctx, log, group.OrgID, subgroupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.groupProvider.AddSubgroup(ctx, groupID, subgroupID); err != nil {
+		if errors.Is(err, storage.ErrGroupCycle) {
+			log.Warn("subgroup would form a cycle", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupCycle)
+		}
+
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		log.Error("failed to add subgroup", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("subgroup added")
+
+	return nil
+}
+
+// RemoveSubgroup removes the subgroup from the group
+func (a *Auth) RemoveSubgroup(ctx context.Context, token string, groupID uuid.UUID, subgroupID uuid.UUID) error {
+	const op = "auth.RemoveSubgroup"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("subgroupID", subgroupID.String()),
+	)
+	log.Info("removing subgroup")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedGroup(ctx, log, claims.UserID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.groupProvider.RemoveSubgroup(ctx, groupID, subgroupID); err != nil {
+		log.Error("failed to remove subgroup", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("subgroup removed")
+
+	return nil
+}
+
+// ListGroupMembers returns the users and the direct subgroups of the group of the organization of the token owner.
+// With transitive set the users of the subgroups at any depth are listed too.
+func (a *Auth) ListGroupMembers(ctx context.Context, token string, groupID uuid.UUID, transitive bool) ([]models.GroupUser, []models.Group, error) {
+	const op = "auth.ListGroupMembers"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.Bool("transitive", transitive),
+	)
+	log.Info("listing group members")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.orgGroup(ctx, log, claims.OrgID, groupID); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users, err := a.groupProvider.GroupUsers(ctx, groupID, transitive)
+	if err != nil {
+		log.Error("failed to get group users", sl.Err(err))
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	subgroups, err := a.groupProvider.Subgroups(ctx, groupID)
+	if err != nil {
+		log.Error("failed to get subgroups", sl.Err(err))
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, subgroups, nil
+}
+
+// ListUserGroups returns the groups the user belongs to directly or through subgroups, the owner of the token when userID is uuid.Nil.
+// Groups of other users are listed only to those who manage the groups of their organization.
+func (a *Auth) ListUserGroups(ctx context.Context, token string, userID uuid.UUID) ([]models.Group, error) {
+	const op = "auth.ListUserGroups"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing user groups")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == uuid.Nil {
+		userID = claims.UserID
+	}
+
+	log = log.With(slog.String("userID", userID.String()))
+
+	if userID != claims.UserID {
+		user, err := a.userProvider.UserByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				log.Warn("user not found", sl.Err(err))
+				return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+			}
+
+			log.Error("failed to get user", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := a.authorizeOrganization(ctx, log, claims.UserID, user.OrgID, PermissionManageGroups); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	groups, err := a.groupProvider.UserGroups(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user groups", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return groups, nil
+}
+
+// GrantGroupRole grants the role to the group, so all its members get it.
+// The owner of the token must manage both the role and the group, roles scoped to an app
+// can be granted only to the groups of the organization owning the app.
+func (a *Auth) GrantGroupRole(ctx context.Context, token string, groupID uuid.UUID, roleID uuid.UUID) error {
+	const op = "auth.GrantGroupRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("roleID", roleID.String()),
+	)
+	log.Info("granting role to group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := a.managedRole(ctx, log, claims.UserID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := a.managedGroup(ctx, log, claims.UserID, groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role.AppID != uuid.Nil {
+		app, err := a.appProvider.App(ctx, role.AppID)
+		if err != nil {
+			log.Error("failed to get app", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if app.OrgID != group.OrgID {
+			log.Warn("group belongs to another organization", slog.String("orgID", group.OrgID.String()))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+	}
+
+	if err := a.groupProvider.GrantGroupRole(ctx, groupID, roleID); err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrGroupNotFound)
+		}
+
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to grant role to group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role granted to group")
+
+	return nil
+}
+
+// RevokeGroupRole takes the role away from the group, the owner of the token must manage both the role and the group
+func (a *Auth) RevokeGroupRole(ctx context.Context, token string, groupID uuid.UUID, roleID uuid.UUID) error {
+	const op = "auth.RevokeGroupRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("groupID", groupID.String()),
+		slog.String("roleID", roleID.String()),
+	)
+	log.Info("revoking role from group")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedRole(ctx, log, claims.UserID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedGroup(ctx, log, claims.UserID, groupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.groupProvider.RevokeGroupRole(ctx, groupID, roleID); err != nil {
+		log.Error("failed to revoke role from group", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role revoked from group")
+
+	return nil
+}
+
+// managedGroup returns the group if the user can manage the groups of its organization
+func (a *Auth) managedGroup(ctx context.Context, log *slog.Logger, userID uuid.UUID, groupID uuid.UUID) (models.Group, error) {
+	group, err := a.group(ctx, log, groupID)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	if err := a.authorizeOrganization(ctx, log, userID, group.OrgID, PermissionManageGroups); err != nil {
+		return models.Group{}, err
+	}
+
+	return group, nil
+}
+
+// orgGroup returns the group of the organization, groups of other organizations look like unknown ones
+func (a *Auth) orgGroup(ctx context.Context, log *slog.Logger, orgID uuid.UUID, groupID uuid.UUID) (models.Group, error) {
+	group, err := a.group(ctx, log, groupID)
+	if err != nil {
+		return models.Group{}, err
+	}
+
+	if group.OrgID != orgID {
+		log.Warn("group belongs to another organization", slog.String("orgID", group.OrgID.String()))
+		return models.Group{}, ErrGroupNotFound
+	}
+
+	return group, nil
+}
+
+func (a *Auth) group(ctx context.Context, log *slog.Logger, groupID uuid.UUID) (models.Group, error) {
+	group, err := a.groupProvider.Group(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, storage.ErrGroupNotFound) {
+			log.Warn("group not found", sl.Err(err))
+			return models.Group{}, ErrGroupNotFound
+		}
+
+		log.Error("failed to get group", sl.Err(err))
+		return models.Group{}, err
+	}
+
+	return group, nil
+}
+
+// groupNames returns the names of the groups for the groups claim
+func groupNames(groups []models.Group) []string {
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// purposeMagicLink is the audience of the tokens in magic links
+const purposeMagicLink = "magic_link"
+
+// magicLinkRateLimit limits magic links sent to an address
+var magicLinkRateLimit = models.RateLimit{Limit: 5, Window: time.Hour}
+
+const magicLinkEmailBody = `Hello,
+
+Open the link below to sign in to %s:
+
+%s
+
+The link expires in %s and can be used once. If you didn't try to sign in, ignore this email.
+`
+
+// RequestMagicLink mails the user a single-use link signing in to the app without the password.
+// The link points to the redirect URI, which must be registered for the app, with the token in the token query parameter.
+// Unknown emails are silently skipped and mailer failures are only logged, so the result doesn't reveal registered emails.
+func (a *Auth) RequestMagicLink(ctx context.Context, email string, appID uuid.UUID, redirectURI string) error {
+	const op = "auth.RequestMagicLink"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("requesting magic link")
+
+	app, err := a.magicLinkApp(ctx, log, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !slices.Contains(app.RedirectURIs, redirectURI) {
+		log.Warn("redirect uri is not registered", slog.String("redirectURI", redirectURI))
+		return fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+
+	allowed, err := a.rateLimiter.Allow(ctx, fmt.Sprintf("magicLinkEmail:%s", opaque.Hash(email)), magicLinkRateLimit)
+	if err != nil {
+		log.Error("failed to check rate limit", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !allowed {
+		log.Warn("magic link email rate limited")
+		return fmt.Errorf("%s: %w", op, ErrRateLimited)
+	}
+
+	user, err := a.userProvider.User(ctx, app.OrgID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", user.ID.String()))
+
+	token, err := jwt.NewActionToken(user.ID, jwt.ActionTokenOptions{
+		Issuer:  a.issuer,
+		Purpose: purposeMagicLink,
+		TTL:     a.emailLinks.MagicLinkTTL,
+		Key:     a.emailLinks.TokenKey,
+		State:   magicLinkState(app.ID, user.Email),
+	})
+	if err != nil {
+		log.Error("failed to generate magic link token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.magicLinks.SaveMagicLink(ctx, opaque.Hash(token), a.emailLinks.MagicLinkTTL); err != nil {
+		log.Error("failed to save magic link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := linkWithToken(redirectURI, token)
+	if err != nil {
+		log.Error("failed to build magic link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mailer.Send(ctx, models.Email{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Sign in to %s", app.Name),
+		Body:    fmt.Sprintf(magicLinkEmailBody, app.Name, link, a.emailLinks.MagicLinkTTL),
+	}); err != nil {
+		// the response must not differ from the one for an unknown email
+		log.Error("failed to send magic link email", sl.Err(err))
+		return nil
+	}
+
+	log.Info("magic link sent")
+
+	return nil
+}
+
+// ConsumeMagicLink exchanges the token of the magic link for the tokens Login issues.
+// The link is accepted only by the app it was requested for, and it proves the user owns the email.
+func (a *Auth) ConsumeMagicLink(ctx context.Context, token string, appID uuid.UUID) (models.TokenPair, error) {
+	const op = "auth.ConsumeMagicLink"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("consuming magic link")
+
+	claims, err := jwt.ParseActionToken(token, a.issuer, purposeMagicLink, a.emailLinks.TokenKey)
+	if err != nil {
+		log.Warn("invalid magic link token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the link signs in only to the app it was requested for and only while the email stays the same
+	if claims.State != magicLinkState(appID, user.Email) {
+		log.Warn("magic link was requested for another app or email")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+	}
+
+	app, err := a.magicLinkApp(ctx, log, appID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.magicLinks.ConsumeMagicLink(ctx, opaque.Hash(token)); err != nil {
+		if errors.Is(err, storage.ErrMagicLinkNotFound) {
+			log.Warn("magic link already used", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to consume magic link", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !user.EmailVerified {
+		verified, err := a.userSaver.SetEmailVerified(ctx, user.ID, user.Email)
+		if err != nil {
+			log.Error("failed to set email verified", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		user.EmailVerified = verified
+	}
+
+	mfaRequired, err := a.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.loginTokens(ctx, log, &user, app, mfaRequired)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("signed in with magic link")
+
+	return tokens, nil
+}
+
+// magicLinkApp returns the app, failing with ErrMagicLinkDisabled when it doesn't accept magic links
+func (a *Auth) magicLinkApp(ctx context.Context, log *slog.Logger, appID uuid.UUID) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, ErrAppNotFound
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, err
+	}
+
+	if !app.MagicLinkEnabled {
+		log.Warn("magic link is disabled for the app")
+		return models.App{}, ErrMagicLinkDisabled
+	}
+
+	return app, nil
+}
+
+// magicLinkState binds magic links to the app they were requested for and the address they were sent to
+func magicLinkState(appID uuid.UUID, email string) string {
+	return opaque.Hash(appID.String() + ":" + email)
+}
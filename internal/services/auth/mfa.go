@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/encryption"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/lib/totp"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// EnrollMFA generates a new TOTP secret for the user signed in with the access token.
+// MFA is enabled once ConfirmMFA gets a code of the secret.
+func (a *Auth) EnrollMFA(ctx context.Context, token string) (models.MFAEnrollment, error) {
+	const op = "auth.EnrollMFA"
+	log := a.log.With(slog.String("op", op))
+	log.Info("enrolling mfa")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate totp secret", sl.Err(err))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	encryptedSecret, err := encryption.Encrypt(a.mfaEncryptionKey, secret)
+	if err != nil {
+		log.Error("failed to encrypt totp secret", sl.Err(err))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfaProvider.SaveTOTPSecret(ctx, user.ID, encryptedSecret); err != nil {
+		if errors.Is(err, storage.ErrMFAEnabled) {
+			log.Warn("mfa already enabled", sl.Err(err))
+			return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+		}
+
+		log.Error("failed to save totp secret", sl.Err(err))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("mfa enrollment started")
+
+	return models.MFAEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI(a.mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables MFA for the user signed in with the access token once the code of the enrolled secret matches.
+// Returns the recovery codes of the user, they are shown only once.
+func (a *Auth) ConfirmMFA(ctx context.Context, token string, code string) ([]string, error) {
+	const op = "auth.ConfirmMFA"
+	log := a.log.With(slog.String("op", op))
+	log.Info("confirming mfa")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	totpSecret, secret, err := a.totpSecret(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPSecretNotFound) {
+			log.Warn("mfa is not enrolled", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+		}
+
+		log.Error("failed to get totp secret", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !totpSecret.EnabledAt.IsZero() {
+		log.Warn("mfa already enabled")
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		log.Warn("invalid mfa code")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfaProvider.EnableTOTP(ctx, claims.UserID, step, recoveryCodeHashes); err != nil {
+		if errors.Is(err, storage.ErrMFAEnabled) {
+			log.Warn("mfa already enabled", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+		}
+
+		log.Error("failed to enable totp", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("mfa enabled")
+
+	return recoveryCodes, nil
+}
+
+// VerifyMFA completes the login started by Login with the code of the second factor
+func (a *Auth) VerifyMFA(ctx context.Context, mfaToken string, code string) (models.TokenPair, error) {
+	const op = "auth.VerifyMFA"
+	log := a.log.With(slog.String("op", op))
+	log.Info("verifying mfa")
+
+	// the login challenge names the app itself, challenges of the browser flows can't be completed here
+	challenge, user, err := a.passMFAChallenge(ctx, log, mfaToken, code, models.MFAPurposeLogin, uuid.Nil)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, challenge.AppID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.issueTokens(ctx, log, &user, app, "")
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tokens.SSOSessionToken, err = a.startSSOSession(ctx, log, user.ID); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+// mfaEnabled reports whether the user confirmed a TOTP enrollment
+func (a *Auth) mfaEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	secret, err := a.mfaProvider.TOTPSecret(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPSecretNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return !secret.EnabledAt.IsZero(), nil
+}
+
+// newMFAChallenge returns the token of the login of the flow of purpose to the app waiting for the second factor
+func (a *Auth) newMFAChallenge(ctx context.Context, userID uuid.UUID, appID uuid.UUID, purpose string) (string, error) {
+	mfaToken, err := opaque.NewToken(mfaChallengeSize)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := models.MFAChallenge{
+		UserID:  userID,
+		AppID:   appID,
+		Purpose: purpose,
+	}
+
+	if err := a.mfaChallengeProvider.SaveMFAChallenge(ctx, opaque.Hash(mfaToken), challenge, mfaChallengeTTL); err != nil {
+		return "", err
+	}
+
+	return mfaToken, nil
+}
+
+// passMFAChallenge checks the code of the second factor and consumes the challenge once it matches.
+// The challenge must be started by the flow of purpose for the app, uuid.Nil app accepts the app the challenge names.
+// A wrong code keeps the challenge so the user can retry until the account is locked out.
+func (a *Auth) passMFAChallenge(
+	ctx context.Context,
+	log *slog.Logger,
+	mfaToken string,
+	code string,
+	purpose string,
+	appID uuid.UUID,
+) (models.MFAChallenge, models.User, error) {
+	tokenHash := opaque.Hash(mfaToken)
+
+	challenge, err := a.mfaChallengeProvider.MFAChallenge(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFAChallengeNotFound) {
+			log.Warn("mfa challenge not found", sl.Err(err))
+			return models.MFAChallenge{}, models.User{}, ErrInvalidMFAToken
+		}
+
+		log.Error("failed to get mfa challenge", sl.Err(err))
+		return models.MFAChallenge{}, models.User{}, err
+	}
+
+	if challenge.Purpose != purpose || challenge.AppID == uuid.Nil || (appID != uuid.Nil && challenge.AppID != appID) {
+		log.Warn(
+			"mfa challenge was started by another flow",
+			slog.String("purpose", challenge.Purpose),
+			slog.String("appID", challenge.AppID.String()),
+		)
+		return models.MFAChallenge{}, models.User{}, ErrInvalidMFAToken
+	}
+
+	user, err := a.userProvider.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.MFAChallenge{}, models.User{}, ErrInvalidMFAToken
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.MFAChallenge{}, models.User{}, err
+	}
+
+	if err := a.verifySecondFactor(ctx, log, user, code); err != nil {
+		return models.MFAChallenge{}, models.User{}, err
+	}
+
+	deleted, err := a.mfaChallengeProvider.DeleteMFAChallenge(ctx, tokenHash)
+	if err != nil {
+		log.Error("failed to delete mfa challenge", sl.Err(err))
+		return models.MFAChallenge{}, models.User{}, err
+	}
+
+	if !deleted {
+		log.Warn("mfa challenge was already used")
+		return models.MFAChallenge{}, models.User{}, ErrInvalidMFAToken
+	}
+
+	return challenge, user, nil
+}
+
+// verifySecondFactor checks the TOTP or recovery code of the user,
+// wrong and reused codes count towards the account lockout like wrong passwords
+func (a *Auth) verifySecondFactor(ctx context.Context, log *slog.Logger, user models.User, code string) error {
+	failedLoginAttempt, isFirstAttempt, err := a.checkLockout(ctx, log, user.ID)
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	if isRecoveryCode(code) {
+		ok, err = a.useRecoveryCode(ctx, log, user.ID, code)
+	} else {
+		ok, err = a.useTOTPCode(ctx, log, user.ID, code)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		log.Warn("invalid mfa code")
+
+		if err := a.registerFailedAttempt(ctx, user, failedLoginAttempt, isFirstAttempt); err != nil {
+			return err
+		}
+
+		return ErrInvalidMFACode
+	}
+
+	return a.failedLoginsProvider.RemoveFailedLoginAttempts(ctx, user.ID.String())
+}
+
+// useTOTPCode checks the TOTP code and records its time step so it can't be replayed
+func (a *Auth) useTOTPCode(ctx context.Context, log *slog.Logger, userID uuid.UUID, code string) (bool, error) {
+	_, secret, err := a.totpSecret(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrTOTPSecretNotFound) {
+			log.Warn("mfa is not enrolled", sl.Err(err))
+			return false, ErrInvalidMFAToken
+		}
+
+		log.Error("failed to get totp secret", sl.Err(err))
+		return false, err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	used, err := a.mfaProvider.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		log.Error("failed to use totp step", sl.Err(err))
+		return false, err
+	}
+
+	return used, nil
+}
+
+// totpSecret returns the stored TOTP secret of the user with the decrypted secret
+func (a *Auth) totpSecret(ctx context.Context, userID uuid.UUID) (models.TOTPSecret, []byte, error) {
+	totpSecret, err := a.mfaProvider.TOTPSecret(ctx, userID)
+	if err != nil {
+		return models.TOTPSecret{}, nil, err
+	}
+
+	secret, err := encryption.Decrypt(a.mfaEncryptionKey, totpSecret.EncryptedSecret)
+	if err != nil {
+		return models.TOTPSecret{}, nil, err
+	}
+
+	return totpSecret, secret, nil
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ValidateAuthorizationRequest checks the client and the redirect URI of the authorization request.
+// Until it succeeds errors must be shown to the user instead of being sent to the redirect URI.
+func (a *Auth) ValidateAuthorizationRequest(ctx context.Context, req models.AuthorizationRequest) (models.App, error) {
+	const op = "auth.ValidateAuthorizationRequest"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", req.ClientID.String()),
+	)
+
+	app, err := a.appProvider.App(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("client not found", sl.Err(err))
+			return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !slices.Contains(app.RedirectURIs, req.RedirectURI) {
+		log.Warn("redirect uri is not registered", slog.String("redirectURI", req.RedirectURI))
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+
+	if req.CodeChallenge == "" && app.ClientType == models.ClientTypePublic {
+		log.Warn("public client without code challenge")
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrPKCERequired)
+	}
+
+	if req.CodeChallenge != "" && req.CodeChallengeMethod != models.CodeChallengeMethodS256 {
+		log.Warn("unsupported code challenge method", slog.String("method", req.CodeChallengeMethod))
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrPKCERequired)
+	}
+
+	return app, nil
+}
+
+// Authorize authenticates the user and grants an authorization code for the client.
+// Users with MFA get an MFA token instead of the code, AuthorizeMFA grants the code for it.
+func (a *Auth) Authorize(ctx context.Context, req models.AuthorizationRequest, email string, password string) (string, string, error) {
+	const op = "auth.Authorize"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", req.ClientID.String()),
+		slog.String("username", email),
+	)
+	log.Info("authorizing user")
+
+	app, err := a.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, mfaRequired, err := a.authenticate(ctx, log, app.OrgID, email, password)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkEmailVerified(log, &user, app); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if mfaRequired {
+		mfaToken, err := a.newMFAChallenge(ctx, user.ID, app.ID, models.MFAPurposeAuthorize)
+		if err != nil {
+			log.Error("failed to create mfa challenge", sl.Err(err))
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("mfa required")
+
+		return "", mfaToken, nil
+	}
+
+	code, err := a.grantAuthorizationCode(ctx, user.ID, req, []string{models.AMRPassword})
+	if err != nil {
+		log.Error("failed to grant authorization code", sl.Err(err))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code granted")
+
+	return code, "", nil
+}
+
+// AuthorizeMFA grants the authorization code once the user passes the second factor of the Authorize challenge
+func (a *Auth) AuthorizeMFA(ctx context.Context, req models.AuthorizationRequest, mfaToken string, otp string) (string, error) {
+	const op = "auth.AuthorizeMFA"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", req.ClientID.String()),
+	)
+	log.Info("authorizing user with mfa")
+
+	app, err := a.ValidateAuthorizationRequest(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, user, err := a.passMFAChallenge(ctx, log, mfaToken, otp, models.MFAPurposeAuthorize, app.ID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkEmailVerified(log, &user, app); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := a.grantAuthorizationCode(ctx, user.ID, req, []string{models.AMRPassword, models.AMROTP})
+	if err != nil {
+		log.Error("failed to grant authorization code", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("authorization code granted")
+
+	return code, nil
+}
+
+// ExchangeCode redeems the authorization code for the same token pair Login issues.
+// The code is consumed only by the client it was granted to, presenting it again revokes the tokens issued from it.
+func (a *Auth) ExchangeCode(ctx context.Context, exchange models.CodeExchange) (models.TokenPair, error) {
+	const op = "auth.ExchangeCode"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", exchange.ClientID.String()),
+	)
+	log.Info("exchanging authorization code")
+
+	app, err := a.authenticateClient(ctx, exchange.ClientID, exchange.ClientSecret)
+	if err != nil {
+		log.Warn("client authentication failed", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codeHash := opaque.Hash(exchange.Code)
+
+	code, err := a.authCodeProvider.AuthorizationCode(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrAuthCodeNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.checkCodeReuse(ctx, log, codeHash, app))
+		}
+
+		log.Error("failed to get authorization code", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if code.AppID != app.ID || code.RedirectURI != exchange.RedirectURI {
+		log.Warn("authorization code was granted to another client or redirect uri")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+
+	if !verifyCodeChallenge(code.CodeChallenge, exchange.CodeVerifier) {
+		log.Warn("code verifier does not match the code challenge")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+	}
+
+	// the redemption lives as long as the refresh token issued from the code
+	sessionID := uuid.New()
+	redeemed := models.RedeemedAuthorizationCode{AppID: app.ID, SessionID: sessionID}
+	if err := a.authCodeProvider.ConsumeAuthorizationCode(ctx, codeHash, redeemed, a.refreshTokenTTL); err != nil {
+		if errors.Is(err, storage.ErrAuthCodeNotFound) {
+			// a concurrent exchange consumed it first
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.checkCodeReuse(ctx, log, codeHash, app))
+		}
+
+		log.Error("failed to consume authorization code", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, code.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidGrant)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.issueSessionTokens(ctx, log, &user, app, sessionID, strings.Join(code.Scopes, " "))
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if slices.Contains(code.Scopes, models.ScopeOpenID) {
+		if tokens.IDToken, err = a.newIDToken(ctx, &user, app, code, tokens.SessionID); err != nil {
+			log.Error("failed to generate id token", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("authorization code exchanged")
+
+	return tokens, nil
+}
+
+// checkCodeReuse revokes the tokens issued from the code already redeemed by the client (RFC 6749 section 4.1.2),
+// it returns ErrInvalidGrant for the code not found as well
+func (a *Auth) checkCodeReuse(ctx context.Context, log *slog.Logger, codeHash string, app models.App) error {
+	redeemed, err := a.authCodeProvider.RedeemedAuthorizationCode(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrAuthCodeNotFound) {
+			log.Warn("authorization code not found", sl.Err(err))
+			return ErrInvalidGrant
+		}
+
+		log.Error("failed to get redeemed authorization code", sl.Err(err))
+		return err
+	}
+
+	// another client can't make the tokens of the code revoked
+	if redeemed.AppID != app.ID {
+		log.Warn("redeemed authorization code was granted to another client")
+		return ErrInvalidGrant
+	}
+
+	log.Warn("authorization code reuse detected, revoking tokens issued from it", slog.String("sessionID", redeemed.SessionID.String()))
+
+	if err := a.revokeSessionTokens(ctx, log, redeemed.SessionID, app.ID); err != nil {
+		return err
+	}
+
+	return ErrInvalidGrant
+}
+
+// authenticateClient checks the secret of confidential clients, public clients are identified by id only
+func (a *Auth) authenticateClient(ctx context.Context, clientID uuid.UUID, clientSecret string) (models.App, error) {
+	app, err := a.appProvider.App(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return models.App{}, ErrInvalidClient
+		}
+
+		return models.App{}, err
+	}
+
+	if app.ClientType == models.ClientTypePublic {
+		return app, nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(app.Secret), []byte(clientSecret)) != 1 {
+		return models.App{}, ErrInvalidClient
+	}
+
+	return app, nil
+}
+
+// authenticateConfidentialClient checks the credentials of an app calling the service on its own behalf,
+// public apps can't keep a secret and are refused with ErrUnauthorizedClient
+func (a *Auth) authenticateConfidentialClient(ctx context.Context, log *slog.Logger, appID uuid.UUID, secret string) (models.App, error) {
+	app, err := a.authenticateClient(ctx, appID, secret)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			log.Warn("client authentication failed", sl.Err(err))
+		} else {
+			log.Error("failed to authenticate client", sl.Err(err))
+		}
+
+		return models.App{}, err
+	}
+
+	if app.ClientType == models.ClientTypePublic {
+		log.Warn("public client is not allowed", slog.String("appID", appID.String()))
+		return models.App{}, ErrUnauthorizedClient
+	}
+
+	return app, nil
+}
+
+// grantAuthorizationCode saves the code with the authentication details the ID token is issued with.
+// Scopes the service does not know are dropped.
+func (a *Auth) grantAuthorizationCode(ctx context.Context, userID uuid.UUID, req models.AuthorizationRequest, amr []string) (string, error) {
+	code, err := opaque.NewToken(authCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	authCode := models.AuthorizationCode{
+		UserID:              userID,
+		AppID:               req.ClientID,
+		RedirectURI:         req.RedirectURI,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Scopes:              supportedScopes(req.Scopes),
+		Nonce:               req.Nonce,
+		AuthTime:            time.Now(),
+		AMR:                 amr,
+	}
+
+	if err := a.authCodeProvider.SaveAuthorizationCode(ctx, opaque.Hash(code), authCode, authCodeTTL); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// verifyCodeChallenge checks the S256 PKCE code verifier, codes granted without a challenge need no verifier
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+
+	hash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// SupportedScopes are the OAuth scopes the service grants
+var SupportedScopes = []string{models.ScopeOpenID, models.ScopeEmail, models.ScopeProfile}
+
+// UserInfo returns the claims about the token owner released for the scopes of the access token
+func (a *Auth) UserInfo(ctx context.Context, accessToken string) (models.UserInfo, error) {
+	const op = "auth.UserInfo"
+	log := a.log.With(slog.String("op", op))
+
+	claims, err := a.ValidateToken(ctx, accessToken)
+	if err != nil {
+		return models.UserInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, models.ScopeOpenID) {
+		log.Warn("access token was granted without openid scope")
+		return models.UserInfo{}, fmt.Errorf("%s: %w", op, ErrInsufficientScope)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.UserInfo{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.UserInfo{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	userInfo := models.UserInfo{Subject: user.ID}
+	if slices.Contains(scopes, models.ScopeEmail) {
+		userInfo.Email = user.Email
+	}
+	if slices.Contains(scopes, models.ScopeProfile) {
+		userInfo.PreferredUsername = user.Email
+	}
+
+	return userInfo, nil
+}
+
+// EndSession ends the session identified by the ID token hint by revoking its refresh token family.
+// Returns the post logout redirect URI if it was requested and is registered for the client.
+func (a *Auth) EndSession(ctx context.Context, req models.EndSession) (string, error) {
+	const op = "auth.EndSession"
+	log := a.log.With(slog.String("op", op))
+	log.Info("ending session")
+
+	clientID := req.ClientID
+
+	if req.IDTokenHint != "" {
+		claims, err := jwt.ParseIDToken(req.IDTokenHint, a.issuer, a.verificationKeys(ctx))
+		if err != nil {
+			if errors.Is(err, jwt.ErrInvalidToken) {
+				log.Warn("invalid id token hint", sl.Err(err))
+				return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+			}
+
+			log.Error("failed to parse id token hint", sl.Err(err))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		if clientID != uuid.Nil && clientID != claims.Audience {
+			log.Warn("id token hint was issued to another client")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		clientID = claims.Audience
+
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		if err := a.revokeSessionTokens(ctx, log, sessionID, claims.Audience); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("session ended", slog.String("userID", claims.Subject.String()), slog.String("sid", claims.SessionID))
+	}
+
+	if req.PostLogoutRedirectURI == "" {
+		return "", nil
+	}
+
+	// without a client the redirect URI can't be checked
+	if clientID == uuid.Nil {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidClient)
+	}
+
+	app, err := a.appProvider.App(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !slices.Contains(app.PostLogoutRedirectURIs, req.PostLogoutRedirectURI) {
+		log.Warn("post logout redirect uri is not registered", slog.String("redirectURI", req.PostLogoutRedirectURI))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+
+	return req.PostLogoutRedirectURI, nil
+}
+
+// newIDToken issues the ID token for the authorization code, releasing the claims of its scopes
+func (a *Auth) newIDToken(ctx context.Context, user *models.User, app models.App, code models.AuthorizationCode, sessionID uuid.UUID) (string, error) {
+	signingKey, err := a.keyProvider.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	acr := models.ACRSingleFactor
+	if slices.Contains(code.AMR, models.AMROTP) {
+		acr = models.ACRMultiFactor
+	}
+
+	claims := make(map[string]any)
+	if slices.Contains(code.Scopes, models.ScopeEmail) {
+		claims["email"] = user.Email
+	}
+	if slices.Contains(code.Scopes, models.ScopeProfile) {
+		claims["preferred_username"] = user.Email
+	}
+
+	return jwt.NewIDToken(user, app, jwt.IDTokenOptions{
+		Issuer:      a.issuer,
+		TTL:         a.appTokenTTL(app),
+		Key:         signingKey,
+		SessionID:   sessionID.String(),
+		Nonce:       code.Nonce,
+		AuthTime:    code.AuthTime,
+		AMR:         code.AMR,
+		ACR:         acr,
+		ExtraClaims: claims,
+	})
+}
+
+// supportedScopes drops the scopes the service does not know
+func supportedScopes(scopes []string) []string {
+	supported := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if slices.Contains(SupportedScopes, scope) && !slices.Contains(supported, scope) {
+			supported = append(supported, scope)
+		}
+	}
+
+	return supported
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// CreateOrganization creates the organization, the owner of the token needs the global PermissionManageOrganizations
+func (a *Auth) CreateOrganization(ctx context.Context, token string, name string) (uuid.UUID, error) {
+	const op = "auth.CreateOrganization"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", name),
+	)
+	log.Info("creating organization")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := a.userRoles(ctx, claims.UserID, uuid.Nil)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !grantsPermission(roles, PermissionManageOrganizations) {
+		log.Warn("organizations management denied", slog.String("requesterID", claims.UserID.String()))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	org := models.Organization{
+		ID:   uuid.New(),
+		Name: name,
+	}
+
+	if err := a.organizationProvider.SaveOrganization(ctx, org); err != nil {
+		if errors.Is(err, storage.ErrOrganizationExists) {
+			log.Warn("organization already exists", sl.Err(err))
+			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrOrganizationExists)
+		}
+
+		log.Error("failed to save organization", sl.Err(err))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("organization created", slog.String("orgID", org.ID.String()))
+
+	return org.ID, nil
+}
+
+// ListOrganizationMembers returns the members of the organization, the organization of the token owner when orgID is uuid.Nil.
+// Members are listed only to the admins of the organization.
+func (a *Auth) ListOrganizationMembers(ctx context.Context, token string, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	const op = "auth.ListOrganizationMembers"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing organization members")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if orgID == uuid.Nil {
+		orgID = claims.OrgID
+	}
+
+	log = log.With(slog.String("orgID", orgID.String()))
+
+	if err := a.authorizeOrganization(ctx, log, claims.UserID, orgID, PermissionManageOrganizations); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := a.organizationProvider.OrganizationMembers(ctx, orgID)
+	if err != nil {
+		log.Error("failed to get organization members", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return members, nil
+}
+
+// SetOrganizationMemberRole makes the member an admin of the organization or a regular member again,
+// the owner of the token must be an admin of the organization
+func (a *Auth) SetOrganizationMemberRole(ctx context.Context, token string, orgID uuid.UUID, userID uuid.UUID, role string) error {
+	const op = "auth.SetOrganizationMemberRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("orgID", orgID.String()),
+		slog.String("userID", userID.String()),
+		slog.String("role", role),
+	)
+	log.Info("setting organization member role")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.authorizeOrganization(ctx, log, claims.UserID, orgID, PermissionManageOrganizations); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.organizationProvider.SetOrganizationMemberRole(ctx, orgID, userID, role); err != nil {
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			log.Warn("organization member not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrMemberNotFound)
+		}
+
+		log.Error("failed to set organization member role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("organization member role set")
+
+	return nil
+}
+
+// authorizeOrganization fails with ErrPermissionDenied unless the user is an admin of the organization
+// or has the permission granted by a global role
+func (a *Auth) authorizeOrganization(ctx context.Context, log *slog.Logger, userID uuid.UUID, orgID uuid.UUID, permission string) error {
+	isAdmin, err := a.isOrganizationAdmin(ctx, userID, orgID)
+	if err != nil {
+		log.Error("failed to get organization member", sl.Err(err))
+		return err
+	}
+
+	if isAdmin {
+		return nil
+	}
+
+	roles, err := a.userRoles(ctx, userID, uuid.Nil)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return err
+	}
+
+	if !grantsPermission(roles, permission) {
+		log.Warn("organization management denied", slog.String("requesterID", userID.String()), slog.String("permission", permission))
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// isOrganizationAdmin reports whether the user is an admin of the organization
+func (a *Auth) isOrganizationAdmin(ctx context.Context, userID uuid.UUID, orgID uuid.UUID) (bool, error) {
+	member, err := a.organizationProvider.OrganizationMember(ctx, orgID, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMemberNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return member.Role == models.OrganizationRoleAdmin, nil
+}
+
+// optionalApp returns the app, or the app-less settings of the default organization when appID is uuid.Nil
+func (a *Auth) optionalApp(ctx context.Context, log *slog.Logger, appID uuid.UUID) (models.App, error) {
+	if appID == uuid.Nil {
+		return models.App{OrgID: models.DefaultOrgID}, nil
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, ErrAppNotFound
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, err
+	}
+
+	return app, nil
+}
@@ -0,0 +1,367 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// passkeySelection requires discoverable credentials with user verification, so a passkey alone signs the user in
+var passkeySelection = protocol.AuthenticatorSelection{
+	RequireResidentKey: protocol.ResidentKeyRequired(),
+	ResidentKey:        protocol.ResidentKeyRequirementRequired,
+	UserVerification:   protocol.VerificationRequired,
+}
+
+// BeginPasskeyRegistration starts the WebAuthn registration ceremony for the user signed in with the access token
+func (a *Auth) BeginPasskeyRegistration(ctx context.Context, token string) (models.PasskeyCeremony, error) {
+	const op = "auth.BeginPasskeyRegistration"
+	log := a.log.With(slog.String("op", op))
+	log.Info("starting passkey registration")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	user, err := a.webAuthnUser(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, sessionData, err := a.webAuthn.BeginRegistration(
+		user,
+		webauthn.WithAuthenticatorSelection(passkeySelection),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		log.Error("failed to begin registration", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ceremony, err := a.savePasskeyCeremony(ctx, creation, sessionData, models.WebAuthnSession{UserID: claims.UserID})
+	if err != nil {
+		log.Error("failed to save passkey registration", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ceremony, nil
+}
+
+// FinishPasskeyRegistration checks the authenticator response and saves the new passkey of the user
+func (a *Auth) FinishPasskeyRegistration(ctx context.Context, token string, sessionID string, name string, response []byte) ([]byte, error) {
+	const op = "auth.FinishPasskeyRegistration"
+	log := a.log.With(slog.String("op", op))
+	log.Info("finishing passkey registration")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	session, sessionData, err := a.consumePasskeyCeremony(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnSessionNotFound) {
+			log.Warn("passkey registration not found", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+		}
+
+		log.Error("failed to get passkey registration", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.UserID != claims.UserID {
+		log.Warn("passkey registration was started by another user")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	user, err := a.webAuthnUser(ctx, claims.UserID)
+	if err != nil {
+		log.Error("failed to get user", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsedResponse, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		log.Warn("malformed passkey registration response", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	credential, err := a.webAuthn.CreateCredential(user, sessionData, parsedResponse)
+	if err != nil {
+		log.Warn("passkey registration response is invalid", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	err = a.passkeyProvider.SaveWebAuthnCredential(ctx, models.WebAuthnCredential{
+		ID:              credential.ID,
+		UserID:          claims.UserID,
+		Name:            name,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnCredentialExists) {
+			log.Warn("passkey already registered", sl.Err(err))
+			return nil, fmt.Errorf("%s: %w", op, ErrPasskeyExists)
+		}
+
+		log.Error("failed to save passkey", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("passkey registered")
+
+	return credential.ID, nil
+}
+
+// BeginPasskeyLogin starts the WebAuthn login ceremony for any passkey, the user is known from the response
+func (a *Auth) BeginPasskeyLogin(ctx context.Context, appID uuid.UUID) (models.PasskeyCeremony, error) {
+	const op = "auth.BeginPasskeyLogin"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("starting passkey login")
+
+	if _, err := a.appProvider.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	assertion, sessionData, err := a.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		log.Error("failed to begin login", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ceremony, err := a.savePasskeyCeremony(ctx, assertion, sessionData, models.WebAuthnSession{AppID: appID})
+	if err != nil {
+		log.Error("failed to save passkey login", sl.Err(err))
+		return models.PasskeyCeremony{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ceremony, nil
+}
+
+// FinishPasskeyLogin checks the assertion of the passkey and issues the same token pair Login does
+func (a *Auth) FinishPasskeyLogin(ctx context.Context, sessionID string, response []byte) (models.TokenPair, error) {
+	const op = "auth.FinishPasskeyLogin"
+	log := a.log.With(slog.String("op", op))
+	log.Info("finishing passkey login")
+
+	session, sessionData, err := a.consumePasskeyCeremony(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrWebAuthnSessionNotFound) {
+			log.Warn("passkey login not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+		}
+
+		log.Error("failed to get passkey login", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.UserID != uuid.Nil {
+		log.Warn("passkey registration can't be used to login")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	parsedResponse, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		log.Warn("malformed passkey login response", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	var user *webAuthnUser
+	credential, err := a.webAuthn.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		user, err = a.webAuthnUser(ctx, userID)
+
+		return user, err
+	}, sessionData, parsedResponse)
+	if err != nil {
+		log.Warn("passkey login response is invalid", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	log = log.With(slog.String("userID", user.user.ID.String()))
+
+	// a counter that didn't grow means the credential may have been cloned
+	if credential.Authenticator.CloneWarning {
+		log.Warn("passkey signature counter didn't grow")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	used, err := a.passkeyProvider.UseWebAuthnCredential(ctx, credential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+	if err != nil {
+		log.Error("failed to update passkey", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !used {
+		log.Warn("passkey signature counter was used concurrently")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidPasskey)
+	}
+
+	app, err := a.appProvider.App(ctx, session.AppID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.issueTokens(ctx, log, &user.user, app, "")
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tokens.SSOSessionToken, err = a.startSSOSession(ctx, log, user.user.ID); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tokens, nil
+}
+
+func (a *Auth) savePasskeyCeremony(ctx context.Context, options any, sessionData *webauthn.SessionData, session models.WebAuthnSession) (models.PasskeyCeremony, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return models.PasskeyCeremony{}, err
+	}
+
+	session.Data, err = json.Marshal(sessionData)
+	if err != nil {
+		return models.PasskeyCeremony{}, err
+	}
+
+	sessionID, err := opaque.NewToken(passkeySessionSize)
+	if err != nil {
+		return models.PasskeyCeremony{}, err
+	}
+
+	if err := a.passkeySessions.SaveWebAuthnSession(ctx, opaque.Hash(sessionID), session, passkeySessionTTL); err != nil {
+		return models.PasskeyCeremony{}, err
+	}
+
+	return models.PasskeyCeremony{SessionID: sessionID, Options: optionsJSON}, nil
+}
+
+func (a *Auth) consumePasskeyCeremony(ctx context.Context, sessionID string) (models.WebAuthnSession, webauthn.SessionData, error) {
+	session, err := a.passkeySessions.ConsumeWebAuthnSession(ctx, opaque.Hash(sessionID))
+	if err != nil {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	var sessionData webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &sessionData); err != nil {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+
+	return session, sessionData, nil
+}
+
+// webAuthnUser returns the user with their passkeys
+func (a *Auth) webAuthnUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := a.passkeyProvider.WebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+// webAuthnUser adapts the user to the webauthn library, the user handle is the user ID
+type webAuthnUser struct {
+	user        models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, credential := range u.credentials {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(credential.Transports))
+		for _, transport := range credential.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              credential.ID,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: credential.BackupEligible,
+				BackupState:    credential.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    credential.AAGUID,
+				SignCount: credential.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ChangePassword sets the new password of the user signed in with the access token once the current password matches.
+// Other sessions of the user are revoked, the session of the refresh token, if any, is kept and gets new tokens.
+// Without the refresh token the returned tokens start a new session.
+func (a *Auth) ChangePassword(ctx context.Context, token string, refreshToken string, currentPassword string, newPassword string) (models.TokenPair, error) {
+	const op = "auth.ChangePassword"
+	log := a.log.With(slog.String("op", op))
+	log.Info("changing password")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	user, err := a.userProvider.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// a stolen access token must not allow guessing the password, so wrong ones count towards the lockout
+	failedLoginAttempt, isFirstAttempt, err := a.checkLockout(ctx, log, user.ID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	match, _, err := a.passwordHasher.Verify(user.PassHash, currentPassword)
+	if err != nil {
+		log.Error("failed to verify password", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !match {
+		log.Warn("invalid current password")
+
+		if err := a.registerFailedAttempt(ctx, user, failedLoginAttempt, isFirstAttempt); err != nil {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := a.failedLoginsProvider.RemoveFailedLoginAttempts(ctx, user.ID.String()); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.currentSession(ctx, log, user.ID, refreshToken)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, claims.AppID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := checkPassword(log, a.passwordPolicyOf(app), newPassword, user.Email); err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	familyID, scope := uuid.New(), claims.Scope
+	if session != nil {
+		familyID, scope = session.FamilyID, session.Scope
+	}
+
+	passwordHash, err := a.passwordHasher.Hash(newPassword)
+	if err != nil {
+		log.Error("failed to generate passwordHash", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userSaver.ChangePassword(ctx, user.ID, passwordHash, familyID); err != nil {
+		log.Error("failed to change password", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// refresh tokens of other sessions are revoked with the password, bumping the version revokes access tokens
+	if _, err := a.revokedTokenProvider.IncrementTokenVersion(ctx, user.ID.String()); err != nil {
+		log.Error("failed to revoke access tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.apiKeyProvider.RevokeUserAPIKeys(ctx, user.ID); err != nil {
+		log.Error("failed to revoke api keys", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, newRefreshToken, err := a.newTokenPair(ctx, &user, app, familyID, scope)
+	if err != nil {
+		log.Error("failed to generate tokens", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session != nil {
+		err = a.refreshTokenProvider.RotateRefreshToken(ctx, session.ID, newRefreshToken)
+	} else {
+		err = a.sessionProvider.StartSession(ctx, newSession(ctx, newRefreshToken), newRefreshToken)
+	}
+	if err != nil {
+		log.Error("failed to save refresh token", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed")
+
+	return tokens, nil
+}
+
+// currentSession returns the active refresh token of the user, nil when the token isn't given
+func (a *Auth) currentSession(ctx context.Context, log *slog.Logger, userID uuid.UUID, refreshToken string) (*models.RefreshToken, error) {
+	if refreshToken == "" {
+		return nil, nil
+	}
+
+	storedToken, err := a.refreshTokenProvider.RefreshToken(ctx, opaque.Hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+			log.Warn("refresh token not found", sl.Err(err))
+			return nil, ErrInvalidRefreshToken
+		}
+
+		log.Error("failed to get refresh token", sl.Err(err))
+		return nil, err
+	}
+
+	if storedToken.UserID != userID || !storedToken.RevokedAt.IsZero() || !storedToken.RotatedAt.IsZero() ||
+		time.Now().After(storedToken.ExpiresAt) {
+		log.Warn("refresh token is not active", slog.String("familyID", storedToken.FamilyID.String()))
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return &storedToken, nil
+}
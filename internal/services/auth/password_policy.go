@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/passwordpolicy"
+	"github.com/google/uuid"
+)
+
+// PasswordPolicyError lists the rules of the password policy the password breaks, it wraps ErrWeakPassword
+type PasswordPolicyError struct {
+	Violations []models.PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		descriptions = append(descriptions, violation.Description)
+	}
+
+	return ErrWeakPassword.Error() + ": " + strings.Join(descriptions, ", ")
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// appPasswordPolicy returns the password policy of the app, the global one when appID is uuid.Nil
+func (a *Auth) appPasswordPolicy(ctx context.Context, log *slog.Logger, appID uuid.UUID) (models.PasswordPolicy, error) {
+	app, err := a.optionalApp(ctx, log, appID)
+	if err != nil {
+		return models.PasswordPolicy{}, err
+	}
+
+	return a.passwordPolicyOf(app), nil
+}
+
+// passwordPolicyOf returns the password policy the app overrides the global one with
+func (a *Auth) passwordPolicyOf(app models.App) models.PasswordPolicy {
+	if app.PasswordPolicy != nil {
+		return *app.PasswordPolicy
+	}
+
+	return a.passwordPolicy
+}
+
+// checkPassword returns PasswordPolicyError when the password chosen by the user with the email breaks the policy
+func checkPassword(log *slog.Logger, policy models.PasswordPolicy, password string, email string) error {
+	violations := passwordpolicy.Check(policy, password, email)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	rules := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		rules = append(rules, violation.Rule)
+	}
+	log.Warn("password violates the password policy", slog.Any("rules", rules))
+
+	return &PasswordPolicyError{Violations: violations}
+}
@@ -0,0 +1,204 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// passwordResetRateLimit limits password reset emails sent to an address
+var passwordResetRateLimit = models.RateLimit{Limit: 5, Window: time.Hour}
+
+const passwordResetEmailBody = `Hello,
+
+Someone asked to reset the password of your account. Open the link below to set a new password:
+
+%s
+
+The link expires in %s and can be used once. If you didn't ask for it, ignore this email, your password stays the same.
+`
+
+// RequestPasswordReset mails the user of the organization of the app a single-use link setting a new password
+// following the password policy of the app, the user of the default organization under the global policy when appID is uuid.Nil.
+// Unknown emails are silently skipped and mailer failures are only logged, so the result doesn't reveal registered emails.
+func (a *Auth) RequestPasswordReset(ctx context.Context, email string, appID uuid.UUID) error {
+	const op = "auth.RequestPasswordReset"
+	log := a.log.With(slog.String("op", op))
+	log.Info("requesting password reset")
+
+	allowed, err := a.rateLimiter.Allow(ctx, fmt.Sprintf("passwordResetEmail:%s", opaque.Hash(email)), passwordResetRateLimit)
+	if err != nil {
+		log.Error("failed to check rate limit", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !allowed {
+		log.Warn("password reset email rate limited")
+		return fmt.Errorf("%s: %w", op, ErrRateLimited)
+	}
+
+	app, err := a.optionalApp(ctx, log, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.User(ctx, app.OrgID, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", user.ID.String()))
+
+	token, err := opaque.NewToken(passwordResetTokenSize)
+	if err != nil {
+		log.Error("failed to generate password reset token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	reset := models.PasswordReset{
+		UserID:        user.ID,
+		AppID:         appID,
+		PasswordState: passwordState(user.PassHash),
+	}
+
+	if err := a.passwordResets.SavePasswordReset(ctx, opaque.Hash(token), reset, a.emailLinks.PasswordResetTTL); err != nil {
+		log.Error("failed to save password reset", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	link, err := linkWithToken(a.emailLinks.PasswordResetURL, token)
+	if err != nil {
+		log.Error("failed to build password reset link", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mailer.Send(ctx, models.Email{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf(passwordResetEmailBody, link, a.emailLinks.PasswordResetTTL),
+	}); err != nil {
+		// the response must not differ from the one for an unknown email
+		log.Error("failed to send password reset email", sl.Err(err))
+		return nil
+	}
+
+	log.Info("password reset requested")
+
+	return nil
+}
+
+// ResetPassword sets the new password with the token from the reset link.
+// The user is signed out everywhere and the failed login attempts are cleared.
+// The token stays valid when the password breaks the password policy, so the user can choose another one.
+func (a *Auth) ResetPassword(ctx context.Context, token string, password string) error {
+	const op = "auth.ResetPassword"
+	log := a.log.With(slog.String("op", op))
+	log.Info("resetting password")
+
+	tokenHash := opaque.Hash(token)
+
+	reset, err := a.passwordResets.PasswordReset(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrPasswordResetNotFound) {
+			log.Warn("password reset not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to get password reset", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", reset.UserID.String()))
+
+	user, err := a.userProvider.UserByID(ctx, reset.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// other reset links of the user are void once the password changes
+	if reset.PasswordState != passwordState(user.PassHash) {
+		log.Warn("password changed after the reset was requested")
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+	}
+
+	policy, err := a.appPasswordPolicy(ctx, log, reset.AppID)
+	if err != nil {
+		// the app may be deleted after the reset was requested
+		if !errors.Is(err, ErrAppNotFound) {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		policy = a.passwordPolicy
+	}
+
+	if err := checkPassword(log, policy, password, user.Email); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// consuming the reset last keeps concurrent requests from using the token twice
+	if _, err := a.passwordResets.ConsumePasswordReset(ctx, tokenHash); err != nil {
+		if errors.Is(err, storage.ErrPasswordResetNotFound) {
+			log.Warn("password reset already used", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidEmailToken)
+		}
+
+		log.Error("failed to consume password reset", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := a.passwordHasher.Hash(password)
+	if err != nil {
+		log.Error("failed to generate passwordHash", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.userSaver.ResetPassword(ctx, user.ID, passwordHash); err != nil {
+		log.Error("failed to reset password", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// refresh tokens are revoked with the password, bumping the version revokes access tokens
+	if _, err := a.revokedTokenProvider.IncrementTokenVersion(ctx, user.ID.String()); err != nil {
+		log.Error("failed to revoke access tokens", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.apiKeyProvider.RevokeUserAPIKeys(ctx, user.ID); err != nil {
+		log.Error("failed to revoke api keys", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.failedLoginsProvider.RemoveFailedLoginAttempts(ctx, user.ID.String()); err != nil {
+		log.Error("failed to remove failed login attempts", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset")
+
+	return nil
+}
+
+// passwordState binds password resets to the password they were requested for
+func passwordState(passwordHash []byte) string {
+	return opaque.Hash(string(passwordHash))
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/google/uuid"
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// recoveryCodeAlphabet has no look-alike characters
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user signed in with the access token
+func (a *Auth) RegenerateRecoveryCodes(ctx context.Context, token string) ([]string, error) {
+	const op = "auth.RegenerateRecoveryCodes"
+	log := a.log.With(slog.String("op", op))
+	log.Info("regenerating recovery codes")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", claims.UserID.String()))
+
+	enabled, err := a.mfaEnabled(ctx, claims.UserID)
+	if err != nil {
+		log.Error("failed to check mfa", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !enabled {
+		log.Warn("mfa is not enabled")
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Error("failed to generate recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.mfaProvider.SaveRecoveryCodes(ctx, claims.UserID, recoveryCodeHashes); err != nil {
+		log.Error("failed to save recovery codes", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("recovery codes regenerated")
+
+	return recoveryCodes, nil
+}
+
+// useRecoveryCode marks the matching unused recovery code of the user used
+func (a *Auth) useRecoveryCode(ctx context.Context, log *slog.Logger, userID uuid.UUID, code string) (bool, error) {
+	recoveryCodes, err := a.mfaProvider.RecoveryCodes(ctx, userID)
+	if err != nil {
+		log.Error("failed to get recovery codes", sl.Err(err))
+		return false, err
+	}
+
+	code = normalizeRecoveryCode(code)
+	for _, recoveryCode := range recoveryCodes {
+		if bcrypt.CompareHashAndPassword(recoveryCode.CodeHash, []byte(code)) != nil {
+			continue
+		}
+
+		used, err := a.mfaProvider.UseRecoveryCode(ctx, userID, recoveryCode.ID)
+		if err != nil {
+			log.Error("failed to use recovery code", sl.Err(err))
+			return false, err
+		}
+
+		if used {
+			log.Info("recovery code used")
+		}
+
+		return used, nil
+	}
+
+	return false, nil
+}
+
+// newRecoveryCodes returns the formatted recovery codes with their hashes
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := randomCode(recoveryCodeAlphabet, recoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hash)
+	}
+
+	return codes, hashes, nil
+}
+
+// isRecoveryCode tells recovery codes from TOTP codes which are shorter
+func isRecoveryCode(code string) bool {
+	return len(normalizeRecoveryCode(code)) == recoveryCodeLength
+}
+
+// normalizeRecoveryCode drops separators and case the user could type the code with
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToLower(code))
+}
@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// maxRelationDepth limits how many usersets deep Check and ListObjects follow the relationships
+const maxRelationDepth = 16
+
+// WriteRelationships writes and deletes the relationships of the app at once.
+// Only confidential apps can manage their relationships.
+func (a *Auth) WriteRelationships(ctx context.Context, appID uuid.UUID, secret string, writes []models.Relationship, deletes []models.Relationship) error {
+	const op = "auth.WriteRelationships"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("writing relationships", slog.Int("writes", len(writes)), slog.Int("deletes", len(deletes)))
+
+	if _, err := a.authenticateConfidentialClient(ctx, log, appID, secret); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.relationshipProvider.WriteRelationships(ctx, appID, writes, deletes); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+
+		log.Error("failed to write relationships", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("relationships written")
+
+	return nil
+}
+
+// DefineRelation makes the relation of the objects of the type granted by the other relations of the same object,
+// e.g. editors of a document include its owners. It replaces the previous definition, no relations remove it.
+func (a *Auth) DefineRelation(ctx context.Context, appID uuid.UUID, secret string, rewrite models.RelationRewrite) error {
+	const op = "auth.DefineRelation"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+		slog.String("objectType", rewrite.ObjectType),
+		slog.String("relation", rewrite.Relation),
+	)
+	log.Info("defining relation")
+
+	if _, err := a.authenticateConfidentialClient(ctx, log, appID, secret); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.relationshipProvider.SaveRelationRewrite(ctx, appID, rewrite); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+
+		log.Error("failed to save relation rewrite", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("relation defined")
+
+	return nil
+}
+
+// Check reports whether the subject has the relation to the object, directly, through the usersets
+// of other objects or through the relations implying it
+func (a *Auth) Check(ctx context.Context, appID uuid.UUID, secret string, object models.ObjectRef, relation string, subject models.SubjectRef) (bool, error) {
+	const op = "auth.Check"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+		slog.String("object", object.Type+":"+object.ID),
+		slog.String("relation", relation),
+	)
+
+	if _, err := a.authenticateConfidentialClient(ctx, log, appID, secret); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	graph := a.relationGraph(appID)
+
+	allowed, err := graph.check(ctx, object, relation, subject)
+	if err != nil {
+		if errors.Is(err, ErrRelationTooDeep) {
+			log.Warn("relationship graph is too deep", sl.Err(err))
+		} else {
+			log.Error("failed to check relation", sl.Err(err))
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return allowed, nil
+}
+
+// ListObjects returns the IDs of the objects of the type the subject has the relation to, as Check decides, ordered by ID
+func (a *Auth) ListObjects(ctx context.Context, appID uuid.UUID, secret string, objectType string, relation string, subject models.SubjectRef) ([]string, error) {
+	const op = "auth.ListObjects"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+		slog.String("objectType", objectType),
+		slog.String("relation", relation),
+	)
+
+	if _, err := a.authenticateConfidentialClient(ctx, log, appID, secret); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	graph := a.relationGraph(appID)
+
+	objectIDs, err := graph.listObjects(ctx, objectType, relation, subject)
+	if err != nil {
+		if errors.Is(err, ErrRelationTooDeep) {
+			log.Warn("relationship graph is too deep", sl.Err(err))
+		} else {
+			log.Error("failed to list objects", sl.Err(err))
+		}
+
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return objectIDs, nil
+}
+
+// relationGraph walks the relationships of the app, rewrites of each object type are loaded once
+type relationGraph struct {
+	provider RelationshipProvider
+	appID    uuid.UUID
+	rewrites map[string][]models.RelationRewrite
+}
+
+func (a *Auth) relationGraph(appID uuid.UUID) *relationGraph {
+	return &relationGraph{
+		provider: a.relationshipProvider,
+		appID:    appID,
+		rewrites: make(map[string][]models.RelationRewrite),
+	}
+}
+
+func (g *relationGraph) check(ctx context.Context, object models.ObjectRef, relation string, subject models.SubjectRef) (bool, error) {
+	usersets := []models.SubjectRef{{Object: object, Relation: relation}}
+	visited := make(map[models.SubjectRef]struct{})
+
+	for depth := 0; len(usersets) > 0; depth++ {
+		if depth == maxRelationDepth {
+			return false, ErrRelationTooDeep
+		}
+
+		var next []models.SubjectRef
+		for _, userset := range usersets {
+			if _, ok := visited[userset]; ok {
+				continue
+			}
+			visited[userset] = struct{}{}
+
+			if userset == subject {
+				return true, nil
+			}
+
+			relations, err := g.impliedBy(ctx, userset.Object.Type, userset.Relation)
+			if err != nil {
+				return false, err
+			}
+
+			relationships, err := g.provider.Relationships(ctx, g.appID, userset.Object, relations)
+			if err != nil {
+				return false, err
+			}
+
+			for _, relationship := range relationships {
+				if relationship.Subject == subject {
+					return true, nil
+				}
+
+				if relationship.Subject.Relation != "" {
+					next = append(next, relationship.Subject)
+				}
+			}
+		}
+
+		usersets = next
+	}
+
+	return false, nil
+}
+
+func (g *relationGraph) listObjects(ctx context.Context, objectType string, relation string, subject models.SubjectRef) ([]string, error) {
+	var objectIDs []string
+	found := make(map[string]struct{})
+
+	subjects := []models.SubjectRef{subject}
+	visited := make(map[models.SubjectRef]struct{})
+
+	for depth := 0; len(subjects) > 0; depth++ {
+		if depth == maxRelationDepth {
+			return nil, ErrRelationTooDeep
+		}
+
+		var next []models.SubjectRef
+		for _, current := range subjects {
+			if _, ok := visited[current]; ok {
+				continue
+			}
+			visited[current] = struct{}{}
+
+			if current.Object.Type == objectType && current.Relation == relation {
+				if _, ok := found[current.Object.ID]; !ok {
+					found[current.Object.ID] = struct{}{}
+					objectIDs = append(objectIDs, current.Object.ID)
+				}
+			}
+
+			relationships, err := g.provider.SubjectRelationships(ctx, g.appID, current)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, relationship := range relationships {
+				relations, err := g.implies(ctx, relationship.Object.Type, relationship.Relation)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, implied := range relations {
+					next = append(next, models.SubjectRef{Object: relationship.Object, Relation: implied})
+				}
+			}
+		}
+
+		subjects = next
+	}
+
+	slices.Sort(objectIDs)
+
+	return objectIDs, nil
+}
+
+// impliedBy returns the relation and all the relations implying it, directly or through other relations
+func (g *relationGraph) impliedBy(ctx context.Context, objectType string, relation string) ([]string, error) {
+	rewrites, err := g.rewritesOf(ctx, objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := []string{relation}
+	for i := 0; i < len(relations); i++ {
+		for _, rewrite := range rewrites {
+			if rewrite.Relation != relations[i] {
+				continue
+			}
+
+			for _, impliedBy := range rewrite.ImpliedBy {
+				if !slices.Contains(relations, impliedBy) {
+					relations = append(relations, impliedBy)
+				}
+			}
+		}
+	}
+
+	return relations, nil
+}
+
+// implies returns the relation and all the relations it implies, directly or through other relations
+func (g *relationGraph) implies(ctx context.Context, objectType string, relation string) ([]string, error) {
+	rewrites, err := g.rewritesOf(ctx, objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	relations := []string{relation}
+	for i := 0; i < len(relations); i++ {
+		for _, rewrite := range rewrites {
+			if slices.Contains(rewrite.ImpliedBy, relations[i]) && !slices.Contains(relations, rewrite.Relation) {
+				relations = append(relations, rewrite.Relation)
+			}
+		}
+	}
+
+	return relations, nil
+}
+
+func (g *relationGraph) rewritesOf(ctx context.Context, objectType string) ([]models.RelationRewrite, error) {
+	if rewrites, ok := g.rewrites[objectType]; ok {
+		return rewrites, nil
+	}
+
+	rewrites, err := g.provider.RelationRewrites(ctx, g.appID, objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	g.rewrites[objectType] = rewrites
+
+	return rewrites, nil
+}
@@ -0,0 +1,502 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// CreateRole creates the role, global or scoped to the app when role.AppID is set.
+// The owner of the token needs PermissionManageRoles in the scope of the role.
+func (a *Auth) CreateRole(ctx context.Context, token string, role models.Role) (uuid.UUID, error) {
+	const op = "auth.CreateRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("name", role.Name),
+		slog.String("appID", role.AppID.String()),
+	)
+	log.Info("creating role")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.authorizeRoleManagement(ctx, log, claims.UserID, role.AppID); err != nil {
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role.ID = uuid.New()
+	role.BuiltIn = false
+	role.Permissions = slices.Clone(role.Permissions)
+	slices.Sort(role.Permissions)
+	role.Permissions = slices.Compact(role.Permissions)
+
+	if err := a.roleProvider.SaveRole(ctx, role); err != nil {
+		if errors.Is(err, storage.ErrRoleExists) {
+			log.Warn("role already exists", sl.Err(err))
+			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrRoleExists)
+		}
+
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return uuid.Nil, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to save role", sl.Err(err))
+		return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role created", slog.String("roleID", role.ID.String()))
+
+	return role.ID, nil
+}
+
+// DeleteRole deletes the role and takes it away from its users, built-in roles can't be deleted
+func (a *Auth) DeleteRole(ctx context.Context, token string, roleID uuid.UUID) error {
+	const op = "auth.DeleteRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("roleID", roleID.String()),
+	)
+	log.Info("deleting role")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := a.managedRole(ctx, log, claims.UserID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role.BuiltIn {
+		log.Warn("built-in role can't be deleted")
+		return fmt.Errorf("%s: %w", op, ErrBuiltInRole)
+	}
+
+	if err := a.roleProvider.DeleteRole(ctx, roleID); err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to delete role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role deleted")
+
+	return nil
+}
+
+// ListRoles returns the global roles and, when appID is set, the roles scoped to the app.
+// The owner of the token needs PermissionManageRoles in the app or to be an admin of the organization owning it,
+// apps of other organizations are not found.
+func (a *Auth) ListRoles(ctx context.Context, token string, appID uuid.UUID) ([]models.Role, error) {
+	const op = "auth.ListRoles"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("listing roles")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if appID != uuid.Nil {
+		if _, err := a.organizationApp(ctx, log, claims.OrgID, appID); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := a.authorizeRoleListing(ctx, log, claims, appID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := a.roleProvider.Roles(ctx, appID)
+	if err != nil {
+		log.Error("failed to get roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// AssignRole assigns the role to the user, the owner of the token needs PermissionManageRoles in the scope of the role.
+// Roles scoped to an app can be assigned only to the users of the organization owning the app.
+func (a *Auth) AssignRole(ctx context.Context, token string, userID uuid.UUID, roleID uuid.UUID) error {
+	const op = "auth.AssignRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("userID", userID.String()),
+		slog.String("roleID", roleID.String()),
+	)
+	log.Info("assigning role")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := a.managedRole(ctx, log, claims.UserID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if role.AppID != uuid.Nil {
+		if err := a.checkAppOrganizationMember(ctx, log, userID, role.AppID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := a.roleProvider.AssignRole(ctx, userID, roleID); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrRoleNotFound)
+		}
+
+		log.Error("failed to assign role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role assigned")
+
+	return nil
+}
+
+// UnassignRole takes the role away from the user, the owner of the token needs PermissionManageRoles in the scope of the role
+func (a *Auth) UnassignRole(ctx context.Context, token string, userID uuid.UUID, roleID uuid.UUID) error {
+	const op = "auth.UnassignRole"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("userID", userID.String()),
+		slog.String("roleID", roleID.String()),
+	)
+	log.Info("unassigning role")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := a.managedRole(ctx, log, claims.UserID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.roleProvider.UnassignRole(ctx, userID, roleID); err != nil {
+		log.Error("failed to unassign role", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role unassigned")
+
+	return nil
+}
+
+// ListUserRoles returns the roles assigned to the user, the owner of the token when userID is uuid.Nil.
+// Roles of other users are listed only with the global PermissionManageRoles.
+func (a *Auth) ListUserRoles(ctx context.Context, token string, userID uuid.UUID) ([]models.Role, error) {
+	const op = "auth.ListUserRoles"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing user roles")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == uuid.Nil {
+		userID = claims.UserID
+	}
+
+	log = log.With(slog.String("userID", userID.String()))
+
+	if userID != claims.UserID {
+		if err := a.authorizeRoleManagement(ctx, log, claims.UserID, uuid.Nil); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	roles, err := a.roleProvider.UserRoles(ctx, userID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return roles, nil
+}
+
+// HasPermission reports whether the roles of the user grant the permission,
+// in the app when appID is set or globally otherwise.
+// The caller is either a user with the token or a confidential app with its credentials,
+// only users and apps of the organization of the caller are found.
+func (a *Auth) HasPermission(
+	ctx context.Context,
+	token string,
+	clientID uuid.UUID,
+	clientSecret string,
+	userID uuid.UUID,
+	appID uuid.UUID,
+	permission string,
+) (bool, error) {
+	const op = "auth.HasPermission"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("userID", userID.String()),
+		slog.String("appID", appID.String()),
+		slog.String("permission", permission),
+	)
+	log.Info("checking permission")
+
+	orgID, err := a.callerOrganization(ctx, log, token, clientID, clientSecret)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if user.OrgID != orgID {
+		log.Warn("user belongs to another organization", slog.String("orgID", user.OrgID.String()))
+		return false, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+
+	if appID != uuid.Nil {
+		if _, err := a.organizationApp(ctx, log, orgID, appID); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	roles, err := a.userRoles(ctx, userID, appID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	granted := grantsPermission(roles, permission)
+
+	log.Info("checked permission", slog.Bool("granted", granted))
+
+	return granted, nil
+}
+
+// callerOrganization authenticates the caller with the token, or with the client credentials
+// of a confidential app when there is no token, and returns the organization of the caller
+func (a *Auth) callerOrganization(ctx context.Context, log *slog.Logger, token string, clientID uuid.UUID, clientSecret string) (uuid.UUID, error) {
+	if token != "" {
+		claims, err := a.ValidateToken(ctx, token)
+		if err != nil {
+			return uuid.Nil, err
+		}
+
+		return claims.OrgID, nil
+	}
+
+	app, err := a.authenticateConfidentialClient(ctx, log, clientID, clientSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return app.OrgID, nil
+}
+
+// organizationApp returns the app unless it belongs to another organization, such apps are not found
+func (a *Auth) organizationApp(ctx context.Context, log *slog.Logger, orgID uuid.UUID, appID uuid.UUID) (models.App, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.App{}, ErrAppNotFound
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.App{}, err
+	}
+
+	if app.OrgID != orgID {
+		log.Warn("app belongs to another organization", slog.String("orgID", app.OrgID.String()))
+		return models.App{}, ErrAppNotFound
+	}
+
+	return app, nil
+}
+
+// authorizeRoleListing fails with ErrPermissionDenied unless the owner of the token can manage the roles of the app,
+// global roles are listed to admins of the organization of the token too
+func (a *Auth) authorizeRoleListing(ctx context.Context, log *slog.Logger, claims jwt.Claims, appID uuid.UUID) error {
+	err := a.authorizeRoleManagement(ctx, log, claims.UserID, appID)
+	if appID != uuid.Nil || !errors.Is(err, ErrPermissionDenied) {
+		return err
+	}
+
+	isAdmin, err := a.isOrganizationAdmin(ctx, claims.UserID, claims.OrgID)
+	if err != nil {
+		log.Error("failed to check organization admin", sl.Err(err))
+		return err
+	}
+
+	if !isAdmin {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// managedRole returns the role if the user can manage it
+func (a *Auth) managedRole(ctx context.Context, log *slog.Logger, userID uuid.UUID, roleID uuid.UUID) (models.Role, error) {
+	role, err := a.roleProvider.Role(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleNotFound) {
+			log.Warn("role not found", sl.Err(err))
+			return models.Role{}, ErrRoleNotFound
+		}
+
+		log.Error("failed to get role", sl.Err(err))
+		return models.Role{}, err
+	}
+
+	if err := a.authorizeRoleManagement(ctx, log, userID, role.AppID); err != nil {
+		return models.Role{}, err
+	}
+
+	return role, nil
+}
+
+// authorizeRoleManagement fails with ErrPermissionDenied unless the user has PermissionManageRoles in the app
+// or is an admin of its organization, uuid.Nil requires the permission to be granted by a global role
+func (a *Auth) authorizeRoleManagement(ctx context.Context, log *slog.Logger, userID uuid.UUID, appID uuid.UUID) error {
+	roles, err := a.userRoles(ctx, userID, appID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return err
+	}
+
+	if grantsPermission(roles, PermissionManageRoles) {
+		return nil
+	}
+
+	if appID != uuid.Nil {
+		isAdmin, err := a.isAppOrganizationAdmin(ctx, userID, appID)
+		if err != nil {
+			log.Error("failed to check organization admin", sl.Err(err))
+			return err
+		}
+
+		if isAdmin {
+			return nil
+		}
+	}
+
+	log.Warn("roles management denied", slog.String("requesterID", userID.String()))
+
+	return ErrPermissionDenied
+}
+
+// isAppOrganizationAdmin reports whether the user is an admin of the organization owning the app, unknown apps have no admins
+func (a *Auth) isAppOrganizationAdmin(ctx context.Context, userID uuid.UUID, appID uuid.UUID) (bool, error) {
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return a.isOrganizationAdmin(ctx, userID, app.OrgID)
+}
+
+// checkAppOrganizationMember fails with ErrUserNotFound unless the user belongs to the organization owning the app
+func (a *Auth) checkAppOrganizationMember(ctx context.Context, log *slog.Logger, userID uuid.UUID, appID uuid.UUID) error {
+	user, err := a.userProvider.UserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return ErrUserNotFound
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return err
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		log.Error("failed to get app", sl.Err(err))
+		return err
+	}
+
+	if user.OrgID != app.OrgID {
+		log.Warn("user belongs to another organization", slog.String("orgID", user.OrgID.String()))
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// userRoles returns the roles of the user applying in the app: the global ones and the ones scoped to it
+func (a *Auth) userRoles(ctx context.Context, userID uuid.UUID, appID uuid.UUID) ([]models.Role, error) {
+	roles, err := a.roleProvider.UserRoles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(roles, func(role models.Role) bool {
+		return role.AppID != uuid.Nil && role.AppID != appID
+	}), nil
+}
+
+// grantsPermission reports whether any of the roles grants the permission, PermissionAll grants every permission
+func grantsPermission(roles []models.Role, permission string) bool {
+	return slices.ContainsFunc(roles, func(role models.Role) bool {
+		return slices.Contains(role.Permissions, permission) || slices.Contains(role.Permissions, PermissionAll)
+	})
+}
+
+// roleNames returns the distinct names of the roles for the roles claim
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	slices.Sort(names)
+
+	return slices.Compact(names)
+}
+
+// rolePermissions returns the distinct permissions granted by the roles for the permissions claim
+func rolePermissions(roles []models.Role) []string {
+	permissions := make([]string, 0, len(roles))
+	for _, role := range roles {
+		permissions = append(permissions, role.Permissions...)
+	}
+
+	slices.Sort(permissions)
+
+	return slices.Compact(permissions)
+}
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+// maxUserAgentLength limits the user agent kept in sessions
+const maxUserAgentLength = 512
+
+// ListSessions returns active sessions of the user, the owner of the token when userID is uuid.Nil.
+// Only admins can list sessions of other users.
+func (a *Auth) ListSessions(ctx context.Context, token string, userID uuid.UUID) ([]models.Session, error) {
+	const op = "auth.ListSessions"
+	log := a.log.With(slog.String("op", op))
+	log.Info("listing sessions")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if userID == uuid.Nil {
+		userID = claims.UserID
+	}
+
+	log = log.With(slog.String("userID", userID.String()))
+
+	if err := a.checkUserAccess(ctx, log, claims, userID); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := a.sessionProvider.Sessions(ctx, userID)
+	if err != nil {
+		log.Error("failed to get sessions", sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID.String() == claims.SessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession ends the session: its refresh tokens are revoked and its access tokens stop passing validation.
+// Users can revoke their own sessions, admins can revoke sessions of any user.
+func (a *Auth) RevokeSession(ctx context.Context, token string, sessionID uuid.UUID) error {
+	const op = "auth.RevokeSession"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("sessionID", sessionID.String()),
+	)
+	log.Info("revoking session")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := a.sessionProvider.Session(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Warn("session not found", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+
+		log.Error("failed to get session", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkUserAccess(ctx, log, claims, session.UserID); err != nil {
+		// sessions of other users are not revealed to users who can't manage them
+		if errors.Is(err, ErrPermissionDenied) {
+			return fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeSessionTokens(ctx, log, session.ID, session.AppID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("session revoked", slog.String("userID", session.UserID.String()))
+
+	return nil
+}
+
+// revokeSessionTokens revokes the refresh tokens of the session and makes its access tokens fail validation.
+// RevokeSession, EndSession and Logout end sessions only through it.
+func (a *Auth) revokeSessionTokens(ctx context.Context, log *slog.Logger, sessionID uuid.UUID, appID uuid.UUID) error {
+	// access tokens of the session live no longer than the token TTL of its app
+	ttl := a.tokenTTL
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil && !errors.Is(err, storage.ErrAppNotFound) {
+		log.Error("failed to get app", sl.Err(err))
+		return err
+	}
+	if err == nil {
+		ttl = a.appTokenTTL(app)
+	}
+
+	if err := a.revokedTokenProvider.RevokeSession(ctx, sessionID.String(), ttl); err != nil {
+		log.Error("failed to revoke session tokens", sl.Err(err))
+		return err
+	}
+
+	if err := a.refreshTokenProvider.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		log.Error("failed to revoke token family", sl.Err(err))
+		return err
+	}
+
+	return nil
+}
+
+// checkUserAccess fails with ErrPermissionDenied unless the owner of the token is the user or an admin.
+// It guards the sessions and the API keys of users.
+func (a *Auth) checkUserAccess(ctx context.Context, log *slog.Logger, claims jwt.Claims, userID uuid.UUID) error {
+	if claims.UserID == userID {
+		return nil
+	}
+
+	isAdmin, err := a.userProvider.IsAdmin(ctx, claims.UserID)
+	if err != nil {
+		log.Error("failed to check admin", sl.Err(err))
+		return err
+	}
+
+	if !isAdmin {
+		log.Warn("resources of another user requested", slog.String("requesterID", claims.UserID.String()))
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// newSession describes the session the refresh token starts, the client is taken from the request context
+func newSession(ctx context.Context, token models.RefreshToken) models.Session {
+	now := time.Now()
+
+	return models.Session{
+		ID:         token.FamilyID,
+		UserID:     token.UserID,
+		AppID:      token.AppID,
+		UserAgent:  userAgent(ctx),
+		IPAddress:  clientIP(ctx),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
+
+// userAgent returns the user agent of the gRPC client, HTTP handlers set it from the request header
+func userAgent(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get("user-agent")
+	if len(values) == 0 {
+		return ""
+	}
+
+	if len(values[0]) > maxUserAgentLength {
+		return values[0][:maxUserAgentLength]
+	}
+
+	return values[0]
+}
+
+// clientIP returns the address of the peer without the port
+func clientIP(ctx context.Context) string {
+	addr := clientAddr(ctx)
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	return host
+}
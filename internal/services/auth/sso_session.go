@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ObtainAppToken issues tokens of the app to the user of the SSO session without asking for the password again.
+// Using the session extends its idle timeout, but not past its max age.
+// Logging out everywhere or changing the password ends the session.
+func (a *Auth) ObtainAppToken(ctx context.Context, ssoSessionToken string, appID uuid.UUID) (models.TokenPair, error) {
+	const op = "auth.ObtainAppToken"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("appID", appID.String()),
+	)
+	log.Info("obtaining app token with sso session")
+
+	tokenHash := opaque.Hash(ssoSessionToken)
+
+	session, err := a.ssoSessions.SSOSession(ctx, tokenHash)
+	if err != nil {
+		if errors.Is(err, storage.ErrSSOSessionNotFound) {
+			log.Warn("sso session not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidSSOSession)
+		}
+
+		log.Error("failed to get sso session", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("userID", session.UserID.String()))
+
+	ttl := a.ssoSessionTTL(session)
+	if ttl <= 0 {
+		log.Warn("sso session reached max age")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.endSSOSession(ctx, log, tokenHash))
+	}
+
+	version, err := a.revokedTokenProvider.TokenVersion(ctx, session.UserID.String())
+	if err != nil {
+		log.Error("failed to get token version", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if session.TokenVersion != version {
+		log.Warn("sso session outlived the token version", slog.Int64("version", session.TokenVersion), slog.Int64("currentVersion", version))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.endSSOSession(ctx, log, tokenHash))
+	}
+
+	user, err := a.userProvider.UserByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.endSSOSession(ctx, log, tokenHash))
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.appProvider.App(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+
+		log.Error("failed to get app", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.ssoSessions.ExtendSSOSession(ctx, tokenHash, ttl); err != nil {
+		if errors.Is(err, storage.ErrSSOSessionNotFound) {
+			log.Warn("sso session expired", sl.Err(err))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidSSOSession)
+		}
+
+		log.Error("failed to extend sso session", sl.Err(err))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tokens, err := a.issueTokens(ctx, log, &user, app, "")
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app token obtained with sso session")
+
+	return tokens, nil
+}
+
+// startSSOSession starts the SSO session of the user who has just logged in and returns its token
+func (a *Auth) startSSOSession(ctx context.Context, log *slog.Logger, userID uuid.UUID) (string, error) {
+	version, err := a.revokedTokenProvider.TokenVersion(ctx, userID.String())
+	if err != nil {
+		log.Error("failed to get token version", sl.Err(err))
+		return "", err
+	}
+
+	token, err := opaque.NewToken(ssoSessionTokenSize)
+	if err != nil {
+		log.Error("failed to generate sso session token", sl.Err(err))
+		return "", err
+	}
+
+	session := models.SSOSession{
+		UserID:       userID,
+		TokenVersion: version,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := a.ssoSessions.SaveSSOSession(ctx, opaque.Hash(token), session, a.ssoSessionTTL(session)); err != nil {
+		log.Error("failed to save sso session", sl.Err(err))
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ssoSessionTTL returns how long the session may stay unused: the idle timeout, cut by the rest of its max age
+func (a *Auth) ssoSessionTTL(session models.SSOSession) time.Duration {
+	return min(a.ssoSessionLimits.IdleTimeout, time.Until(session.CreatedAt.Add(a.ssoSessionLimits.MaxAge)))
+}
+
+// endSSOSession deletes the session which can't be used anymore and returns ErrInvalidSSOSession
+func (a *Auth) endSSOSession(ctx context.Context, log *slog.Logger, tokenHash string) error {
+	if err := a.ssoSessions.DeleteSSOSession(ctx, tokenHash); err != nil {
+		log.Error("failed to delete sso session", sl.Err(err))
+		return err
+	}
+
+	return ErrInvalidSSOSession
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/BariVakhidov/sso/internal/lib/opaque"
+	"github.com/BariVakhidov/sso/internal/services/keys"
+	"github.com/BariVakhidov/sso/internal/storage"
+	"github.com/google/uuid"
+)
+
+// ValidateToken verifies the access token of a user and checks that it was not revoked
+// either by itself, by bumping the user's token version or by revoking its session.
+// Tokens of apps themselves don't act on behalf of a user and fail with ErrInvalidToken.
+func (a *Auth) ValidateToken(ctx context.Context, token string) (jwt.Claims, error) {
+	const op = "auth.ValidateToken"
+
+	claims, err := a.validateAccessToken(ctx, token)
+	if err != nil {
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.ClientID != uuid.Nil {
+		a.log.Warn("client token is not accepted", slog.String("op", op), slog.String("clientID", claims.ClientID.String()))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// validateAccessToken verifies the access token of a user or of an app itself and checks that it was not revoked.
+// Tokens of users are also revoked by bumping the user's token version or by revoking their session.
+func (a *Auth) validateAccessToken(ctx context.Context, token string) (jwt.Claims, error) {
+	const op = "auth.validateAccessToken"
+	log := a.log.With(slog.String("op", op))
+
+	claims, err := jwt.ParseToken(token, a.issuer, a.verificationKeys(ctx))
+	if err != nil {
+		if errors.Is(err, jwt.ErrInvalidToken) {
+			log.Warn("invalid token", sl.Err(err))
+			return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to parse token", sl.Err(err))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("tokenID", claims.ID))
+
+	revoked, err := a.revokedTokenProvider.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		log.Error("failed to check token revocation", sl.Err(err))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if revoked {
+		log.Warn("token is revoked")
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	// tokens of the app itself have neither the user's token version nor a session
+	if claims.ClientID != uuid.Nil {
+		return claims, nil
+	}
+
+	version, err := a.revokedTokenProvider.TokenVersion(ctx, claims.UserID.String())
+	if err != nil {
+		log.Error("failed to get token version", sl.Err(err))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if claims.Version != version {
+		log.Warn("token version is outdated", slog.Int64("version", claims.Version), slog.Int64("currentVersion", version))
+		return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := a.revokedTokenProvider.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil {
+			log.Error("failed to check session revocation", sl.Err(err))
+			return jwt.Claims{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if revoked {
+			log.Warn("session is revoked", slog.String("sessionID", claims.SessionID))
+			return jwt.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+	}
+
+	return claims, nil
+}
+
+// Introspect reports whether the access token or the API key is active (RFC 7662).
+// The caller authenticates as a confidential app and learns only about credentials of its organization.
+// Besides ValidateCredential checks the credential is active only while its user and app still exist,
+// client credentials tokens have no user and are reported with the app as the client.
+func (a *Auth) Introspect(ctx context.Context, clientID uuid.UUID, clientSecret string, token string) (models.Introspection, error) {
+	const op = "auth.Introspect"
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("clientID", clientID.String()),
+	)
+
+	client, err := a.authenticateConfidentialClient(ctx, log, clientID, clientSecret)
+	if err != nil {
+		return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	principal, err := a.ValidateCredential(ctx, token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return models.Introspection{Active: false}, nil
+		}
+
+		return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("tokenID", principal.CredentialID))
+
+	// credentials of other organizations look like invalid ones
+	if principal.OrgID != client.OrgID {
+		log.Warn("token belongs to another organization", slog.String("orgID", principal.OrgID.String()))
+		return models.Introspection{Active: false}, nil
+	}
+
+	if principal.AppID != uuid.Nil {
+		if _, err := a.appProvider.App(ctx, principal.AppID); err != nil {
+			if errors.Is(err, storage.ErrAppNotFound) {
+				log.Warn("app of the token not found", sl.Err(err))
+				return models.Introspection{Active: false}, nil
+			}
+
+			log.Error("failed to get app", sl.Err(err))
+			return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if principal.ClientID != uuid.Nil {
+		return models.Introspection{
+			Active:    true,
+			TokenID:   principal.CredentialID,
+			TokenType: principal.CredentialType,
+			AppID:     principal.AppID,
+			ClientID:  principal.ClientID,
+			OrgID:     principal.OrgID,
+			Scope:     strings.Join(principal.Scopes, " "),
+			ExpiresAt: principal.ExpiresAt,
+		}, nil
+	}
+
+	user, err := a.userProvider.UserByID(ctx, principal.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Warn("user of the token not found", sl.Err(err))
+			return models.Introspection{Active: false}, nil
+		}
+
+		log.Error("failed to get user", sl.Err(err))
+		return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := a.userRoles(ctx, user.ID, principal.AppID)
+	if err != nil {
+		log.Error("failed to get user roles", sl.Err(err))
+		return models.Introspection{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.Introspection{
+		Active:    true,
+		TokenID:   principal.CredentialID,
+		TokenType: principal.CredentialType,
+		UserID:    user.ID,
+		Email:     user.Email,
+		AppID:     principal.AppID,
+		OrgID:     user.OrgID,
+		Roles:     roleNames(roles),
+		Scope:     strings.Join(principal.Scopes, " "),
+		ExpiresAt: principal.ExpiresAt,
+	}, nil
+}
+
+// Logout revokes the access token and ends its session and, if given, the session of the refresh token
+func (a *Auth) Logout(ctx context.Context, token string, refreshToken string) error {
+	const op = "auth.Logout"
+	log := a.log.With(slog.String("op", op))
+	log.Info("logging out")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokedTokenProvider.RevokeToken(ctx, claims.ID, time.Until(claims.ExpiresAt)); err != nil {
+		log.Error("failed to revoke token", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if refreshToken != "" {
+		storedToken, err := a.refreshTokenProvider.RefreshToken(ctx, opaque.Hash(refreshToken))
+		if err != nil {
+			if errors.Is(err, storage.ErrRefreshTokenNotFound) {
+				log.Warn("refresh token not found", sl.Err(err))
+				return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+			}
+
+			log.Error("failed to get refresh token", sl.Err(err))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if storedToken.UserID != claims.UserID {
+			log.Warn("refresh token belongs to another user")
+			return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+
+		if err := a.revokeSessionTokens(ctx, log, storedToken.FamilyID, storedToken.AppID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	// the session of the token ends too, so its other access tokens stop passing validation
+	if claims.SessionID != "" {
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		if err := a.revokeSessionTokens(ctx, log, sessionID, claims.AppID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("logged out", slog.String("userID", claims.UserID.String()))
+
+	return nil
+}
+
+// LogoutAll signs the user out everywhere: every access token issued so far
+// stops passing validation and every refresh token is revoked
+func (a *Auth) LogoutAll(ctx context.Context, token string) error {
+	const op = "auth.LogoutAll"
+	log := a.log.With(slog.String("op", op))
+	log.Info("logging out from all devices")
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.revokeAllUserTokens(ctx, claims.UserID); err != nil {
+		log.Error("failed to revoke user tokens", sl.Err(err))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("logged out from all devices", slog.String("userID", claims.UserID.String()))
+
+	return nil
+}
+
+// verificationKeys accepts only tokens of the configured signing mode:
+// HS256 tokens are verified with the server-held secret, others with a published key
+func (a *Auth) verificationKeys(ctx context.Context) jwt.VerificationKeys {
+	if a.keyProvider.Symmetric() {
+		return jwt.VerificationKeys{
+			Secret: func() ([]byte, error) {
+				key, err := a.keyProvider.SigningKey(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				return key.Secret, nil
+			},
+		}
+	}
+
+	return jwt.VerificationKeys{
+		PublicKey: func(keyID string) (models.SigningKey, error) {
+			key, err := a.keyProvider.PublicKey(ctx, keyID)
+			if err != nil {
+				if errors.Is(err, keys.ErrKeyNotFound) {
+					return models.SigningKey{}, fmt.Errorf("%w: %w", jwt.ErrInvalidToken, err)
+				}
+
+				return models.SigningKey{}, err
+			}
+
+			return key, nil
+		},
+	}
+}
+
+func (a *Auth) revokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	if _, err := a.revokedTokenProvider.IncrementTokenVersion(ctx, userID.String()); err != nil {
+		return err
+	}
+
+	if err := a.apiKeyProvider.RevokeUserAPIKeys(ctx, userID); err != nil {
+		return err
+	}
+
+	return a.refreshTokenProvider.RevokeUserRefreshTokens(ctx, userID)
+}
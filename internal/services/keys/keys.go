@@ -0,0 +1,414 @@
+package keys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/encryption"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+	"github.com/BariVakhidov/sso/internal/lib/logger/sl"
+	"github.com/google/uuid"
+)
+
+const (
+	rsaKeyBits = 2048
+	// defaultReloadInterval is the least time between reloads caused by unknown kids
+	defaultReloadInterval = 10 * time.Second
+	// maxCachedMisses bounds the cache of unknown kids, the cache is cleared when it's full
+	maxCachedMisses = 1024
+)
+
+var (
+	ErrKeyNotFound = errors.New("signing key not found")
+)
+
+type KeyStorage interface {
+	SaveSigningKey(ctx context.Context, key models.StoredSigningKey) error
+	SigningKeys(ctx context.Context) ([]models.StoredSigningKey, error)
+}
+
+// Manager keeps asymmetric signing keys: it creates a new key every rotationInterval
+// and keeps publishing retired keys for the overlap window, so tokens signed
+// right before a rotation can still be verified.
+// With HS256 there is nothing to manage and tokens are signed with the configured secret.
+type Manager struct {
+	log              *slog.Logger
+	keyStorage       KeyStorage
+	algorithm        string
+	rotationInterval time.Duration
+	overlap          time.Duration
+	encryptionKey    []byte
+	// secret is the HMAC key of HS256 tokens, it is never published
+	secret []byte
+
+	mu     sync.RWMutex
+	active models.SigningKey
+	keys   map[string]models.SigningKey
+	// misses are the kids not found by the latest reload, they are cleared on every reload
+	misses map[string]struct{}
+
+	// reloadMu serializes reloads caused by unknown kids, so they happen at most once per reloadInterval
+	reloadMu       sync.Mutex
+	reloadInterval time.Duration
+	lastReload     time.Time
+
+	stopChan chan struct{}
+}
+
+// New returns a new instance of the key Manager
+func New(
+	log *slog.Logger,
+	keyStorage KeyStorage,
+	algorithm string,
+	rotationInterval time.Duration,
+	overlap time.Duration,
+	encryptionKey []byte,
+	secret []byte,
+) *Manager {
+	return &Manager{
+		log:              log,
+		keyStorage:       keyStorage,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		overlap:          overlap,
+		encryptionKey:    encryptionKey,
+		secret:           secret,
+		keys:             make(map[string]models.SigningKey),
+		misses:           make(map[string]struct{}),
+		reloadInterval:   defaultReloadInterval,
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// Symmetric reports whether tokens are signed with the server-held HS256 secret
+func (m *Manager) Symmetric() bool {
+	return m.algorithm == jwt.AlgorithmHS256
+}
+
+// Algorithm returns the algorithm tokens are signed with
+func (m *Manager) Algorithm() string {
+	return m.algorithm
+}
+
+// MustInit loads the keys and creates the first one if needed, panics on failure
+func (m *Manager) MustInit(ctx context.Context) {
+	if m.Symmetric() {
+		return
+	}
+
+	if err := m.rotateIfNeeded(ctx); err != nil {
+		panic(err)
+	}
+}
+
+// StartRotation periodically reloads keys created by other instances and rotates the active key
+func (m *Manager) StartRotation(ctx context.Context, interval time.Duration) {
+	const op = "service.keys.StartRotation"
+	log := m.log.With(slog.String("op", op))
+
+	if m.Symmetric() {
+		log.Info("symmetric signing, key rotation is disabled")
+		return
+	}
+
+	log.Info("starting key rotation", slog.Duration("interval", interval), slog.Duration("rotationInterval", m.rotationInterval))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("stopping key rotation")
+				return
+			case <-m.stopChan:
+				log.Info("stopping key rotation")
+				return
+			case <-ticker.C:
+				if err := m.rotateIfNeeded(ctx); err != nil {
+					log.Error("failed to rotate signing key", sl.Err(err))
+				}
+			}
+		}
+	}()
+}
+
+func (m *Manager) StopRotation() {
+	close(m.stopChan)
+}
+
+// SigningKey returns the key new tokens are signed with
+func (m *Manager) SigningKey(_ context.Context) (models.SigningKey, error) {
+	const op = "service.keys.SigningKey"
+
+	if m.Symmetric() {
+		return models.SigningKey{Algorithm: jwt.AlgorithmHS256, Secret: m.secret}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.active.ID == "" {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+	}
+
+	return m.active, nil
+}
+
+// PublicKey returns a published key by its kid.
+// Unknown kid triggers a reload as the key could be created by another instance,
+// reloads happen at most once per reloadInterval and kids the reload didn't find are not looked up again.
+func (m *Manager) PublicKey(ctx context.Context, keyID string) (models.SigningKey, error) {
+	const op = "service.keys.PublicKey"
+
+	if key, ok := m.publishedKey(keyID); ok {
+		return key, nil
+	}
+
+	if m.Symmetric() || m.missed(keyID) {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+	}
+
+	reloaded, err := m.reloadThrottled(ctx)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if key, ok := m.publishedKey(keyID); ok {
+		return key, nil
+	}
+
+	// a throttled reload could miss a key created since the last one, so only fresh misses are cached
+	if reloaded {
+		m.addMiss(keyID)
+	}
+
+	return models.SigningKey{}, fmt.Errorf("%s: %w", op, ErrKeyNotFound)
+}
+
+// PublicKeys returns all published keys, newest first
+func (m *Manager) PublicKeys(_ context.Context) []models.SigningKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]models.SigningKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if time.Now().Before(key.ExpiresAt) {
+			keys = append(keys, key)
+		}
+	}
+
+	// newest first, so clients that take the first key pick the active one
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys
+}
+
+func (m *Manager) publishedKey(keyID string) (models.SigningKey, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[keyID]
+	if !ok || time.Now().After(key.ExpiresAt) {
+		return models.SigningKey{}, false
+	}
+
+	return key, true
+}
+
+func (m *Manager) missed(keyID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.misses[keyID]
+	return ok
+}
+
+func (m *Manager) addMiss(keyID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.misses) >= maxCachedMisses {
+		m.misses = make(map[string]struct{})
+	}
+
+	m.misses[keyID] = struct{}{}
+}
+
+// reloadThrottled reloads the keys unless it was done less than reloadInterval ago and reports whether it did.
+// Failed reloads count too, so a broken storage isn't hammered either.
+func (m *Manager) reloadThrottled(ctx context.Context) (bool, error) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if time.Since(m.lastReload) < m.reloadInterval {
+		return false, nil
+	}
+
+	m.lastReload = time.Now()
+
+	if err := m.reload(ctx); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (m *Manager) rotateIfNeeded(ctx context.Context) error {
+	const op = "service.keys.rotateIfNeeded"
+	log := m.log.With(slog.String("op", op))
+
+	if err := m.reload(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	m.mu.RLock()
+	active := m.active
+	m.mu.RUnlock()
+
+	if active.ID != "" && time.Since(active.CreatedAt) < m.rotationInterval {
+		return nil
+	}
+
+	key, err := m.generateKey()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := m.keyStorage.SaveSigningKey(ctx, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("signing key rotated", slog.String("kid", key.ID), slog.String("previousKid", active.ID))
+
+	return m.reload(ctx)
+}
+
+// reload replaces cached keys with the ones from the storage
+func (m *Manager) reload(ctx context.Context) error {
+	const op = "service.keys.reload"
+
+	storedKeys, err := m.keyStorage.SigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys := make(map[string]models.SigningKey, len(storedKeys))
+	var active models.SigningKey
+
+	// keys are ordered newest first
+	for _, storedKey := range storedKeys {
+		key, err := m.decodeKey(storedKey)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		keys[key.ID] = key
+
+		if active.ID == "" && key.Algorithm == m.algorithm {
+			active = key
+		}
+	}
+
+	m.mu.Lock()
+	m.keys = keys
+	m.active = active
+	m.misses = make(map[string]struct{})
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Manager) generateKey() (models.StoredSigningKey, error) {
+	var (
+		privateKey crypto.Signer
+		err        error
+	)
+
+	switch m.algorithm {
+	case jwt.AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case jwt.AlgorithmES256:
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return models.StoredSigningKey{}, fmt.Errorf("%w: %s", jwt.ErrUnsupportedAlgorithm, m.algorithm)
+	}
+	if err != nil {
+		return models.StoredSigningKey{}, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return models.StoredSigningKey{}, err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return models.StoredSigningKey{}, err
+	}
+
+	encryptedPrivateKey, err := encryption.Encrypt(m.encryptionKey, privateDER)
+	if err != nil {
+		return models.StoredSigningKey{}, err
+	}
+
+	now := time.Now()
+
+	return models.StoredSigningKey{
+		ID:                  uuid.NewString(),
+		Algorithm:           m.algorithm,
+		EncryptedPrivateKey: encryptedPrivateKey,
+		PublicKey:           publicDER,
+		CreatedAt:           now,
+		ExpiresAt:           now.Add(m.rotationInterval + m.overlap),
+	}, nil
+}
+
+func (m *Manager) decodeKey(storedKey models.StoredSigningKey) (models.SigningKey, error) {
+	publicKey, err := x509.ParsePKIXPublicKey(storedKey.PublicKey)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("key %s: %w", storedKey.ID, err)
+	}
+
+	privateDER, err := encryption.Decrypt(m.encryptionKey, storedKey.EncryptedPrivateKey)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("key %s: %w", storedKey.ID, err)
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("key %s: %w", storedKey.ID, err)
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return models.SigningKey{}, fmt.Errorf("key %s: private key is not a signer", storedKey.ID)
+	}
+
+	return models.SigningKey{
+		ID:         storedKey.ID,
+		Algorithm:  storedKey.Algorithm,
+		PrivateKey: signer,
+		PublicKey:  publicKey,
+		CreatedAt:  storedKey.CreatedAt,
+		ExpiresAt:  storedKey.ExpiresAt,
+	}, nil
+}
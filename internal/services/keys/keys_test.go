@@ -0,0 +1,292 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BariVakhidov/sso/internal/domain/models"
+	"github.com/BariVakhidov/sso/internal/lib/jwt"
+)
+
+const (
+	issuer           = "sso-unit-tests"
+	rotationInterval = time.Hour
+	overlap          = 10 * time.Minute
+)
+
+func TestManager_SignVerify(t *testing.T) {
+	for _, algorithm := range []string{jwt.AlgorithmRS256, jwt.AlgorithmES256, jwt.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			ctx := context.Background()
+			manager := newManager(t, newFakeStorage(), algorithm)
+			manager.MustInit(ctx)
+
+			key, err := manager.SigningKey(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, key.Algorithm)
+
+			token := newToken(t, key)
+			_, err = jwt.ParseToken(token, issuer, verificationKeys(ctx, manager))
+			require.NoError(t, err)
+
+			published := manager.PublicKeys(ctx)
+			require.Len(t, published, 1)
+			assert.Equal(t, key.ID, published[0].ID)
+		})
+	}
+}
+
+func TestManager_Rotate(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeStorage()
+	manager := newManager(t, storage, jwt.AlgorithmES256)
+	manager.MustInit(ctx)
+
+	oldKey, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+	oldToken := newToken(t, oldKey)
+
+	// the key is due for rotation, but it is still inside the overlap
+	storage.age(oldKey.ID, rotationInterval+time.Minute)
+	require.NoError(t, manager.rotateIfNeeded(ctx))
+
+	newKey, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, oldKey.ID, newKey.ID)
+	newToken := newToken(t, newKey)
+
+	published := manager.PublicKeys(ctx)
+	require.Len(t, published, 2)
+	assert.Equal(t, newKey.ID, published[0].ID)
+	assert.Equal(t, oldKey.ID, published[1].ID)
+
+	_, err = jwt.ParseToken(oldToken, issuer, verificationKeys(ctx, manager))
+	require.NoError(t, err)
+	_, err = jwt.ParseToken(newToken, issuer, verificationKeys(ctx, manager))
+	require.NoError(t, err)
+
+	// the overlap is over
+	storage.age(oldKey.ID, overlap)
+	require.NoError(t, manager.rotateIfNeeded(ctx))
+
+	activeKey, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, newKey.ID, activeKey.ID)
+
+	published = manager.PublicKeys(ctx)
+	require.Len(t, published, 1)
+	assert.Equal(t, newKey.ID, published[0].ID)
+
+	_, err = jwt.ParseToken(oldToken, issuer, verificationKeys(ctx, manager))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = jwt.ParseToken(newToken, issuer, verificationKeys(ctx, manager))
+	require.NoError(t, err)
+}
+
+func TestManager_PublicKey_KeyOfAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeStorage()
+
+	manager := newManager(t, storage, jwt.AlgorithmES256)
+	manager.MustInit(ctx)
+
+	anotherManager := newManager(t, storage, jwt.AlgorithmES256)
+	anotherManager.encryptionKey = manager.encryptionKey
+	anotherManager.MustInit(ctx)
+
+	key, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+
+	storage.age(key.ID, rotationInterval)
+	require.NoError(t, anotherManager.rotateIfNeeded(ctx))
+
+	newKey, err := anotherManager.SigningKey(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, key.ID, newKey.ID)
+
+	_, err = jwt.ParseToken(newToken(t, newKey), issuer, verificationKeys(ctx, manager))
+	require.NoError(t, err)
+}
+
+func TestManager_PublicKey_ThrottledReloads(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeStorage()
+
+	manager := newManager(t, storage, jwt.AlgorithmES256)
+	manager.MustInit(ctx)
+	loads := storage.loadCount()
+
+	unknownKeyID, anotherUnknownKeyID := uuid.NewString(), uuid.NewString()
+
+	_, err := manager.PublicKey(ctx, unknownKeyID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, loads+1, storage.loadCount())
+
+	// the miss is cached
+	_, err = manager.PublicKey(ctx, unknownKeyID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, loads+1, storage.loadCount())
+
+	// another kid is throttled
+	_, err = manager.PublicKey(ctx, anotherUnknownKeyID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, loads+1, storage.loadCount())
+
+	manager.lastReload = time.Time{}
+
+	// the cached miss is not looked up again even once the interval is over
+	_, err = manager.PublicKey(ctx, unknownKeyID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, loads+1, storage.loadCount())
+
+	// the throttled kid was not cached
+	_, err = manager.PublicKey(ctx, anotherUnknownKeyID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, loads+2, storage.loadCount())
+
+	// a key created by another instance within the interval is found once it's over
+	anotherManager := newManager(t, storage, jwt.AlgorithmES256)
+	anotherManager.encryptionKey = manager.encryptionKey
+	anotherManager.MustInit(ctx)
+
+	key, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+
+	storage.age(key.ID, rotationInterval)
+	require.NoError(t, anotherManager.rotateIfNeeded(ctx))
+
+	newKey, err := anotherManager.SigningKey(ctx)
+	require.NoError(t, err)
+
+	_, err = manager.PublicKey(ctx, newKey.ID)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	loads = storage.loadCount()
+
+	manager.lastReload = time.Time{}
+
+	_, err = manager.PublicKey(ctx, newKey.ID)
+	require.NoError(t, err)
+	require.Equal(t, loads+1, storage.loadCount())
+}
+
+func TestManager_Symmetric(t *testing.T) {
+	ctx := context.Background()
+	storage := newFakeStorage()
+
+	manager := newManager(t, storage, jwt.AlgorithmHS256)
+	manager.MustInit(ctx)
+
+	key, err := manager.SigningKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, jwt.AlgorithmHS256, key.Algorithm)
+	assert.Equal(t, manager.secret, key.Secret)
+
+	_, err = manager.PublicKey(ctx, uuid.NewString())
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	assert.Empty(t, manager.PublicKeys(ctx))
+	assert.Zero(t, storage.loadCount())
+}
+
+type fakeStorage struct {
+	mu    sync.Mutex
+	keys  []models.StoredSigningKey
+	loads int
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{}
+}
+
+func (s *fakeStorage) SaveSigningKey(_ context.Context, key models.StoredSigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// keys are ordered newest first
+	s.keys = append([]models.StoredSigningKey{key}, s.keys...)
+
+	return nil
+}
+
+func (s *fakeStorage) SigningKeys(_ context.Context) ([]models.StoredSigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loads++
+
+	return append([]models.StoredSigningKey(nil), s.keys...), nil
+}
+
+func (s *fakeStorage) loadCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loads
+}
+
+// age moves the key back in time as if it was created d ago
+func (s *fakeStorage) age(keyID string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID == keyID {
+			s.keys[i].CreatedAt = s.keys[i].CreatedAt.Add(-d)
+			s.keys[i].ExpiresAt = s.keys[i].ExpiresAt.Add(-d)
+		}
+	}
+}
+
+func newManager(t *testing.T, storage KeyStorage, algorithm string) *Manager {
+	t.Helper()
+
+	return New(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		storage,
+		algorithm,
+		rotationInterval,
+		overlap,
+		randomBytes(t),
+		randomBytes(t),
+	)
+}
+
+func newToken(t *testing.T, key models.SigningKey) string {
+	t.Helper()
+
+	token, err := jwt.NewToken(&models.User{ID: uuid.New()}, models.App{ID: uuid.New()}, jwt.TokenOptions{
+		Issuer: issuer,
+		TTL:    time.Hour,
+		Key:    key,
+	})
+	require.NoError(t, err)
+
+	return token
+}
+
+func verificationKeys(ctx context.Context, manager *Manager) jwt.VerificationKeys {
+	return jwt.VerificationKeys{
+		PublicKey: func(keyID string) (models.SigningKey, error) {
+			return manager.PublicKey(ctx, keyID)
+		},
+	}
+}
+
+func randomBytes(t *testing.T) []byte {
+	t.Helper()
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+
+	return b
+}
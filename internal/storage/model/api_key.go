@@ -0,0 +1,21 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type APIKey struct {
+	ID         uuid.UUID     `db:"id"`
+	UserID     uuid.UUID     `db:"user_id"`
+	AppID      uuid.NullUUID `db:"app_id"`
+	Name       string        `db:"name"`
+	Prefix     string        `db:"prefix"`
+	KeyHash    string        `db:"key_hash"`
+	Scopes     []string      `db:"scopes"`
+	CreatedAt  time.Time     `db:"created_at"`
+	ExpiresAt  sql.NullTime  `db:"expires_at"`
+	LastUsedAt sql.NullTime  `db:"last_used_at"`
+}
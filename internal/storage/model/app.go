@@ -0,0 +1,36 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type App struct {
+	ID                     uuid.UUID     `db:"id"`
+	OrgID                  uuid.UUID     `db:"org_id"`
+	Name                   string        `db:"name"`
+	Secret                 string        `db:"secret"`
+	TokenTTLSeconds        sql.NullInt64 `db:"token_ttl_seconds"`
+	Claims                 []string      `db:"claims"`
+	RedirectURIs           []string      `db:"redirect_uris"`
+	ClientType             string        `db:"client_type"`
+	PostLogoutRedirectURIs []string      `db:"post_logout_redirect_uris"`
+	Scopes                 []string      `db:"scopes"`
+	RequireVerifiedEmail   bool          `db:"require_verified_email"`
+	// PasswordPolicy is NULL when the app uses the global password policy
+	PasswordPolicy   *PasswordPolicy `db:"password_policy"`
+	MagicLinkEnabled bool            `db:"magic_link_enabled"`
+}
+
+// PasswordPolicy is stored as JSON
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`
+	MaxLength     int  `json:"max_length"`
+	RequireUpper  bool `json:"require_upper"`
+	RequireLower  bool `json:"require_lower"`
+	RequireDigit  bool `json:"require_digit"`
+	RequireSymbol bool `json:"require_symbol"`
+	RejectCommon  bool `json:"reject_common"`
+	RejectEmail   bool `json:"reject_email"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type TOTPSecret struct {
+	UserID          uuid.UUID    `db:"user_id"`
+	EncryptedSecret []byte       `db:"encrypted_secret"`
+	EnabledAt       sql.NullTime `db:"enabled_at"`
+	LastUsedStep    int64        `db:"last_used_step"`
+}
+
+type RecoveryCode struct {
+	ID       uuid.UUID `db:"id"`
+	CodeHash []byte    `db:"code_hash"`
+}
@@ -0,0 +1,10 @@
+package model
+
+type Relationship struct {
+	ObjectType      string `db:"object_type"`
+	ObjectID        string `db:"object_id"`
+	Relation        string `db:"relation"`
+	SubjectType     string `db:"subject_type"`
+	SubjectID       string `db:"subject_id"`
+	SubjectRelation string `db:"subject_relation"`
+}
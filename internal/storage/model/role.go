@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	ID          uuid.UUID     `db:"id"`
+	Name        string        `db:"name"`
+	AppID       uuid.NullUUID `db:"app_id"`
+	Permissions []string      `db:"permissions"`
+	BuiltIn     bool          `db:"built_in"`
+	CreatedAt   time.Time     `db:"created_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type SigningKey struct {
+	ID         string    `db:"id"`
+	Algorithm  string    `db:"algorithm"`
+	PrivateKey []byte    `db:"private_key"`
+	PublicKey  []byte    `db:"public_key"`
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+}
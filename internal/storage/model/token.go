@@ -0,0 +1,30 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshToken struct {
+	ID        uuid.UUID    `db:"id"`
+	FamilyID  uuid.UUID    `db:"family_id"`
+	UserID    uuid.UUID    `db:"user_id"`
+	AppID     uuid.UUID    `db:"app_id"`
+	TokenHash string       `db:"token_hash"`
+	Scope     string       `db:"scope"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	RotatedAt sql.NullTime `db:"rotated_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	AppID      uuid.UUID `db:"app_id"`
+	UserAgent  string    `db:"user_agent"`
+	IPAddress  string    `db:"ip_address"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+}
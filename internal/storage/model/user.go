@@ -3,7 +3,9 @@ package model
 import "github.com/google/uuid"
 
 type User struct {
-	ID       uuid.UUID `db:"id"`
-	Email    string    `db:"email"`
-	PassHash []byte    `db:"pass_hash"`
+	ID            uuid.UUID `db:"id"`
+	OrgID         uuid.UUID `db:"org_id"`
+	Email         string    `db:"email"`
+	PassHash      []byte    `db:"pass_hash"`
+	EmailVerified bool      `db:"email_verified"`
 }
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebAuthnCredential struct {
+	ID              []byte       `db:"id"`
+	UserID          uuid.UUID    `db:"user_id"`
+	Name            string       `db:"name"`
+	PublicKey       []byte       `db:"public_key"`
+	AttestationType string       `db:"attestation_type"`
+	AAGUID          []byte       `db:"aaguid"`
+	SignCount       int64        `db:"sign_count"`
+	Transports      []string     `db:"transports"`
+	BackupEligible  bool         `db:"backup_eligible"`
+	BackupState     bool         `db:"backup_state"`
+	CreatedAt       time.Time    `db:"created_at"`
+	LastUsedAt      sql.NullTime `db:"last_used_at"`
+}
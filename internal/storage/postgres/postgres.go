@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/BariVakhidov/sso/internal/domain/converter"
 	"github.com/BariVakhidov/sso/internal/domain/models"
@@ -48,7 +49,8 @@ func New(log *slog.Logger, dbAddr string) (*Storage, error) {
 	return &Storage{dbpool: dbpool, log: log}, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash []byte) (user models.User, err error) {
+// SaveUser saves the user owned by the organization together with the membership in it
+func (s *Storage) SaveUser(ctx context.Context, orgID uuid.UUID, userID, email string, passHash []byte) (user models.User, err error) {
 	const op = "storage.postgres.SaveUser"
 	log := s.log.With(slog.String("op", op))
 
@@ -73,9 +75,10 @@ func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash [
 		}
 	}()
 
-	query := "INSERT INTO users(id,email,pass_hash) VALUES(@userId,@userEmail,@userPassHash) RETURNING id,email,pass_hash"
+	query := "INSERT INTO users(id,org_id,email,pass_hash) VALUES(@userId,@orgId,@userEmail,@userPassHash) RETURNING id,org_id,email,pass_hash,email_verified"
 	args := pgx.NamedArgs{
 		"userId":       userID,
+		"orgId":        orgID,
 		"userEmail":    email,
 		"userPassHash": passHash,
 	}
@@ -86,7 +89,7 @@ func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash [
 		ctx,
 		query,
 		args,
-	).Scan(&storageUser.ID, &storageUser.Email, &storageUser.PassHash)
+	).Scan(&storageUser.ID, &storageUser.OrgID, &storageUser.Email, &storageUser.PassHash, &storageUser.EmailVerified)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -97,6 +100,11 @@ func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash [
 		return user, fmt.Errorf("%s: %w", op, err)
 	}
 
+	query = "INSERT INTO organization_members(org_id,user_id,role) VALUES($1,$2,$3)"
+	if _, err = tx.Exec(ctx, query, orgID, storageUser.ID, models.OrganizationRoleMember); err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
 	eventPayload, err := json.Marshal(converter.ToUserEventFromStorage(storageUser))
 	if err != nil {
 		return user, fmt.Errorf("%s: %w", op, err)
@@ -109,13 +117,31 @@ func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash [
 	return converter.ToUserFromStorage(storageUser), nil
 }
 
-func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+// User returns the user of the organization with the email
+func (s *Storage) User(ctx context.Context, orgID uuid.UUID, email string) (models.User, error) {
 	const op = "storage.postgres.User"
 
-	query := "SELECT id,email,pass_hash FROM users WHERE email=$1"
+	query := "SELECT id,org_id,email,pass_hash,email_verified FROM users WHERE org_id=$1 AND email=$2"
+	var user storageModel.User
+
+	err := s.dbpool.QueryRow(ctx, query, orgID, email).Scan(&user.ID, &user.OrgID, &user.Email, &user.PassHash, &user.EmailVerified)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToUserFromStorage(user), nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID uuid.UUID) (models.User, error) {
+	const op = "storage.postgres.UserByID"
+
+	query := "SELECT id,org_id,email,pass_hash,email_verified FROM users WHERE id=$1"
 	var user storageModel.User
 
-	err := s.dbpool.QueryRow(ctx, query, email).Scan(&user.ID, &user.Email, &user.PassHash)
+	err := s.dbpool.QueryRow(ctx, query, userID).Scan(&user.ID, &user.OrgID, &user.Email, &user.PassHash, &user.EmailVerified)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -126,12 +152,160 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return converter.ToUserFromStorage(user), nil
 }
 
+// SetEmailVerified marks the email of the user verified and saves the event about it,
+// reporting false if the user has another email or it was verified already
+func (s *Storage) SetEmailVerified(ctx context.Context, userID uuid.UUID, email string) (verified bool, err error) {
+	const op = "storage.postgres.SetEmailVerified"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil || !verified {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			verified = false
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE users SET email_verified=TRUE WHERE id=$1 AND email=$2 AND NOT email_verified RETURNING id,email"
+
+	storageUser := storageModel.User{}
+	if err = tx.QueryRow(ctx, query, userID, email).Scan(&storageUser.ID, &storageUser.Email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	eventPayload, err := json.Marshal(converter.ToUserEventFromStorage(storageUser))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.saveEvent(ctx, tx, storage.EventEmailVerified, string(eventPayload)); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// ResetPassword sets the new password hash, revokes refresh tokens of the user and saves the event about it
+func (s *Storage) ResetPassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	const op = "storage.postgres.ResetPassword"
+
+	// no token family has the nil ID, so all of them are revoked
+	if err := s.updatePassword(ctx, userID, passHash, uuid.Nil, storage.EventPasswordReset); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ChangePassword sets the new password hash, revokes refresh tokens of the user except the kept family
+// and saves the event about it
+func (s *Storage) ChangePassword(ctx context.Context, userID uuid.UUID, passHash []byte, keptFamilyID uuid.UUID) error {
+	const op = "storage.postgres.ChangePassword"
+
+	if err := s.updatePassword(ctx, userID, passHash, keptFamilyID, storage.EventPasswordChanged); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpgradePasswordHash replaces the hash of the same password made with outdated parameters.
+// The hash is kept when the password has changed since oldPassHash was read.
+func (s *Storage) UpgradePasswordHash(ctx context.Context, userID uuid.UUID, oldPassHash []byte, newPassHash []byte) error {
+	const op = "storage.postgres.UpgradePasswordHash"
+
+	query := "UPDATE users SET pass_hash=$3 WHERE id=$1 AND pass_hash=$2"
+	if _, err := s.dbpool.Exec(ctx, query, userID, oldPassHash, newPassHash); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) updatePassword(ctx context.Context, userID uuid.UUID, passHash []byte, keptFamilyID uuid.UUID, eventType string) (err error) {
+	const op = "storage.postgres.updatePassword"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE users SET pass_hash=$2 WHERE id=$1 RETURNING id,email"
+
+	storageUser := storageModel.User{}
+	if err = tx.QueryRow(ctx, query, userID, passHash).Scan(&storageUser.ID, &storageUser.Email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND family_id<>$2 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, userID, keptFamilyID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET revoked_at=NOW() WHERE user_id=$1 AND id<>$2 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, userID, keptFamilyID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	eventPayload, err := json.Marshal(converter.ToUserEventFromStorage(storageUser))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.saveEvent(ctx, tx, eventType, string(eventPayload)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// IsAdmin reports whether the user is assigned the built-in admin role
 func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 	const op = "storage.postgres.IsAdmin"
 
-	query := "SELECT is_admin FROM users WHERE id=$1"
+	query := userGroupsQuery + `
+		SELECT EXISTS (SELECT 1 FROM user_roles WHERE user_id=users.id AND role_id=$2)
+			OR EXISTS (SELECT 1 FROM group_roles gr JOIN user_groups ug ON ug.id=gr.group_id WHERE gr.role_id=$2)
+		FROM users WHERE id=$1`
 	var isAdmin bool
-	err := s.dbpool.QueryRow(ctx, query, userID).Scan(&isAdmin)
+	err := s.dbpool.QueryRow(ctx, query, userID, models.AdminRoleID).Scan(&isAdmin)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -144,66 +318,100 @@ func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 	return isAdmin, nil
 }
 
+// appColumns are selected into storageModel.App
+const appColumns = "id, org_id, name, secret, token_ttl_seconds, claims, redirect_uris, client_type, post_logout_redirect_uris, scopes, require_verified_email, password_policy, magic_link_enabled"
+
 func (s *Storage) App(ctx context.Context, appID uuid.UUID) (models.App, error) {
 	const op = "storage.postgres.App"
 
-	query := "SELECT id,name,secret FROM apps WHERE id=$1"
-	var app models.App
-	err := s.dbpool.QueryRow(ctx, query, appID).Scan(&app.ID, &app.Name, &app.Secret)
-
+	query := "SELECT " + appColumns + " FROM apps WHERE id=$1"
+	app, err := s.queryApp(ctx, query, appID)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
-		}
-
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return app, nil
 }
 
-func (s *Storage) FindApp(ctx context.Context, name string) (models.App, error) {
+// FindApp returns the app of the organization with the name
+func (s *Storage) FindApp(ctx context.Context, orgID uuid.UUID, name string) (models.App, error) {
 	const op = "storage.postgres.FindApp"
-	var app models.App
-
-	query := "SELECT id,name,secret FROM apps WHERE name=$1"
-	err := s.dbpool.QueryRow(ctx, query, name).Scan(&app.ID, &app.Name, &app.Secret)
 
+	query := "SELECT " + appColumns + " FROM apps WHERE org_id=$1 AND name=$2"
+	app, err := s.queryApp(ctx, query, orgID, name)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
-		}
-
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return app, nil
 }
 
-func (s *Storage) CreateApp(ctx context.Context, appID, name string, secret string) (models.App, error) {
+func (s *Storage) CreateApp(ctx context.Context, newApp models.App) (models.App, error) {
 	const op = "storage.postgres.CreateApp"
 
-	query := "INSERT INTO apps(id,name,secret) VALUES(@appId,@appName,@appSecret) RETURNING id,name,secret"
+	query := `INSERT INTO apps(id, org_id, name, secret, token_ttl_seconds, claims, redirect_uris, client_type, post_logout_redirect_uris, scopes, require_verified_email, password_policy, magic_link_enabled)
+		VALUES(@appId, @orgId, @appName, @appSecret, @tokenTTLSeconds, @claims, @redirectURIs, @clientType, @postLogoutRedirectURIs, @scopes, @requireVerifiedEmail, @passwordPolicy, @magicLinkEnabled)
+		RETURNING ` + appColumns
 	args := pgx.NamedArgs{
-		"appId":     appID,
-		"appName":   name,
-		"appSecret": secret,
+		"appId":                  newApp.ID,
+		"orgId":                  newApp.OrgID,
+		"appName":                newApp.Name,
+		"appSecret":              newApp.Secret,
+		"tokenTTLSeconds":        converter.ToStorageTokenTTL(newApp.TokenTTL),
+		"claims":                 nonNilStrings(newApp.Claims),
+		"redirectURIs":           nonNilStrings(newApp.RedirectURIs),
+		"clientType":             newApp.ClientType,
+		"postLogoutRedirectURIs": nonNilStrings(newApp.PostLogoutRedirectURIs),
+		"scopes":                 nonNilStrings(newApp.Scopes),
+		"requireVerifiedEmail":   newApp.RequireVerifiedEmail,
+		"passwordPolicy":         converter.ToStoragePasswordPolicy(newApp.PasswordPolicy),
+		"magicLinkEnabled":       newApp.MagicLinkEnabled,
 	}
-	var app models.App
-	err := s.dbpool.QueryRow(ctx, query, args).Scan(&app.ID, &app.Name, &app.Secret)
 
+	app, err := s.queryApp(ctx, query, args)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
 		}
 
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrOrganizationNotFound)
+		}
+
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return app, nil
 }
 
+func (s *Storage) queryApp(ctx context.Context, query string, args ...any) (models.App, error) {
+	rows, err := s.dbpool.Query(ctx, query, args...)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	app, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.App])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.App{}, storage.ErrAppNotFound
+		}
+
+		return models.App{}, err
+	}
+
+	return converter.ToAppFromStorage(app), nil
+}
+
+// nonNilStrings keeps NOT NULL array columns from receiving NULL, as nil slices are encoded as NULL
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
 func (s *Storage) NewEvents(ctx context.Context, limit int) ([]models.Event, error) {
 	const op = "storage.postgres.NewEvents"
 
@@ -254,21 +462,1466 @@ func (s *Storage) SetEventDone(ctx context.Context, eventId uuid.UUID) (models.E
 	return converter.ToEventFromStorage(event), nil
 }
 
-func (s *Storage) saveEvent(ctx context.Context, tx pgx.Tx, eventType, payload string) error {
-	const op = "storage.postgres.saveEvent"
+// StartSession saves the session with the first refresh token of its token family in one transaction
+func (s *Storage) StartSession(ctx context.Context, session models.Session, token models.RefreshToken) (err error) {
+	const op = "storage.postgres.StartSession"
+	log := s.log.With(slog.String("op", op))
 
-	query := "INSERT INTO events(id,event_type,payload) VALUES(@eventId,@eventType,@payload)"
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := `INSERT INTO sessions(id,user_id,app_id,user_agent,ip_address,created_at,last_seen_at)
+		VALUES(@sessionId,@userId,@appId,@userAgent,@ipAddress,@createdAt,@createdAt)`
 	args := pgx.NamedArgs{
-		"eventId":   uuid.New(),
-		"eventType": eventType,
-		"payload":   payload,
+		"sessionId": session.ID,
+		"userId":    session.UserID,
+		"appId":     session.AppID,
+		"userAgent": session.UserAgent,
+		"ipAddress": session.IPAddress,
+		"createdAt": session.CreatedAt.UTC(),
 	}
 
-	if _, err := tx.Exec(
-		ctx,
-		query,
-		args,
-	); err != nil {
+	if _, err = tx.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.insertRefreshToken(ctx, tx, token); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Session(ctx context.Context, sessionID uuid.UUID) (models.Session, error) {
+	const op = "storage.postgres.Session"
+
+	query := `SELECT id, user_id, app_id, user_agent, ip_address, created_at, last_seen_at
+		FROM sessions
+		WHERE id=$1`
+
+	rows, err := s.dbpool.Query(ctx, query, sessionID)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	session, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.Session])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToSessionFromStorage(session), nil
+}
+
+// Sessions returns the sessions of the user having an active refresh token, most recently seen first
+func (s *Storage) Sessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	const op = "storage.postgres.Sessions"
+
+	query := `SELECT id, user_id, app_id, user_agent, ip_address, created_at, last_seen_at
+		FROM sessions
+		WHERE user_id=$1 AND revoked_at IS NULL AND EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE family_id=sessions.id AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		)
+		ORDER BY last_seen_at DESC`
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sessions, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Session])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToSessionsFromStorage(sessions), nil
+}
+
+func (s *Storage) RefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.postgres.RefreshToken"
+
+	query := `SELECT id, family_id, user_id, app_id, token_hash, scope, expires_at, rotated_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash=$1`
+
+	rows, err := s.dbpool.Query(ctx, query, tokenHash)
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.RefreshToken])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenNotFound)
+		}
+
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRefreshTokenFromStorage(token), nil
+}
+
+// RotateRefreshToken marks the old token as rotated and saves its successor in one transaction.
+// Returns storage.ErrRefreshTokenRotated if the old token was rotated or revoked concurrently.
+func (s *Storage) RotateRefreshToken(ctx context.Context, oldTokenID uuid.UUID, newToken models.RefreshToken) (err error) {
+	const op = "storage.postgres.RotateRefreshToken"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE refresh_tokens SET rotated_at=NOW() WHERE id=$1 AND rotated_at IS NULL AND revoked_at IS NULL"
+	tag, err := tx.Exec(ctx, query, oldTokenID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRefreshTokenRotated)
+	}
+
+	if err = s.insertRefreshToken(ctx, tx, newToken); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET last_seen_at=NOW() WHERE id=$1"
+	if _, err = tx.Exec(ctx, query, newToken.FamilyID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeRefreshTokenFamily revokes the refresh tokens of the family and ends the session of the family
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyID uuid.UUID) (err error) {
+	const op = "storage.postgres.RevokeRefreshTokenFamily"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE refresh_tokens SET revoked_at=NOW() WHERE family_id=$1 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, familyID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET revoked_at=NOW() WHERE id=$1 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, familyID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token of the user and ends all the user's sessions
+func (s *Storage) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (err error) {
+	const op = "storage.postgres.RevokeUserRefreshTokens"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE refresh_tokens SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = "UPDATE sessions SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL"
+	if _, err = tx.Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.StoredSigningKey) error {
+	const op = "storage.postgres.SaveSigningKey"
+
+	query := `INSERT INTO signing_keys(id,algorithm,private_key,public_key,created_at,expires_at)
+		VALUES(@keyId,@algorithm,@privateKey,@publicKey,@createdAt,@expiresAt)`
+	args := pgx.NamedArgs{
+		"keyId":      key.ID,
+		"algorithm":  key.Algorithm,
+		"privateKey": key.EncryptedPrivateKey,
+		"publicKey":  key.PublicKey,
+		"createdAt":  key.CreatedAt.UTC(),
+		"expiresAt":  key.ExpiresAt.UTC(),
+	}
+
+	if _, err := s.dbpool.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SigningKeys returns not expired signing keys, newest first
+func (s *Storage) SigningKeys(ctx context.Context) ([]models.StoredSigningKey, error) {
+	const op = "storage.postgres.SigningKeys"
+
+	query := `SELECT id, algorithm, private_key, public_key, created_at, expires_at
+		FROM signing_keys
+		WHERE expires_at > NOW()
+		ORDER BY created_at DESC`
+
+	rows, err := s.dbpool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.SigningKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToSigningKeysFromStorage(keys), nil
+}
+
+func (s *Storage) saveEvent(ctx context.Context, tx pgx.Tx, eventType, payload string) error {
+	const op = "storage.postgres.saveEvent"
+
+	query := "INSERT INTO events(id,event_type,payload) VALUES(@eventId,@eventType,@payload)"
+	args := pgx.NamedArgs{
+		"eventId":   uuid.New(),
+		"eventType": eventType,
+		"payload":   payload,
+	}
+
+	if _, err := tx.Exec(
+		ctx,
+		query,
+		args,
+	); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// execer is implemented by both pgxpool.Pool and pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func (s *Storage) insertRefreshToken(ctx context.Context, db execer, token models.RefreshToken) error {
+	const op = "storage.postgres.insertRefreshToken"
+
+	query := `INSERT INTO refresh_tokens(id,family_id,user_id,app_id,token_hash,scope,expires_at)
+		VALUES(@tokenId,@familyId,@userId,@appId,@tokenHash,@scope,@expiresAt)`
+	args := pgx.NamedArgs{
+		"tokenId":   token.ID,
+		"familyId":  token.FamilyID,
+		"userId":    token.UserID,
+		"appId":     token.AppID,
+		"tokenHash": token.TokenHash,
+		"scope":     token.Scope,
+		"expiresAt": token.ExpiresAt.UTC(),
+	}
+
+	if _, err := db.Exec(ctx, query, args); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveTOTPSecret saves the secret of a new enrollment, replacing the unconfirmed one.
+// Returns storage.ErrMFAEnabled if the user already confirmed an enrollment.
+func (s *Storage) SaveTOTPSecret(ctx context.Context, userID uuid.UUID, encryptedSecret []byte) error {
+	const op = "storage.postgres.SaveTOTPSecret"
+
+	query := `INSERT INTO mfa_totp(user_id, encrypted_secret)
+		VALUES($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET encrypted_secret=EXCLUDED.encrypted_secret, last_used_step=0, created_at=CURRENT_TIMESTAMP
+		WHERE mfa_totp.enabled_at IS NULL`
+
+	tag, err := s.dbpool.Exec(ctx, query, userID, encryptedSecret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAEnabled)
+	}
+
+	return nil
+}
+
+func (s *Storage) TOTPSecret(ctx context.Context, userID uuid.UUID) (models.TOTPSecret, error) {
+	const op = "storage.postgres.TOTPSecret"
+
+	query := "SELECT user_id, encrypted_secret, enabled_at, last_used_step FROM mfa_totp WHERE user_id=$1"
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return models.TOTPSecret{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.TOTPSecret])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.TOTPSecret{}, fmt.Errorf("%s: %w", op, storage.ErrTOTPSecretNotFound)
+		}
+
+		return models.TOTPSecret{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToTOTPSecretFromStorage(secret), nil
+}
+
+// EnableTOTP confirms the enrollment with the step of the code it was confirmed with,
+// saving the recovery codes of the user in the same transaction
+func (s *Storage) EnableTOTP(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes [][]byte) (err error) {
+	const op = "storage.postgres.EnableTOTP"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE mfa_totp SET enabled_at=NOW(), last_used_step=$2 WHERE user_id=$1 AND enabled_at IS NULL"
+
+	tag, err := tx.Exec(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAEnabled)
+	}
+
+	if err = s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the step of the accepted code, reporting false if the step or a later one was used already
+func (s *Storage) UseTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	const op = "storage.postgres.UseTOTPStep"
+
+	query := "UPDATE mfa_totp SET last_used_step=$2 WHERE user_id=$1 AND last_used_step < $2"
+
+	tag, err := s.dbpool.Exec(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// SaveRecoveryCodes replaces the recovery codes of the user, the old ones can't be used anymore
+func (s *Storage) SaveRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes [][]byte) (err error) {
+	const op = "storage.postgres.SaveRecoveryCodes"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	if err = s.replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RecoveryCodes returns the unused recovery codes of the user
+func (s *Storage) RecoveryCodes(ctx context.Context, userID uuid.UUID) ([]models.RecoveryCode, error) {
+	const op = "storage.postgres.RecoveryCodes"
+
+	query := "SELECT id, code_hash FROM mfa_recovery_codes WHERE user_id=$1 AND used_at IS NULL"
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	codes, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.RecoveryCode])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRecoveryCodesFromStorage(codes), nil
+}
+
+// UseRecoveryCode marks the recovery code used and saves the event about it,
+// reporting false if the code was used already
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeID uuid.UUID) (used bool, err error) {
+	const op = "storage.postgres.UseRecoveryCode"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil || !used {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			used = false
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "UPDATE mfa_recovery_codes SET used_at=NOW() WHERE id=$1 AND user_id=$2 AND used_at IS NULL"
+
+	tag, err := tx.Exec(ctx, query, codeID, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	event := models.RecoveryCodeEvent{UserID: userID}
+
+	query = "SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id=$1 AND used_at IS NULL"
+	if err = tx.QueryRow(ctx, query, userID).Scan(&event.Remaining); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	eventPayload, err := json.Marshal(event)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = s.saveEvent(ctx, tx, storage.EventRecoveryCodeUsed, string(eventPayload)); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+func (s *Storage) replaceRecoveryCodes(ctx context.Context, db execer, userID uuid.UUID, codeHashes [][]byte) error {
+	const op = "storage.postgres.replaceRecoveryCodes"
+
+	if _, err := db.Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id=$1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := "INSERT INTO mfa_recovery_codes(id,user_id,code_hash) VALUES(@codeId,@userId,@codeHash)"
+	for _, codeHash := range codeHashes {
+		args := pgx.NamedArgs{
+			"codeId":   uuid.New(),
+			"userId":   userID,
+			"codeHash": codeHash,
+		}
+
+		if _, err := db.Exec(ctx, query, args); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error {
+	const op = "storage.postgres.SaveWebAuthnCredential"
+
+	query := `INSERT INTO webauthn_credentials(id,user_id,name,public_key,attestation_type,aaguid,sign_count,transports,backup_eligible,backup_state)
+		VALUES(@id,@userId,@name,@publicKey,@attestationType,@aaguid,@signCount,@transports,@backupEligible,@backupState)`
+	args := pgx.NamedArgs{
+		"id":              credential.ID,
+		"userId":          credential.UserID,
+		"name":            credential.Name,
+		"publicKey":       credential.PublicKey,
+		"attestationType": credential.AttestationType,
+		"aaguid":          credential.AAGUID,
+		"signCount":       int64(credential.SignCount),
+		"transports":      nonNilStrings(credential.Transports),
+		"backupEligible":  credential.BackupEligible,
+		"backupState":     credential.BackupState,
+	}
+
+	if _, err := s.dbpool.Exec(ctx, query, args); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWebAuthnCredentialExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) WebAuthnCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	const op = "storage.postgres.WebAuthnCredentials"
+
+	query := `SELECT id, user_id, name, public_key, attestation_type, aaguid, sign_count, transports,
+		backup_eligible, backup_state, created_at, last_used_at
+		FROM webauthn_credentials WHERE user_id=$1 ORDER BY created_at`
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	credentials, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.WebAuthnCredential])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToWebAuthnCredentialsFromStorage(credentials), nil
+}
+
+// UseWebAuthnCredential records the assertion of the credential, reporting false if the signature counter didn't grow.
+// Authenticators that don't count always report zero.
+func (s *Storage) UseWebAuthnCredential(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) (bool, error) {
+	const op = "storage.postgres.UseWebAuthnCredential"
+
+	query := `UPDATE webauthn_credentials SET sign_count=$2, backup_state=$3, last_used_at=NOW()
+		WHERE id=$1 AND (sign_count < $2 OR (sign_count = 0 AND $2 = 0))`
+
+	tag, err := s.dbpool.Exec(ctx, query, credentialID, int64(signCount), backupState)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *Storage) SaveOrganization(ctx context.Context, org models.Organization) error {
+	const op = "storage.postgres.SaveOrganization"
+
+	if _, err := s.dbpool.Exec(ctx, "INSERT INTO organizations(id, name) VALUES($1, $2)", org.ID, org.Name); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrganizationExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Organization(ctx context.Context, orgID uuid.UUID) (models.Organization, error) {
+	const op = "storage.postgres.Organization"
+
+	rows, err := s.dbpool.Query(ctx, "SELECT id, name, created_at FROM organizations WHERE id=$1", orgID)
+	if err != nil {
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	org, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.Organization])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Organization{}, fmt.Errorf("%s: %w", op, storage.ErrOrganizationNotFound)
+		}
+
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToOrganizationFromStorage(org), nil
+}
+
+func (s *Storage) OrganizationMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (models.OrganizationMember, error) {
+	const op = "storage.postgres.OrganizationMember"
+
+	query := `SELECT m.org_id, m.user_id, u.email, m.role, m.created_at
+		FROM organization_members m JOIN users u ON u.id=m.user_id
+		WHERE m.org_id=$1 AND m.user_id=$2`
+
+	rows, err := s.dbpool.Query(ctx, query, orgID, userID)
+	if err != nil {
+		return models.OrganizationMember{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	member, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.OrganizationMember])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OrganizationMember{}, fmt.Errorf("%s: %w", op, storage.ErrMemberNotFound)
+		}
+
+		return models.OrganizationMember{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToOrganizationMemberFromStorage(member), nil
+}
+
+// OrganizationMembers returns the members of the organization in the order they joined
+func (s *Storage) OrganizationMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	const op = "storage.postgres.OrganizationMembers"
+
+	query := `SELECT m.org_id, m.user_id, u.email, m.role, m.created_at
+		FROM organization_members m JOIN users u ON u.id=m.user_id
+		WHERE m.org_id=$1
+		ORDER BY m.created_at, u.email`
+
+	rows, err := s.dbpool.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	members, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.OrganizationMember])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToOrganizationMembersFromStorage(members), nil
+}
+
+func (s *Storage) SetOrganizationMemberRole(ctx context.Context, orgID uuid.UUID, userID uuid.UUID, role string) error {
+	const op = "storage.postgres.SetOrganizationMemberRole"
+
+	tag, err := s.dbpool.Exec(ctx, "UPDATE organization_members SET role=$3 WHERE org_id=$1 AND user_id=$2", orgID, userID, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMemberNotFound)
+	}
+
+	return nil
+}
+
+// roleColumns are selected into storageModel.Role
+const roleColumns = "id, name, app_id, permissions, built_in, created_at"
+
+func (s *Storage) SaveRole(ctx context.Context, role models.Role) error {
+	const op = "storage.postgres.SaveRole"
+
+	query := `INSERT INTO roles(id, name, app_id, permissions)
+		VALUES(@id, @name, @appId, @permissions)`
+	args := pgx.NamedArgs{
+		"id":          role.ID,
+		"name":        role.Name,
+		"appId":       converter.ToStorageRoleAppID(role.AppID),
+		"permissions": nonNilStrings(role.Permissions),
+	}
+
+	if _, err := s.dbpool.Exec(ctx, query, args); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Role(ctx context.Context, roleID uuid.UUID) (models.Role, error) {
+	const op = "storage.postgres.Role"
+
+	query := "SELECT " + roleColumns + " FROM roles WHERE id=$1"
+
+	rows, err := s.dbpool.Query(ctx, query, roleID)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	role, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.Role])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRoleFromStorage(role), nil
+}
+
+// Roles returns the global roles and the roles scoped to the app, only the global ones for uuid.Nil
+func (s *Storage) Roles(ctx context.Context, appID uuid.UUID) ([]models.Role, error) {
+	const op = "storage.postgres.Roles"
+
+	query := "SELECT " + roleColumns + " FROM roles WHERE app_id IS NULL OR app_id=$1 ORDER BY created_at, name"
+
+	rows, err := s.dbpool.Query(ctx, query, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Role])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRolesFromStorage(roles), nil
+}
+
+// DeleteRole deletes the role together with its assignments
+func (s *Storage) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	const op = "storage.postgres.DeleteRole"
+
+	tag, err := s.dbpool.Exec(ctx, "DELETE FROM roles WHERE id=$1", roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+
+	return nil
+}
+
+// AssignRole assigns the role to the user, assigning it again is a no-op
+func (s *Storage) AssignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	const op = "storage.postgres.AssignRole"
+
+	query := "INSERT INTO user_roles(user_id, role_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+
+	if _, err := s.dbpool.Exec(ctx, query, userID, roleID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "user_roles_user_id_fkey" {
+				return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UnassignRole takes the role away from the user, unassigning a role the user doesn't have is a no-op
+func (s *Storage) UnassignRole(ctx context.Context, userID uuid.UUID, roleID uuid.UUID) error {
+	const op = "storage.postgres.UnassignRole"
+
+	if _, err := s.dbpool.Exec(ctx, "DELETE FROM user_roles WHERE user_id=$1 AND role_id=$2", userID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UserRoles returns every role assigned to the user directly or granted to the groups of the user, both global and scoped to apps
+func (s *Storage) UserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	const op = "storage.postgres.UserRoles"
+
+	query := userGroupsQuery + `
+		SELECT ` + roleColumns + ` FROM roles
+		WHERE id IN (
+			SELECT role_id FROM user_roles WHERE user_id=$1
+			UNION
+			SELECT gr.role_id FROM group_roles gr JOIN user_groups ug ON ug.id=gr.group_id
+		)
+		ORDER BY created_at, name`
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Role])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRolesFromStorage(roles), nil
+}
+
+// userGroupsQuery resolves the groups user $1 belongs to directly or through subgroups into user_groups,
+// UNION drops the groups already found, so the recursion ends
+const userGroupsQuery = `WITH RECURSIVE user_groups(id) AS (
+		SELECT group_id FROM group_users WHERE user_id=$1
+		UNION
+		SELECT gs.group_id FROM group_subgroups gs JOIN user_groups ug ON gs.subgroup_id=ug.id
+	)`
+
+// groupDescendantsQuery resolves group $1 and the groups nested in it at any depth into descendants
+const groupDescendantsQuery = `WITH RECURSIVE descendants(id) AS (
+		SELECT $1::uuid
+		UNION
+		SELECT gs.subgroup_id FROM group_subgroups gs JOIN descendants d ON gs.group_id=d.id
+	)`
+
+// groupColumns are selected into storageModel.Group
+const groupColumns = "id, org_id, name, created_at"
+
+func (s *Storage) SaveGroup(ctx context.Context, group models.Group) error {
+	const op = "storage.postgres.SaveGroup"
+
+	query := "INSERT INTO groups(id, org_id, name) VALUES($1, $2, $3)"
+
+	if _, err := s.dbpool.Exec(ctx, query, group.ID, group.OrgID, group.Name); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupExists)
+		}
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrganizationNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Group(ctx context.Context, groupID uuid.UUID) (models.Group, error) {
+	const op = "storage.postgres.Group"
+
+	rows, err := s.dbpool.Query(ctx, "SELECT "+groupColumns+" FROM groups WHERE id=$1", groupID)
+	if err != nil {
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	group, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.Group])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Group{}, fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return models.Group{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToGroupFromStorage(group), nil
+}
+
+// Groups returns the groups of the organization ordered by name
+func (s *Storage) Groups(ctx context.Context, orgID uuid.UUID) ([]models.Group, error) {
+	const op = "storage.postgres.Groups"
+
+	rows, err := s.dbpool.Query(ctx, "SELECT "+groupColumns+" FROM groups WHERE org_id=$1 ORDER BY name", orgID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Group])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToGroupsFromStorage(groups), nil
+}
+
+// DeleteGroup deletes the group together with its memberships and granted roles
+func (s *Storage) DeleteGroup(ctx context.Context, groupID uuid.UUID) error {
+	const op = "storage.postgres.DeleteGroup"
+
+	tag, err := s.dbpool.Exec(ctx, "DELETE FROM groups WHERE id=$1", groupID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+	}
+
+	return nil
+}
+
+// AddGroupUser adds the user to the group, adding the user again is a no-op
+func (s *Storage) AddGroupUser(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error {
+	const op = "storage.postgres.AddGroupUser"
+
+	query := "INSERT INTO group_users(group_id, user_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+
+	if _, err := s.dbpool.Exec(ctx, query, groupID, userID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "group_users_user_id_fkey" {
+				return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveGroupUser removes the user from the group, removing a user who isn't a member is a no-op
+func (s *Storage) RemoveGroupUser(ctx context.Context, groupID uuid.UUID, userID uuid.UUID) error {
+	const op = "storage.postgres.RemoveGroupUser"
+
+	if _, err := s.dbpool.Exec(ctx, "DELETE FROM group_users WHERE group_id=$1 AND user_id=$2", groupID, userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddSubgroup makes the subgroup a member of the group, failing with storage.ErrGroupCycle
+// when the group is the subgroup itself or is already nested in it
+func (s *Storage) AddSubgroup(ctx context.Context, groupID uuid.UUID, subgroupID uuid.UUID) (err error) {
+	const op = "storage.postgres.AddSubgroup"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	// two concurrent additions could each pass the check and close a cycle together, so they are serialized
+	if _, err = tx.Exec(ctx, "LOCK TABLE group_subgroups IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := groupDescendantsQuery + " SELECT EXISTS (SELECT 1 FROM descendants WHERE id=$2)"
+
+	var cycle bool
+	if err = tx.QueryRow(ctx, query, subgroupID, groupID).Scan(&cycle); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if cycle {
+		return fmt.Errorf("%s: %w", op, storage.ErrGroupCycle)
+	}
+
+	query = "INSERT INTO group_subgroups(group_id, subgroup_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+	if _, err = tx.Exec(ctx, query, groupID, subgroupID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveSubgroup removes the subgroup from the group, removing a group which isn't a member is a no-op
+func (s *Storage) RemoveSubgroup(ctx context.Context, groupID uuid.UUID, subgroupID uuid.UUID) error {
+	const op = "storage.postgres.RemoveSubgroup"
+
+	if _, err := s.dbpool.Exec(ctx, "DELETE FROM group_subgroups WHERE group_id=$1 AND subgroup_id=$2", groupID, subgroupID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GroupUsers returns the users of the group ordered by email, with the users of its subgroups at any depth when transitive is set
+func (s *Storage) GroupUsers(ctx context.Context, groupID uuid.UUID, transitive bool) ([]models.GroupUser, error) {
+	const op = "storage.postgres.GroupUsers"
+
+	query := `SELECT u.id AS user_id, u.email
+		FROM group_users gu JOIN users u ON u.id=gu.user_id
+		WHERE gu.group_id=$1
+		ORDER BY u.email`
+	if transitive {
+		query = groupDescendantsQuery + `
+			SELECT DISTINCT u.id AS user_id, u.email
+			FROM group_users gu JOIN descendants d ON d.id=gu.group_id JOIN users u ON u.id=gu.user_id
+			ORDER BY u.email`
+	}
+
+	rows, err := s.dbpool.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.GroupUser])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToGroupUsersFromStorage(users), nil
+}
+
+// Subgroups returns the groups which are direct members of the group ordered by name
+func (s *Storage) Subgroups(ctx context.Context, groupID uuid.UUID) ([]models.Group, error) {
+	const op = "storage.postgres.Subgroups"
+
+	query := `SELECT g.id, g.org_id, g.name, g.created_at
+		FROM groups g JOIN group_subgroups gs ON gs.subgroup_id=g.id
+		WHERE gs.group_id=$1
+		ORDER BY g.name`
+
+	rows, err := s.dbpool.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Group])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToGroupsFromStorage(groups), nil
+}
+
+// UserGroups returns the groups the user belongs to directly or through subgroups ordered by name
+func (s *Storage) UserGroups(ctx context.Context, userID uuid.UUID) ([]models.Group, error) {
+	const op = "storage.postgres.UserGroups"
+
+	query := userGroupsQuery + `
+		SELECT g.id, g.org_id, g.name, g.created_at
+		FROM groups g JOIN user_groups ug ON ug.id=g.id
+		ORDER BY g.name`
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	groups, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Group])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToGroupsFromStorage(groups), nil
+}
+
+// GrantGroupRole grants the role to the group, granting it again is a no-op
+func (s *Storage) GrantGroupRole(ctx context.Context, groupID uuid.UUID, roleID uuid.UUID) error {
+	const op = "storage.postgres.GrantGroupRole"
+
+	query := "INSERT INTO group_roles(group_id, role_id) VALUES($1, $2) ON CONFLICT DO NOTHING"
+
+	if _, err := s.dbpool.Exec(ctx, query, groupID, roleID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "group_roles_group_id_fkey" {
+				return fmt.Errorf("%s: %w", op, storage.ErrGroupNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RevokeGroupRole takes the role away from the group, revoking a role the group doesn't have is a no-op
+func (s *Storage) RevokeGroupRole(ctx context.Context, groupID uuid.UUID, roleID uuid.UUID) error {
+	const op = "storage.postgres.RevokeGroupRole"
+
+	if _, err := s.dbpool.Exec(ctx, "DELETE FROM group_roles WHERE group_id=$1 AND role_id=$2", groupID, roleID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// WriteRelationships writes and deletes the relationships of the app at once.
+// Writing an existing relationship or deleting a missing one is a no-op.
+func (s *Storage) WriteRelationships(ctx context.Context, appID uuid.UUID, writes []models.Relationship, deletes []models.Relationship) (err error) {
+	const op = "storage.postgres.WriteRelationships"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := `DELETE FROM relationships
+		WHERE app_id=$1 AND object_type=$2 AND object_id=$3 AND relation=$4
+		AND subject_type=$5 AND subject_id=$6 AND subject_relation=$7`
+	for _, r := range deletes {
+		if _, err = tx.Exec(ctx, query, appID, r.Object.Type, r.Object.ID, r.Relation, r.Subject.Object.Type, r.Subject.Object.ID, r.Subject.Relation); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	query = `INSERT INTO relationships(app_id, object_type, object_id, relation, subject_type, subject_id, subject_relation)
+		VALUES($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`
+	for _, r := range writes {
+		if _, err = tx.Exec(ctx, query, appID, r.Object.Type, r.Object.ID, r.Relation, r.Subject.Object.Type, r.Subject.Object.ID, r.Subject.Relation); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// Relationships returns the relationships of the app to the object with any of the relations
+func (s *Storage) Relationships(ctx context.Context, appID uuid.UUID, object models.ObjectRef, relations []string) ([]models.Relationship, error) {
+	const op = "storage.postgres.Relationships"
+
+	query := `SELECT object_type, object_id, relation, subject_type, subject_id, subject_relation
+		FROM relationships
+		WHERE app_id=$1 AND object_type=$2 AND object_id=$3 AND relation=ANY($4)`
+
+	rows, err := s.dbpool.Query(ctx, query, appID, object.Type, object.ID, relations)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	relationships, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Relationship])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRelationshipsFromStorage(relationships), nil
+}
+
+// SubjectRelationships returns the relationships of the app the subject is in
+func (s *Storage) SubjectRelationships(ctx context.Context, appID uuid.UUID, subject models.SubjectRef) ([]models.Relationship, error) {
+	const op = "storage.postgres.SubjectRelationships"
+
+	query := `SELECT object_type, object_id, relation, subject_type, subject_id, subject_relation
+		FROM relationships
+		WHERE app_id=$1 AND subject_type=$2 AND subject_id=$3 AND subject_relation=$4`
+
+	rows, err := s.dbpool.Query(ctx, query, appID, subject.Object.Type, subject.Object.ID, subject.Relation)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	relationships, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.Relationship])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToRelationshipsFromStorage(relationships), nil
+}
+
+// SaveRelationRewrite replaces the relations implying the relation of the objects of the type, no relations remove the rewrite
+func (s *Storage) SaveRelationRewrite(ctx context.Context, appID uuid.UUID, rewrite models.RelationRewrite) (err error) {
+	const op = "storage.postgres.SaveRelationRewrite"
+	log := s.log.With(slog.String("op", op))
+
+	tx, err := s.dbpool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rErr := tx.Rollback(ctx)
+			if rErr != nil {
+				log.Error("rollback failed", sl.Err(rErr))
+			}
+			return
+		}
+
+		if commitErr := tx.Commit(ctx); commitErr != nil {
+			log.Error("commit failed", sl.Err(commitErr))
+			err = fmt.Errorf("%s: %w", op, commitErr)
+		}
+	}()
+
+	query := "DELETE FROM relation_rewrites WHERE app_id=$1 AND object_type=$2 AND relation=$3"
+	if _, err = tx.Exec(ctx, query, appID, rewrite.ObjectType, rewrite.Relation); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	query = `INSERT INTO relation_rewrites(app_id, object_type, relation, implied_by)
+		VALUES($1, $2, $3, $4) ON CONFLICT DO NOTHING`
+	for _, impliedBy := range rewrite.ImpliedBy {
+		if _, err = tx.Exec(ctx, query, appID, rewrite.ObjectType, rewrite.Relation, impliedBy); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// RelationRewrites returns the rewrites of the relations of the objects of the type ordered by relation
+func (s *Storage) RelationRewrites(ctx context.Context, appID uuid.UUID, objectType string) ([]models.RelationRewrite, error) {
+	const op = "storage.postgres.RelationRewrites"
+
+	query := `SELECT relation, array_agg(implied_by ORDER BY implied_by)
+		FROM relation_rewrites
+		WHERE app_id=$1 AND object_type=$2
+		GROUP BY relation
+		ORDER BY relation`
+
+	rows, err := s.dbpool.Query(ctx, query, appID, objectType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rewrites, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.RelationRewrite, error) {
+		rewrite := models.RelationRewrite{ObjectType: objectType}
+		err := row.Scan(&rewrite.Relation, &rewrite.ImpliedBy)
+		return rewrite, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rewrites, nil
+}
+
+// apiKeyColumns are selected into storageModel.APIKey
+const apiKeyColumns = "id, user_id, app_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at"
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey) error {
+	const op = "storage.postgres.SaveAPIKey"
+
+	query := `INSERT INTO api_keys(id, user_id, app_id, name, prefix, key_hash, scopes, created_at, expires_at)
+		VALUES(@id, @userId, @appId, @name, @prefix, @keyHash, @scopes, @createdAt, @expiresAt)`
+	args := pgx.NamedArgs{
+		"id":        key.ID,
+		"userId":    key.UserID,
+		"appId":     converter.ToStorageAPIKeyAppID(key.AppID),
+		"name":      key.Name,
+		"prefix":    key.Prefix,
+		"keyHash":   key.KeyHash,
+		"scopes":    nonNilStrings(key.Scopes),
+		"createdAt": key.CreatedAt,
+		"expiresAt": converter.ToStorageAPIKeyExpiresAt(key.ExpiresAt),
+	}
+
+	if _, err := s.dbpool.Exec(ctx, query, args); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			if pgErr.ConstraintName == "api_keys_user_id_fkey" {
+				return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			}
+
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// APIKey returns the key unless it was revoked
+func (s *Storage) APIKey(ctx context.Context, keyID uuid.UUID) (models.APIKey, error) {
+	const op = "storage.postgres.APIKey"
+
+	return s.apiKey(ctx, op, "id=$1", keyID)
+}
+
+// APIKeyByHash returns the key with the hash unless it was revoked
+func (s *Storage) APIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	const op = "storage.postgres.APIKeyByHash"
+
+	return s.apiKey(ctx, op, "key_hash=$1", keyHash)
+}
+
+func (s *Storage) apiKey(ctx context.Context, op string, condition string, arg any) (models.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE " + condition + " AND revoked_at IS NULL"
+
+	rows, err := s.dbpool.Query(ctx, query, arg)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[storageModel.APIKey])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToAPIKeyFromStorage(key), nil
+}
+
+// APIKeys returns the keys of the user which were not revoked, expired ones included, newest first
+func (s *Storage) APIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	const op = "storage.postgres.APIKeys"
+
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id=$1 AND revoked_at IS NULL ORDER BY created_at DESC"
+
+	rows, err := s.dbpool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	keys, err := pgx.CollectRows(rows, pgx.RowToStructByName[storageModel.APIKey])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return converter.ToAPIKeysFromStorage(keys), nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, keyID uuid.UUID) error {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	tag, err := s.dbpool.Exec(ctx, "UPDATE api_keys SET revoked_at=NOW() WHERE id=$1 AND revoked_at IS NULL", keyID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// RevokeUserAPIKeys revokes all API keys of the user which are not revoked yet
+func (s *Storage) RevokeUserAPIKeys(ctx context.Context, userID uuid.UUID) error {
+	const op = "storage.postgres.RevokeUserAPIKeys"
+
+	if _, err := s.dbpool.Exec(ctx, "UPDATE api_keys SET revoked_at=NOW() WHERE user_id=$1 AND revoked_at IS NULL", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TouchAPIKey records the time the key was used at
+func (s *Storage) TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time) error {
+	const op = "storage.postgres.TouchAPIKey"
+
+	if _, err := s.dbpool.Exec(ctx, "UPDATE api_keys SET last_used_at=$2 WHERE id=$1", keyID, usedAt); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
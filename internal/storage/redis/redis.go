@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -69,6 +70,522 @@ func (s *Storage) SaveFailedLoginAttempts(ctx context.Context, userId string, fa
 	return nil
 }
 
+// RevokeToken adds the token to the denylist until it expires by itself
+func (s *Storage) RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	const op = "storage.redis.RevokeToken"
+
+	if err := s.client.Set(ctx, fmt.Sprintf("revokedToken:%s", tokenID), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	const op = "storage.redis.IsTokenRevoked"
+
+	count, err := s.client.Exists(ctx, fmt.Sprintf("revokedToken:%s", tokenID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count > 0, nil
+}
+
+// RevokeSession marks the access tokens of the session revoked, ttl must cover the lifetime of the last issued one
+func (s *Storage) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	const op = "storage.redis.RevokeSession"
+
+	if err := s.client.Set(ctx, fmt.Sprintf("revokedSession:%s", sessionID), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	const op = "storage.redis.IsSessionRevoked"
+
+	count, err := s.client.Exists(ctx, fmt.Sprintf("revokedSession:%s", sessionID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count > 0, nil
+}
+
+// TokenVersion returns the current token version of the user, 0 if it was never bumped
+func (s *Storage) TokenVersion(ctx context.Context, userId string) (int64, error) {
+	const op = "storage.redis.TokenVersion"
+
+	version, err := s.client.Get(ctx, fmt.Sprintf("tokenVersion:%s", userId)).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// IncrementTokenVersion bumps the token version of the user, invalidating all tokens issued before
+func (s *Storage) IncrementTokenVersion(ctx context.Context, userId string) (int64, error) {
+	const op = "storage.redis.IncrementTokenVersion"
+
+	version, err := s.client.Incr(ctx, fmt.Sprintf("tokenVersion:%s", userId)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return version, nil
+}
+
+func (s *Storage) SaveAuthorizationCode(ctx context.Context, codeHash string, code models.AuthorizationCode, ttl time.Duration) error {
+	const op = "storage.redis.SaveAuthorizationCode"
+
+	data, err := json.Marshal(code)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, fmt.Sprintf("authCode:%s", codeHash), string(data), ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuthorizationCode returns the code without consuming it
+func (s *Storage) AuthorizationCode(ctx context.Context, codeHash string) (models.AuthorizationCode, error) {
+	const op = "storage.redis.AuthorizationCode"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("authCode:%s", codeHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, storage.ErrAuthCodeNotFound)
+		}
+
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var code models.AuthorizationCode
+	if err := json.Unmarshal([]byte(data), &code); err != nil {
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return code, nil
+}
+
+// consumeAuthCodeScript deletes the code and keeps the redemption in its place in one step,
+// so a concurrent exchange either gets the code or finds it redeemed
+var consumeAuthCodeScript = redis.NewScript(`
+if redis.call("DEL", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("SET", KEYS[2], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// ConsumeAuthorizationCode deletes the code, so each code can be exchanged only once, and keeps the redemption for ttl.
+// It fails with storage.ErrAuthCodeNotFound when the code is already consumed or expired.
+func (s *Storage) ConsumeAuthorizationCode(ctx context.Context, codeHash string, redeemed models.RedeemedAuthorizationCode, ttl time.Duration) error {
+	const op = "storage.redis.ConsumeAuthorizationCode"
+
+	data, err := json.Marshal(redeemed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	consumed, err := consumeAuthCodeScript.Run(
+		ctx,
+		s.client,
+		[]string{fmt.Sprintf("authCode:%s", codeHash), fmt.Sprintf("authCodeRedeemed:%s", codeHash)},
+		string(data),
+		ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if consumed == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAuthCodeNotFound)
+	}
+
+	return nil
+}
+
+// RedeemedAuthorizationCode returns the redemption of the consumed code
+func (s *Storage) RedeemedAuthorizationCode(ctx context.Context, codeHash string) (models.RedeemedAuthorizationCode, error) {
+	const op = "storage.redis.RedeemedAuthorizationCode"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("authCodeRedeemed:%s", codeHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.RedeemedAuthorizationCode{}, fmt.Errorf("%s: %w", op, storage.ErrAuthCodeNotFound)
+		}
+
+		return models.RedeemedAuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var redeemed models.RedeemedAuthorizationCode
+	if err := json.Unmarshal([]byte(data), &redeemed); err != nil {
+		return models.RedeemedAuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return redeemed, nil
+}
+
+func (s *Storage) SavePasswordReset(ctx context.Context, tokenHash string, reset models.PasswordReset, ttl time.Duration) error {
+	const op = "storage.redis.SavePasswordReset"
+
+	data, err := json.Marshal(reset)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, fmt.Sprintf("passwordReset:%s", tokenHash), string(data), ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SaveSSOSession(ctx context.Context, tokenHash string, session models.SSOSession, ttl time.Duration) error {
+	const op = "storage.redis.SaveSSOSession"
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, fmt.Sprintf("ssoSession:%s", tokenHash), string(data), ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) SSOSession(ctx context.Context, tokenHash string) (models.SSOSession, error) {
+	const op = "storage.redis.SSOSession"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("ssoSession:%s", tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.SSOSession{}, fmt.Errorf("%s: %w", op, storage.ErrSSOSessionNotFound)
+		}
+
+		return models.SSOSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session models.SSOSession
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return models.SSOSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// ExtendSSOSession sets the new TTL of the session, it's how the idle timeout slides with each use
+func (s *Storage) ExtendSSOSession(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	const op = "storage.redis.ExtendSSOSession"
+
+	extended, err := s.client.Expire(ctx, fmt.Sprintf("ssoSession:%s", tokenHash), ttl).Result()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !extended {
+		return fmt.Errorf("%s: %w", op, storage.ErrSSOSessionNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteSSOSession(ctx context.Context, tokenHash string) error {
+	const op = "storage.redis.DeleteSSOSession"
+
+	if err := s.client.Del(ctx, fmt.Sprintf("ssoSession:%s", tokenHash)).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveMagicLink marks the magic link token as not used yet
+func (s *Storage) SaveMagicLink(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	const op = "storage.redis.SaveMagicLink"
+
+	if err := s.client.Set(ctx, fmt.Sprintf("magicLink:%s", tokenHash), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeMagicLink deletes the mark of the magic link token, so each link can be used only once
+func (s *Storage) ConsumeMagicLink(ctx context.Context, tokenHash string) error {
+	const op = "storage.redis.ConsumeMagicLink"
+
+	deleted, err := s.client.Del(ctx, fmt.Sprintf("magicLink:%s", tokenHash)).Result()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMagicLinkNotFound)
+	}
+
+	return nil
+}
+
+// PasswordReset returns the reset without consuming it, so the token can be used again after a rejected password
+func (s *Storage) PasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error) {
+	const op = "storage.redis.PasswordReset"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("passwordReset:%s", tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.PasswordReset{}, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetNotFound)
+		}
+
+		return models.PasswordReset{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var reset models.PasswordReset
+	if err := json.Unmarshal([]byte(data), &reset); err != nil {
+		return models.PasswordReset{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reset, nil
+}
+
+// ConsumePasswordReset returns the reset and deletes it, so each reset token can be used only once
+func (s *Storage) ConsumePasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error) {
+	const op = "storage.redis.ConsumePasswordReset"
+
+	data, err := s.client.GetDel(ctx, fmt.Sprintf("passwordReset:%s", tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.PasswordReset{}, fmt.Errorf("%s: %w", op, storage.ErrPasswordResetNotFound)
+		}
+
+		return models.PasswordReset{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var reset models.PasswordReset
+	if err := json.Unmarshal([]byte(data), &reset); err != nil {
+		return models.PasswordReset{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reset, nil
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, sessionIDHash string, session models.WebAuthnSession, ttl time.Duration) error {
+	const op = "storage.redis.SaveWebAuthnSession"
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, fmt.Sprintf("webauthnSession:%s", sessionIDHash), string(data), ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ConsumeWebAuthnSession returns the ceremony and deletes it, so each challenge can be answered only once
+func (s *Storage) ConsumeWebAuthnSession(ctx context.Context, sessionIDHash string) (models.WebAuthnSession, error) {
+	const op = "storage.redis.ConsumeWebAuthnSession"
+
+	data, err := s.client.GetDel(ctx, fmt.Sprintf("webauthnSession:%s", sessionIDHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrWebAuthnSessionNotFound)
+		}
+
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var session models.WebAuthnSession
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return session, nil
+}
+
+// SaveDeviceAuthorization saves the pending request by the device code hash and indexes it by the user code
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, deviceCodeHash string, authorization models.DeviceAuthorization, ttl time.Duration) error {
+	const op = "storage.redis.SaveDeviceAuthorization"
+
+	data, err := json.Marshal(authorization)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("deviceCode:%s", deviceCodeHash), string(data), ttl)
+	pipe.Set(ctx, fmt.Sprintf("userCode:%s", authorization.UserCode), deviceCodeHash, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error) {
+	const op = "storage.redis.DeviceAuthorization"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("deviceCode:%s", deviceCodeHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var authorization models.DeviceAuthorization
+	if err := json.Unmarshal([]byte(data), &authorization); err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return authorization, nil
+}
+
+// DeviceCodeHash returns the device code hash of the pending request with the user code
+func (s *Storage) DeviceCodeHash(ctx context.Context, userCode string) (string, error) {
+	const op = "storage.redis.DeviceCodeHash"
+
+	deviceCodeHash, err := s.client.Get(ctx, fmt.Sprintf("userCode:%s", userCode)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deviceCodeHash, nil
+}
+
+// UpdateDeviceAuthorization saves the user's decision keeping the expiration of the request
+func (s *Storage) UpdateDeviceAuthorization(ctx context.Context, deviceCodeHash string, authorization models.DeviceAuthorization) error {
+	const op = "storage.redis.UpdateDeviceAuthorization"
+
+	data, err := json.Marshal(authorization)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// XX doesn't bring back the request expired in the meantime
+	err = s.client.SetArgs(ctx, fmt.Sprintf("deviceCode:%s", deviceCodeHash), string(data), redis.SetArgs{KeepTTL: true, Mode: "XX"}).Err()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DeleteDeviceAuthorization deletes the request, reporting false if it was already deleted,
+// so tokens are issued only to the first poll after the approval
+func (s *Storage) DeleteDeviceAuthorization(ctx context.Context, deviceCodeHash string, userCode string) (bool, error) {
+	const op = "storage.redis.DeleteDeviceAuthorization"
+
+	pipe := s.client.TxPipeline()
+	deleted := pipe.Del(ctx, fmt.Sprintf("deviceCode:%s", deviceCodeHash))
+	pipe.Del(ctx, fmt.Sprintf("userCode:%s", userCode))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted.Val() > 0, nil
+}
+
+// MarkDevicePoll reports false if the device already polled within the interval
+func (s *Storage) MarkDevicePoll(ctx context.Context, deviceCodeHash string, interval time.Duration) (bool, error) {
+	const op = "storage.redis.MarkDevicePoll"
+
+	ok, err := s.client.SetNX(ctx, fmt.Sprintf("devicePoll:%s", deviceCodeHash), 1, interval).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ok, nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, tokenHash string, challenge models.MFAChallenge, ttl time.Duration) error {
+	const op = "storage.redis.SaveMFAChallenge"
+
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.client.Set(ctx, fmt.Sprintf("mfaChallenge:%s", tokenHash), string(data), ttl).Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error) {
+	const op = "storage.redis.MFAChallenge"
+
+	data, err := s.client.Get(ctx, fmt.Sprintf("mfaChallenge:%s", tokenHash)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrMFAChallengeNotFound)
+		}
+
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var challenge models.MFAChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return challenge, nil
+}
+
+// DeleteMFAChallenge deletes the passed challenge, reporting false if it was already deleted
+func (s *Storage) DeleteMFAChallenge(ctx context.Context, tokenHash string) (bool, error) {
+	const op = "storage.redis.DeleteMFAChallenge"
+
+	deleted, err := s.client.Del(ctx, fmt.Sprintf("mfaChallenge:%s", tokenHash)).Result()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted > 0, nil
+}
+
+// Allow counts the attempt in the fixed window of the key and reports whether the limit is not exceeded yet
+func (s *Storage) Allow(ctx context.Context, key string, limit models.RateLimit) (bool, error) {
+	const op = "storage.redis.Allow"
+
+	rateKey := fmt.Sprintf("rateLimit:%s", key)
+
+	pipe := s.client.TxPipeline()
+	count := pipe.Incr(ctx, rateKey)
+	// NX keeps the expiration set by the first attempt of the window
+	pipe.ExpireNX(ctx, rateKey, limit.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count.Val() <= int64(limit.Limit), nil
+}
+
 func (s *Storage) Stop() error {
 	const op = "storage.redis.Stop"
 
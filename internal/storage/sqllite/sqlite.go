@@ -1,3 +1,5 @@
+// Package sqllite is the legacy storage of users and apps. The service runs on postgres only,
+// the package is kept in step with the postgres schema for users, organizations and admins.
 package sqllite
 
 import (
@@ -27,18 +29,26 @@ func New(storagePath string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash []byte) (models.User, error) {
+// SaveUser saves the user as a member of the organization
+func (s *Storage) SaveUser(ctx context.Context, orgID uuid.UUID, userID, email string, passHash []byte) (user models.User, err error) {
 	const op = "storage.sqlite.SaveUser"
 
-	stmt, err := s.db.Prepare("INSERT INTO users(id,email,pass_hash) VALUES(?,?,?) RETURNING id,email,pass_hash")
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	user := models.User{}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+
+		err = tx.Commit()
+	}()
 
-	row := stmt.QueryRowContext(ctx, userID, email, passHash)
-	if err := row.Scan(&user.ID, &user.Email, &user.PassHash); err != nil {
+	row := tx.QueryRowContext(ctx, "INSERT INTO users(id,org_id,email,pass_hash) VALUES(?,?,?,?) RETURNING id,org_id,email,pass_hash", userID, orgID, email, passHash)
+	if err := row.Scan(&user.ID, &user.OrgID, &user.Email, &user.PassHash); err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
@@ -47,21 +57,46 @@ func (s *Storage) SaveUser(ctx context.Context, userID, email string, passHash [
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if _, err := tx.ExecContext(ctx, "INSERT INTO organization_members(org_id,user_id,role) VALUES(?,?,?)", orgID, userID, models.OrganizationRoleMember); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return user, nil
 }
 
-func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+func (s *Storage) User(ctx context.Context, orgID uuid.UUID, email string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	stmt, err := s.db.Prepare("SELECT id,email,pass_hash FROM users WHERE email=?")
+	stmt, err := s.db.Prepare("SELECT id,org_id,email,pass_hash FROM users WHERE org_id=? AND email=?")
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	row := stmt.QueryRowContext(ctx, orgID, email)
+
+	var user models.User
+	if err := row.Scan(&user.ID, &user.OrgID, &user.Email, &user.PassHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, userID uuid.UUID) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	stmt, err := s.db.Prepare("SELECT id,org_id,email,pass_hash FROM users WHERE id=?")
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := stmt.QueryRowContext(ctx, email)
+	row := stmt.QueryRowContext(ctx, userID)
 
 	var user models.User
-	if err := row.Scan(&user.ID, &user.Email, &user.PassHash); err != nil {
+	if err := row.Scan(&user.ID, &user.OrgID, &user.Email, &user.PassHash); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
 		}
@@ -71,14 +106,15 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
+// IsAdmin reports whether the user has the built-in admin role
 func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
-	stmt, err := s.db.Prepare("SELECT is_admin FROM users WHERE id=?")
+	stmt, err := s.db.Prepare("SELECT EXISTS (SELECT 1 FROM user_roles WHERE user_id=users.id AND role_id=?) FROM users WHERE id=?")
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res := stmt.QueryRowContext(ctx, userID)
+	res := stmt.QueryRowContext(ctx, models.AdminRoleID, userID)
 	var isAdmin bool
 	if err := res.Scan(&isAdmin); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -94,7 +130,7 @@ func (s *Storage) IsAdmin(ctx context.Context, userID uuid.UUID) (bool, error) {
 func (s *Storage) App(ctx context.Context, appID uuid.UUID) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	stmt, err := s.db.Prepare("SELECT id,name,secret FROM apps WHERE id=?")
+	stmt, err := s.db.Prepare("SELECT id,org_id,name,secret FROM apps WHERE id=?")
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -102,7 +138,7 @@ func (s *Storage) App(ctx context.Context, appID uuid.UUID) (models.App, error)
 	res := stmt.QueryRowContext(ctx, appID)
 
 	var app models.App
-	if err := res.Scan(&app.ID, &app.Name, &app.Secret); err != nil {
+	if err := res.Scan(&app.ID, &app.OrgID, &app.Name, &app.Secret); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
 		}
@@ -113,18 +149,18 @@ func (s *Storage) App(ctx context.Context, appID uuid.UUID) (models.App, error)
 	return app, nil
 }
 
-func (s *Storage) FindApp(ctx context.Context, name string) (models.App, error) {
+func (s *Storage) FindApp(ctx context.Context, orgID uuid.UUID, name string) (models.App, error) {
 	const op = "storage.sqlite.FindApp"
 
-	stmt, err := s.db.Prepare("SELECT id,name,secret FROM apps WHERE name=?")
+	stmt, err := s.db.Prepare("SELECT id,org_id,name,secret FROM apps WHERE org_id=? AND name=?")
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res := stmt.QueryRowContext(ctx, name)
+	res := stmt.QueryRowContext(ctx, orgID, name)
 
 	var app models.App
-	if err := res.Scan(&app.ID, &app.Name, &app.Secret); err != nil {
+	if err := res.Scan(&app.ID, &app.OrgID, &app.Name, &app.Secret); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
 		}
@@ -135,18 +171,18 @@ func (s *Storage) FindApp(ctx context.Context, name string) (models.App, error)
 	return app, nil
 }
 
-func (s *Storage) CreateApp(ctx context.Context, appID, name string, secret string) (models.App, error) {
+func (s *Storage) CreateApp(ctx context.Context, orgID uuid.UUID, appID, name string, secret string) (models.App, error) {
 	const op = "storage.sqlite.CreateApp"
 
-	stmt, err := s.db.Prepare("INSERT INTO apps(id,name,secret) VALUES(?,?,?) RETURNING id,name,secret")
+	stmt, err := s.db.Prepare("INSERT INTO apps(id,org_id,name,secret) VALUES(?,?,?,?) RETURNING id,org_id,name,secret")
 	if err != nil {
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	res := stmt.QueryRowContext(ctx, appID, name, secret)
+	res := stmt.QueryRowContext(ctx, appID, orgID, name, secret)
 
 	var app models.App
-	if err := res.Scan(&app.ID, &app.Name, &app.Secret); err != nil {
+	if err := res.Scan(&app.ID, &app.OrgID, &app.Name, &app.Secret); err != nil {
 		fmt.Println(err)
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
@@ -3,14 +3,45 @@ package storage
 import "errors"
 
 var (
-	ErrUserExists          = errors.New("user already exists")
-	ErrAppExists           = errors.New("app already exists")
-	ErrUserNotFound        = errors.New("user not found")
-	ErrAppNotFound         = errors.New("app not found")
-	ErrFailedLoginNotFound = errors.New("failed login not found")
-	ErrEventsNotFound      = errors.New("events not found")
+	ErrUserExists               = errors.New("user already exists")
+	ErrAppExists                = errors.New("app already exists")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrAppNotFound              = errors.New("app not found")
+	ErrFailedLoginNotFound      = errors.New("failed login not found")
+	ErrEventsNotFound           = errors.New("events not found")
+	ErrRefreshTokenNotFound     = errors.New("refresh token not found")
+	ErrRefreshTokenRotated      = errors.New("refresh token already rotated")
+	ErrAuthCodeNotFound         = errors.New("authorization code not found")
+	ErrAuthCodeRedeemed         = errors.New("authorization code already redeemed")
+	ErrDeviceCodeNotFound       = errors.New("device code not found")
+	ErrTOTPSecretNotFound       = errors.New("totp secret not found")
+	ErrMFAEnabled               = errors.New("mfa already enabled")
+	ErrMFAChallengeNotFound     = errors.New("mfa challenge not found")
+	ErrWebAuthnSessionNotFound  = errors.New("webauthn session not found")
+	ErrWebAuthnCredentialExists = errors.New("webauthn credential already exists")
+	ErrPasswordResetNotFound    = errors.New("password reset not found")
+	ErrMagicLinkNotFound        = errors.New("magic link not found")
+	ErrSessionNotFound          = errors.New("session not found")
+	ErrSSOSessionNotFound       = errors.New("sso session not found")
+	ErrRoleExists               = errors.New("role already exists")
+	ErrRoleNotFound             = errors.New("role not found")
+	ErrOrganizationExists       = errors.New("organization already exists")
+	ErrOrganizationNotFound     = errors.New("organization not found")
+	ErrMemberNotFound           = errors.New("organization member not found")
+	ErrGroupExists              = errors.New("group already exists")
+	ErrGroupNotFound            = errors.New("group not found")
+	ErrGroupCycle               = errors.New("group membership cycle")
+	ErrAPIKeyNotFound           = errors.New("api key not found")
 )
 
 const (
 	EventUserCreated = "user_created"
+	// EventRecoveryCodeUsed is saved when a recovery code is used as the second factor
+	EventRecoveryCodeUsed = "mfa_recovery_code_used"
+	// EventEmailVerified is saved when the user verifies the email
+	EventEmailVerified = "user_email_verified"
+	// EventPasswordReset is saved when the user sets a new password with the reset link
+	EventPasswordReset = "password_reset"
+	// EventPasswordChanged is saved when the user changes the password knowing the current one
+	EventPasswordChanged = "password_changed"
 )
@@ -0,0 +1,5 @@
+ALTER TABLE
+    apps DROP require_verified_email;
+
+ALTER TABLE
+    users DROP email_verified;
@@ -0,0 +1,9 @@
+ALTER TABLE
+    users
+ADD
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE
+    apps
+ADD
+    require_verified_email BOOLEAN NOT NULL DEFAULT FALSE;
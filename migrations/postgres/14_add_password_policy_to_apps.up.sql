@@ -0,0 +1,4 @@
+ALTER TABLE
+    apps
+ADD
+    password_policy JSONB;
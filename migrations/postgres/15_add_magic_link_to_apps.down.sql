@@ -0,0 +1,2 @@
+ALTER TABLE
+    apps DROP magic_link_enabled;
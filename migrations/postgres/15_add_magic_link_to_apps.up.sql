@@ -0,0 +1,4 @@
+ALTER TABLE
+    apps
+ADD
+    magic_link_enabled BOOLEAN NOT NULL DEFAULT FALSE;
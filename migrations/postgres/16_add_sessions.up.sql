@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id UUID NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+-- sessions of the token families issued before the sessions were tracked
+INSERT INTO sessions (id, user_id, app_id, created_at, last_seen_at, revoked_at)
+SELECT
+    family_id,
+    user_id,
+    app_id,
+    COALESCE(MIN(created_at), NOW()),
+    COALESCE(MAX(created_at), NOW()),
+    CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id, app_id
+ON CONFLICT (id) DO NOTHING;
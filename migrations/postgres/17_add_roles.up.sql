@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    app_id UUID REFERENCES apps (id) ON DELETE CASCADE,
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    built_in BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- role names are unique among global roles and among the roles of each app
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_global_name ON roles (name) WHERE app_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_app_name ON roles (app_id, name) WHERE app_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+-- the built-in admin role replaces the is_admin flag and grants every permission
+INSERT INTO roles (id, name, permissions, built_in)
+VALUES ('00000000-0000-0000-0000-000000000001', 'admin', '{*}', TRUE)
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+SELECT id, '00000000-0000-0000-0000-000000000001' FROM users WHERE is_admin
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS organization_members;
+
+ALTER TABLE apps DROP CONSTRAINT IF EXISTS apps_org_id_name_key;
+ALTER TABLE apps ADD CONSTRAINT apps_name_key UNIQUE (name);
+ALTER TABLE apps DROP COLUMN IF EXISTS org_id;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_org_id_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE users DROP COLUMN IF EXISTS org_id;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS relation_rewrites;
+DROP TABLE IF EXISTS relationships;
@@ -0,0 +1,24 @@
+-- object_type:object_id#relation@subject_type:subject_id[#subject_relation], the subject relation is empty for direct subjects
+CREATE TABLE IF NOT EXISTS relationships (
+    app_id UUID NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    object_type TEXT NOT NULL,
+    object_id TEXT NOT NULL,
+    relation TEXT NOT NULL,
+    subject_type TEXT NOT NULL,
+    subject_id TEXT NOT NULL,
+    subject_relation TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (app_id, object_type, object_id, relation, subject_type, subject_id, subject_relation)
+);
+
+CREATE INDEX IF NOT EXISTS idx_relationships_subject ON relationships (app_id, subject_type, subject_id, subject_relation);
+
+-- relation of the objects of the type is also granted by each of the implied_by relations on the same object
+CREATE TABLE IF NOT EXISTS relation_rewrites (
+    app_id UUID NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    object_type TEXT NOT NULL,
+    relation TEXT NOT NULL,
+    implied_by TEXT NOT NULL,
+    PRIMARY KEY (app_id, object_type, relation, implied_by),
+    CHECK (relation <> implied_by)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE
+    apps DROP token_ttl_seconds,
+    DROP claims;
@@ -0,0 +1,6 @@
+ALTER TABLE
+    apps
+ADD
+    token_ttl_seconds INTEGER DEFAULT NULL,
+ADD
+    claims TEXT [] NOT NULL DEFAULT '{}';
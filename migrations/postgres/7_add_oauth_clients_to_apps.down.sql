@@ -0,0 +1,3 @@
+ALTER TABLE
+    apps DROP redirect_uris,
+    DROP client_type;
@@ -0,0 +1,6 @@
+ALTER TABLE
+    apps
+ADD
+    redirect_uris TEXT [] NOT NULL DEFAULT '{}',
+ADD
+    client_type TEXT NOT NULL DEFAULT 'confidential';
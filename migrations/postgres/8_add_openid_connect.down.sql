@@ -0,0 +1,5 @@
+ALTER TABLE
+    refresh_tokens DROP scope;
+
+ALTER TABLE
+    apps DROP post_logout_redirect_uris;
@@ -0,0 +1,9 @@
+ALTER TABLE
+    apps
+ADD
+    post_logout_redirect_uris TEXT [] NOT NULL DEFAULT '{}';
+
+ALTER TABLE
+    refresh_tokens
+ADD
+    scope TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE
+    apps
+ADD
+    scopes TEXT [] NOT NULL DEFAULT '{}';
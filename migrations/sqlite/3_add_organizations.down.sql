@@ -0,0 +1,30 @@
+DROP TABLE IF EXISTS organization_members;
+
+CREATE TABLE users_old (
+    id UUID PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    pass_hash BLOB NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+INSERT INTO users_old (id, email, pass_hash, is_admin) SELECT id, email, pass_hash, is_admin FROM users;
+
+DROP TABLE users;
+
+ALTER TABLE users_old RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+CREATE TABLE apps_old (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    secret TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO apps_old (id, name, secret) SELECT id, name, secret FROM apps;
+
+DROP TABLE apps;
+
+ALTER TABLE apps_old RENAME TO apps;
+
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, name) VALUES ('00000000-0000-0000-0000-000000000001', 'default');
+
+-- SQLite can't change constraints in place, so users and apps are rebuilt with org-scoped uniqueness
+CREATE TABLE users_new (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations (id),
+    email TEXT NOT NULL,
+    pass_hash BLOB NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    UNIQUE (org_id, email)
+);
+
+INSERT INTO users_new (id, email, pass_hash, is_admin) SELECT id, email, pass_hash, is_admin FROM users;
+
+DROP TABLE users;
+
+ALTER TABLE users_new RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_email ON users (email);
+
+CREATE TABLE apps_new (
+    id UUID PRIMARY KEY,
+    org_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000001' REFERENCES organizations (id),
+    name TEXT NOT NULL,
+    secret TEXT NOT NULL UNIQUE,
+    UNIQUE (org_id, name)
+);
+
+INSERT INTO apps_new (id, name, secret) SELECT id, name, secret FROM apps;
+
+DROP TABLE apps;
+
+ALTER TABLE apps_new RENAME TO apps;
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'admin')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
+
+INSERT INTO organization_members (org_id, user_id) SELECT org_id, id FROM users;
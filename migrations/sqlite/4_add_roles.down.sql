@@ -0,0 +1,8 @@
+ALTER TABLE users ADD is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET is_admin = TRUE
+WHERE id IN (SELECT user_id FROM user_roles WHERE role_id = '00000000-0000-0000-0000-000000000001');
+
+DROP TABLE IF EXISTS user_roles;
+
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,354 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAPIKeys_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"), withScopes("email", "openid", "documents.read"))
+	email, password, userID := registerUser(t, suite, ctx)
+	token := oauthAccessToken(t, suite, appID, email, password, "openid email")
+
+	createResp, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{
+		Token:      token,
+		Name:       "ci",
+		AppId:      appID,
+		Scopes:     []string{"email", "openid"},
+		TtlSeconds: int64(time.Hour / time.Second),
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(createResp.GetApiKey(), createResp.GetPrefix()))
+	assert.True(t, strings.HasPrefix(createResp.GetPrefix(), "sso_"))
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), createResp.GetExpiresAt(), 5)
+
+	// the key and the token resolve to the same user
+	for tokenType, credential := range map[string]string{"api_key": createResp.GetApiKey(), "access_token": token} {
+		resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: credential, AppId: adminAppID, Secret: adminAppSecret})
+		require.NoError(t, err)
+		assert.True(t, resp.GetActive())
+		assert.Equal(t, tokenType, resp.GetTokenType())
+		assert.Equal(t, userID, resp.GetUserId())
+		assert.Equal(t, email, resp.GetEmail())
+		assert.Equal(t, defaultOrgID, resp.GetOrgId())
+	}
+
+	introspectResp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: createResp.GetApiKey(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.Equal(t, createResp.GetKeyId(), introspectResp.GetJti())
+	assert.Equal(t, "email openid", introspectResp.GetScope())
+	assert.Equal(t, appID, introspectResp.GetAppId())
+	assert.Equal(t, createResp.GetExpiresAt(), introspectResp.GetExp())
+
+	listResp, err := suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: token})
+	require.NoError(t, err)
+	require.Len(t, listResp.GetApiKeys(), 1)
+
+	key := listResp.GetApiKeys()[0]
+	assert.Equal(t, createResp.GetKeyId(), key.GetKeyId())
+	assert.Equal(t, "ci", key.GetName())
+	assert.Equal(t, createResp.GetPrefix(), key.GetPrefix())
+	assert.Equal(t, []string{"email", "openid"}, key.GetScopes())
+	assert.NotZero(t, key.GetCreatedAt())
+	assert.GreaterOrEqual(t, key.GetLastUsedAt(), key.GetCreatedAt())
+
+	_, err = suite.AuthClient.RevokeAPIKey(ctx, &ssov1.RevokeAPIKeyRequest{Token: token, KeyId: createResp.GetKeyId()})
+	require.NoError(t, err)
+
+	introspectResp, err = suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: createResp.GetApiKey(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.False(t, introspectResp.GetActive())
+
+	listResp, err = suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: token})
+	require.NoError(t, err)
+	assert.Empty(t, listResp.GetApiKeys())
+
+	_, err = suite.AuthClient.RevokeAPIKey(ctx, &ssov1.RevokeAPIKeyRequest{Token: token, KeyId: createResp.GetKeyId()})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeys_ScopesOfTokenAndApp(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"), withScopes("openid", "documents.read"))
+	email, password, _ := registerUser(t, suite, ctx)
+
+	// the token has only the openid and email scopes
+	token := oauthAccessToken(t, suite, appID, email, password, "openid email")
+
+	tests := []struct {
+		name   string
+		scopes []string
+	}{
+		{name: "Scope of the app the token doesn't have", scopes: []string{"documents.read"}},
+		{name: "Scope of the token the app doesn't have", scopes: []string{"email"}},
+		{name: "Scope of both and of the app only", scopes: []string{"openid", "documents.read"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", AppId: appID, Scopes: tt.scopes})
+			assertErrCode(t, err, codes.InvalidArgument, auth.ErrAPIKeyScopeNotAllowed)
+		})
+	}
+
+	createResp, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", AppId: appID, Scopes: []string{"openid"}})
+	require.NoError(t, err)
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: createResp.GetApiKey(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.Equal(t, "openid", resp.GetScope())
+}
+
+func TestAPIKeys_AppBound(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	createResp, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{
+		Token: loginResp.GetToken(),
+		Name:  "deploy",
+		AppId: appID,
+	})
+	require.NoError(t, err)
+	assert.Zero(t, createResp.GetExpiresAt())
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: createResp.GetApiKey(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+	assert.Equal(t, appID, resp.GetAppId())
+	assert.Zero(t, resp.GetExp())
+	assert.Empty(t, resp.GetScope())
+
+	// API keys can't create other keys
+	_, err = suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: createResp.GetApiKey(), Name: "copy"})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func TestAPIKeys_RevokedWithTokens(t *testing.T) {
+	ctx, suite := suite.New(t)
+	email, password, _ := registerUser(t, suite, ctx)
+	appID, _ := createApp(t, suite, ctx)
+
+	login := func() string {
+		loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+		require.NoError(t, err)
+		return loginResp.GetToken()
+	}
+
+	createKey := func(token string) string {
+		createResp, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci"})
+		require.NoError(t, err)
+		return createResp.GetApiKey()
+	}
+
+	assertInactive := func(apiKey string) {
+		resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: apiKey, AppId: adminAppID, Secret: adminAppSecret})
+		require.NoError(t, err)
+		assert.False(t, resp.GetActive())
+	}
+
+	token := login()
+	apiKey := createKey(token)
+	_, err := suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: token})
+	require.NoError(t, err)
+	assertInactive(apiKey)
+
+	token = login()
+	apiKey = createKey(token)
+	newPassword := generatePassword()
+	_, err = suite.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{Token: token, CurrentPassword: password, NewPassword: newPassword})
+	require.NoError(t, err)
+	password = newPassword
+	assertInactive(apiKey)
+
+	listResp, err := suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: login()})
+	require.NoError(t, err)
+	assert.Empty(t, listResp.GetApiKeys())
+}
+
+func TestAPIKeys_OtherUser(t *testing.T) {
+	ctx, suite := suite.New(t)
+	ownerResp := registerAndLogin(t, suite, ctx)
+	_, _, otherUserID := registerUser(t, suite, ctx)
+
+	createResp, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: ownerResp.GetToken(), Name: "ci"})
+	require.NoError(t, err)
+
+	otherResp := registerAndLogin(t, suite, ctx)
+
+	_, err = suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: otherResp.GetToken(), UserId: otherUserID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrAPIKeysPermission)
+
+	// keys of other users look like unknown ones
+	_, err = suite.AuthClient.RevokeAPIKey(ctx, &ssov1.RevokeAPIKeyRequest{Token: otherResp.GetToken(), KeyId: createResp.GetKeyId()})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAPIKeyNotFound)
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: createResp.GetApiKey(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+}
+
+func TestAPIKeys_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+	appID, _ := createApp(t, suite, ctx, withScopes("documents.read"))
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name: "Create key without token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Name: "ci"})
+				return err
+			},
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key without name",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrAPIKeyNameRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key with whitespace in scope",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", Scopes: []string{"documents read"}})
+				return err
+			},
+			expectedErr: auth.ErrInvalidAPIKeyScope,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key with negative ttl",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", TtlSeconds: -1})
+				return err
+			},
+			expectedErr: auth.ErrInvalidAPIKeyTTL,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key with scope the token doesn't have",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", Scopes: []string{"documents.read"}})
+				return err
+			},
+			expectedErr: auth.ErrAPIKeyScopeNotAllowed,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key with scope the app doesn't have",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", AppId: appID, Scopes: []string{"documents.write"}})
+				return err
+			},
+			expectedErr: auth.ErrAPIKeyScopeNotAllowed,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create key for unknown app",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: token, Name: "ci", AppId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrAppNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Create key with invalid token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateAPIKey(ctx, &ssov1.CreateAPIKeyRequest{Token: gofakeit.LetterN(20), Name: "ci"})
+				return err
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name: "List keys with invalid user id",
+			call: func() error {
+				_, err := suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: token, UserId: gofakeit.LetterN(10)})
+				return err
+			},
+			expectedErr: auth.ErrInvalidUserID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Revoke key without key id",
+			call: func() error {
+				_, err := suite.AuthClient.RevokeAPIKey(ctx, &ssov1.RevokeAPIKeyRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrAPIKeyIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Revoke unknown key",
+			call: func() error {
+				_, err := suite.AuthClient.RevokeAPIKey(ctx, &ssov1.RevokeAPIKeyRequest{Token: token, KeyId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrAPIKeyNotFound,
+			code:        codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertErrCode(t, tt.call(), tt.code, tt.expectedErr)
+		})
+	}
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: "sso_" + gofakeit.LetterN(40), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.False(t, resp.GetActive())
+}
+
+// oauthAccessToken signs the user in to the public app with the authorization code flow and returns the access token of the scope
+func oauthAccessToken(t *testing.T, suite *suite.Suite, appID, email, password, scope string) string {
+	t.Helper()
+
+	verifier := gofakeit.LetterN(64)
+	code := authorize(t, suite, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {appID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {scope},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {email},
+		"password":              {password},
+	})
+
+	status, tokens := exchangeCode(t, suite, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, scope, tokens.Scope)
+
+	return tokens.AccessToken
+}
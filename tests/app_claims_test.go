@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAppClaims_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	const tokenTTLSeconds = 60
+	var (
+		appName  = fmt.Sprintf("test_%s", gofakeit.LetterN(10))
+		email    = fmt.Sprintf("test_%s", gofakeit.Email())
+		password = generatePassword()
+	)
+
+	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name:            appName,
+		Token:           adminToken(t, suite, ctx),
+		TokenTtlSeconds: tokenTTLSeconds,
+		Claims:          []string{"roles", "is_admin"},
+	})
+	require.NoError(t, err)
+
+	appResp, err := suite.AuthClient.App(ctx, &ssov1.AppRequest{Name: appName})
+	require.NoError(t, err)
+	assert.Equal(t, int64(tokenTTLSeconds), appResp.GetTokenTtlSeconds())
+	assert.ElementsMatch(t, []string{"roles", "is_admin"}, appResp.GetClaims())
+
+	registerResp, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    createAppResp.GetAppId(),
+	})
+	require.NoError(t, err)
+	loginTime := time.Now()
+
+	claims := assertTokenClaims(t, suite, loginResp.GetToken(), email, createAppResp.GetAppId(), registerResp.GetUserId())
+	assert.Equal(t, false, claims["is_admin"])
+	assert.Empty(t, claims["roles"])
+
+	const deltaSeconds = 1
+	assert.InDelta(t, loginTime.Add(tokenTTLSeconds*time.Second).Unix(), claims["exp"].(float64), deltaSeconds)
+}
+
+func TestAppClaims_UnsupportedClaim(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	_, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name:   fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token:  adminToken(t, suite, ctx),
+		Claims: []string{"password"},
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrUnsupportedClaim)
+}
@@ -28,8 +28,8 @@ func TestCreateApp_HappyPath(t *testing.T) {
 	ctx, suite := suite.New(t)
 
 	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
-		Name:   fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
-		Secret: gofakeit.LetterN(10),
+		Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token: adminToken(t, suite, ctx),
 	})
 	require.NoError(t, err)
 	assert.NotNil(t, createAppResp.GetAppId())
@@ -40,8 +40,8 @@ func TestFindApp_HappyPath(t *testing.T) {
 	name := fmt.Sprintf("test_%s", gofakeit.LetterN(10))
 
 	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
-		Name:   name,
-		Secret: gofakeit.LetterN(10),
+		Name:  name,
+		Token: adminToken(t, suite, ctx),
 	})
 	require.NoError(t, err)
 	assert.NotNil(t, createAppResp.GetAppId())
@@ -150,7 +150,7 @@ func TestRegister_UnHappyPath(t *testing.T) {
 
 func TestBruteforceLogin(t *testing.T) {
 	ctx, suite := suite.New(t)
-	appID, secret := createApp(t, suite, ctx)
+	appID, _ := createApp(t, suite, ctx)
 
 	var (
 		email    = fmt.Sprintf("test_%s", gofakeit.Email())
@@ -181,12 +181,12 @@ func TestBruteforceLogin(t *testing.T) {
 	assertErrCode(t, err, codes.InvalidArgument, auth.ErrAccountTemporaryLocked)
 
 	time.Sleep(authService.BaseLockoutDuration)
-	assertLogin(t, ctx, email, password, appID, registerResp.GetUserId(), secret, suite)
+	assertLogin(t, ctx, email, password, appID, registerResp.GetUserId(), suite)
 }
 
 func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	ctx, suite := suite.New(t)
-	appID, secret := createApp(t, suite, ctx)
+	appID, _ := createApp(t, suite, ctx)
 
 	var (
 		email    = fmt.Sprintf("test_%s", gofakeit.Email())
@@ -200,7 +200,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, registerResp.GetUserId())
 
-	assertLogin(t, ctx, email, password, appID, registerResp.GetUserId(), secret, suite)
+	assertLogin(t, ctx, email, password, appID, registerResp.GetUserId(), suite)
 }
 
 func TestRegisterLogin_Login_UnHappyPath(t *testing.T) {
@@ -333,19 +333,78 @@ func assertErrCode(t *testing.T, err error, targetCode codes.Code, msg string) {
 	assert.Equal(t, msg, code.Message())
 }
 
-func createApp(t *testing.T, suite *suite.Suite, ctx context.Context) (appId string, secret string) {
+// appOption sets up the app created by createApp
+type appOption func(req *ssov1.CreateAppRequest)
+
+// withAppName names the app, apps get a random name by default
+func withAppName(name string) appOption {
+	return func(req *ssov1.CreateAppRequest) { req.Name = name }
+}
+
+// withClientType makes the app an OAuth client of the type redirecting to redirectURI and postLogoutRedirectURI
+func withClientType(clientType string) appOption {
+	return func(req *ssov1.CreateAppRequest) {
+		req.ClientType = clientType
+		req.RedirectUris = []string{redirectURI}
+		req.PostLogoutRedirectUris = []string{postLogoutRedirectURI}
+	}
+}
+
+func withOrgID(orgID string) appOption {
+	return func(req *ssov1.CreateAppRequest) { req.OrgId = orgID }
+}
+
+func withScopes(scopes ...string) appOption {
+	return func(req *ssov1.CreateAppRequest) { req.Scopes = scopes }
+}
+
+func withRequiredVerifiedEmail() appOption {
+	return func(req *ssov1.CreateAppRequest) { req.RequireVerifiedEmail = true }
+}
+
+func withPasswordPolicy(policy *ssov1.PasswordPolicy) appOption {
+	return func(req *ssov1.CreateAppRequest) { req.PasswordPolicy = policy }
+}
+
+// withMagicLink redirects the magic links of the app to redirectURI, sign in with them is allowed only if enabled
+func withMagicLink(enabled bool) appOption {
+	return func(req *ssov1.CreateAppRequest) {
+		req.RedirectUris = []string{redirectURI}
+		req.MagicLinkEnabled = enabled
+	}
+}
+
+// createApp creates the app with the admin token, the options set up the request
+func createApp(t *testing.T, suite *suite.Suite, ctx context.Context, opts ...appOption) (appId string, secret string) {
 	t.Helper()
-	secret = gofakeit.LetterN(10)
-	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
-		Name:   fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
-		Secret: secret,
-	})
+	req := &ssov1.CreateAppRequest{
+		Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token: adminToken(t, suite, ctx),
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	createAppResp, err := suite.AuthClient.CreateApp(ctx, req)
 	require.NoError(t, err)
 	assert.NotNil(t, createAppResp.GetAppId())
-	return createAppResp.GetAppId(), secret
+	assert.NotEmpty(t, createAppResp.GetSecret())
+	return createAppResp.GetAppId(), createAppResp.GetSecret()
+}
+
+// adminToken signs in the admin seeded by the tests migrations, only admins create apps
+func adminToken(t *testing.T, suite *suite.Suite, ctx context.Context) string {
+	t.Helper()
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    adminEmail,
+		Password: adminPassword,
+		AppId:    adminAppID,
+	})
+	require.NoError(t, err)
+	return loginResp.GetToken()
 }
 
-func assertLogin(t *testing.T, ctx context.Context, email, password, appID, userID, appSecret string, suite *suite.Suite) {
+func assertLogin(t *testing.T, ctx context.Context, email, password, appID, userID string, suite *suite.Suite) {
 	t.Helper()
 	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
 		Email:    email,
@@ -357,10 +416,18 @@ func assertLogin(t *testing.T, ctx context.Context, email, password, appID, user
 
 	token := loginResp.GetToken()
 	assert.NotNil(t, token)
+	assert.NotEmpty(t, loginResp.GetRefreshToken())
 
-	tokenParsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		return []byte(appSecret), nil
-	})
+	claims := assertTokenClaims(t, suite, token, email, appID, userID)
+
+	const deltaSeconds = 1
+	assert.InDelta(t, loginTime.Add(suite.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+}
+
+func assertTokenClaims(t *testing.T, suite *suite.Suite, token, email, appID, userID string) jwt.MapClaims {
+	t.Helper()
+
+	tokenParsed, err := jwt.Parse(token, suite.KeyFunc)
 	require.NoError(t, err)
 
 	claims, ok := tokenParsed.Claims.(jwt.MapClaims)
@@ -369,7 +436,12 @@ func assertLogin(t *testing.T, ctx context.Context, email, password, appID, user
 	assert.Equal(t, userID, claims["uid"].(string))
 	assert.Equal(t, email, claims["email"].(string))
 	assert.Equal(t, appID, claims["app_id"].(string))
-
-	const deltaSeconds = 1
-	assert.InDelta(t, loginTime.Add(suite.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+	assert.Equal(t, userID, claims["sub"].(string))
+	assert.Equal(t, appID, claims["aud"].(string))
+	assert.NotEmpty(t, claims["iss"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.Contains(t, claims, "iat")
+	assert.Contains(t, claims, "nbf")
+
+	return claims
 }
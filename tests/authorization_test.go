@@ -0,0 +1,217 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/authorization"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAuthorization_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx)
+
+	alice := subject("user", "alice", "")
+	bob := subject("user", "bob", "")
+	carol := subject("user", "carol", "")
+
+	// owners edit documents, editors view them
+	for relation, impliedBy := range map[string][]string{"editor": {"owner"}, "viewer": {"editor"}} {
+		_, err := suite.AuthorizationClient.DefineRelation(ctx, &ssov1.DefineRelationRequest{
+			AppId:      appID,
+			Secret:     secret,
+			ObjectType: "document",
+			Relation:   relation,
+			ImpliedBy:  impliedBy,
+		})
+		require.NoError(t, err)
+	}
+
+	_, err := suite.AuthorizationClient.WriteRelationships(ctx, &ssov1.WriteRelationshipsRequest{
+		AppId:  appID,
+		Secret: secret,
+		Writes: []*ssov1.Relationship{
+			{Object: object("document", "readme"), Relation: "owner", Subject: alice},
+			{Object: object("document", "readme"), Relation: "viewer", Subject: subject("group", "eng", "member")},
+			{Object: object("document", "roadmap"), Relation: "editor", Subject: subject("group", "eng", "member")},
+			{Object: object("group", "eng"), Relation: "member", Subject: bob},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		object   *ssov1.ObjectReference
+		relation string
+		subject  *ssov1.SubjectReference
+		allowed  bool
+	}{
+		{name: "Owner is editor", object: object("document", "readme"), relation: "editor", subject: alice, allowed: true},
+		{name: "Owner is viewer", object: object("document", "readme"), relation: "viewer", subject: alice, allowed: true},
+		{name: "Group member is viewer", object: object("document", "readme"), relation: "viewer", subject: bob, allowed: true},
+		{name: "Group member is not editor", object: object("document", "readme"), relation: "editor", subject: bob},
+		{name: "Userset is viewer", object: object("document", "readme"), relation: "viewer", subject: subject("group", "eng", "member"), allowed: true},
+		{name: "Stranger is not viewer", object: object("document", "readme"), relation: "viewer", subject: carol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := suite.AuthorizationClient.Check(ctx, &ssov1.CheckRequest{
+				AppId:    appID,
+				Secret:   secret,
+				Object:   tt.object,
+				Relation: tt.relation,
+				Subject:  tt.subject,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.allowed, resp.GetAllowed())
+		})
+	}
+
+	listResp, err := suite.AuthorizationClient.ListObjects(ctx, &ssov1.ListObjectsRequest{
+		AppId:      appID,
+		Secret:     secret,
+		ObjectType: "document",
+		Relation:   "viewer",
+		Subject:    bob,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"readme", "roadmap"}, listResp.GetObjectIds())
+
+	_, err = suite.AuthorizationClient.WriteRelationships(ctx, &ssov1.WriteRelationshipsRequest{
+		AppId:   appID,
+		Secret:  secret,
+		Deletes: []*ssov1.Relationship{{Object: object("group", "eng"), Relation: "member", Subject: bob}},
+	})
+	require.NoError(t, err)
+
+	checkResp, err := suite.AuthorizationClient.Check(ctx, &ssov1.CheckRequest{
+		AppId:    appID,
+		Secret:   secret,
+		Object:   object("document", "readme"),
+		Relation: "viewer",
+		Subject:  bob,
+	})
+	require.NoError(t, err)
+	assert.False(t, checkResp.GetAllowed())
+}
+
+func TestAuthorization_AppsAreIsolated(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx)
+	otherAppID, otherSecret := createApp(t, suite, ctx)
+
+	relationship := &ssov1.Relationship{Object: object("document", "readme"), Relation: "owner", Subject: subject("user", "alice", "")}
+
+	_, err := suite.AuthorizationClient.WriteRelationships(ctx, &ssov1.WriteRelationshipsRequest{
+		AppId:  appID,
+		Secret: secret,
+		Writes: []*ssov1.Relationship{relationship},
+	})
+	require.NoError(t, err)
+
+	resp, err := suite.AuthorizationClient.Check(ctx, &ssov1.CheckRequest{
+		AppId:    otherAppID,
+		Secret:   otherSecret,
+		Object:   relationship.GetObject(),
+		Relation: relationship.GetRelation(),
+		Subject:  relationship.GetSubject(),
+	})
+	require.NoError(t, err)
+	assert.False(t, resp.GetAllowed())
+}
+
+func TestAuthorization_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx)
+	readme := object("document", "readme")
+	alice := subject("user", "alice", "")
+
+	tests := []struct {
+		name        string
+		req         *ssov1.CheckRequest
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Empty app id",
+			req:         &ssov1.CheckRequest{Secret: secret, Object: readme, Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrAppIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Wrong secret",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: gofakeit.LetterN(10), Object: readme, Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrInvalidClient,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name:        "Unknown app",
+			req:         &ssov1.CheckRequest{AppId: gofakeit.UUID(), Secret: secret, Object: readme, Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrInvalidClient,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name:        "Empty object",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: secret, Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrObjectRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Invalid object type",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: secret, Object: object("Document", "readme"), Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrInvalidType,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Object id with whitespace",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: secret, Object: object("document", "read me"), Relation: "viewer", Subject: alice},
+			expectedErr: authorization.ErrInvalidObjectID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty relation",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: secret, Object: readme, Subject: alice},
+			expectedErr: authorization.ErrInvalidRelation,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty subject",
+			req:         &ssov1.CheckRequest{AppId: appID, Secret: secret, Object: readme, Relation: "viewer"},
+			expectedErr: authorization.ErrSubjectRequired,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthorizationClient.Check(ctx, tt.req)
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+
+	_, err := suite.AuthorizationClient.WriteRelationships(ctx, &ssov1.WriteRelationshipsRequest{AppId: appID, Secret: secret})
+	assertErrCode(t, err, codes.InvalidArgument, authorization.ErrRelationshipsRequired)
+
+	_, err = suite.AuthorizationClient.DefineRelation(ctx, &ssov1.DefineRelationRequest{
+		AppId:      appID,
+		Secret:     secret,
+		ObjectType: "document",
+		Relation:   "editor",
+		ImpliedBy:  []string{"editor"},
+	})
+	assertErrCode(t, err, codes.InvalidArgument, authorization.ErrSelfImpliedRelation)
+}
+
+func object(objectType string, id string) *ssov1.ObjectReference {
+	return &ssov1.ObjectReference{Type: objectType, Id: id}
+}
+
+func subject(objectType string, id string, relation string) *ssov1.SubjectReference {
+	return &ssov1.SubjectReference{Object: object(objectType, id), Relation: relation}
+}
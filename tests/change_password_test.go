@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestChangePassword_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginReq := &ssov1.LoginRequest{Email: email, Password: password, AppId: appID}
+
+	currentSession, err := suite.AuthClient.Login(ctx, loginReq)
+	require.NoError(t, err)
+
+	otherSession, err := suite.AuthClient.Login(ctx, loginReq)
+	require.NoError(t, err)
+
+	newPassword := generatePassword()
+	changeResp, err := suite.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           currentSession.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     newPassword,
+		RefreshToken:    currentSession.GetRefreshToken(),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, changeResp.GetToken())
+	assert.NotEmpty(t, changeResp.GetRefreshToken())
+
+	_, err = suite.AuthClient.Login(ctx, loginReq)
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidCredentials)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+
+	// the current session continues with the returned tokens
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: changeResp.GetRefreshToken()})
+	require.NoError(t, err)
+
+	// other sessions and tokens issued before the change are revoked
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: otherSession.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	_, err = suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: currentSession.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func TestChangePassword_WithoutRefreshToken(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	changeResp, err := suite.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           loginResp.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     generatePassword(),
+	})
+	require.NoError(t, err)
+
+	// every session started before the change is revoked, the returned tokens start a new one
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: changeResp.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestChangePassword_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	otherUser := registerAndLogin(t, suite, ctx)
+
+	tests := []struct {
+		name        string
+		req         *ssov1.ChangePasswordRequest
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Empty token",
+			req:         &ssov1.ChangePasswordRequest{CurrentPassword: password, NewPassword: generatePassword()},
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty current password",
+			req:         &ssov1.ChangePasswordRequest{Token: loginResp.GetToken(), NewPassword: generatePassword()},
+			expectedErr: auth.ErrCurrentPasswordRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty new password",
+			req:         &ssov1.ChangePasswordRequest{Token: loginResp.GetToken(), CurrentPassword: password},
+			expectedErr: auth.ErrNewPasswordRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Invalid token",
+			req: &ssov1.ChangePasswordRequest{
+				Token:           gofakeit.LetterN(40),
+				CurrentPassword: password,
+				NewPassword:     generatePassword(),
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name: "Wrong current password",
+			req: &ssov1.ChangePasswordRequest{
+				Token:           loginResp.GetToken(),
+				CurrentPassword: generatePassword(),
+				NewPassword:     generatePassword(),
+			},
+			expectedErr: auth.ErrInvalidCredentials,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Refresh token of another user",
+			req: &ssov1.ChangePasswordRequest{
+				Token:           loginResp.GetToken(),
+				CurrentPassword: password,
+				NewPassword:     generatePassword(),
+				RefreshToken:    otherUser.GetRefreshToken(),
+			},
+			expectedErr: auth.ErrInvalidRefreshToken,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.ChangePassword(ctx, tt.req)
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+
+	// failed attempts leave the password unchanged
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
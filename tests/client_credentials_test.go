@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestClientToken_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx, withScopes("orders:read", "orders:write"))
+
+	resp, err := suite.AuthClient.ClientToken(ctx, &ssov1.ClientTokenRequest{
+		AppId:  appID,
+		Secret: secret,
+		Scopes: []string{"orders:read"},
+	})
+	require.NoError(t, err)
+	issuedAt := time.Now()
+
+	assert.Equal(t, "orders:read", resp.GetScope())
+	assert.Equal(t, int64(suite.Cfg.ClientCredentials.TokenTTL.Seconds()), resp.GetExpiresIn())
+
+	token, err := jwt.Parse(resp.GetToken(), suite.KeyFunc)
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, appID, claims["sub"])
+	assert.Equal(t, appID, claims["client_id"])
+	assert.Equal(t, "orders:read", claims["scope"])
+	assert.NotContains(t, claims, "uid")
+
+	const deltaSeconds = 1
+	assert.InDelta(t, issuedAt.Add(suite.Cfg.ClientCredentials.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
+}
+
+func TestClientToken_HTTP(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx, withScopes("orders:read", "orders:write"))
+
+	req, err := http.NewRequest(http.MethodPost, suite.HTTPURL("/token"), strings.NewReader(url.Values{
+		"grant_type": {"client_credentials"},
+	}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(appID, secret)
+
+	resp, err := suite.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tokens tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Empty(t, tokens.RefreshToken)
+	assert.Equal(t, "orders:read orders:write", tokens.Scope)
+}
+
+func TestClientToken_Introspect(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx, withScopes("orders:read", "orders:write"))
+
+	tokenResp, err := suite.AuthClient.ClientToken(ctx, &ssov1.ClientTokenRequest{
+		AppId:  appID,
+		Secret: secret,
+		Scopes: []string{"orders:read"},
+	})
+	require.NoError(t, err)
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: tokenResp.GetToken(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+	assert.Equal(t, appID, resp.GetClientId())
+	assert.Equal(t, appID, resp.GetAppId())
+	assert.Equal(t, defaultOrgID, resp.GetOrgId())
+	assert.Equal(t, "orders:read", resp.GetScope())
+	assert.Equal(t, "access_token", resp.GetTokenType())
+	assert.NotEmpty(t, resp.GetJti())
+	assert.NotZero(t, resp.GetExp())
+	assert.Empty(t, resp.GetUserId())
+	assert.Empty(t, resp.GetEmail())
+
+	// the app's own token doesn't act on behalf of a user
+	_, err = suite.AuthClient.ListAPIKeys(ctx, &ssov1.ListAPIKeysRequest{Token: tokenResp.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func TestClientToken_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx, withScopes("orders:read"))
+
+	_, err := suite.AuthClient.ClientToken(ctx, &ssov1.ClientTokenRequest{AppId: appID, Secret: gofakeit.LetterN(10)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidClient)
+
+	_, err = suite.AuthClient.ClientToken(ctx, &ssov1.ClientTokenRequest{
+		AppId:  appID,
+		Secret: secret,
+		Scopes: []string{"orders:write"},
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidScope)
+
+	publicAppID, publicSecret := createApp(t, suite, ctx, withClientType("public"))
+	_, err = suite.AuthClient.ClientToken(ctx, &ssov1.ClientTokenRequest{AppId: publicAppID, Secret: publicSecret})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrUnauthorizedClient)
+}
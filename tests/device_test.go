@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+const deviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+type deviceAuthorizationResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Interval        int64  `json:"interval"`
+}
+
+func TestDeviceAuthorization_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, userID := registerUser(t, suite, ctx)
+
+	resp, err := suite.HTTPClient.PostForm(suite.HTTPURL("/device_authorization"), url.Values{"client_id": {appID}})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var device deviceAuthorizationResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&device))
+	assert.Equal(t, suite.HTTPURL("/device"), device.VerificationURI)
+	require.NotEmpty(t, device.DeviceCode)
+	require.NotEmpty(t, device.UserCode)
+
+	pollForm := url.Values{
+		"grant_type":  {deviceGrantType},
+		"device_code": {device.DeviceCode},
+		"client_id":   {appID},
+	}
+
+	status, tokens := exchangeCode(t, suite, pollForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "authorization_pending", tokens.Error)
+
+	// polling faster than the interval
+	status, tokens = exchangeCode(t, suite, pollForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "slow_down", tokens.Error)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ApproveDevice(ctx, &ssov1.ApproveDeviceRequest{
+		Token:    loginResp.GetToken(),
+		UserCode: device.UserCode,
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Duration(device.Interval) * time.Second)
+
+	status, tokens = exchangeCode(t, suite, pollForm)
+	require.Equal(t, http.StatusOK, status)
+	assertTokenClaims(t, suite, tokens.AccessToken, email, appID, userID)
+	assert.NotEmpty(t, tokens.RefreshToken)
+}
+
+func TestApproveDevice_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.ApproveDevice(ctx, &ssov1.ApproveDeviceRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrUserCodeRequired)
+
+	_, err = suite.AuthClient.ApproveDevice(ctx, &ssov1.ApproveDeviceRequest{
+		Token:    loginResp.GetToken(),
+		UserCode: "BCDF-GHJK",
+	})
+	assertErrCode(t, err, codes.NotFound, auth.ErrInvalidUserCode)
+}
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// linkPattern finds the link in the body of an email
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+func TestVerifyEmail_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withRequiredVerifiedEmail())
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginReq := &ssov1.LoginRequest{Email: email, Password: password, AppId: appID}
+
+	_, err := suite.AuthClient.Login(ctx, loginReq)
+	assertErrCode(t, err, codes.FailedPrecondition, auth.ErrEmailNotVerified)
+
+	token := emailLink(t, suite, email).Query().Get("token")
+	require.NotEmpty(t, token)
+
+	_, err = suite.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: token})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, loginReq)
+	require.NoError(t, err)
+	assert.NotEmpty(t, loginResp.GetToken())
+
+	// verifying a verified email succeeds
+	_, err = suite.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: token})
+	require.NoError(t, err)
+}
+
+func TestVerifyEmail_Link(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withRequiredVerifiedEmail())
+	email, password, _ := registerUser(t, suite, ctx)
+
+	resp, err := suite.HTTPClient.Get(emailLink(t, suite, email).String())
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestVerifyEmail_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Empty token",
+			token:       "",
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Invalid token",
+			token:       gofakeit.LetterN(40),
+			expectedErr: auth.ErrInvalidEmailToken,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: tt.token})
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+
+	// access tokens can't verify emails
+	loginResp := registerAndLogin(t, suite, ctx)
+	_, err := suite.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+}
+
+func TestResendVerification(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withRequiredVerifiedEmail())
+	email, password, _ := registerUser(t, suite, ctx)
+	registrationLink := emailLink(t, suite, email)
+
+	_, err := suite.AuthClient.ResendVerification(ctx, &ssov1.ResendVerificationRequest{Email: email})
+	require.NoError(t, err)
+
+	link := emailLink(t, suite, email)
+	require.NotEqual(t, registrationLink.String(), link.String())
+
+	_, err = suite.AuthClient.VerifyEmail(ctx, &ssov1.VerifyEmailRequest{Token: link.Query().Get("token")})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	// unknown emails aren't revealed
+	_, err = suite.AuthClient.ResendVerification(ctx, &ssov1.ResendVerificationRequest{
+		Email: fmt.Sprintf("test_%s", gofakeit.Email()),
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ResendVerification(ctx, &ssov1.ResendVerificationRequest{Email: gofakeit.LetterN(10)})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmail)
+}
+
+// emailLink returns the link from the latest email written to the outbox of the test server for the recipient
+func emailLink(t *testing.T, suite *suite.Suite, recipient string) *url.URL {
+	t.Helper()
+
+	// the test server runs from the repository root
+	outboxDir := filepath.Join("..", suite.Cfg.Mailer.OutboxDir)
+	paths, err := filepath.Glob(filepath.Join(outboxDir, "*_"+recipient+".eml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths, "no emails for %s", recipient)
+
+	// file names start with the unix nano time of sending
+	sort.Strings(paths)
+
+	file, err := os.Open(paths[len(paths)-1])
+	require.NoError(t, err)
+	defer file.Close()
+
+	msg, err := mail.ReadMessage(file)
+	require.NoError(t, err)
+
+	body := new(strings.Builder)
+	_, err = io.Copy(body, msg.Body)
+	require.NoError(t, err)
+
+	rawLink := linkPattern.FindString(body.String())
+	require.NotEmpty(t, rawLink)
+
+	link, err := url.Parse(rawLink)
+	require.NoError(t, err)
+
+	return link
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGroups_RegularMember(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+	_, _, userID := registerUser(t, suite, ctx)
+
+	// regular members of the organization can't manage its groups
+	_, err := suite.AuthClient.CreateGroup(ctx, &ssov1.CreateGroupRequest{Token: token, Name: gofakeit.LetterN(10)})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrGroupsPermission)
+
+	_, err = suite.AuthClient.ListGroups(ctx, &ssov1.ListGroupsRequest{Token: token})
+	require.NoError(t, err)
+
+	userGroupsResp, err := suite.AuthClient.ListUserGroups(ctx, &ssov1.ListUserGroupsRequest{Token: token})
+	require.NoError(t, err)
+	assert.Empty(t, userGroupsResp.GetGroups())
+
+	_, err = suite.AuthClient.ListUserGroups(ctx, &ssov1.ListUserGroupsRequest{Token: token, UserId: userID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrGroupsPermission)
+}
+
+func TestGroups_Claims(t *testing.T) {
+	ctx, suite := suite.New(t)
+	email, password, userID := registerUser(t, suite, ctx)
+
+	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name:   fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token:  adminToken(t, suite, ctx),
+		Claims: []string{"groups"},
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: createAppResp.GetAppId()})
+	require.NoError(t, err)
+
+	claims := assertTokenClaims(t, suite, loginResp.GetToken(), email, createAppResp.GetAppId(), userID)
+	assert.Contains(t, claims, "groups")
+	assert.Empty(t, claims["groups"])
+	assert.NotContains(t, claims, "roles")
+}
+
+func TestGroups_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name: "Create group without token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateGroup(ctx, &ssov1.CreateGroupRequest{Name: "engineering"})
+				return err
+			},
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create group without name",
+			call: func() error {
+				_, err := suite.AuthClient.CreateGroup(ctx, &ssov1.CreateGroupRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrGroupNameRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create group with invalid token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateGroup(ctx, &ssov1.CreateGroupRequest{Token: gofakeit.LetterN(20), Name: "engineering"})
+				return err
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name: "Delete group without group id",
+			call: func() error {
+				_, err := suite.AuthClient.DeleteGroup(ctx, &ssov1.DeleteGroupRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrGroupIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Delete unknown group",
+			call: func() error {
+				_, err := suite.AuthClient.DeleteGroup(ctx, &ssov1.DeleteGroupRequest{Token: token, GroupId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrGroupNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Add member without member",
+			call: func() error {
+				_, err := suite.AuthClient.AddGroupMember(ctx, &ssov1.AddGroupMemberRequest{Token: token, GroupId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrGroupMemberRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Add both user and group",
+			call: func() error {
+				_, err := suite.AuthClient.AddGroupMember(ctx, &ssov1.AddGroupMemberRequest{
+					Token:         token,
+					GroupId:       gofakeit.UUID(),
+					UserId:        gofakeit.UUID(),
+					MemberGroupId: gofakeit.UUID(),
+				})
+				return err
+			},
+			expectedErr: auth.ErrGroupMemberRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Add user with invalid user id",
+			call: func() error {
+				_, err := suite.AuthClient.AddGroupMember(ctx, &ssov1.AddGroupMemberRequest{Token: token, GroupId: gofakeit.UUID(), UserId: gofakeit.LetterN(10)})
+				return err
+			},
+			expectedErr: auth.ErrInvalidUserID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Add user to unknown group",
+			call: func() error {
+				_, err := suite.AuthClient.AddGroupMember(ctx, &ssov1.AddGroupMemberRequest{Token: token, GroupId: gofakeit.UUID(), UserId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrGroupNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Remove subgroup of unknown group",
+			call: func() error {
+				_, err := suite.AuthClient.RemoveGroupMember(ctx, &ssov1.RemoveGroupMemberRequest{Token: token, GroupId: gofakeit.UUID(), MemberGroupId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrGroupNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "List members of unknown group",
+			call: func() error {
+				_, err := suite.AuthClient.ListGroupMembers(ctx, &ssov1.ListGroupMembersRequest{Token: token, GroupId: gofakeit.UUID(), Transitive: true})
+				return err
+			},
+			expectedErr: auth.ErrGroupNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "List groups of user with invalid user id",
+			call: func() error {
+				_, err := suite.AuthClient.ListUserGroups(ctx, &ssov1.ListUserGroupsRequest{Token: token, UserId: gofakeit.LetterN(10)})
+				return err
+			},
+			expectedErr: auth.ErrInvalidUserID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Grant role without role id",
+			call: func() error {
+				_, err := suite.AuthClient.GrantGroupRole(ctx, &ssov1.GrantGroupRoleRequest{Token: token, GroupId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrRoleIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Grant admin role",
+			call: func() error {
+				_, err := suite.AuthClient.GrantGroupRole(ctx, &ssov1.GrantGroupRoleRequest{Token: token, GroupId: gofakeit.UUID(), RoleId: adminRoleID})
+				return err
+			},
+			expectedErr: auth.ErrGroupRolesPermission,
+			code:        codes.PermissionDenied,
+		},
+		{
+			name: "Revoke unknown role",
+			call: func() error {
+				_, err := suite.AuthClient.RevokeGroupRole(ctx, &ssov1.RevokeGroupRoleRequest{Token: token, GroupId: gofakeit.UUID(), RoleId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrRoleNotFound,
+			code:        codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertErrCode(t, tt.call(), tt.code, tt.expectedErr)
+		})
+	}
+}
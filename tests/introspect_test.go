@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/introspection"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestIntrospect_Active(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: loginResp.GetToken(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+	assert.NotEmpty(t, resp.GetUserId())
+	assert.NotEmpty(t, resp.GetEmail())
+	assert.NotEmpty(t, resp.GetAppId())
+	assert.NotEmpty(t, resp.GetJti())
+	assert.NotZero(t, resp.GetExp())
+}
+
+func TestIntrospect_Inactive(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	tokens := []string{loginResp.GetToken(), gofakeit.LetterN(30)}
+	for _, token := range tokens {
+		resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: token, AppId: adminAppID, Secret: adminAppSecret})
+		require.NoError(t, err)
+		assert.False(t, resp.GetActive())
+		assert.Empty(t, resp.GetUserId())
+	}
+}
+
+func TestIntrospect_AppSecretSignedToken(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	attackerAppID, attackerSecret := createApp(t, suite, ctx)
+
+	token, _, err := jwt.NewParser().ParseUnverified(loginResp.GetToken(), jwt.MapClaims{})
+	require.NoError(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+
+	// the victim's claims signed with a secret the attacker chose, as is and retargeted to the attacker's app
+	forgedForApp, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(attackerSecret))
+	require.NoError(t, err)
+
+	claims["aud"] = attackerAppID
+	claims["app_id"] = attackerAppID
+	forgedForAttacker, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(attackerSecret))
+	require.NoError(t, err)
+
+	for _, forged := range []string{forgedForApp, forgedForAttacker} {
+		resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: forged, AppId: adminAppID, Secret: adminAppSecret})
+		require.NoError(t, err)
+		assert.False(t, resp.GetActive())
+		assert.Empty(t, resp.GetUserId())
+	}
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: loginResp.GetToken(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+}
+
+func TestIntrospect_ClientAuthentication(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+	publicAppID, _ := createApp(t, suite, ctx, withClientType("public"))
+
+	tests := []struct {
+		name        string
+		req         *ssov1.IntrospectRequest
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Introspect without app id",
+			req:         &ssov1.IntrospectRequest{Token: token, Secret: adminAppSecret},
+			expectedErr: introspection.ErrAppIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Introspect without secret",
+			req:         &ssov1.IntrospectRequest{Token: token, AppId: adminAppID},
+			expectedErr: introspection.ErrAppSecretRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Introspect with wrong secret",
+			req:         &ssov1.IntrospectRequest{Token: token, AppId: adminAppID, Secret: gofakeit.LetterN(20)},
+			expectedErr: introspection.ErrInvalidClient,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name:        "Introspect with malformed app id",
+			req:         &ssov1.IntrospectRequest{Token: token, AppId: gofakeit.LetterN(10), Secret: adminAppSecret},
+			expectedErr: introspection.ErrInvalidClient,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name:        "Introspect as public app",
+			req:         &ssov1.IntrospectRequest{Token: token, AppId: publicAppID, Secret: gofakeit.LetterN(20)},
+			expectedErr: introspection.ErrUnauthorizedClient,
+			code:        codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.IntrospectionClient.Introspect(ctx, tt.req)
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BariVakhidov/sso/tests/suite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKS_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, suite.HTTPURL("/.well-known/jwks.json"), nil)
+	require.NoError(t, err)
+
+	resp, err := suite.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	set, err := suite.JWKS()
+	require.NoError(t, err)
+	require.NotEmpty(t, set.Keys)
+
+	for _, key := range set.Keys {
+		assert.NotEmpty(t, key.Kid)
+		assert.Equal(t, "sig", key.Use)
+		assert.Equal(t, suite.Cfg.JWT.Algorithm, key.Alg)
+	}
+}
+
+func TestJWKS_VerifiesLoginToken(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	set, err := suite.JWKS()
+	require.NoError(t, err)
+
+	// the token is verified with nothing but the published key its kid points to
+	token, err := jwt.Parse(
+		loginResp.GetToken(),
+		func(token *jwt.Token) (interface{}, error) {
+			keyID, _ := token.Header["kid"].(string)
+			for _, key := range set.Keys {
+				if key.Kid == keyID {
+					return key.PublicKey()
+				}
+			}
+
+			return nil, jwt.ErrTokenUnverifiable
+		},
+		jwt.WithValidMethods([]string{suite.Cfg.JWT.Algorithm}),
+		jwt.WithIssuer(suite.Cfg.JWT.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, suite.Cfg.JWT.Algorithm, token.Method.Alg())
+
+	// the newest key is the active one
+	assert.Equal(t, set.Keys[0].Kid, token.Header["kid"])
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	assert.NotEmpty(t, claims["uid"])
+}
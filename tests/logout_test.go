@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLogout_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{
+		Token:        loginResp.GetToken(),
+		RefreshToken: loginResp.GetRefreshToken(),
+	})
+	require.NoError(t, err)
+
+	// the token is in the denylist now
+	_, err = suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
+
+func TestLogout_EndsSession(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	// another access token of the same session
+	refreshResp, err := suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: refreshResp.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: refreshResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
+
+func TestLogoutAll_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
+
+func TestLogout_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	_, err := suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: ""})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrTokenRequired)
+
+	_, err = suite.AuthClient.Logout(ctx, &ssov1.LogoutRequest{Token: gofakeit.LetterN(20)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func registerAndLogin(t *testing.T, suite *suite.Suite, ctx context.Context) *ssov1.LoginResponse {
+	t.Helper()
+	appID, _ := createApp(t, suite, ctx)
+
+	var (
+		email    = fmt.Sprintf("test_%s", gofakeit.Email())
+		password = generatePassword()
+	)
+
+	_, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	return loginResp
+}
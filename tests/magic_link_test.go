@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMagicLink_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withMagicLink(true))
+	email, _, userID := registerUser(t, suite, ctx)
+
+	_, err := suite.AuthClient.RequestMagicLink(ctx, &ssov1.RequestMagicLinkRequest{
+		Email:       email,
+		AppId:       appID,
+		RedirectUri: redirectURI,
+	})
+	require.NoError(t, err)
+
+	link := emailLink(t, suite, email)
+	assert.Equal(t, redirectURI, fmt.Sprintf("%s://%s%s", link.Scheme, link.Host, link.Path))
+
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token)
+
+	resp, err := suite.AuthClient.ConsumeMagicLink(ctx, &ssov1.ConsumeMagicLinkRequest{Token: token, AppId: appID})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.GetRefreshToken())
+
+	assertTokenClaims(t, suite, resp.GetToken(), email, appID, userID)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: resp.GetRefreshToken()})
+	require.NoError(t, err)
+
+	// the link is single-use
+	_, err = suite.AuthClient.ConsumeMagicLink(ctx, &ssov1.ConsumeMagicLinkRequest{Token: token, AppId: appID})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+}
+
+func TestMagicLink_BoundToApp(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withMagicLink(true))
+	otherAppID, _ := createApp(t, suite, ctx, withMagicLink(true))
+	email, _, _ := registerUser(t, suite, ctx)
+
+	_, err := suite.AuthClient.RequestMagicLink(ctx, &ssov1.RequestMagicLinkRequest{
+		Email:       email,
+		AppId:       appID,
+		RedirectUri: redirectURI,
+	})
+	require.NoError(t, err)
+
+	token := emailLink(t, suite, email).Query().Get("token")
+
+	_, err = suite.AuthClient.ConsumeMagicLink(ctx, &ssov1.ConsumeMagicLinkRequest{Token: token, AppId: otherAppID})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+
+	// the rejected attempt doesn't use the link up
+	_, err = suite.AuthClient.ConsumeMagicLink(ctx, &ssov1.ConsumeMagicLinkRequest{Token: token, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestMagicLink_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withMagicLink(true))
+	disabledAppID, _ := createApp(t, suite, ctx, withMagicLink(false))
+	email, _, _ := registerUser(t, suite, ctx)
+
+	tests := []struct {
+		name        string
+		req         *ssov1.RequestMagicLinkRequest
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Empty email",
+			req:         &ssov1.RequestMagicLinkRequest{AppId: appID, RedirectUri: redirectURI},
+			expectedErr: auth.ErrEmailRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty app id",
+			req:         &ssov1.RequestMagicLinkRequest{Email: email, RedirectUri: redirectURI},
+			expectedErr: auth.ErrAppIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty redirect uri",
+			req:         &ssov1.RequestMagicLinkRequest{Email: email, AppId: appID},
+			expectedErr: auth.ErrRedirectURIRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Unregistered redirect uri",
+			req:         &ssov1.RequestMagicLinkRequest{Email: email, AppId: appID, RedirectUri: "http://localhost:3000/other"},
+			expectedErr: auth.ErrUnregisteredRedirectURI,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Disabled for the app",
+			req:         &ssov1.RequestMagicLinkRequest{Email: email, AppId: disabledAppID, RedirectUri: redirectURI},
+			expectedErr: auth.ErrMagicLinkDisabled,
+			code:        codes.FailedPrecondition,
+		},
+		{
+			name:        "Unknown app",
+			req:         &ssov1.RequestMagicLinkRequest{Email: email, AppId: gofakeit.UUID(), RedirectUri: redirectURI},
+			expectedErr: auth.ErrAppNotFound,
+			code:        codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.RequestMagicLink(ctx, tt.req)
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+
+	// unknown emails aren't revealed
+	_, err := suite.AuthClient.RequestMagicLink(ctx, &ssov1.RequestMagicLinkRequest{
+		Email:       fmt.Sprintf("test_%s", gofakeit.Email()),
+		AppId:       appID,
+		RedirectUri: redirectURI,
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ConsumeMagicLink(ctx, &ssov1.ConsumeMagicLinkRequest{Token: gofakeit.LetterN(32), AppId: appID})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+}
+
+func TestMagicLink_RateLimited(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withMagicLink(true))
+	email, _, _ := registerUser(t, suite, ctx)
+
+	req := &ssov1.RequestMagicLinkRequest{Email: email, AppId: appID, RedirectUri: redirectURI}
+
+	var err error
+	for range 10 {
+		if _, err = suite.AuthClient.RequestMagicLink(ctx, req); err != nil {
+			break
+		}
+	}
+	assertErrCode(t, err, codes.ResourceExhausted, auth.ErrRateLimited)
+}
@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"context"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/internal/lib/totp"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// recoveryCodeCount is how many recovery codes the user gets
+const recoveryCodeCount = 10
+
+func TestMFA_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, email, password, secret := enrollMFA(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, loginResp.GetToken())
+	assert.Empty(t, loginResp.GetRefreshToken())
+	require.NotEmpty(t, loginResp.GetMfaToken())
+
+	verifyResp, err := suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     totp.Code(secret, totp.Step(time.Now())),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, verifyResp.GetToken())
+	assert.NotEmpty(t, verifyResp.GetRefreshToken())
+
+	// the challenge is consumed
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     totp.Code(secret, totp.Step(time.Now())),
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFAToken)
+}
+
+func TestMFA_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, email, password, secret := enrollMFA(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{MfaToken: loginResp.GetMfaToken()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrMFACodeRequired)
+
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: gofakeit.LetterN(20),
+		Code:     totp.Code(secret, totp.Step(time.Now())),
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFAToken)
+
+	// the code used for the confirmation can't be used again
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     totp.Code(secret, totp.Step(time.Now())-1),
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFACode)
+
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     "000000",
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFACode)
+
+	_, err = suite.AuthClient.EnrollMFA(ctx, &ssov1.EnrollMFARequest{Token: gofakeit.LetterN(20)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func TestMFA_AlreadyEnabled(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, email, password, secret := enrollMFA(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	verifyResp, err := suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     totp.Code(secret, totp.Step(time.Now())),
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.EnrollMFA(ctx, &ssov1.EnrollMFARequest{Token: verifyResp.GetToken()})
+	assertErrCode(t, err, codes.FailedPrecondition, auth.ErrMFAAlreadyEnabled)
+}
+
+func TestMFA_ChallengeBoundToFlow(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, email, password, secret := enrollMFA(t, suite, ctx)
+	oauthAppID, _ := createApp(t, suite, ctx, withClientType("public"))
+	anotherOAuthAppID, _ := createApp(t, suite, ctx, withClientType("public"))
+	code := totp.Code(secret, totp.Step(time.Now()))
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, loginResp.GetMfaToken())
+
+	authorizeForm := func(clientID string) url.Values {
+		return url.Values{
+			"response_type":         {"code"},
+			"client_id":             {clientID},
+			"redirect_uri":          {redirectURI},
+			"code_challenge":        {codeChallenge(gofakeit.LetterN(64))},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	// the challenge of the login can't be passed in the browser flow
+	form := authorizeForm(oauthAppID)
+	form.Set("mfa_token", loginResp.GetMfaToken())
+	form.Set("otp", code)
+	resp := postAuthorize(t, suite, form)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	form = authorizeForm(oauthAppID)
+	form.Set("email", email)
+	form.Set("password", password)
+	resp = postAuthorize(t, suite, form)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	match := regexp.MustCompile(`name="mfa_token" value="([^"]+)"`).FindSubmatch(body)
+	require.Len(t, match, 2)
+	authorizeMFAToken := string(match[1])
+
+	// the challenge of the browser flow can't be passed for another client or in the login
+	form = authorizeForm(anotherOAuthAppID)
+	form.Set("mfa_token", authorizeMFAToken)
+	form.Set("otp", code)
+	resp = postAuthorize(t, suite, form)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: authorizeMFAToken,
+		Code:     code,
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFAToken)
+
+	// rejected challenges are not consumed
+	verifyResp, err := suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: loginResp.GetMfaToken(),
+		Code:     code,
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, verifyResp.GetToken())
+}
+
+// enrollMFA registers a user with confirmed MFA and returns the decoded TOTP secret.
+// The enrollment is confirmed with the code of the previous time step so the current one is left for the login.
+func enrollMFA(t *testing.T, suite *suite.Suite, ctx context.Context) (appID, email, password string, secret []byte) {
+	t.Helper()
+	appID, _ = createApp(t, suite, ctx)
+
+	email = fmt.Sprintf("test_%s", gofakeit.Email())
+	password = generatePassword()
+
+	_, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+
+	enrollResp, err := suite.AuthClient.EnrollMFA(ctx, &ssov1.EnrollMFARequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(enrollResp.GetOtpauthUri(), "otpauth://totp/"))
+
+	secret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollResp.GetSecret())
+	require.NoError(t, err)
+
+	confirmResp, err := suite.AuthClient.ConfirmMFA(ctx, &ssov1.ConfirmMFARequest{
+		Token: loginResp.GetToken(),
+		Code:  totp.Code(secret, totp.Step(time.Now())-1),
+	})
+	require.NoError(t, err)
+	require.Len(t, confirmResp.GetRecoveryCodes(), recoveryCodeCount)
+
+	return appID, email, password, secret
+}
+
+func TestMFA_RecoveryCodes(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, email, password, secret := enrollMFA(t, suite, ctx)
+
+	login := func() string {
+		loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appID,
+		})
+		require.NoError(t, err)
+
+		return loginResp.GetMfaToken()
+	}
+
+	mfaToken := login()
+	verifyResp, err := suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: mfaToken,
+		Code:     totp.Code(secret, totp.Step(time.Now())),
+	})
+	require.NoError(t, err)
+
+	regenerateResp, err := suite.AuthClient.RegenerateRecoveryCodes(ctx, &ssov1.RegenerateRecoveryCodesRequest{
+		Token: verifyResp.GetToken(),
+	})
+	require.NoError(t, err)
+	recoveryCodes := regenerateResp.GetRecoveryCodes()
+	require.Len(t, recoveryCodes, recoveryCodeCount)
+
+	mfaToken = login()
+	verifyResp, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: mfaToken,
+		Code:     strings.ToUpper(recoveryCodes[0]),
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, verifyResp.GetToken())
+
+	// recovery codes are single-use
+	mfaToken = login()
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: mfaToken,
+		Code:     recoveryCodes[0],
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFACode)
+
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: mfaToken,
+		Code:     recoveryCodes[1],
+	})
+	require.NoError(t, err)
+
+	// regeneration invalidates the previous codes
+	_, err = suite.AuthClient.RegenerateRecoveryCodes(ctx, &ssov1.RegenerateRecoveryCodesRequest{
+		Token: verifyResp.GetToken(),
+	})
+	require.NoError(t, err)
+
+	mfaToken = login()
+	_, err = suite.AuthClient.VerifyMFA(ctx, &ssov1.VerifyMFARequest{
+		MfaToken: mfaToken,
+		Code:     recoveryCodes[2],
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidMFACode)
+}
+
+func TestRegenerateRecoveryCodes_MFANotEnabled(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.RegenerateRecoveryCodes(ctx, &ssov1.RegenerateRecoveryCodesRequest{
+		Token: loginResp.GetToken(),
+	})
+	assertErrCode(t, err, codes.FailedPrecondition, auth.ErrMFANotEnrolled)
+}
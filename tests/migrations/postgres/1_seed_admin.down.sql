@@ -0,0 +1,3 @@
+DELETE FROM apps WHERE id = '00000000-0000-0000-0000-00000000a002';
+
+DELETE FROM users WHERE id = '00000000-0000-0000-0000-00000000a001';
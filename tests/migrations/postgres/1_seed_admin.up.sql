@@ -0,0 +1,17 @@
+-- apps are created only by admins, so the tests sign in as this admin to the seeded app
+-- the password is Sso-Tests-Admin-1
+INSERT INTO users (id, email, pass_hash)
+VALUES ('00000000-0000-0000-0000-00000000a001', 'admin@sso.tests', '$2a$10$O7Vv6x/MoNvu1vxglgVdMOoMZ6kQ3IVjHk4VYX/Ak72tzOhCHgoe6')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO organization_members (org_id, user_id, role)
+VALUES ('00000000-0000-0000-0000-000000000001', '00000000-0000-0000-0000-00000000a001', 'admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, role_id)
+VALUES ('00000000-0000-0000-0000-00000000a001', '00000000-0000-0000-0000-000000000001')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO apps (id, name, secret)
+VALUES ('00000000-0000-0000-0000-00000000a002', 'sso tests admin', 'sso-tests-admin-secret')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	redirectURI           = "http://localhost:3000/callback"
+	postLogoutRedirectURI = "http://localhost:3000/signed-out"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+}
+
+func TestOAuthAuthorizationCode_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, userID := registerUser(t, suite, ctx)
+
+	verifier := gofakeit.LetterN(64)
+	code := authorize(t, suite, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {appID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {"xyz"},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {email},
+		"password":              {password},
+	})
+
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+
+	status, tokens := exchangeCode(t, suite, tokenForm)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, int64(suite.Cfg.TokenTTL.Seconds()), tokens.ExpiresIn)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assertTokenClaims(t, suite, tokens.AccessToken, email, appID, userID)
+
+	// codes are single use
+	status, tokens = exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", tokens.Error)
+}
+
+func TestOAuthAuthorizationCode_PKCE(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, _ := registerUser(t, suite, ctx)
+
+	// public clients must send a code challenge
+	resp := postAuthorize(t, suite, url.Values{
+		"response_type": {"code"},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"email":         {email},
+		"password":      {password},
+	})
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "invalid_request", location.Query().Get("error"))
+
+	verifier := gofakeit.LetterN(64)
+	code := authorize(t, suite, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {appID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {email},
+		"password":              {password},
+	})
+
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {gofakeit.LetterN(64)},
+	}
+
+	status, tokens := exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", tokens.Error)
+
+	// the code is not consumed by the exchange without the verifier
+	tokenForm.Set("code_verifier", verifier)
+	status, tokens = exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestOAuthAuthorizationCode_ReuseRevokesTokens(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, _ := registerUser(t, suite, ctx)
+
+	verifier := gofakeit.LetterN(64)
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {authorizeWithPKCE(t, suite, appID, email, password, verifier)},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+
+	status, tokens := exchangeCode(t, suite, tokenForm)
+	require.Equal(t, http.StatusOK, status)
+
+	status, reused := exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", reused.Error)
+
+	// the tokens issued from the code are revoked
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: tokens.AccessToken, AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.False(t, resp.GetActive())
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
+
+func TestOAuthAuthorizationCode_AnotherClient(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	anotherAppID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, _ := registerUser(t, suite, ctx)
+
+	verifier := gofakeit.LetterN(64)
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {authorizeWithPKCE(t, suite, appID, email, password, verifier)},
+		"client_id":     {anotherAppID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+
+	status, tokens := exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "invalid_grant", tokens.Error)
+
+	// the code is still redeemable by the client it was granted to
+	tokenForm.Set("client_id", appID)
+	status, tokens = exchangeCode(t, suite, tokenForm)
+	require.Equal(t, http.StatusOK, status)
+
+	// another client presenting the redeemed code doesn't revoke its tokens
+	tokenForm.Set("client_id", anotherAppID)
+	status, _ = exchangeCode(t, suite, tokenForm)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: tokens.AccessToken, AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.True(t, resp.GetActive())
+}
+
+func TestOAuthAuthorize_UnregisteredRedirectURI(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("confidential"))
+
+	resp := postAuthorize(t, suite, url.Values{
+		"response_type": {"code"},
+		"client_id":     {appID},
+		"redirect_uri":  {"http://evil.example.com/callback"},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Location"))
+}
+
+func registerUser(t *testing.T, suite *suite.Suite, ctx context.Context) (email string, password string, userID string) {
+	t.Helper()
+	email = fmt.Sprintf("test_%s", gofakeit.Email())
+	password = generatePassword()
+
+	resp, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	return email, password, resp.GetUserId()
+}
+
+// authorize submits the login form and returns the code from the redirect
+func authorize(t *testing.T, suite *suite.Suite, form url.Values) string {
+	t.Helper()
+
+	resp := postAuthorize(t, suite, form)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, form.Get("state"), location.Query().Get("state"))
+	require.NotEmpty(t, location.Query().Get("code"))
+
+	return location.Query().Get("code")
+}
+
+func postAuthorize(t *testing.T, suite *suite.Suite, form url.Values) *http.Response {
+	t.Helper()
+	return postFormNoRedirect(t, suite, "/authorize", form)
+}
+
+// postFormNoRedirect posts the form without following the redirect
+func postFormNoRedirect(t *testing.T, suite *suite.Suite, path string, form url.Values) *http.Response {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Post(suite.HTTPURL(path), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func exchangeCode(t *testing.T, suite *suite.Suite, form url.Values) (int, tokenResponse) {
+	t.Helper()
+
+	resp, err := suite.HTTPClient.PostForm(suite.HTTPURL("/token"), form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var tokens tokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokens))
+
+	return resp.StatusCode, tokens
+}
+
+// authorizeWithPKCE logs the user in to the public app and returns the code bound to the verifier
+func authorizeWithPKCE(t *testing.T, suite *suite.Suite, appID, email, password, verifier string) string {
+	t.Helper()
+
+	return authorize(t, suite, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {appID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {email},
+		"password":              {password},
+	})
+}
+
+func codeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestOIDC_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withClientType("public"))
+	email, password, userID := registerUser(t, suite, ctx)
+
+	verifier := gofakeit.LetterN(64)
+	nonce := gofakeit.LetterN(16)
+	code := authorize(t, suite, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {appID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email"},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"email":                 {email},
+		"password":              {password},
+	})
+
+	status, tokens := exchangeCode(t, suite, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {appID},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "openid email", tokens.Scope)
+	require.NotEmpty(t, tokens.IDToken)
+
+	idToken, err := jwt.Parse(tokens.IDToken, suite.KeyFunc)
+	require.NoError(t, err)
+
+	claims := idToken.Claims.(jwt.MapClaims)
+	assert.Equal(t, suite.Cfg.JWT.Issuer, claims["iss"])
+	assert.Equal(t, userID, claims["sub"])
+	assert.Equal(t, appID, claims["aud"])
+	assert.Equal(t, nonce, claims["nonce"])
+	assert.Equal(t, email, claims["email"])
+	assert.Equal(t, []interface{}{"pwd"}, claims["amr"])
+	assert.NotEmpty(t, claims["acr"])
+	assert.NotEmpty(t, claims["auth_time"])
+	assert.NotEmpty(t, claims["sid"])
+
+	req, err := http.NewRequest(http.MethodGet, suite.HTTPURL("/userinfo"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	resp, err := suite.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var userInfo map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&userInfo))
+	assert.Equal(t, userID, userInfo["sub"])
+	assert.Equal(t, email, userInfo["email"])
+
+	endSession := postFormNoRedirect(t, suite, "/end_session", url.Values{
+		"id_token_hint":            {tokens.IDToken},
+		"post_logout_redirect_uri": {postLogoutRedirectURI},
+		"state":                    {"abc"},
+	})
+	require.Equal(t, http.StatusFound, endSession.StatusCode)
+	assert.Equal(t, postLogoutRedirectURI+"?state=abc", endSession.Header.Get("Location"))
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: tokens.RefreshToken})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	// access tokens of the ended session are revoked too
+	req, err = http.NewRequest(http.MethodGet, suite.HTTPURL("/userinfo"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+	resp, err = suite.HTTPClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestOIDC_Discovery(t *testing.T) {
+	_, suite := suite.New(t)
+
+	resp, err := suite.HTTPClient.Get(suite.HTTPURL("/.well-known/openid-configuration"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var config map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&config))
+	assert.Equal(t, suite.Cfg.JWT.Issuer, config["issuer"])
+	assert.Equal(t, suite.Cfg.JWT.Issuer+"/authorize", config["authorization_endpoint"])
+	assert.Equal(t, suite.Cfg.JWT.Issuer+"/token", config["token_endpoint"])
+	assert.Equal(t, suite.Cfg.JWT.Issuer+"/userinfo", config["userinfo_endpoint"])
+	assert.Equal(t, suite.Cfg.JWT.Issuer+"/.well-known/jwks.json", config["jwks_uri"])
+	assert.Contains(t, config["scopes_supported"], "openid")
+}
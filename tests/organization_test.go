@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultOrgID is the ID of the organization seeded by the migrations, users and apps created without one join it
+const defaultOrgID = "00000000-0000-0000-0000-000000000001"
+
+// the admin of the default organization and the app it signs in to are seeded by tests/migrations/postgres
+const (
+	adminEmail    = "admin@sso.tests"
+	adminPassword = "Sso-Tests-Admin-1"
+	adminAppID    = "00000000-0000-0000-0000-00000000a002"
+	// adminAppSecret lets the tests introspect tokens as a confidential app
+	adminAppSecret = "sso-tests-admin-secret"
+)
+
+func TestOrganizations_DefaultOrganization(t *testing.T) {
+	ctx, suite := suite.New(t)
+	name := fmt.Sprintf("test_%s", gofakeit.LetterN(10))
+	email, password, userID := registerUser(t, suite, ctx)
+
+	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{Name: name, Token: adminToken(t, suite, ctx)})
+	require.NoError(t, err)
+	appID := createAppResp.GetAppId()
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	claims := assertTokenClaims(t, suite, loginResp.GetToken(), email, appID, userID)
+	assert.Equal(t, defaultOrgID, claims["org_id"])
+
+	introspectResp, err := suite.IntrospectionClient.Introspect(ctx, &ssov1.IntrospectRequest{Token: loginResp.GetToken(), AppId: adminAppID, Secret: adminAppSecret})
+	require.NoError(t, err)
+	assert.Equal(t, defaultOrgID, introspectResp.GetOrgId())
+
+	appResp, err := suite.AuthClient.App(ctx, &ssov1.AppRequest{Name: name, OrgId: defaultOrgID})
+	require.NoError(t, err)
+	assert.Equal(t, appID, appResp.GetAppId())
+	assert.Equal(t, defaultOrgID, appResp.GetOrgId())
+
+	// apps of unknown organizations aren't found by name
+	_, err = suite.AuthClient.App(ctx, &ssov1.AppRequest{Name: name, OrgId: gofakeit.UUID()})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAppNotFound)
+}
+
+func TestOrganizations_RegularMember(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	_, _, otherUserID := registerUser(t, suite, ctx)
+	token := loginResp.GetToken()
+
+	_, err := suite.AuthClient.CreateOrganization(ctx, &ssov1.CreateOrganizationRequest{
+		Token: token,
+		Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+	})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrOrganizationsPermission)
+
+	_, err = suite.AuthClient.ListOrganizationMembers(ctx, &ssov1.ListOrganizationMembersRequest{Token: token})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrOrganizationsPermission)
+
+	_, err = suite.AuthClient.SetOrganizationMemberRole(ctx, &ssov1.SetOrganizationMemberRoleRequest{
+		Token:  token,
+		OrgId:  defaultOrgID,
+		UserId: otherUserID,
+		Role:   "admin",
+	})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrOrganizationsPermission)
+
+	// apps are created only with the token of an admin, the default organization included
+	for _, orgID := range []string{defaultOrgID, gofakeit.UUID()} {
+		_, err = suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+			Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+			OrgId: orgID,
+			Token: token,
+		})
+		assertErrCode(t, err, codes.PermissionDenied, auth.ErrOrganizationsPermission)
+	}
+
+	_, err = suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name: fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrTokenRequired)
+
+	_, err = suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token: gofakeit.LetterN(30),
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func TestOrganizations_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name: "Create organization without token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateOrganization(ctx, &ssov1.CreateOrganizationRequest{Name: "acme"})
+				return err
+			},
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create organization without name",
+			call: func() error {
+				_, err := suite.AuthClient.CreateOrganization(ctx, &ssov1.CreateOrganizationRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrOrgNameRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create organization with invalid token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateOrganization(ctx, &ssov1.CreateOrganizationRequest{Token: gofakeit.LetterN(20), Name: "acme"})
+				return err
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name: "List members with invalid org id",
+			call: func() error {
+				_, err := suite.AuthClient.ListOrganizationMembers(ctx, &ssov1.ListOrganizationMembersRequest{Token: token, OrgId: gofakeit.LetterN(10)})
+				return err
+			},
+			expectedErr: auth.ErrInvalidOrgID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Set member role without org id",
+			call: func() error {
+				_, err := suite.AuthClient.SetOrganizationMemberRole(ctx, &ssov1.SetOrganizationMemberRoleRequest{Token: token, UserId: gofakeit.UUID(), Role: "admin"})
+				return err
+			},
+			expectedErr: auth.ErrOrgIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Set unknown member role",
+			call: func() error {
+				_, err := suite.AuthClient.SetOrganizationMemberRole(ctx, &ssov1.SetOrganizationMemberRoleRequest{
+					Token:  token,
+					OrgId:  defaultOrgID,
+					UserId: gofakeit.UUID(),
+					Role:   "owner",
+				})
+				return err
+			},
+			expectedErr: auth.ErrInvalidOrgRole,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Find app with invalid org id",
+			call: func() error {
+				_, err := suite.AuthClient.App(ctx, &ssov1.AppRequest{Name: "app", OrgId: gofakeit.LetterN(10)})
+				return err
+			},
+			expectedErr: auth.ErrInvalidOrgID,
+			code:        codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertErrCode(t, tt.call(), tt.code, tt.expectedErr)
+		})
+	}
+}
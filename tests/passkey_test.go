@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestPasskey_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	authenticator := newVirtualAuthenticator(t, webAuthnOrigin)
+	registerPasskey(t, suite, ctx, authenticator)
+	appID, _ := createApp(t, suite, ctx)
+
+	// the passkey signs the user in without a password, each time with a grown counter
+	for range 2 {
+		loginResp := passkeyLogin(t, suite, ctx, authenticator, appID)
+		assert.NotEmpty(t, loginResp.GetToken())
+		assert.NotEmpty(t, loginResp.GetRefreshToken())
+	}
+}
+
+func TestPasskey_ClonedAuthenticator(t *testing.T) {
+	ctx, suite := suite.New(t)
+	authenticator := newVirtualAuthenticator(t, webAuthnOrigin)
+	registerPasskey(t, suite, ctx, authenticator)
+	appID, _ := createApp(t, suite, ctx)
+
+	passkeyLogin(t, suite, ctx, authenticator, appID)
+
+	// a copy of the key reports the counter the original already used
+	authenticator.signCount--
+
+	beginResp, err := suite.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+		SessionId:  beginResp.GetSessionId(),
+		Credential: authenticator.get(t, beginResp.GetOptions()),
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidPasskey)
+}
+
+func TestPasskey_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	// the origin is not the relying party one
+	phished := newVirtualAuthenticator(t, "http://sso.example.com")
+	beginResp, err := suite.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      loginResp.GetToken(),
+		SessionId:  beginResp.GetSessionId(),
+		Credential: phished.create(t, beginResp.GetOptions()),
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidPasskey)
+
+	// the registration session is single-use
+	_, err = suite.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      loginResp.GetToken(),
+		SessionId:  beginResp.GetSessionId(),
+		Credential: newVirtualAuthenticator(t, webAuthnOrigin).create(t, beginResp.GetOptions()),
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidPasskey)
+
+	_, err = suite.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+		SessionId:  gofakeit.LetterN(20),
+		Credential: "{}",
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidPasskey)
+
+	_, err = suite.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: gofakeit.LetterN(20)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+}
+
+func registerPasskey(t *testing.T, suite *suite.Suite, ctx context.Context, authenticator *virtualAuthenticator) {
+	t.Helper()
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	beginResp, err := suite.AuthClient.BeginPasskeyRegistration(ctx, &ssov1.BeginPasskeyRegistrationRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	finishResp, err := suite.AuthClient.FinishPasskeyRegistration(ctx, &ssov1.FinishPasskeyRegistrationRequest{
+		Token:      loginResp.GetToken(),
+		SessionId:  beginResp.GetSessionId(),
+		Credential: authenticator.create(t, beginResp.GetOptions()),
+		Name:       "virtual authenticator",
+	})
+	require.NoError(t, err)
+	require.Equal(t, encode(authenticator.credentialID), finishResp.GetCredentialId())
+}
+
+func passkeyLogin(t *testing.T, suite *suite.Suite, ctx context.Context, authenticator *virtualAuthenticator, appID string) *ssov1.FinishPasskeyLoginResponse {
+	t.Helper()
+
+	beginResp, err := suite.AuthClient.BeginPasskeyLogin(ctx, &ssov1.BeginPasskeyLoginRequest{AppId: appID})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.FinishPasskeyLogin(ctx, &ssov1.FinishPasskeyLoginRequest{
+		SessionId:  beginResp.GetSessionId(),
+		Credential: authenticator.get(t, beginResp.GetOptions()),
+	})
+	require.NoError(t, err)
+
+	return loginResp
+}
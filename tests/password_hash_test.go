@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/lib/passhash"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLogin_UpgradesPasswordHash(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+
+	tests := []struct {
+		name       string
+		legacyHash func(t *testing.T, password string) []byte
+	}{
+		{
+			name: "Plain bcrypt",
+			legacyHash: func(t *testing.T, password string) []byte {
+				hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+				require.NoError(t, err)
+				require.True(t, strings.HasPrefix(string(hash), "$2a$"))
+
+				return hash
+			},
+		},
+		{
+			name: "Argon2id with outdated parameters",
+			legacyHash: func(t *testing.T, password string) []byte {
+				return hashWith(t, password, passhash.Argon2Params{Memory: 8192, Iterations: 1, Parallelism: 1})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email, password, userID := registerUser(t, suite, ctx)
+			setPasswordHash(t, suite, ctx, userID, tt.legacyHash(t, password))
+
+			loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+			require.NoError(t, err)
+			assert.NotEmpty(t, loginResp.GetToken())
+
+			upgradedHash := passwordHash(t, suite, ctx, userID)
+			assert.True(t, strings.HasPrefix(upgradedHash, currentHashPrefix(suite)), upgradedHash)
+
+			// the upgraded hash still verifies the password and is not rehashed again
+			_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+			require.NoError(t, err)
+			assert.Equal(t, upgradedHash, passwordHash(t, suite, ctx, userID))
+		})
+	}
+}
+
+// TestLogin_ConcurrentLogins checks logins racing to upgrade the password hash all succeed
+func TestLogin_ConcurrentLogins(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, userID := registerUser(t, suite, ctx)
+
+	setPasswordHash(t, suite, ctx, userID, hashWith(t, password, passhash.Argon2Params{Memory: 8192, Iterations: 1, Parallelism: 1}))
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+			assert.NoError(t, err)
+			assert.NotEmpty(t, loginResp.GetToken())
+		}()
+	}
+	wg.Wait()
+
+	upgradedHash := passwordHash(t, suite, ctx, userID)
+	assert.True(t, strings.HasPrefix(upgradedHash, currentHashPrefix(suite)), upgradedHash)
+
+	_, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	assert.NoError(t, err)
+}
+
+// hashWith hashes the password with argon2id parameters other than the server's ones
+func hashWith(t *testing.T, password string, params passhash.Argon2Params) []byte {
+	t.Helper()
+
+	hasher, err := passhash.New(passhash.Params{Algorithm: passhash.AlgorithmArgon2id, Argon2: params}, nil)
+	require.NoError(t, err)
+
+	hash, err := hasher.Hash(password)
+	require.NoError(t, err)
+
+	return hash
+}
+
+// currentHashPrefix is the algorithm and parameters part of the hashes the server makes, the tests config has no pepper
+func currentHashPrefix(suite *suite.Suite) string {
+	p := suite.Cfg.PasswordHash
+	require.Equal(suite.T, passhash.AlgorithmArgon2id, p.Algorithm)
+
+	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$", p.Argon2Memory, p.Argon2Iterations, p.Argon2Parallelism)
+}
+
+func setPasswordHash(t *testing.T, suite *suite.Suite, ctx context.Context, userID string, hash []byte) {
+	t.Helper()
+
+	_, err := suite.DB.Exec(ctx, "UPDATE users SET pass_hash=$2 WHERE id=$1", userID, hash)
+	require.NoError(t, err)
+}
+
+func passwordHash(t *testing.T, suite *suite.Suite, ctx context.Context, userID string) string {
+	t.Helper()
+
+	var hash []byte
+	require.NoError(t, suite.DB.QueryRow(ctx, "SELECT pass_hash FROM users WHERE id=$1", userID).Scan(&hash))
+
+	return string(hash)
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPasswordPolicy_Register(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{
+			name:     "Too short",
+			email:    fmt.Sprintf("test_%s", gofakeit.Email()),
+			password: "a",
+		},
+		{
+			name:     "Common",
+			email:    fmt.Sprintf("test_%s", gofakeit.Email()),
+			password: "Password123!",
+		},
+		{
+			name:     "Derived from the email",
+			email:    fmt.Sprintf("test_%s@example.com", gofakeit.LetterN(8)),
+			password: "example-2024",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: tt.email, Password: tt.password})
+			assertErrCode(t, err, codes.InvalidArgument, auth.ErrWeakPassword)
+			assertFieldViolations(t, err, "password")
+		})
+	}
+}
+
+func TestPasswordPolicy_AppOverride(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	policy := &ssov1.PasswordPolicy{MinLength: 12, RequireUpper: true, RejectCommon: true}
+	appID, _ := createApp(t, suite, ctx, withPasswordPolicy(policy))
+
+	email := fmt.Sprintf("test_%s", gofakeit.Email())
+
+	// passwords following the global policy may still break the policy of the app
+	_, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: generatePassword(), AppId: appID})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrWeakPassword)
+	assertFieldViolations(t, err, "password")
+
+	password := gofakeit.Password(true, true, true, true, false, 16)
+	_, err = suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    fmt.Sprintf("test_%s", gofakeit.Email()),
+		Password: password,
+		AppId:    gofakeit.UUID(),
+	})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAppNotFound)
+}
+
+func TestPasswordPolicy_ChangePassword(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ChangePassword(ctx, &ssov1.ChangePasswordRequest{
+		Token:           loginResp.GetToken(),
+		CurrentPassword: password,
+		NewPassword:     "qwerty",
+	})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrWeakPassword)
+	assertFieldViolations(t, err, "new_password")
+
+	// the rejected password leaves the password and the tokens unchanged
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestPasswordPolicy_ResetPassword(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx, withPasswordPolicy(&ssov1.PasswordPolicy{MinLength: 12, RequireDigit: true}))
+	email, _, _ := registerUser(t, suite, ctx)
+
+	_, err := suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email, AppId: appID})
+	require.NoError(t, err)
+
+	token := emailLink(t, suite, email).Query().Get("token")
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, Password: generatePassword()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrWeakPassword)
+	assertFieldViolations(t, err, "password")
+
+	// the link can be used again after the rejected password
+	newPassword := gofakeit.Password(true, true, true, false, false, 16) + "7"
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, Password: newPassword})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestPasswordPolicy_CreateApp(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	policy := &ssov1.PasswordPolicy{MinLength: 10, MaxLength: 40, RequireSymbol: true, RejectEmail: true}
+	name := fmt.Sprintf("test_%s", gofakeit.LetterN(10))
+	appID, _ := createApp(t, suite, ctx, withAppName(name), withPasswordPolicy(policy))
+
+	appResp, err := suite.AuthClient.App(ctx, &ssov1.AppRequest{Name: name})
+	require.NoError(t, err)
+	assert.Equal(t, appID, appResp.GetAppId())
+	assert.Equal(t, policy.GetMinLength(), appResp.GetPasswordPolicy().GetMinLength())
+	assert.Equal(t, policy.GetMaxLength(), appResp.GetPasswordPolicy().GetMaxLength())
+
+	tests := []struct {
+		name   string
+		policy *ssov1.PasswordPolicy
+	}{
+		{
+			name:   "Zero min length",
+			policy: &ssov1.PasswordPolicy{},
+		},
+		{
+			name:   "Max length less than min length",
+			policy: &ssov1.PasswordPolicy{MinLength: 12, MaxLength: 8},
+		},
+		{
+			name:   "Negative max length",
+			policy: &ssov1.PasswordPolicy{MinLength: 8, MaxLength: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+				Name:           fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+				Token:          adminToken(t, suite, ctx),
+				PasswordPolicy: tt.policy,
+			})
+			assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidPasswordPolicy)
+		})
+	}
+}
+
+// assertFieldViolations checks the status details list the broken rules of the password policy for the field
+func assertFieldViolations(t *testing.T, err error, field string) {
+	t.Helper()
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = append(violations, badRequest.GetFieldViolations()...)
+		}
+	}
+
+	require.NotEmpty(t, violations)
+	for _, violation := range violations {
+		assert.Equal(t, field, violation.GetField())
+		assert.NotEmpty(t, violation.GetDescription())
+	}
+}
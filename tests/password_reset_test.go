@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	authService "github.com/BariVakhidov/sso/internal/services/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestResetPassword_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	link := emailLink(t, suite, email)
+	assert.Equal(t, "/reset_password", link.Path)
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token)
+
+	newPassword := generatePassword()
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, Password: newPassword})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidCredentials)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+
+	// sessions started with the old password are revoked
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	_, err = suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	// reset tokens are single-use
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: token, Password: generatePassword()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+}
+
+func TestResetPassword_Form(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, _, _ := registerUser(t, suite, ctx)
+
+	_, err := suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	link := emailLink(t, suite, email)
+
+	formResp, err := suite.HTTPClient.Get(link.String())
+	require.NoError(t, err)
+	formResp.Body.Close()
+	require.Equal(t, http.StatusOK, formResp.StatusCode)
+
+	newPassword := generatePassword()
+	form := url.Values{
+		"token":    {link.Query().Get("token")},
+		"password": {newPassword},
+	}
+
+	resp, err := suite.HTTPClient.Post(suite.HTTPURL("/reset_password"), "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestResetPassword_ClearsLockout(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, _, _ := registerUser(t, suite, ctx)
+
+	for i := 0; i < authService.MaxFailedLoginAttempts; i++ {
+		_, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: generatePassword(), AppId: appID})
+		assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidCredentials)
+	}
+
+	_, err := suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+
+	newPassword := generatePassword()
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{
+		Token:    emailLink(t, suite, email).Query().Get("token"),
+		Password: newPassword,
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: newPassword, AppId: appID})
+	require.NoError(t, err)
+}
+
+func TestResetPassword_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	email, _, _ := registerUser(t, suite, ctx)
+
+	// unknown emails get the same response
+	_, err := suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{
+		Email: fmt.Sprintf("test_%s", gofakeit.Email()),
+	})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: gofakeit.LetterN(10)})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmail)
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Password: generatePassword()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrTokenRequired)
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: gofakeit.LetterN(40)})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrPasswordRequired)
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: gofakeit.LetterN(40), Password: generatePassword()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+
+	// a reset voids the other links of the user
+	_, err = suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+	firstToken := emailLink(t, suite, email).Query().Get("token")
+
+	_, err = suite.AuthClient.RequestPasswordReset(ctx, &ssov1.RequestPasswordResetRequest{Email: email})
+	require.NoError(t, err)
+	secondToken := emailLink(t, suite, email).Query().Get("token")
+	require.NotEqual(t, firstToken, secondToken)
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: secondToken, Password: generatePassword()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ResetPassword(ctx, &ssov1.ResetPasswordRequest{Token: firstToken, Password: generatePassword()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidEmailToken)
+}
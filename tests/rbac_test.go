@@ -0,0 +1,320 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+// adminRoleID is the ID of the built-in admin role seeded by the migrations
+const adminRoleID = "00000000-0000-0000-0000-000000000001"
+
+func TestRoles_BuiltInAdmin(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	// roles are listed only to users managing them
+	_, err := suite.AuthClient.ListRoles(ctx, &ssov1.ListRolesRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	listResp, err := suite.AuthClient.ListRoles(ctx, &ssov1.ListRolesRequest{Token: adminToken(t, suite, ctx)})
+	require.NoError(t, err)
+
+	var admin *ssov1.Role
+	for _, role := range listResp.GetRoles() {
+		if role.GetRoleId() == adminRoleID {
+			admin = role
+		}
+	}
+	require.NotNil(t, admin)
+	assert.Equal(t, "admin", admin.GetName())
+	assert.Empty(t, admin.GetAppId())
+	assert.Equal(t, []string{"*"}, admin.GetPermissions())
+	assert.True(t, admin.GetBuiltIn())
+}
+
+func TestRoles_RegularUser(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, userID := registerUser(t, suite, ctx)
+	_, _, otherUserID := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	token := loginResp.GetToken()
+
+	// users without roles.manage can't grant themselves anything
+	_, err = suite.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{
+		Token:       token,
+		Name:        fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		AppId:       appID,
+		Permissions: []string{"documents.read"},
+	})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	_, err = suite.AuthClient.AssignRole(ctx, &ssov1.AssignRoleRequest{Token: token, UserId: userID, RoleId: adminRoleID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	_, err = suite.AuthClient.DeleteRole(ctx, &ssov1.DeleteRoleRequest{Token: token, RoleId: adminRoleID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	_, err = suite.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: token, UserId: otherUserID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	userRolesResp, err := suite.AuthClient.ListUserRoles(ctx, &ssov1.ListUserRolesRequest{Token: token})
+	require.NoError(t, err)
+	assert.Empty(t, userRolesResp.GetRoles())
+
+	_, err = suite.AuthClient.ListRoles(ctx, &ssov1.ListRolesRequest{Token: token, AppId: appID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+
+	permissionResp, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		Token:      token,
+		UserId:     userID,
+		Permission: "roles.manage",
+		AppId:      appID,
+	})
+	require.NoError(t, err)
+	assert.False(t, permissionResp.GetHasPermission())
+
+	isAdminResp, err := suite.AuthClient.IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: userID})
+	require.NoError(t, err)
+	assert.False(t, isAdminResp.GetIsAdmin())
+}
+
+func TestRoles_OrganizationBoundary(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, secret := createApp(t, suite, ctx)
+	publicAppID, publicSecret := createApp(t, suite, ctx, withClientType("public"))
+	_, _, userID := registerUser(t, suite, ctx)
+
+	orgResp, err := suite.AuthClient.CreateOrganization(ctx, &ssov1.CreateOrganizationRequest{
+		Token: adminToken(t, suite, ctx),
+		Name:  fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+	})
+	require.NoError(t, err)
+
+	otherAppID, _ := createApp(t, suite, ctx, withOrgID(orgResp.GetOrgId()))
+	otherEmail, otherPassword := fmt.Sprintf("test_%s", gofakeit.Email()), generatePassword()
+
+	_, err = suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{Email: otherEmail, Password: otherPassword, AppId: otherAppID})
+	require.NoError(t, err)
+
+	otherLoginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: otherEmail, Password: otherPassword, AppId: otherAppID})
+	require.NoError(t, err)
+	otherToken := otherLoginResp.GetToken()
+
+	// a confidential app of the organization of the user checks its permissions
+	permissionResp, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		ClientId:     appID,
+		ClientSecret: secret,
+		UserId:       userID,
+		AppId:        appID,
+		Permission:   "roles.manage",
+	})
+	require.NoError(t, err)
+	assert.False(t, permissionResp.GetHasPermission())
+
+	_, err = suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		Token:      otherToken,
+		UserId:     userID,
+		Permission: "roles.manage",
+	})
+	assertErrCode(t, err, codes.NotFound, auth.ErrUserNotFound)
+
+	_, err = suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		ClientId:     appID,
+		ClientSecret: secret,
+		UserId:       userID,
+		AppId:        otherAppID,
+		Permission:   "roles.manage",
+	})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAppNotFound)
+
+	_, err = suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		ClientId:     publicAppID,
+		ClientSecret: publicSecret,
+		UserId:       userID,
+		Permission:   "roles.manage",
+	})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrUnauthorizedClient)
+
+	_, err = suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{
+		ClientId:     appID,
+		ClientSecret: gofakeit.LetterN(20),
+		UserId:       userID,
+		Permission:   "roles.manage",
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidClient)
+
+	// apps of other organizations are not found even for the admin
+	_, err = suite.AuthClient.ListRoles(ctx, &ssov1.ListRolesRequest{Token: adminToken(t, suite, ctx), AppId: otherAppID})
+	assertErrCode(t, err, codes.NotFound, auth.ErrAppNotFound)
+
+	_, err = suite.AuthClient.ListRoles(ctx, &ssov1.ListRolesRequest{Token: otherToken, AppId: otherAppID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrRolesPermission)
+}
+
+func TestRoles_Claims(t *testing.T) {
+	ctx, suite := suite.New(t)
+	email, password, userID := registerUser(t, suite, ctx)
+
+	createAppResp, err := suite.AuthClient.CreateApp(ctx, &ssov1.CreateAppRequest{
+		Name:   fmt.Sprintf("test_%s", gofakeit.LetterN(10)),
+		Token:  adminToken(t, suite, ctx),
+		Claims: []string{"roles", "permissions"},
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: createAppResp.GetAppId()})
+	require.NoError(t, err)
+
+	claims := assertTokenClaims(t, suite, loginResp.GetToken(), email, createAppResp.GetAppId(), userID)
+	assert.Empty(t, claims["roles"])
+	assert.Empty(t, claims["permissions"])
+	assert.NotContains(t, claims, "is_admin")
+}
+
+func TestRoles_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	token := loginResp.GetToken()
+
+	tests := []struct {
+		name        string
+		call        func() error
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name: "Create role without token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Name: "editor"})
+				return err
+			},
+			expectedErr: auth.ErrTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create role without name",
+			call: func() error {
+				_, err := suite.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrRoleNameRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create role with whitespace in permission",
+			call: func() error {
+				_, err := suite.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: token, Name: "editor", Permissions: []string{"documents read"}})
+				return err
+			},
+			expectedErr: auth.ErrInvalidPermission,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Create role with invalid token",
+			call: func() error {
+				_, err := suite.AuthClient.CreateRole(ctx, &ssov1.CreateRoleRequest{Token: gofakeit.LetterN(20), Name: "editor"})
+				return err
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name: "Delete role without role id",
+			call: func() error {
+				_, err := suite.AuthClient.DeleteRole(ctx, &ssov1.DeleteRoleRequest{Token: token})
+				return err
+			},
+			expectedErr: auth.ErrRoleIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Delete unknown role",
+			call: func() error {
+				_, err := suite.AuthClient.DeleteRole(ctx, &ssov1.DeleteRoleRequest{Token: token, RoleId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrRoleNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Assign role without user id",
+			call: func() error {
+				_, err := suite.AuthClient.AssignRole(ctx, &ssov1.AssignRoleRequest{Token: token, RoleId: adminRoleID})
+				return err
+			},
+			expectedErr: auth.ErrUserIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Assign unknown role",
+			call: func() error {
+				_, err := suite.AuthClient.AssignRole(ctx, &ssov1.AssignRoleRequest{Token: token, UserId: gofakeit.UUID(), RoleId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrRoleNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Unassign role with invalid user id",
+			call: func() error {
+				_, err := suite.AuthClient.UnassignRole(ctx, &ssov1.UnassignRoleRequest{Token: token, UserId: gofakeit.LetterN(10), RoleId: adminRoleID})
+				return err
+			},
+			expectedErr: auth.ErrInvalidUserID,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Has permission without permission",
+			call: func() error {
+				_, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{UserId: gofakeit.UUID()})
+				return err
+			},
+			expectedErr: auth.ErrPermissionRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Has permission of unknown user",
+			call: func() error {
+				_, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{Token: token, UserId: gofakeit.UUID(), Permission: "roles.manage"})
+				return err
+			},
+			expectedErr: auth.ErrUserNotFound,
+			code:        codes.NotFound,
+		},
+		{
+			name: "Has permission without caller",
+			call: func() error {
+				_, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{UserId: gofakeit.UUID(), Permission: "roles.manage"})
+				return err
+			},
+			expectedErr: auth.ErrCallerRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name: "Has permission with invalid token",
+			call: func() error {
+				_, err := suite.AuthClient.HasPermission(ctx, &ssov1.HasPermissionRequest{Token: gofakeit.LetterN(20), UserId: gofakeit.UUID(), Permission: "roles.manage"})
+				return err
+			},
+			expectedErr: auth.ErrInvalidToken,
+			code:        codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertErrCode(t, tt.call(), tt.code, tt.expectedErr)
+		})
+	}
+}
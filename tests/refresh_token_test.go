@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRefresh_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+
+	var (
+		email    = fmt.Sprintf("test_%s", gofakeit.Email())
+		password = generatePassword()
+	)
+
+	registerResp, err := suite.AuthClient.Register(ctx, &ssov1.RegisterRequest{
+		Email:    email,
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{
+		Email:    email,
+		Password: password,
+		AppId:    appID,
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, loginResp.GetRefreshToken())
+
+	refreshResp, err := suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	require.NoError(t, err)
+	assert.NotEmpty(t, refreshResp.GetToken())
+	assert.NotEqual(t, loginResp.GetRefreshToken(), refreshResp.GetRefreshToken())
+
+	assertTokenClaims(t, suite, refreshResp.GetToken(), email, appID, registerResp.GetUserId())
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	refreshResp, err := suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	require.NoError(t, err)
+
+	// the rotated token is presented again
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: loginResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	// the whole family is revoked, including the legitimate successor
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: refreshResp.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
+
+func TestRefresh_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+
+	_, err := suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: ""})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrRefreshTokenRequired)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: gofakeit.LetterN(43)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+}
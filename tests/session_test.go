@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSessions_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	email, password, userID := registerUser(t, suite, ctx)
+
+	loginReq := &ssov1.LoginRequest{Email: email, Password: password, AppId: appID}
+	first, err := suite.AuthClient.Login(ctx, loginReq)
+	require.NoError(t, err)
+	second, err := suite.AuthClient.Login(ctx, loginReq)
+	require.NoError(t, err)
+
+	firstClaims := assertTokenClaims(t, suite, first.GetToken(), email, appID, userID)
+	secondClaims := assertTokenClaims(t, suite, second.GetToken(), email, appID, userID)
+	require.NotEmpty(t, firstClaims["sid"])
+	require.NotEqual(t, firstClaims["sid"], secondClaims["sid"])
+
+	listResp, err := suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: first.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, listResp.GetSessions(), 2)
+
+	for _, session := range listResp.GetSessions() {
+		assert.Equal(t, appID, session.GetAppId())
+		assert.NotEmpty(t, session.GetUserAgent())
+		assert.NotEmpty(t, session.GetIpAddress())
+		assert.NotZero(t, session.GetCreatedAt())
+		assert.GreaterOrEqual(t, session.GetLastSeenAt(), session.GetCreatedAt())
+		assert.Equal(t, firstClaims["sid"] == session.GetSessionId(), session.GetCurrent())
+	}
+
+	_, err = suite.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{
+		Token:     first.GetToken(),
+		SessionId: secondClaims["sid"].(string),
+	})
+	require.NoError(t, err)
+
+	// both tokens of the revoked session stop working
+	_, err = suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: second.GetToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: second.GetRefreshToken()})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidRefreshToken)
+
+	listResp, err = suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: first.GetToken()})
+	require.NoError(t, err)
+	require.Len(t, listResp.GetSessions(), 1)
+	assert.Equal(t, firstClaims["sid"], listResp.GetSessions()[0].GetSessionId())
+	assert.True(t, listResp.GetSessions()[0].GetCurrent())
+
+	// refreshing keeps the session
+	refreshResp, err := suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: first.GetRefreshToken()})
+	require.NoError(t, err)
+
+	refreshedClaims := assertTokenClaims(t, suite, refreshResp.GetToken(), email, appID, userID)
+	assert.Equal(t, firstClaims["sid"], refreshedClaims["sid"])
+}
+
+func TestSessions_OtherUser(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+
+	email, password, userID := registerUser(t, suite, ctx)
+	ownerResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	ownerClaims := assertTokenClaims(t, suite, ownerResp.GetToken(), email, appID, userID)
+
+	otherResp := registerAndLogin(t, suite, ctx)
+
+	_, err = suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: otherResp.GetToken(), UserId: userID})
+	assertErrCode(t, err, codes.PermissionDenied, auth.ErrSessionsPermission)
+
+	// sessions of other users look like unknown ones
+	_, err = suite.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{
+		Token:     otherResp.GetToken(),
+		SessionId: ownerClaims["sid"].(string),
+	})
+	assertErrCode(t, err, codes.NotFound, auth.ErrSessionNotFound)
+
+	_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: ownerResp.GetRefreshToken()})
+	require.NoError(t, err)
+}
+
+func TestSessions_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+
+	_, err := suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrTokenRequired)
+
+	_, err = suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: gofakeit.LetterN(20)})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidToken)
+
+	_, err = suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: loginResp.GetToken(), UserId: gofakeit.LetterN(10)})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrInvalidUserID)
+
+	_, err = suite.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{Token: loginResp.GetToken()})
+	assertErrCode(t, err, codes.InvalidArgument, auth.ErrSessionIDRequired)
+
+	_, err = suite.AuthClient.RevokeSession(ctx, &ssov1.RevokeSessionRequest{Token: loginResp.GetToken(), SessionId: gofakeit.UUID()})
+	assertErrCode(t, err, codes.NotFound, auth.ErrSessionNotFound)
+}
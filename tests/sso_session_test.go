@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/BariVakhidov/sso/internal/grpc/auth"
+	"github.com/BariVakhidov/sso/tests/suite"
+	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSSOSession_HappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	otherAppID, _ := createApp(t, suite, ctx)
+	email, password, userID := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+	require.NotEmpty(t, loginResp.GetSsoSessionToken())
+
+	// the session signs the user in to other apps without the password
+	for range 2 {
+		resp, err := suite.AuthClient.ObtainAppToken(ctx, &ssov1.ObtainAppTokenRequest{
+			SsoSessionToken: loginResp.GetSsoSessionToken(),
+			AppId:           otherAppID,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp.GetRefreshToken())
+
+		assertTokenClaims(t, suite, resp.GetToken(), email, otherAppID, userID)
+
+		_, err = suite.AuthClient.Refresh(ctx, &ssov1.RefreshRequest{RefreshToken: resp.GetRefreshToken()})
+		require.NoError(t, err)
+	}
+
+	// the token of the other app starts its own session
+	listResp, err := suite.AuthClient.ListSessions(ctx, &ssov1.ListSessionsRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+	assert.Len(t, listResp.GetSessions(), 3)
+}
+
+func TestSSOSession_LogoutAll(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	otherAppID, _ := createApp(t, suite, ctx)
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.LogoutAll(ctx, &ssov1.LogoutAllRequest{Token: loginResp.GetToken()})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ObtainAppToken(ctx, &ssov1.ObtainAppTokenRequest{
+		SsoSessionToken: loginResp.GetSsoSessionToken(),
+		AppId:           otherAppID,
+	})
+	assertErrCode(t, err, codes.Unauthenticated, auth.ErrInvalidSSOSession)
+
+	// a new login starts a new session
+	loginResp, err = suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	_, err = suite.AuthClient.ObtainAppToken(ctx, &ssov1.ObtainAppTokenRequest{
+		SsoSessionToken: loginResp.GetSsoSessionToken(),
+		AppId:           otherAppID,
+	})
+	require.NoError(t, err)
+}
+
+func TestSSOSession_VerifiedEmailApp(t *testing.T) {
+	ctx, suite := suite.New(t)
+	appID, _ := createApp(t, suite, ctx)
+	verifiedEmailAppID, _ := createApp(t, suite, ctx, withRequiredVerifiedEmail())
+	email, password, _ := registerUser(t, suite, ctx)
+
+	loginResp, err := suite.AuthClient.Login(ctx, &ssov1.LoginRequest{Email: email, Password: password, AppId: appID})
+	require.NoError(t, err)
+
+	// the session doesn't bypass the requirements of the app
+	_, err = suite.AuthClient.ObtainAppToken(ctx, &ssov1.ObtainAppTokenRequest{
+		SsoSessionToken: loginResp.GetSsoSessionToken(),
+		AppId:           verifiedEmailAppID,
+	})
+	assertErrCode(t, err, codes.FailedPrecondition, auth.ErrEmailNotVerified)
+}
+
+func TestSSOSession_UnHappyPath(t *testing.T) {
+	ctx, suite := suite.New(t)
+	loginResp := registerAndLogin(t, suite, ctx)
+	appID, _ := createApp(t, suite, ctx)
+
+	tests := []struct {
+		name        string
+		req         *ssov1.ObtainAppTokenRequest
+		expectedErr string
+		code        codes.Code
+	}{
+		{
+			name:        "Empty session token",
+			req:         &ssov1.ObtainAppTokenRequest{AppId: appID},
+			expectedErr: auth.ErrSSOSessionTokenRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Empty app id",
+			req:         &ssov1.ObtainAppTokenRequest{SsoSessionToken: loginResp.GetSsoSessionToken()},
+			expectedErr: auth.ErrAppIDRequired,
+			code:        codes.InvalidArgument,
+		},
+		{
+			name:        "Unknown session",
+			req:         &ssov1.ObtainAppTokenRequest{SsoSessionToken: gofakeit.LetterN(43), AppId: appID},
+			expectedErr: auth.ErrInvalidSSOSession,
+			code:        codes.Unauthenticated,
+		},
+		{
+			name:        "Unknown app",
+			req:         &ssov1.ObtainAppTokenRequest{SsoSessionToken: loginResp.GetSsoSessionToken(), AppId: gofakeit.UUID()},
+			expectedErr: auth.ErrAppNotFound,
+			code:        codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := suite.AuthClient.ObtainAppToken(ctx, tt.req)
+			assertErrCode(t, err, tt.code, tt.expectedErr)
+		})
+	}
+}
@@ -2,12 +2,19 @@ package suite
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
 	"strconv"
 	"testing"
 
 	"github.com/BariVakhidov/sso/internal/config"
+	"github.com/BariVakhidov/sso/internal/lib/jwk"
 	ssov1 "github.com/BariVakhidov/ssoprotos/gen/go/sso"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
@@ -18,8 +25,13 @@ const (
 
 type Suite struct {
 	*testing.T
-	Cfg        *config.Config
-	AuthClient ssov1.AuthClient
+	Cfg                 *config.Config
+	AuthClient          ssov1.AuthClient
+	IntrospectionClient ssov1.IntrospectionClient
+	AuthorizationClient ssov1.AuthorizationClient
+	HTTPClient          *http.Client
+	// DB is the database of the server, for the state the API doesn't expose
+	DB *pgxpool.Pool
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
@@ -29,21 +41,83 @@ func New(t *testing.T) (context.Context, *Suite) {
 	cfg := config.MustLoadByPath("../config/local_tests.yml")
 	ctx, cancelCtx := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
 
+	cc, err := grpc.NewClient(grpcAddress(cfg), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc server connection failed: %v", err)
+	}
+
+	db, err := pgxpool.New(ctx, fmt.Sprintf("postgres://postgres:password@%s/sso", cfg.Addr.Db))
+	if err != nil {
+		t.Fatalf("db connection failed: %v", err)
+	}
+
 	t.Cleanup(func() {
 		t.Helper()
+		db.Close()
 		cancelCtx()
 	})
 
-	cc, err := grpc.NewClient(grpcAddress(cfg), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return ctx, &Suite{
+		T:                   t,
+		Cfg:                 cfg,
+		AuthClient:          ssov1.NewAuthClient(cc),
+		IntrospectionClient: ssov1.NewIntrospectionClient(cc),
+		AuthorizationClient: ssov1.NewAuthorizationClient(cc),
+		HTTPClient:          &http.Client{},
+		DB:                  db,
+	}
+}
+
+// HTTPURL returns URL of the path on the HTTP server
+func (s *Suite) HTTPURL(path string) string {
+	return fmt.Sprintf("http://%s%s", net.JoinHostPort(grpcHost, strconv.Itoa(s.Cfg.HTTP.Port)), path)
+}
+
+// KeyFunc returns the published key with the kid of the token, as any client of the service verifies tokens
+func (s *Suite) KeyFunc(token *jwt.Token) (interface{}, error) {
+	keyID, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("kid header is missing")
+	}
+
+	set, err := s.JWKS()
 	if err != nil {
-		t.Fatalf("grpc server connection failed: %v", err)
+		return nil, err
 	}
 
-	return ctx, &Suite{
-		T:          t,
-		Cfg:        cfg,
-		AuthClient: ssov1.NewAuthClient(cc),
+	for _, key := range set.Keys {
+		if key.Kid != keyID {
+			continue
+		}
+
+		if key.Alg != token.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, not %s", keyID, key.Alg, token.Method.Alg())
+		}
+
+		return key.PublicKey()
 	}
+
+	return nil, fmt.Errorf("key %s is not published", keyID)
+}
+
+// JWKS returns the keys published at /.well-known/jwks.json
+func (s *Suite) JWKS() (jwk.Set, error) {
+	resp, err := s.HTTPClient.Get(s.HTTPURL("/.well-known/jwks.json"))
+	if err != nil {
+		return jwk.Set{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jwk.Set{}, fmt.Errorf("unexpected jwks status %d", resp.StatusCode)
+	}
+
+	var set jwk.Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return jwk.Set{}, err
+	}
+
+	return set, nil
 }
 
 func grpcAddress(cfg *config.Config) string {